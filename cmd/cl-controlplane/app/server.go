@@ -17,6 +17,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/bombsimon/logrusr/v4"
 	"github.com/sirupsen/logrus"
@@ -29,6 +30,8 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 
 	"github.com/clusterlink-net/clusterlink/pkg/apis/clusterlink.net/v1alpha1"
 	"github.com/clusterlink-net/clusterlink/pkg/controlplane/api"
@@ -36,8 +39,10 @@ import (
 	"github.com/clusterlink-net/clusterlink/pkg/controlplane/control"
 	cprest "github.com/clusterlink-net/clusterlink/pkg/controlplane/rest"
 	"github.com/clusterlink-net/clusterlink/pkg/controlplane/xds"
+	"github.com/clusterlink-net/clusterlink/pkg/metrics"
 	"github.com/clusterlink-net/clusterlink/pkg/store/kv"
 	"github.com/clusterlink-net/clusterlink/pkg/store/kv/bolt"
+	"github.com/clusterlink-net/clusterlink/pkg/util/audit"
 	"github.com/clusterlink-net/clusterlink/pkg/util/controller"
 	"github.com/clusterlink-net/clusterlink/pkg/util/grpc"
 	"github.com/clusterlink-net/clusterlink/pkg/util/log"
@@ -51,6 +56,10 @@ import (
 const (
 	// logLevel is the default log level.
 	logLevel = "warn"
+	// auditLevel is the default audit level.
+	auditLevel = "none"
+	// metricsAddress is the default address of the Prometheus metrics endpoint.
+	metricsAddress = ":8080"
 
 	// StoreFile is the path to the file holding the persisted state.
 	StoreFile = "/var/lib/clink/controlplane.db"
@@ -61,6 +70,9 @@ const (
 	CertificateFile = "/etc/ssl/certs/clink-controlplane.pem"
 	// KeyFile is the path to the private-key file.
 	KeyFile = "/etc/ssl/private/clink-controlplane.pem"
+	// DataplaneCertificateDirectory is the path to the directory holding the dataplane certificate,
+	// private-key and CA files, served to the dataplanes.
+	DataplaneCertificateDirectory = "/etc/ssl/dataplane"
 
 	// httpServerAddress is the address of the localhost HTTP server.
 	httpServerAddress = "127.0.0.1:1100"
@@ -83,6 +95,24 @@ type Options struct {
 	// CRDMode indicates a k8s CRD-based controlplane.
 	// This flag will be removed once the CRD-based controlplane feature is complete and stable.
 	CRDMode bool
+	// AuditFile is the path to file where audit records will be written.
+	AuditFile string
+	// AuditLevel is the audit level.
+	AuditLevel string
+	// AuditSigningKey is the path to the private key signing the audit records.
+	AuditSigningKey string
+	// WorkloadMTLS indicates that egress clients authenticate using SPIFFE SVIDs.
+	WorkloadMTLS bool
+	// MetricsAddress is the address of the Prometheus metrics endpoint.
+	MetricsAddress string
+	// EndpointDiscovery indicates that exported services are routed directly to their endpoints.
+	EndpointDiscovery bool
+	// AccessLog is the access log mode of dataplane import listeners.
+	AccessLog string
+	// MaxAccessTokenLifetime is the maximal lifetime of access tokens requested by remote peers.
+	MaxAccessTokenLifetime time.Duration
+	// DataplaneType is the type of the dataplanes (envoy or go).
+	DataplaneType string
 }
 
 // AddFlags adds flags to fs and binds them to options.
@@ -92,6 +122,29 @@ func (o *Options) AddFlags(fs *pflag.FlagSet) {
 	fs.StringVar(&o.LogLevel, "log-level", logLevel,
 		"The log level. One of fatal, error, warn, info, debug.")
 	fs.BoolVar(&o.CRDMode, "crd-mode", false, "Run a CRD-based controlplane.")
+	fs.StringVar(&o.AuditFile, "audit-file", "",
+		"Path to a file where audit records will be written. If not specified, audit records will be printed to stdout.")
+	fs.StringVar(&o.AuditLevel, "audit-level", auditLevel,
+		"The audit level. One of none, management, authorization, all.")
+	fs.StringVar(&o.AuditSigningKey, "audit-signing-key", "",
+		"Path to a PEM-encoded Ed25519 private key signing the audit records. Required if auditing is enabled.")
+	fs.BoolVar(&o.WorkloadMTLS, "workload-mtls", false,
+		"Require clients of imported services to authenticate using SPIFFE SVIDs, "+
+			"and identify them by their SPIFFE ID rather than their IP address.")
+	fs.StringVar(&o.MetricsAddress, "metrics-address", metricsAddress,
+		"The address the Prometheus metrics endpoint ("+metrics.Path+") binds to. Set to \"0\" to disable.")
+	fs.BoolVar(&o.EndpointDiscovery, "endpoint-discovery", false,
+		"Route exported services directly to the endpoints of their Kubernetes service, "+
+			"rather than through the service address. Requires --crd-mode.")
+	fs.StringVar(&o.AccessLog, "access-log", string(api.AccessLogNone),
+		"The access log mode of dataplane import listeners. One of none, json (dataplane stdout), "+
+			"grpc (streamed to the controlplane, which prints them to stdout).")
+	fs.DurationVar(&o.MaxAccessTokenLifetime, "max-access-token-lifetime", authz.DefaultMaxTokenLifetime,
+		"The maximal lifetime of access tokens requested by remote peers, "+
+			"bounding the time their dataplanes may cache authorization results.")
+	fs.StringVar(&o.DataplaneType, "dataplane", string(v1alpha1.DataplaneTypeEnvoy),
+		"The type of the dataplanes. One of envoy, go. "+
+			"Imports limiting the concurrent connections of each source IP are only supported by the go dataplane.")
 }
 
 // Run the various controlplane servers.
@@ -112,6 +165,44 @@ func (o *Options) Run() error {
 
 	logrus.Infof("Starting cl-controlplane (version: %s)", versioninfo.Short())
 
+	level, err := audit.ParseLevel(o.AuditLevel)
+	if err != nil {
+		return err
+	}
+
+	accessLogMode, err := api.ParseAccessLogMode(o.AccessLog)
+	if err != nil {
+		return err
+	}
+
+	dataplaneType := v1alpha1.DataplaneType(o.DataplaneType)
+	if dataplaneType != v1alpha1.DataplaneTypeEnvoy && dataplaneType != v1alpha1.DataplaneTypeGo {
+		return fmt.Errorf("unknown dataplane type: %s", o.DataplaneType)
+	}
+
+	var auditLogger *audit.Logger
+	if level != audit.LevelNone {
+		if o.AuditSigningKey == "" {
+			return fmt.Errorf("auditing requires an audit signing key")
+		}
+
+		signingKey, err := audit.ReadSigningKey(o.AuditSigningKey)
+		if err != nil {
+			return err
+		}
+
+		auditLogger, err = audit.Open(level, o.AuditFile, signingKey)
+		if err != nil {
+			return err
+		}
+
+		defer func() {
+			if err := auditLogger.Close(); err != nil {
+				logrus.Errorf("Cannot close audit file: %v", err)
+			}
+		}()
+	}
+
 	namespace := os.Getenv(NamespaceEnvVariable)
 	if namespace == "" {
 		namespace = SystemNamespace
@@ -162,6 +253,9 @@ func (o *Options) Run() error {
 		Cache: cache.Options{
 			ByObject: make(map[client.Object]cache.ByObject),
 		},
+		Metrics: metricsserver.Options{
+			BindAddress: o.MetricsAddress,
+		},
 		Scheme: scheme,
 	}
 
@@ -186,13 +280,26 @@ func (o *Options) Run() error {
 		grpcServerName: grpcServerAddress,
 	})
 
+	// controlplane metrics are served along with the controller-runtime metrics
+	controlplaneMetrics, err := metrics.NewControlplaneMetrics(ctrlmetrics.Registry)
+	if err != nil {
+		return fmt.Errorf("cannot register metrics: %w", err)
+	}
+
 	httpServer := utilrest.NewServer("controlplane-http", parsedCertData.ServerConfig())
+	httpServer.SetAuditLogger(auditLogger)
+	// management requests (and xDS dumps, served in CRD mode as well) are authorized by the user roles of gwctl
+	httpServer.SetAuthorizer(cprest.NewAuthorizer(namespace, parsedCertData))
 	grpcServer := grpc.NewServer("controlplane-grpc", parsedCertData.ServerConfig())
 
 	authzManager, err := authz.NewManager(parsedCertData, mgr.GetClient(), namespace)
 	if err != nil {
 		return fmt.Errorf("cannot create authorization manager: %w", err)
 	}
+	authzManager.SetAuditLogger(auditLogger)
+	authzManager.SetWorkloadMTLS(o.WorkloadMTLS)
+	authzManager.SetMetrics(controlplaneMetrics)
+	authzManager.SetMaxTokenLifetime(o.MaxAccessTokenLifetime)
 
 	err = authz.CreateControllers(authzManager, mgr, o.CRDMode)
 	if err != nil {
@@ -200,8 +307,11 @@ func (o *Options) Run() error {
 	}
 
 	authz.RegisterHandlers(authzManager, &httpServer.Server)
+	metrics.RegisterHandlers(metrics.NewMetrics(parsedCertData), &httpServer.Server)
 
 	controlManager := control.NewManager(mgr.GetClient(), parsedCertData, namespace, o.CRDMode)
+	controlManager.SetMetrics(controlplaneMetrics)
+	controlManager.SetDataplaneType(dataplaneType)
 
 	err = control.CreateControllers(controlManager, mgr, o.CRDMode)
 	if err != nil {
@@ -209,8 +319,29 @@ func (o *Options) Run() error {
 	}
 
 	xdsManager := xds.NewManager(o.CRDMode)
+	xdsManager.SetWorkloadMTLS(o.WorkloadMTLS)
+	xdsManager.SetMetrics(controlplaneMetrics)
+	xdsManager.SetPeerTLS(parsedCertData)
+	xdsManager.SetDataplaneType(dataplaneType)
+	xdsManager.SetAccessLog(accessLogMode)
+	if o.EndpointDiscovery {
+		if !o.CRDMode {
+			return fmt.Errorf("endpoint discovery requires a CRD-based controlplane")
+		}
+		xdsManager.SetEndpointDiscovery(mgr.GetClient())
+	}
+	// only envoy dataplanes get their certificate from the controlplane (over SDS)
+	var certificateWatcher *xds.CertificateWatcher
+	if dataplaneType == v1alpha1.DataplaneTypeEnvoy {
+		certificateWatcher, err = xds.NewCertificateWatcher(xdsManager, DataplaneCertificateDirectory)
+		if err != nil {
+			return fmt.Errorf("cannot load dataplane certificate: %w", err)
+		}
+	}
 	xds.RegisterService(
 		context.Background(), xdsManager, grpcServer.GetGRPCServer())
+	xds.RegisterAccessLogService(grpcServer.GetGRPCServer(), os.Stdout, parsedCertData)
+	xds.RegisterHandlers(xdsManager, httpServer)
 
 	if o.CRDMode {
 		err := xds.CreateControllers(xdsManager, mgr)
@@ -245,6 +376,7 @@ func (o *Options) Run() error {
 		authzManager.SetGetPeerCallback(restManager.GetK8sPeer)
 		controlManager.SetGetImportCallback(restManager.GetK8sImport)
 		controlManager.SetGetMergeImportListCallback(restManager.GetMergeImportList)
+		controlManager.SetGetImportListCallback(restManager.GetK8sImportList)
 		controlManager.SetPeerStatusCallback(func(pr *v1alpha1.Peer) {
 			restManager.UpdatePeerStatus(pr.Name, &pr.Status)
 		})
@@ -256,6 +388,11 @@ func (o *Options) Run() error {
 	runnableManager := runnable.NewManager()
 	runnableManager.Add(controller.NewManager(mgr))
 	runnableManager.Add(controlManager)
+	runnableManager.Add(xds.NewStatusPublisher(xdsManager, mgr.GetClient(), namespace))
+	if certificateWatcher != nil {
+		runnableManager.Add(certificateWatcher)
+	}
+	runnableManager.Add(xds.NewPeerResolver(xdsManager))
 	runnableManager.AddServer(httpServerAddress, httpServer)
 	runnableManager.AddServer(grpcServerAddress, grpcServer)
 	runnableManager.AddServer(controlplaneServerListenAddress, sniProxy)
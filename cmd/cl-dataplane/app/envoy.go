@@ -15,30 +15,74 @@ package app
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
+	"net"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 	"text/template"
 
 	cpapi "github.com/clusterlink-net/clusterlink/pkg/controlplane/api"
 	"github.com/clusterlink-net/clusterlink/pkg/dataplane/api"
+	"github.com/clusterlink-net/clusterlink/pkg/util/spiffe"
 )
 
 const (
 	envoyPath = "/usr/local/bin/envoy"
 )
 
-func (o *Options) runEnvoy(peerName, dataplaneID string) error {
+func (o *Options) runEnvoy(peerName, dataplaneID, svidDirectory string) error {
+	listenAddresses, err := api.ParseListenAddresses(o.ListenAddresses)
+	if err != nil {
+		return err
+	}
+
+	accessLogMode, err := cpapi.ParseAccessLogMode(o.AccessLog)
+	if err != nil {
+		return err
+	}
+
+	var tracingCollectorHost, tracingCollectorPort string
+	if o.TracingCollector != "" {
+		tracingCollectorHost, tracingCollectorPort, err = net.SplitHostPort(o.TracingCollector)
+		if err != nil {
+			return fmt.Errorf("invalid tracing collector address '%s': %w", o.TracingCollector, err)
+		}
+	}
+
+	// listen addresses are passed to the controlplane as a (JSON/YAML) list in the node metadata
+	encodedListenAddresses := ""
+	if len(listenAddresses) > 0 {
+		encoded, err := json.Marshal(listenAddresses)
+		if err != nil {
+			return err
+		}
+		encodedListenAddresses = string(encoded)
+	}
+
 	envoyConfArgs := map[string]interface{}{
 		"peerName":    peerName,
 		"dataplaneID": dataplaneID,
 
+		"listenAddressesMetadataKey": cpapi.ListenAddressesMetadataKey,
+		"listenAddresses":            encodedListenAddresses,
+
 		"controlplaneHost": o.ControlplaneHost,
 		"controlplanePort": cpapi.ListenPort,
 
 		"dataplaneListenPort": api.ListenPort,
 
+		"accessLog":               string(accessLogMode),
+		"accessLogJSON":           string(cpapi.AccessLogJSON),
+		"accessLogGRPC":           string(cpapi.AccessLogGRPC),
+		"accessLogName":           cpapi.AccessLogName,
+		"connectionIDHeader":      cpapi.ConnectionIDHeader,
+		"tracingCollectorCluster": cpapi.TracingCollectorCluster,
+		"tracingCollectorHost":    tracingCollectorHost,
+		"tracingCollectorPort":    tracingCollectorPort,
+
 		"certificateFile": CertificateFile,
 		"keyFile":         KeyFile,
 		"caFile":          CAFile,
@@ -51,8 +95,17 @@ func (o *Options) runEnvoy(peerName, dataplaneID string) error {
 		"egressRouterListener":  cpapi.EgressRouterListener,
 		"ingressRouterListener": cpapi.IngressRouterListener,
 
-		"certificateSecret": cpapi.CertificateSecret,
-		"validationSecret":  cpapi.ValidationSecret,
+		"certificateSecret":          cpapi.CertificateSecret,
+		"validationSecret":           cpapi.ValidationSecret,
+		"bootstrapCertificateSecret": cpapi.BootstrapCertificateSecret,
+		"bootstrapValidationSecret":  cpapi.BootstrapValidationSecret,
+
+		"workloadCertificateSecret": cpapi.WorkloadCertificateSecret,
+		"workloadValidationSecret":  cpapi.WorkloadValidationSecret,
+		"svidDirectory":             svidDirectory,
+		"svidFile":                  filepath.Join(svidDirectory, spiffe.SVIDFileName),
+		"svidKeyFile":               filepath.Join(svidDirectory, spiffe.SVIDKeyFileName),
+		"svidBundleFile":            filepath.Join(svidDirectory, spiffe.BundleFileName),
 
 		"controlplaneGRPCSNI": cpapi.GRPCServerName(peerName),
 		"dataplaneSNI":        api.DataplaneSNI(peerName),
@@ -62,9 +115,17 @@ func (o *Options) runEnvoy(peerName, dataplaneID string) error {
 
 		"importNameHeader":      cpapi.ImportNameHeader,
 		"importNamespaceHeader": cpapi.ImportNamespaceHeader,
+		"importPortHeader":      cpapi.ImportPortHeader,
 		"clientIPHeader":        cpapi.ClientIPHeader,
+		"clientSPIFFEIDHeader":  cpapi.ClientSPIFFEIDHeader,
 		"authorizationHeader":   cpapi.AuthorizationHeader,
 		"targetClusterHeader":   cpapi.TargetClusterHeader,
+
+		"httpModeHeader":          cpapi.HTTPModeHeader,
+		"httpRouteHeader":         cpapi.HTTPRouteHeader,
+		"httpAuthorizationHeader": cpapi.HTTPAuthorizationHeader,
+
+		"udpTunnelPathPrefix": api.UDPTunnelPathPrefix,
 	}
 
 	var envoyConf bytes.Buffer
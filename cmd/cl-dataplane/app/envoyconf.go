@@ -18,6 +18,10 @@ const (
 node:
   id: {{.dataplaneID}}
   cluster: {{.peerName}}
+{{- if .listenAddresses }}
+  metadata:
+    {{.listenAddressesMetadataKey}}: {{.listenAddresses}}
+{{- end }}
 admin:
   address:
     socket_address:
@@ -33,6 +37,7 @@ layered_runtime:
     static_layer:
       overload:
         global_downstream_max_connections: 50000
+      envoy.reloadable_features.enable_connect_udp_support: true
 dynamic_resources:
   ads_config:
     api_type: DELTA_GRPC
@@ -53,17 +58,35 @@ dynamic_resources:
     initial_fetch_timeout: 1s
     ads: {}
 static_resources:
+  # the certificate for peer connections is served by the controlplane over SDS,
+  # while connecting to the controlplane uses the certificate files
   secrets:
-  - name: {{.certificateSecret}}
+  - name: {{.bootstrapCertificateSecret}}
     tls_certificate:
       certificate_chain:
         filename: {{.certificateFile}}
       private_key:
         filename: {{.keyFile}}
-  - name: {{.validationSecret}}
+  - name: {{.bootstrapValidationSecret}}
     validation_context:
       trusted_ca:
         filename: {{.caFile}}
+{{- if .svidDirectory }}
+  - name: {{.workloadCertificateSecret}}
+    tls_certificate:
+      certificate_chain:
+        filename: {{.svidFile}}
+      private_key:
+        filename: {{.svidKeyFile}}
+      watched_directory:
+        path: {{.svidDirectory}}
+  - name: {{.workloadValidationSecret}}
+    validation_context:
+      trusted_ca:
+        filename: {{.svidBundleFile}}
+      watched_directory:
+        path: {{.svidDirectory}}
+{{- end }}
   clusters:
   - name: {{.controlplaneGRPCCluster}}
     type: LOGICAL_DNS
@@ -95,9 +118,9 @@ static_resources:
         max_session_keys: 0 # TODO: remove once controlplane no longer uses inet.af/tcpproxy
         common_tls_context:
           tls_certificate_sds_secret_configs:
-          - name: {{.certificateSecret}}
+          - name: {{.bootstrapCertificateSecret}}
           validation_context_sds_secret_config:
-            name: {{.validationSecret}}
+            name: {{.bootstrapValidationSecret}}
   - name: {{.controlplaneInternalHTTPCluster}}
     type: LOGICAL_DNS
     dns_refresh_rate: 1s
@@ -124,9 +147,9 @@ static_resources:
         max_session_keys: 0 # TODO: remove once controlplane no longer uses inet.af/tcpproxy
         common_tls_context:
           tls_certificate_sds_secret_configs:
-          - name: {{.certificateSecret}}
+          - name: {{.bootstrapCertificateSecret}}
           validation_context_sds_secret_config:
-            name: {{.validationSecret}}
+            name: {{.bootstrapValidationSecret}}
   - name: {{.controlplaneExternalHTTPCluster}}
     type: LOGICAL_DNS
     dns_refresh_rate: 1s
@@ -145,6 +168,25 @@ static_resources:
               socket_address:
                 address: {{.controlplaneHost}}
                 port_value: {{.controlplanePort}}
+{{- if .tracingCollectorHost }}
+  - name: {{.tracingCollectorCluster}}
+    type: STRICT_DNS
+    connect_timeout: 1s
+    typed_extension_protocol_options:
+      envoy.extensions.upstreams.http.v3.HttpProtocolOptions:
+        "@type": type.googleapis.com/envoy.extensions.upstreams.http.v3.HttpProtocolOptions
+        explicit_http_config:
+          http2_protocol_options: {}
+    load_assignment:
+      cluster_name: {{.tracingCollectorCluster}}
+      endpoints:
+      - lb_endpoints:
+        - endpoint:
+            address:
+              socket_address:
+                address: {{.tracingCollectorHost}}
+                port_value: {{.tracingCollectorPort}}
+{{- end }}
   - name: {{.egressRouterCluster}}
     connect_timeout: 1s
     typed_extension_protocol_options:
@@ -170,11 +212,88 @@ static_resources:
         typed_config:
           "@type": type.googleapis.com/envoy.extensions.filters.network.http_connection_manager.v3.HttpConnectionManager
           stat_prefix: hcm-egress
+          http2_protocol_options:
+            allow_connect: true
+{{- if eq .accessLog .accessLogJSON }}
+          access_log:
+          - name: envoy.access_loggers.stdout
+            typed_config:
+              "@type": type.googleapis.com/envoy.extensions.access_loggers.stream.v3.StdoutAccessLog
+              log_format:
+                json_format:
+                  direction: egress
+                  connection_id: "%REQ({{.connectionIDHeader}})%"
+                  target_peer: "%UPSTREAM_CLUSTER%"
+                  start_time: "%START_TIME%"
+                  duration: "%DURATION%"
+                  bytes_received: "%BYTES_RECEIVED%"
+                  bytes_sent: "%BYTES_SENT%"
+                  response_code: "%RESPONSE_CODE%"
+                  response_flags: "%RESPONSE_FLAGS%"
+{{- else if eq .accessLog .accessLogGRPC }}
+          access_log:
+          - name: envoy.access_loggers.http_grpc
+            typed_config:
+              "@type": type.googleapis.com/envoy.extensions.access_loggers.grpc.v3.HttpGrpcAccessLogConfig
+              common_config:
+                log_name: {{.accessLogName}}
+                transport_api_version: V3
+                grpc_service:
+                  envoy_grpc:
+                    cluster_name: {{.controlplaneGRPCCluster}}
+                custom_tags:
+                - tag: direction
+                  literal:
+                    value: egress
+              additional_request_headers_to_log:
+              - {{.connectionIDHeader}}
+{{- end }}
+{{- if .tracingCollectorHost }}
+          tracing:
+            provider:
+              name: envoy.tracers.opentelemetry
+              typed_config:
+                "@type": type.googleapis.com/envoy.config.trace.v3.OpenTelemetryConfig
+                grpc_service:
+                  envoy_grpc:
+                    cluster_name: {{.tracingCollectorCluster}}
+                  timeout: 0.250s
+                service_name: cl-dataplane
+            custom_tags:
+            - tag: clusterlink.connection_id
+              request_header:
+                name: {{.connectionIDHeader}}
+            - tag: clusterlink.peer
+              literal:
+                value: {{.peerName}}
+{{- end }}
           route_config:
             virtual_hosts:
             - name: egress
               domains: ["*"]
               routes:
+              - match:
+                  prefix: /
+                  headers:
+                  - name: {{.httpModeHeader}}
+                    present_match: true
+                route:
+                  cluster_header: {{.targetClusterHeader}}
+                  auto_host_rewrite: true
+                request_headers_to_remove:
+                - {{.importNameHeader}}
+                - {{.importNamespaceHeader}}
+                - {{.importPortHeader}}
+                - {{.clientIPHeader}}
+                - {{.clientSPIFFEIDHeader}}
+                - {{.httpRouteHeader}}
+              - match:
+                  prefix: {{.udpTunnelPathPrefix}}
+                route:
+                  cluster_header: {{.targetClusterHeader}}
+                  auto_host_rewrite: true
+                  upgrade_configs:
+                  - upgrade_type: CONNECT-UDP
               - match:
                   path: /
                 route:
@@ -183,6 +302,7 @@ static_resources:
                   prefix_rewrite: /
           upgrade_configs:
           - upgrade_type: CONNECT
+          - upgrade_type: CONNECT-UDP
           http_filters:
           - name: envoy.filters.http.ext_authz
             typed_config:
@@ -198,13 +318,18 @@ static_resources:
                     patterns:
                     - exact: {{.targetClusterHeader}}
                     - exact: {{.authorizationHeader}}
+                    - exact: {{.httpAuthorizationHeader}}
               clear_route_cache: true
               transport_api_version: V3
               allowed_headers:
                 patterns:
                 - exact: {{.importNameHeader}}
                 - exact: {{.importNamespaceHeader}}
+                - exact: {{.importPortHeader}}
                 - exact: {{.clientIPHeader}}
+                - exact: {{.clientSPIFFEIDHeader}}
+                - exact: {{.httpModeHeader}}
+                - exact: {{.httpRouteHeader}}
           - name: envoy.filters.http.router
             typed_config:
               "@type": type.googleapis.com/envoy.extensions.filters.http.router.v3.Router
@@ -234,20 +359,99 @@ static_resources:
             "@type": type.googleapis.com/envoy.extensions.transport_sockets.tls.v3.DownstreamTlsContext
             require_client_certificate: true
             common_tls_context:
+              alpn_protocols: ["h2", "http/1.1"]
               tls_certificate_sds_secret_configs:
               - name: {{.certificateSecret}}
+                sds_config:
+                  resource_api_version: V3
+                  ads: {}
               validation_context_sds_secret_config:
                 name: {{.validationSecret}}
+                sds_config:
+                  resource_api_version: V3
+                  ads: {}
       filters:
       - name: envoy.filters.network.http_connection_manager
         typed_config:
           "@type": type.googleapis.com/envoy.extensions.filters.network.http_connection_manager.v3.HttpConnectionManager
           stat_prefix: hcm-ingress
+{{- if eq .accessLog .accessLogJSON }}
+          access_log:
+          - name: envoy.access_loggers.stdout
+            typed_config:
+              "@type": type.googleapis.com/envoy.extensions.access_loggers.stream.v3.StdoutAccessLog
+              log_format:
+                json_format:
+                  direction: ingress
+                  connection_id: "%REQ({{.connectionIDHeader}})%"
+                  source_peer: "%DOWNSTREAM_PEER_SUBJECT%"
+                  target: "%UPSTREAM_CLUSTER%"
+                  start_time: "%START_TIME%"
+                  duration: "%DURATION%"
+                  bytes_received: "%BYTES_RECEIVED%"
+                  bytes_sent: "%BYTES_SENT%"
+                  response_code: "%RESPONSE_CODE%"
+                  response_flags: "%RESPONSE_FLAGS%"
+{{- else if eq .accessLog .accessLogGRPC }}
+          access_log:
+          - name: envoy.access_loggers.http_grpc
+            typed_config:
+              "@type": type.googleapis.com/envoy.extensions.access_loggers.grpc.v3.HttpGrpcAccessLogConfig
+              common_config:
+                log_name: {{.accessLogName}}
+                transport_api_version: V3
+                grpc_service:
+                  envoy_grpc:
+                    cluster_name: {{.controlplaneGRPCCluster}}
+                custom_tags:
+                - tag: direction
+                  literal:
+                    value: ingress
+              additional_request_headers_to_log:
+              - {{.connectionIDHeader}}
+{{- end }}
+{{- if .tracingCollectorHost }}
+          tracing:
+            provider:
+              name: envoy.tracers.opentelemetry
+              typed_config:
+                "@type": type.googleapis.com/envoy.config.trace.v3.OpenTelemetryConfig
+                grpc_service:
+                  envoy_grpc:
+                    cluster_name: {{.tracingCollectorCluster}}
+                  timeout: 0.250s
+                service_name: cl-dataplane
+            custom_tags:
+            - tag: clusterlink.connection_id
+              request_header:
+                name: {{.connectionIDHeader}}
+            - tag: clusterlink.peer
+              literal:
+                value: {{.peerName}}
+{{- end }}
           route_config:
             virtual_hosts:
             - name: ingress
               domains: ["*"]
               routes:
+              - match:
+                  prefix: /
+                  headers:
+                  - name: {{.httpModeHeader}}
+                    present_match: true
+                route:
+                  cluster_header: {{.targetClusterHeader}}
+                  auto_host_rewrite: true
+                request_headers_to_remove:
+                - {{.httpModeHeader}}
+                - {{.httpAuthorizationHeader}}
+              - match:
+                  prefix: {{.udpTunnelPathPrefix}}
+                route:
+                  cluster_header: {{.targetClusterHeader}}
+                  upgrade_configs:
+                  - upgrade_type: CONNECT-UDP
+                    connect_config: {}
               - match:
                   path: /
                 route:
@@ -258,6 +462,11 @@ static_resources:
                       allow_post: true
           upgrade_configs:
           - upgrade_type: CONNECT
+          - upgrade_type: CONNECT-UDP
+          # requests of HTTP services are authorized by their path, and forwarded with the normalized path
+          normalize_path: true
+          merge_slashes: true
+          path_with_escaped_slashes_action: UNESCAPE_AND_FORWARD
           http_filters:
           - name: envoy.filters.http.ext_authz
             typed_config:
@@ -277,6 +486,8 @@ static_resources:
               allowed_headers:
                 patterns:
                 - exact: {{.authorizationHeader}}
+                - exact: {{.httpAuthorizationHeader}}
+                - exact: {{.httpModeHeader}}
           - name: envoy.filters.http.router
             typed_config:
               "@type": type.googleapis.com/envoy.extensions.filters.http.router.v3.Router
@@ -14,6 +14,7 @@
 package app
 
 import (
+	"context"
 	"fmt"
 	"os"
 
@@ -22,8 +23,10 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 
+	cpapi "github.com/clusterlink-net/clusterlink/pkg/controlplane/api"
 	"github.com/clusterlink-net/clusterlink/pkg/dataplane/api"
 	"github.com/clusterlink-net/clusterlink/pkg/util/log"
+	"github.com/clusterlink-net/clusterlink/pkg/util/spiffe"
 	"github.com/clusterlink-net/clusterlink/pkg/util/tls"
 )
 
@@ -40,6 +43,10 @@ const (
 
 	// Name is the app label of dataplane pods.
 	Name = "cl-dataplane"
+
+	// PodIPsEnvVariable is the environment variable holding the (comma-separated) IPs of the dataplane pod,
+	// which import listeners are bound to.
+	PodIPsEnvVariable = "CL_POD_IPS"
 )
 
 // Options contains everything necessary to create and run a dataplane.
@@ -50,6 +57,16 @@ type Options struct {
 	LogFile string
 	// LogLevel is the log level.
 	LogLevel string
+	// WorkloadAPIAddress is the address of the SPIFFE Workload API.
+	WorkloadAPIAddress string
+	// SVIDDirectory is a directory holding stand-in SVID files.
+	SVIDDirectory string
+	// ListenAddresses are the IP addresses which import listeners are bound to.
+	ListenAddresses []string
+	// AccessLog is the access log mode of the egress and ingress routers.
+	AccessLog string
+	// TracingCollector is the address of an OpenTelemetry collector receiving traces.
+	TracingCollector string
 }
 
 // AddFlags adds flags to fs and binds them to options.
@@ -60,6 +77,22 @@ func (o *Options) AddFlags(fs *pflag.FlagSet) {
 		"Path to a file where logs will be written. If not specified, logs will be printed to stderr.")
 	fs.StringVar(&o.LogLevel, "log-level", logLevel,
 		"The log level. One of fatal, error, warn, info, debug.")
+	fs.StringVar(&o.WorkloadAPIAddress, "spiffe-workload-api-address", "",
+		"Address of the SPIFFE Workload API (e.g. unix:///run/spire/sockets/agent.sock), "+
+			"used to obtain the SVID for authenticating workloads on import listeners requiring workload mTLS.")
+	fs.StringVar(&o.SVIDDirectory, "spiffe-svid-dir", "",
+		"Directory holding stand-in SVID files ("+spiffe.SVIDFileName+", "+spiffe.SVIDKeyFileName+", "+
+			spiffe.BundleFileName+"), used if no SPIFFE Workload API address is specified.")
+	fs.StringSliceVar(&o.ListenAddresses, "listen-addresses", nil,
+		"IP addresses which import listeners are bound to (e.g. the pod IPs). "+
+			"If not specified, import listeners are bound to all IPv4 addresses (0.0.0.0). "+
+			"The unspecified IPv6 address (::) binds to all IPv4 and IPv6 addresses.")
+	fs.StringVar(&o.AccessLog, "access-log", string(cpapi.AccessLogNone),
+		"The access log mode of the egress and ingress routers. One of none, json (stdout), "+
+			"grpc (streamed to the controlplane). Access logs of import listeners are set by the controlplane.")
+	fs.StringVar(&o.TracingCollector, "tracing-collector", "",
+		"Address (host:port) of an OpenTelemetry collector (OTLP/gRPC) receiving traces of the egress and "+
+			"ingress routers. If not specified, tracing is disabled.")
 }
 
 // RequiredFlags are the names of flags that must be explicitly specified.
@@ -101,7 +134,24 @@ func (o *Options) Run() error {
 	dataplaneID := uuid.New().String()
 	logrus.Infof("Dataplane ID: %s.", dataplaneID)
 
-	return o.runEnvoy(peerName, dataplaneID)
+	svidDirectory := o.SVIDDirectory
+	if o.WorkloadAPIAddress != "" {
+		// Envoy reads the SVID from files, which are kept in sync with the workload API
+		svidDirectory, err = os.MkdirTemp("", "svid")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(svidDirectory)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		if err := spiffe.SyncFiles(ctx, o.WorkloadAPIAddress, svidDirectory); err != nil {
+			return err
+		}
+	}
+
+	return o.runEnvoy(peerName, dataplaneID, svidDirectory)
 }
 
 // NewCLDataplaneCommand creates a *cobra.Command object with default parameters.
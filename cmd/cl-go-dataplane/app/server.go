@@ -14,10 +14,14 @@
 package app
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"os"
+	"os/signal"
 	"strconv"
+	"syscall"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
@@ -28,7 +32,10 @@ import (
 	"github.com/clusterlink-net/clusterlink/pkg/dataplane/api"
 	dpclient "github.com/clusterlink-net/clusterlink/pkg/dataplane/client"
 	dpserver "github.com/clusterlink-net/clusterlink/pkg/dataplane/server"
+	"github.com/clusterlink-net/clusterlink/pkg/metrics"
 	"github.com/clusterlink-net/clusterlink/pkg/util/log"
+	"github.com/clusterlink-net/clusterlink/pkg/util/runnable"
+	"github.com/clusterlink-net/clusterlink/pkg/util/spiffe"
 	"github.com/clusterlink-net/clusterlink/pkg/util/tls"
 )
 
@@ -43,6 +50,9 @@ const (
 	// KeyFile is the path to the private-key file.
 	KeyFile = "/etc/ssl/private/clink-dataplane.pem"
 
+	// metricsAddress is the default address of the Prometheus metrics endpoint.
+	metricsAddress = ":9090"
+
 	// dataplaneServerAddress is the address of the dataplane HTTP server for accepting ingress dataplane connections.
 	dataplaneServerAddress = "127.0.0.1:8443"
 )
@@ -55,6 +65,17 @@ type Options struct {
 	LogFile string
 	// LogLevel is the log level.
 	LogLevel string
+	// WorkloadAPIAddress is the address of the SPIFFE Workload API.
+	WorkloadAPIAddress string
+	// SVIDDirectory is a directory holding stand-in SVID files.
+	SVIDDirectory string
+	// ListenAddresses are the IP addresses which import listeners are bound to.
+	ListenAddresses []string
+	// MetricsAddress is the address of the Prometheus metrics endpoint.
+	MetricsAddress string
+	// DrainTimeout is the time allowed for connections to end, once their listener is removed
+	// or the dataplane is stopped.
+	DrainTimeout time.Duration
 }
 
 // AddFlags adds flags to fs and binds them to options.
@@ -65,6 +86,21 @@ func (o *Options) AddFlags(fs *pflag.FlagSet) {
 		"Path to a file where logs will be written. If not specified, logs will be printed to stderr.")
 	fs.StringVar(&o.LogLevel, "log-level", logLevel,
 		"The log level. One of fatal, error, warn, info, debug.")
+	fs.StringVar(&o.WorkloadAPIAddress, "spiffe-workload-api-address", "",
+		"Address of the SPIFFE Workload API (e.g. unix:///run/spire/sockets/agent.sock), "+
+			"used to obtain the SVID for authenticating workloads on import listeners requiring workload mTLS.")
+	fs.StringVar(&o.SVIDDirectory, "spiffe-svid-dir", "",
+		"Directory holding stand-in SVID files ("+spiffe.SVIDFileName+", "+spiffe.SVIDKeyFileName+", "+
+			spiffe.BundleFileName+"), used if no SPIFFE Workload API address is specified.")
+	fs.StringSliceVar(&o.ListenAddresses, "listen-addresses", nil,
+		"IP addresses which import listeners are bound to (e.g. the pod IPs). "+
+			"If not specified, import listeners are bound to all IPv4 addresses (0.0.0.0). "+
+			"The unspecified IPv6 address (::) binds to all IPv4 and IPv6 addresses.")
+	fs.StringVar(&o.MetricsAddress, "metrics-address", metricsAddress,
+		"The address the Prometheus metrics endpoint ("+metrics.Path+") binds to. Set to \"0\" to disable.")
+	fs.DurationVar(&o.DrainTimeout, "drain-timeout", dpserver.DefaultDrainTimeout,
+		"Time allowed for active connections to end, once their listener is removed or the dataplane is stopped "+
+			"(on SIGTERM). Connections still active after this time are closed.")
 }
 
 // RequiredFlags are the names of flags that must be explicitly specified.
@@ -76,24 +112,68 @@ func (o *Options) RequiredFlags() []string {
 func (o *Options) runGoDataplane(peerName, dataplaneID string, parsedCertData *tls.ParsedCertData) error {
 	controlplaneTarget := net.JoinHostPort(o.ControlplaneHost, strconv.Itoa(cpapi.ListenPort))
 
+	listenAddresses, err := api.ParseListenAddresses(o.ListenAddresses)
+	if err != nil {
+		return err
+	}
+
 	logrus.Infof("Starting go dataplane, Name: %s, ID: %s", peerName, dataplaneID)
 
 	dataplane := dpserver.NewDataplane(dataplaneID, controlplaneTarget, peerName, parsedCertData)
-	go func() {
-		err := dataplane.StartDataplaneServer(dataplaneServerAddress)
-		logrus.Errorf("Failed to start dataplane server: %v.", err)
-	}()
+	dataplane.SetDrainTimeout(o.DrainTimeout)
 
-	go func() {
-		err := dataplane.StartSNIServer(dataplaneServerAddress)
-		logrus.Error("Failed to start dataplane server", err)
-	}()
+	spiffeConfig := &spiffe.Config{
+		WorkloadAPIAddress: o.WorkloadAPIAddress,
+		SVIDDirectory:      o.SVIDDirectory,
+	}
+	if spiffeConfig.Enabled() {
+		source, err := spiffeConfig.NewSource(context.Background())
+		if err != nil {
+			return err
+		}
+		defer source.Close()
+
+		dataplane.SetWorkloadSource(source)
+	}
 
-	// Start xDS client, if it fails to start we keep retrying to connect to the controlplane host
+	if o.MetricsAddress != "0" {
+		registry := metrics.NewRegistry()
+		dataplaneMetrics, err := metrics.NewDataplaneMetrics(registry)
+		if err != nil {
+			return fmt.Errorf("cannot register metrics: %w", err)
+		}
+		dataplane.SetMetrics(dataplaneMetrics)
+
+		go func() {
+			err := metrics.ListenAndServe(o.MetricsAddress, registry)
+			logrus.Errorf("Failed to start metrics server: %v.", err)
+		}()
+	}
+
+	// xDS client keeps retrying to connect to the controlplane host
 	tlsConfig := parsedCertData.ClientConfig(cpapi.GRPCServerName(peerName))
 	xdsClient := dpclient.NewXDSClient(dataplane, controlplaneTarget, tlsConfig)
-	err := xdsClient.Run()
-	return fmt.Errorf("xDS Client stopped: %w", err)
+	xdsClient.SetListenAddresses(listenAddresses)
+
+	// on a graceful stop, listeners are no longer updated by the xDS client, and then drained by the dataplane server
+	runnableManager := runnable.NewManager()
+	runnableManager.Add(xdsClient)
+	runnableManager.AddServer(":"+strconv.Itoa(api.ListenPort), dataplane.NewSNIProxy(dataplaneServerAddress))
+	runnableManager.AddServer(dataplaneServerAddress, dpserver.NewServer(dataplane))
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+	defer signal.Stop(signals)
+
+	go func() {
+		sig := <-signals
+		logrus.Infof("Received %v, draining connections.", sig)
+		if err := runnableManager.GracefulStop(); err != nil {
+			logrus.Errorf("Failed to gracefully stop: %v.", err)
+		}
+	}()
+
+	return runnableManager.Run()
 }
 
 // Run the dataplane.
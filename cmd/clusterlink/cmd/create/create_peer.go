@@ -17,6 +17,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
@@ -24,6 +25,7 @@ import (
 
 	"github.com/clusterlink-net/clusterlink/cmd/clusterlink/config"
 	"github.com/clusterlink-net/clusterlink/pkg/bootstrap"
+	"github.com/clusterlink-net/clusterlink/pkg/controlplane/api"
 )
 
 // PeerOptions contains everything necessary to create and run a 'create peer-cert' subcommand.
@@ -34,6 +36,9 @@ type PeerOptions struct {
 	Fabric string
 	// Path where the certificates will be created.
 	Path string
+	// GWCTLUsers are additional gwctl users to create certificates for,
+	// each of the form <user>[:<role>[:<namespace>]].
+	GWCTLUsers []string
 }
 
 // AddFlags adds flags to fs and binds them to options.
@@ -41,6 +46,10 @@ func (o *PeerOptions) AddFlags(fs *pflag.FlagSet) {
 	fs.StringVar(&o.Name, "name", "", "Peer name.")
 	fs.StringVar(&o.Fabric, "fabric", config.DefaultFabric, "Fabric name.")
 	fs.StringVar(&o.Path, "path", ".", "Path where the certificates will be created.")
+	fs.StringArrayVar(&o.GWCTLUsers, "gwctl-user", []string{},
+		"Additional gwctl user to create a certificate for, of the form <user>[:<role>[:<namespace>]]. "+
+			"Role is one of admin (default), policy-editor or read-only. "+
+			"If a namespace is given, the user can only access objects in this namespace.")
 }
 
 // RequiredFlags are the names of flags that must be explicitly specified.
@@ -113,6 +122,64 @@ func (o *PeerOptions) createGWCTL(peerCert *bootstrap.Certificate) (*bootstrap.C
 	return cert, nil
 }
 
+// gwctlUser is a gwctl user to create a certificate for.
+type gwctlUser struct {
+	name       string
+	role       api.UserRole
+	namespaces []string
+}
+
+// parseGWCTLUser parses a gwctl user of the form <user>[:<role>[:<namespace>]].
+func parseGWCTLUser(value string) (*gwctlUser, error) {
+	parts := strings.Split(value, ":")
+	if len(parts) > 3 {
+		return nil, fmt.Errorf("invalid gwctl user '%s': expected <user>[:<role>[:<namespace>]]", value)
+	}
+
+	user := &gwctlUser{
+		name: parts[0],
+		role: api.UserRoleAdmin,
+	}
+
+	if user.name == "" || filepath.Base(user.name) != user.name {
+		return nil, fmt.Errorf("invalid gwctl user name '%s'", user.name)
+	}
+
+	if len(parts) > 1 {
+		role, err := api.ParseUserRole(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid gwctl user '%s': %w", value, err)
+		}
+
+		user.role = role
+	}
+
+	if len(parts) > 2 && parts[2] != "" {
+		user.namespaces = []string{parts[2]}
+	}
+
+	return user, nil
+}
+
+func (o *PeerOptions) createGWCTLUser(user *gwctlUser, peerCert *bootstrap.Certificate) (*bootstrap.Certificate, error) {
+	cert, err := bootstrap.CreateGWCTLUserCertificate(
+		user.name, []api.UserRole{user.role}, user.namespaces, peerCert)
+	if err != nil {
+		return nil, err
+	}
+
+	outDirectory := config.GWCTLUserDirectory(user.name, o.Name, o.Fabric, o.Path)
+	if err := os.Mkdir(outDirectory, 0o755); err != nil {
+		return nil, err
+	}
+
+	if err := o.saveCertificate(cert, outDirectory); err != nil {
+		return nil, err
+	}
+
+	return cert, nil
+}
+
 // Run the 'create peer-cert' subcommand.
 func (o *PeerOptions) Run() error {
 	if _, err := idna.Lookup.ToASCII(o.Name); err != nil {
@@ -123,6 +190,16 @@ func (o *PeerOptions) Run() error {
 		return err
 	}
 
+	users := make([]*gwctlUser, len(o.GWCTLUsers))
+	for i, value := range o.GWCTLUsers {
+		user, err := parseGWCTLUser(value)
+		if err != nil {
+			return err
+		}
+
+		users[i] = user
+	}
+
 	fabricCert, err := bootstrap.ReadCertificates(config.FabricDirectory(o.Fabric, o.Path), true)
 	if err != nil {
 		return err
@@ -155,6 +232,12 @@ func (o *PeerOptions) Run() error {
 		return err
 	}
 
+	for _, user := range users {
+		if _, err := o.createGWCTLUser(user, peerCertificate); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -75,6 +75,11 @@ func GWCTLDirectory(peer, fabric, path string) string {
 	return filepath.Join(PeerDirectory(peer, fabric, path), GWCTLDirectoryName)
 }
 
+// GWCTLUserDirectory returns the path for a gwctl instance of a specific user.
+func GWCTLUserDirectory(user, peer, fabric, path string) string {
+	return filepath.Join(PeerDirectory(peer, fabric, path), GWCTLDirectoryName+"-"+user)
+}
+
 // FabricCertificate returns the fabric certificate name.
 func FabricCertificate(name, path string) string {
 	return filepath.Join(FabricDirectory(name, path), CertificateFileName)
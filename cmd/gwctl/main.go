@@ -108,6 +108,7 @@ func getCmd() *cobra.Command {
 	getCmd.AddCommand(subcommand.ImportGetCmd())
 	getCmd.AddCommand(subcommand.PolicyGetCmd())
 	getCmd.AddCommand(subcommand.MetricsGetCmd())
+	getCmd.AddCommand(subcommand.XDSGetCmd())
 	getCmd.AddCommand(subcommand.AllGetCmd())
 	return getCmd
 }
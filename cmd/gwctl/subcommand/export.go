@@ -15,6 +15,10 @@ package subcommand
 
 import (
 	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
@@ -31,6 +35,8 @@ type exportCreateOptions struct {
 	name     string
 	host     string
 	port     uint16
+	ports    map[string]int
+	protocol string
 	external string
 }
 
@@ -47,7 +53,9 @@ func ExportCreateCmd() *cobra.Command {
 	}
 
 	o.addFlags(cmd.Flags())
-	cmdutil.MarkFlagsRequired(cmd, []string{"name", "port"})
+	cmdutil.MarkFlagsRequired(cmd, []string{"name"})
+	cmd.MarkFlagsOneRequired("port", "ports")
+	cmd.MarkFlagsMutuallyExclusive("port", "ports")
 	return cmd
 }
 
@@ -64,7 +72,9 @@ func ExportUpdateCmd() *cobra.Command {
 	}
 
 	o.addFlags(cmd.Flags())
-	cmdutil.MarkFlagsRequired(cmd, []string{"name", "port"})
+	cmdutil.MarkFlagsRequired(cmd, []string{"name"})
+	cmd.MarkFlagsOneRequired("port", "ports")
+	cmd.MarkFlagsMutuallyExclusive("port", "ports")
 	return cmd
 }
 
@@ -74,6 +84,8 @@ func (o *exportCreateOptions) addFlags(fs *pflag.FlagSet) {
 	fs.StringVar(&o.name, "name", "", "Exported service name")
 	fs.StringVar(&o.host, "host", "", "Exported service endpoint hostname (IP/DNS), if unspecified, uses the service name")
 	fs.Uint16Var(&o.port, "port", 0, "Exported service port")
+	fs.StringToIntVar(&o.ports, "ports", nil, "Named ports of a multi-port exported service (<name>=<port>,...)")
+	fs.StringVar(&o.protocol, "protocol", string(v1alpha1.ProtocolDefault), "Exported service protocol (TCP, UDP or HTTP)")
 	fs.StringVar(&o.external, "external", "",
 		"External endpoint <host>:<port, which the exported service will be connected")
 }
@@ -89,13 +101,25 @@ func (o *exportCreateOptions) run(isUpdate bool) error {
 		exportOperation = g.Exports.Update
 	}
 
+	portNames, err := namedPortNames(o.ports)
+	if err != nil {
+		return err
+	}
+
+	var ports []v1alpha1.ExportPort
+	for _, name := range portNames {
+		ports = append(ports, v1alpha1.ExportPort{Name: name, Port: uint16(o.ports[name])})
+	}
+
 	err = exportOperation(&v1alpha1.Export{
 		ObjectMeta: metav1.ObjectMeta{
 			Name: o.name,
 		},
 		Spec: v1alpha1.ExportSpec{
-			Host: o.host,
-			Port: o.port,
+			Host:     o.host,
+			Port:     o.port,
+			Ports:    ports,
+			Protocol: v1alpha1.Protocol(o.protocol),
 		},
 	})
 	if err != nil {
@@ -201,8 +225,8 @@ func (o *exportGetOptions) run() error {
 		for i := range *exports {
 			export := &(*exports)[i]
 			fmt.Printf(
-				"%d. Service Name: %s. Host: %s. Port: %d\n",
-				i+1, export.Name, export.Spec.Host, export.Spec.Port)
+				"%d. Service Name: %s. Host: %s. Port: %s. Protocol: %s\n",
+				i+1, export.Name, export.Spec.Host, exportPortsString(&export.Spec), protocolString(export.Spec.Protocol))
 		}
 	} else {
 		s, err := exportClient.Exports.Get(o.name)
@@ -214,3 +238,41 @@ func (o *exportGetOptions) run() error {
 
 	return nil
 }
+
+// protocolString returns the printed protocol of an exported or imported service.
+func protocolString(protocol v1alpha1.Protocol) string {
+	if protocol == "" {
+		return string(v1alpha1.ProtocolDefault)
+	}
+
+	return string(protocol)
+}
+
+// namedPortNames returns the sorted names of the given named ports, after validating their port numbers.
+func namedPortNames(ports map[string]int) ([]string, error) {
+	names := make([]string, 0, len(ports))
+	for name, port := range ports {
+		if port <= 0 || port > math.MaxUint16 {
+			return nil, fmt.Errorf("invalid port number for port '%s': %d", name, port)
+		}
+
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
+
+// exportPortsString returns the port of a single-port export, or the named ports of a multi-port export.
+func exportPortsString(spec *v1alpha1.ExportSpec) string {
+	if len(spec.Ports) == 0 {
+		return strconv.Itoa(int(spec.Port))
+	}
+
+	ports := make([]string, len(spec.Ports))
+	for i, port := range spec.Ports {
+		ports[i] = fmt.Sprintf("%s=%d", port.Name, port.Port)
+	}
+
+	return strings.Join(ports, ",")
+}
@@ -16,6 +16,8 @@ package subcommand
 import (
 	"encoding/json"
 	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
@@ -28,11 +30,13 @@ import (
 
 // importOptions is the command line options for 'create import' or 'update import'.
 type importOptions struct {
-	myID  string
-	name  string
-	port  uint16
-	peers []string
-	merge bool
+	myID     string
+	name     string
+	port     uint16
+	ports    map[string]int
+	protocol string
+	peers    []string
+	merge    bool
 }
 
 // ImportCreateCmd - create an imported service.
@@ -48,7 +52,9 @@ func ImportCreateCmd() *cobra.Command {
 	}
 
 	o.addFlags(cmd.Flags())
-	cmdutil.MarkFlagsRequired(cmd, []string{"name", "port"})
+	cmdutil.MarkFlagsRequired(cmd, []string{"name"})
+	cmd.MarkFlagsOneRequired("port", "ports")
+	cmd.MarkFlagsMutuallyExclusive("port", "ports")
 
 	return cmd
 }
@@ -66,7 +72,9 @@ func ImportUpdateCmd() *cobra.Command {
 	}
 
 	o.addFlags(cmd.Flags())
-	cmdutil.MarkFlagsRequired(cmd, []string{"name", "port"})
+	cmdutil.MarkFlagsRequired(cmd, []string{"name"})
+	cmd.MarkFlagsOneRequired("port", "ports")
+	cmd.MarkFlagsMutuallyExclusive("port", "ports")
 
 	return cmd
 }
@@ -76,6 +84,9 @@ func (o *importOptions) addFlags(fs *pflag.FlagSet) {
 	fs.StringVar(&o.myID, "myid", "", "gwctl ID")
 	fs.StringVar(&o.name, "name", "", "Imported service name")
 	fs.Uint16Var(&o.port, "port", 0, "Imported service port")
+	fs.StringToIntVar(&o.ports, "ports", nil,
+		"Named ports of a multi-port imported service (<name>=<port>,...), matching the exported port names")
+	fs.StringVar(&o.protocol, "protocol", string(v1alpha1.ProtocolDefault), "Imported service protocol (TCP, UDP or HTTP)")
 	fs.StringSliceVar(&o.peers, "peer", []string{}, "Remote peer to import the service from")
 	fs.BoolVar(&o.merge, "merge", false, "Merge with an existing service endpoint")
 }
@@ -98,6 +109,16 @@ func (o *importOptions) run(isUpdate bool) error {
 		sources[i].ExportName = o.name
 	}
 
+	portNames, err := namedPortNames(o.ports)
+	if err != nil {
+		return err
+	}
+
+	var ports []v1alpha1.ImportPort
+	for _, name := range portNames {
+		ports = append(ports, v1alpha1.ImportPort{Name: name, Port: uint16(o.ports[name])})
+	}
+
 	labels := make(map[string]string)
 	if o.merge {
 		labels[v1alpha1.LabelImportMerge] = "true"
@@ -109,8 +130,10 @@ func (o *importOptions) run(isUpdate bool) error {
 			Labels: labels,
 		},
 		Spec: v1alpha1.ImportSpec{
-			Port:    o.port,
-			Sources: sources,
+			Port:     o.port,
+			Ports:    ports,
+			Protocol: v1alpha1.Protocol(o.protocol),
+			Sources:  sources,
 		},
 	})
 	if err != nil {
@@ -215,8 +238,9 @@ func (o *importGetOptions) run() error {
 		for i := range *imports {
 			imp := &(*imports)[i]
 			fmt.Printf(
-				"%d. Imported Name: %s. Port %v. TargetPort %v. Sources %v.\n",
-				i+1, imp.Name, imp.Spec.Port, imp.Spec.TargetPort, imp.Spec.Sources)
+				"%d. Imported Name: %s. Port %s. TargetPort %s. Protocol %s. Sources %v.\n",
+				i+1, imp.Name, importPortsString(&imp.Spec, false), importPortsString(&imp.Spec, true),
+				protocolString(imp.Spec.Protocol), imp.Spec.Sources)
 		}
 	} else {
 		imp, err := importClient.Imports.Get(o.name)
@@ -234,3 +258,23 @@ func (o *importGetOptions) run() error {
 
 	return nil
 }
+
+// importPortsString returns the port (or target port) of a single-port import,
+// or the named ports (or target ports) of a multi-port import.
+func importPortsString(spec *v1alpha1.ImportSpec, targetPort bool) string {
+	ports := make([]string, 0, len(spec.Ports))
+	for _, port := range spec.ServicePorts() {
+		number := port.Port
+		if targetPort {
+			number = port.TargetPort
+		}
+
+		if port.Name == "" {
+			return strconv.Itoa(int(number))
+		}
+
+		ports = append(ports, fmt.Sprintf("%s=%d", port.Name, number))
+	}
+
+	return strings.Join(ports, ",")
+}
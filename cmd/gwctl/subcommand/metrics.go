@@ -20,8 +20,16 @@ import (
 	"github.com/spf13/pflag"
 
 	"github.com/clusterlink-net/clusterlink/cmd/gwctl/config"
+	event "github.com/clusterlink-net/clusterlink/pkg/controlplane/eventmanager"
 )
 
+var connectionStates = map[event.ConnectionState]string{
+	event.Ongoing:    "ongoing",
+	event.Complete:   "complete",
+	event.Denied:     "denied",
+	event.PeerDenied: "peer denied",
+}
+
 // MetricsGetOptions is the command line options for 'get metrics'.
 type metricsGetOptions struct {
 	myID string
@@ -61,8 +69,16 @@ func (o *metricsGetOptions) run() error {
 		fmt.Printf("Unable to get metrics %v\n", err)
 	} else {
 		fmt.Printf("Metrics\n")
-		for i := range metrics {
-			fmt.Printf("%v\n", metrics[i])
+		for _, conn := range metrics {
+			direction := "outgoing"
+			if conn.Direction == event.Incoming {
+				direction = "incoming"
+			}
+			fmt.Printf(
+				"Connection %s (%s). Source: %s. Destination: %s. Peer: %s. "+
+					"Incoming bytes: %d. Outgoing bytes: %d. Duration: %v. State: %s\n",
+				conn.ConnectionID, direction, conn.SrcService, conn.DstService, conn.DestinationPeer,
+				conn.IncomingBytes, conn.OutgoingBytes, conn.LastTstamp.Sub(conn.StartTstamp), connectionStates[conn.State])
 		}
 	}
 	return nil
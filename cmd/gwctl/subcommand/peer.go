@@ -31,6 +31,7 @@ type peerOptions struct {
 	name string
 	host string
 	port uint16
+	srv  string
 }
 
 // PeerCreateCmd - create a peer command.
@@ -46,7 +47,9 @@ func PeerCreateCmd() *cobra.Command {
 	}
 
 	o.addFlags(cmd.Flags())
-	cmdutil.MarkFlagsRequired(cmd, []string{"name", "host", "port"})
+	cmdutil.MarkFlagsRequired(cmd, []string{"name"})
+	cmd.MarkFlagsRequiredTogether("host", "port")
+	cmd.MarkFlagsOneRequired("host", "gateway-srv")
 
 	return cmd
 }
@@ -64,7 +67,9 @@ func PeerUpdateCmd() *cobra.Command {
 	}
 
 	o.addFlags(cmd.Flags())
-	cmdutil.MarkFlagsRequired(cmd, []string{"name", "host", "port"})
+	cmdutil.MarkFlagsRequired(cmd, []string{"name"})
+	cmd.MarkFlagsRequiredTogether("host", "port")
+	cmd.MarkFlagsOneRequired("host", "gateway-srv")
 
 	return cmd
 }
@@ -75,6 +80,7 @@ func (o *peerOptions) addFlags(fs *pflag.FlagSet) {
 	fs.StringVar(&o.name, "name", "", "Peer name")
 	fs.StringVar(&o.host, "host", "", "Peer endpoint hostname (IP/DNS)")
 	fs.Uint16Var(&o.port, "port", 0, "Peer endpoint port")
+	fs.StringVar(&o.srv, "gateway-srv", "", "DNS SRV name pointing to the peer endpoints")
 }
 
 // run performs the execution of the 'create peer' or 'update peer' subcommand.
@@ -89,17 +95,22 @@ func (o *peerOptions) run(isUpdate bool) error {
 		peerOperation = g.Peers.Update
 	}
 
-	err = peerOperation(&v1alpha1.Peer{
+	peer := &v1alpha1.Peer{
 		ObjectMeta: metav1.ObjectMeta{
 			Name: o.name,
 		},
 		Spec: v1alpha1.PeerSpec{
-			Gateways: []v1alpha1.Endpoint{{
-				Host: o.host,
-				Port: o.port,
-			}},
+			GatewaySRV: o.srv,
 		},
-	})
+	}
+	if o.host != "" {
+		peer.Spec.Gateways = []v1alpha1.Endpoint{{
+			Host: o.host,
+			Port: o.port,
+		}}
+	}
+
+	err = peerOperation(peer)
 	if err != nil {
 		return err
 	}
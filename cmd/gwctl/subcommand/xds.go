@@ -0,0 +1,300 @@
+// Copyright 2023 The ClusterLink Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package subcommand
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"sigs.k8s.io/yaml"
+
+	"github.com/clusterlink-net/clusterlink/cmd/gwctl/config"
+	"github.com/clusterlink-net/clusterlink/pkg/controlplane/api"
+)
+
+// xdsGetOptions is the command line options for 'get xds'.
+type xdsGetOptions struct {
+	myID   string
+	output string
+	diff   []string
+}
+
+// XDSGetCmd - get the xDS configuration command.
+func XDSGetCmd() *cobra.Command {
+	o := xdsGetOptions{}
+	cmd := &cobra.Command{
+		Use:   "xds",
+		Short: "Get the xDS configuration pushed to the dataplanes",
+		Long: "Get the xDS resources (clusters, endpoints and listeners) served by the controlplane, " +
+			"and the versions acknowledged or rejected by each connected dataplane. " +
+			"A saved JSON snapshot can be compared with the current configuration, or with another snapshot, " +
+			"using --diff.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd.OutOrStdout())
+		},
+	}
+	o.addFlags(cmd.Flags())
+
+	return cmd
+}
+
+// addFlags registers flags for the CLI.
+func (o *xdsGetOptions) addFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&o.myID, "myid", "", "gwctl ID")
+	fs.StringVarP(&o.output, "output", "o", "json", "Output format (json or yaml)")
+	fs.StringSliceVar(&o.diff, "diff", nil,
+		"Snapshot file(s) to compare. "+
+			"A single snapshot is compared with the current configuration, two snapshots are compared with each other.")
+}
+
+// run performs the execution of the 'get xds' subcommand.
+func (o *xdsGetOptions) run(w io.Writer) error {
+	if len(o.diff) > 2 {
+		return fmt.Errorf("at most two snapshots can be compared")
+	}
+
+	if len(o.diff) == 2 {
+		from, err := readXDSSnapshot(o.diff[0])
+		if err != nil {
+			return err
+		}
+
+		to, err := readXDSSnapshot(o.diff[1])
+		if err != nil {
+			return err
+		}
+
+		return diffXDS(w, from, to)
+	}
+
+	xdsClient, err := config.GetClientFromID(o.myID)
+	if err != nil {
+		return err
+	}
+
+	dump, err := xdsClient.GetXDS()
+	if err != nil {
+		return err
+	}
+
+	if len(o.diff) == 1 {
+		from, err := readXDSSnapshot(o.diff[0])
+		if err != nil {
+			return err
+		}
+
+		return diffXDS(w, from, dump)
+	}
+
+	encoded, err := json.MarshalIndent(dump, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	switch o.output {
+	case "json":
+	case "yaml":
+		encoded, err = yaml.JSONToYAML(encoded)
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown output format '%s'", o.output)
+	}
+
+	_, err = fmt.Fprintln(w, string(encoded))
+	return err
+}
+
+// readXDSSnapshot reads an xDS dump saved in a JSON (or YAML) file.
+func readXDSSnapshot(path string) (*api.XDSDump, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read snapshot: %w", err)
+	}
+
+	var dump api.XDSDump
+	if err := yaml.Unmarshal(data, &dump); err != nil {
+		return nil, fmt.Errorf("cannot decode snapshot '%s': %w", path, err)
+	}
+
+	return &dump, nil
+}
+
+// diffXDS prints the differences between two xDS dumps.
+// Added resources are prefixed with '+', deleted resources with '-', and modified resources with '~',
+// followed by their modified fields.
+func diffXDS(w io.Writer, from, to *api.XDSDump) error {
+	resources := []struct {
+		kind     string
+		from, to map[string]json.RawMessage
+	}{
+		{"cluster", from.Clusters, to.Clusters},
+		{"endpoint", from.Endpoints, to.Endpoints},
+		{"listener", from.Listeners, to.Listeners},
+	}
+
+	for _, res := range resources {
+		fromFields := make(map[string]map[string]string, len(res.from))
+		for name, data := range res.from {
+			fields, err := flattenJSON(data)
+			if err != nil {
+				return fmt.Errorf("cannot decode %s '%s': %w", res.kind, name, err)
+			}
+			fromFields[name] = fields
+		}
+
+		toFields := make(map[string]map[string]string, len(res.to))
+		for name, data := range res.to {
+			fields, err := flattenJSON(data)
+			if err != nil {
+				return fmt.Errorf("cannot decode %s '%s': %w", res.kind, name, err)
+			}
+			toFields[name] = fields
+		}
+
+		for _, name := range sortedUnion(fromFields, toFields) {
+			oldFields, inFrom := fromFields[name]
+			newFields, inTo := toFields[name]
+			switch {
+			case !inFrom:
+				fmt.Fprintf(w, "+ %s %s\n", res.kind, name)
+			case !inTo:
+				fmt.Fprintf(w, "- %s %s\n", res.kind, name)
+			default:
+				printFieldsDiff(w, res.kind+" "+name, oldFields, newFields)
+			}
+		}
+	}
+
+	fromNodes := make(map[string]map[string]string, len(from.Nodes))
+	for _, node := range from.Nodes {
+		fromNodes[node.ID] = nodeFields(&node)
+	}
+
+	toNodes := make(map[string]map[string]string, len(to.Nodes))
+	for _, node := range to.Nodes {
+		toNodes[node.ID] = nodeFields(&node)
+	}
+
+	for _, id := range sortedUnion(fromNodes, toNodes) {
+		oldFields, inFrom := fromNodes[id]
+		newFields, inTo := toNodes[id]
+		switch {
+		case !inFrom:
+			fmt.Fprintf(w, "+ node %s\n", id)
+		case !inTo:
+			fmt.Fprintf(w, "- node %s\n", id)
+		default:
+			printFieldsDiff(w, "node "+id, oldFields, newFields)
+		}
+	}
+
+	return nil
+}
+
+// printFieldsDiff prints the modified fields of an object, if any.
+func printFieldsDiff(w io.Writer, object string, from, to map[string]string) {
+	paths := sortedUnion(from, to)
+	header := false
+	for _, path := range paths {
+		oldValue, inFrom := from[path]
+		newValue, inTo := to[path]
+		if inFrom && inTo && oldValue == newValue {
+			continue
+		}
+
+		if !header {
+			fmt.Fprintf(w, "~ %s\n", object)
+			header = true
+		}
+
+		switch {
+		case !inFrom:
+			fmt.Fprintf(w, "    + %s: %s\n", path, newValue)
+		case !inTo:
+			fmt.Fprintf(w, "    - %s: %s\n", path, oldValue)
+		default:
+			fmt.Fprintf(w, "    ~ %s: %s -> %s\n", path, oldValue, newValue)
+		}
+	}
+}
+
+// nodeFields returns the compared fields of a dataplane xDS status.
+func nodeFields(node *api.XDSNodeStatus) map[string]string {
+	fields := make(map[string]string)
+	for name, status := range node.Resources {
+		fields[name+".ackedVersion"] = status.AckedVersion
+		if status.LastNACK != nil {
+			fields[name+".lastNACK"] = status.LastNACK.Version + ": " + status.LastNACK.Message
+		}
+	}
+
+	return fields
+}
+
+// flattenJSON maps the paths of all leaf values of a JSON document to their JSON encoding.
+func flattenJSON(data []byte) (map[string]string, error) {
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]string)
+	var flatten func(path string, value any)
+	flatten = func(path string, value any) {
+		switch v := value.(type) {
+		case map[string]any:
+			for key, child := range v {
+				if path == "" {
+					flatten(key, child)
+				} else {
+					flatten(path+"."+key, child)
+				}
+			}
+		case []any:
+			for i, child := range v {
+				flatten(path+"["+strconv.Itoa(i)+"]", child)
+			}
+		default:
+			encoded, _ := json.Marshal(v)
+			fields[path] = string(encoded)
+		}
+	}
+	flatten("", value)
+
+	return fields, nil
+}
+
+// sortedUnion returns the sorted union of the keys of two maps.
+func sortedUnion[V any](a, b map[string]V) []string {
+	keys := make([]string, 0, len(a)+len(b))
+	for key := range a {
+		keys = append(keys, key)
+	}
+	for key := range b {
+		if _, ok := a[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,161 @@
+// Copyright 2023 The ClusterLink Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package subcommand
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/clusterlink-net/clusterlink/pkg/controlplane/api"
+)
+
+func TestFlattenJSON(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		fields map[string]string
+	}{{
+		name:   "scalars",
+		data:   `{"name": "a", "port": 80, "enabled": true, "host": null}`,
+		fields: map[string]string{"name": `"a"`, "port": "80", "enabled": "true", "host": "null"},
+	}, {
+		name:   "nested objects",
+		data:   `{"address": {"socketAddress": {"portValue": 443}}}`,
+		fields: map[string]string{"address.socketAddress.portValue": "443"},
+	}, {
+		name: "arrays",
+		data: `{"filters": [{"name": "a"}, {"name": "b"}], "domains": ["*"]}`,
+		fields: map[string]string{
+			"filters[0].name": `"a"`,
+			"filters[1].name": `"b"`,
+			"domains[0]":      `"*"`,
+		},
+	}, {
+		name:   "empty object",
+		data:   `{}`,
+		fields: map[string]string{},
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fields, err := flattenJSON([]byte(tt.data))
+			require.Nil(t, err)
+			require.Equal(t, tt.fields, fields)
+		})
+	}
+
+	_, err := flattenJSON([]byte("{"))
+	require.NotNil(t, err)
+}
+
+func TestPrintFieldsDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to map[string]string
+		output   string
+	}{{
+		name:   "unchanged",
+		from:   map[string]string{"a": "1"},
+		to:     map[string]string{"a": "1"},
+		output: "",
+	}, {
+		name: "added, deleted and modified",
+		from: map[string]string{"a": "1", "b": "2", "c": "3"},
+		to:   map[string]string{"a": "1", "b": "4", "d": "5"},
+		output: "~ cluster c1\n" +
+			"    ~ b: 2 -> 4\n" +
+			"    - c: 3\n" +
+			"    + d: 5\n",
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			printFieldsDiff(&buf, "cluster c1", tt.from, tt.to)
+			require.Equal(t, tt.output, buf.String())
+		})
+	}
+}
+
+func TestDiffXDS(t *testing.T) {
+	resources := func(entries ...string) map[string]json.RawMessage {
+		m := make(map[string]json.RawMessage)
+		for i := 0; i < len(entries); i += 2 {
+			m[entries[i]] = json.RawMessage(entries[i+1])
+		}
+		return m
+	}
+
+	node := func(id, acked string, nack *api.XDSNACK) api.XDSNodeStatus {
+		return api.XDSNodeStatus{
+			ID: id,
+			Resources: map[string]api.XDSResourceStatus{
+				"cluster": {AckedVersion: acked, LastNACK: nack},
+			},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		from, to *api.XDSDump
+		output   string
+	}{{
+		name:   "identical",
+		from:   &api.XDSDump{Clusters: resources("c1", `{"name": "c1"}`)},
+		to:     &api.XDSDump{Clusters: resources("c1", `{"name": "c1"}`)},
+		output: "",
+	}, {
+		name: "resources",
+		from: &api.XDSDump{
+			Clusters:  resources("c1", `{"connectTimeout": "1s"}`, "c2", `{}`),
+			Listeners: resources("l1", `{}`),
+		},
+		to: &api.XDSDump{
+			Clusters:  resources("c1", `{"connectTimeout": "2s"}`, "c3", `{}`),
+			Endpoints: resources("c3", `{}`),
+		},
+		output: "~ cluster c1\n" +
+			`    ~ connectTimeout: "1s" -> "2s"` + "\n" +
+			"- cluster c2\n" +
+			"+ cluster c3\n" +
+			"+ endpoint c3\n" +
+			"- listener l1\n",
+	}, {
+		name: "nodes",
+		from: &api.XDSDump{Nodes: []api.XDSNodeStatus{node("dp1", "1", nil), node("dp2", "1", nil)}},
+		to: &api.XDSDump{Nodes: []api.XDSNodeStatus{
+			node("dp1", "1", &api.XDSNACK{Version: "2", Message: "invalid"}),
+			node("dp3", "2", nil),
+		}},
+		output: "~ node dp1\n" +
+			"    + cluster.lastNACK: 2: invalid\n" +
+			"- node dp2\n" +
+			"+ node dp3\n",
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.Nil(t, diffXDS(&buf, tt.from, tt.to))
+			require.Equal(t, tt.output, buf.String())
+		})
+	}
+
+	// malformed resources fail the diff
+	var buf bytes.Buffer
+	require.NotNil(t, diffXDS(&buf, &api.XDSDump{Clusters: resources("c1", "{")}, &api.XDSDump{}))
+}
@@ -77,6 +77,19 @@ type AccessPolicySpec struct {
 	From WorkloadSetOrSelectorList `json:"from"`
 	// To specifies the set of destination services to which this policy refers.
 	To WorkloadSetOrSelectorList `json:"to"`
+	// HTTPRequests optionally restricts the policy to HTTP requests (of services using the HTTP protocol)
+	// matching any of the given request matches.
+	// If empty, the policy applies to all connections and requests.
+	HTTPRequests []HTTPRequestMatch `json:"httpRequests,omitempty"`
+}
+
+// HTTPRequestMatch matches HTTP requests by their method and path.
+type HTTPRequestMatch struct {
+	// Methods matched by the request method. If empty, all methods are matched.
+	Methods []string `json:"methods,omitempty"`
+	// PathPrefix matched by the normalized request path, on a path segment boundary.
+	// If empty, all paths are matched.
+	PathPrefix string `json:"pathPrefix,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -32,6 +32,30 @@ type Export struct {
 	Status ExportStatus `json:"status,omitempty"`
 }
 
+// Protocol is the protocol of an exported or imported service.
+type Protocol string
+
+const (
+	ProtocolTCP Protocol = "TCP"
+	ProtocolUDP Protocol = "UDP"
+	// ProtocolHTTP is an HTTP service, routed per request (rather than per connection).
+	ProtocolHTTP Protocol = "HTTP"
+
+	ProtocolDefault = ProtocolTCP
+)
+
+// ExportPort is a named port of an exported service.
+type ExportPort struct {
+	// +kubebuilder:validation:MaxLength=15
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]*[a-z]([-a-z0-9]*[a-z0-9])?$`
+	// Name of the port, matching a port name of the imported services.
+	Name string `json:"name"`
+	// Port of the exported service.
+	Port uint16 `json:"port"`
+}
+
+// +kubebuilder:validation:XValidation:rule="!(has(self.port) && has(self.ports))",message="port and ports are mutually exclusive"
+
 // ExportSpec contains all attributes of an exported service.
 type ExportSpec struct {
 	// Host of the exported service.
@@ -39,7 +63,41 @@ type ExportSpec struct {
 	// name and namespace as the export object.
 	Host string `json:"host,omitempty"`
 	// Port of the exported service.
+	// Mutually exclusive with Ports.
 	Port uint16 `json:"port,omitempty"`
+	// +listType=map
+	// +listMapKey=name
+	// Ports are the named ports of a multi-port exported service.
+	// Mutually exclusive with Port.
+	Ports []ExportPort `json:"ports,omitempty"`
+	// +kubebuilder:validation:Enum=TCP;UDP;HTTP
+	// +kubebuilder:default="TCP"
+	// Protocol of the exported service (TCP, UDP or HTTP).
+	// If empty, TCP is used.
+	Protocol Protocol `json:"protocol,omitempty"`
+	// ConnectionLimits limit the connections of each remote peer to the exported service.
+	// MaxConnections is only supported by the Go dataplane.
+	ConnectionLimits *ConnectionLimits `json:"connectionLimits,omitempty"`
+}
+
+// ConnectionLimits limits the connections to a service.
+type ConnectionLimits struct {
+	// MaxConnections is the maximal number of concurrent connections.
+	// If zero, the number of connections is not limited.
+	MaxConnections uint32 `json:"maxConnections,omitempty"`
+	// ConnectionsPerSecond is the maximal rate of new connections.
+	// If zero, the rate of connections is not limited.
+	ConnectionsPerSecond uint32 `json:"connectionsPerSecond,omitempty"`
+}
+
+// ServicePorts returns the ports of the exported service.
+// A single-port exported service has a single unnamed port.
+func (s *ExportSpec) ServicePorts() []ExportPort {
+	if len(s.Ports) == 0 {
+		return []ExportPort{{Port: s.Port}}
+	}
+
+	return s.Ports
 }
 
 const (
@@ -53,18 +53,114 @@ const (
 	LBSchemeDefault = LBSchemeRoundRobin
 )
 
+// ImportPort is a named port of an imported service.
+type ImportPort struct {
+	// +kubebuilder:validation:MaxLength=15
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]*[a-z]([-a-z0-9]*[a-z0-9])?$`
+	// Name of the port, matching a port name of the exported services.
+	Name string `json:"name"`
+	// Port of the imported service.
+	Port uint16 `json:"port"`
+	// TargetPort of the imported service port.
+	// This is the internal (non user-facing) listening port used by the dataplane pods.
+	TargetPort uint16 `json:"targetPort,omitempty"`
+}
+
+// ImportHTTPRoute routes HTTP requests matching a path prefix and headers to a subset of the import sources.
+type ImportHTTPRoute struct {
+	// +kubebuilder:validation:MaxLength=63
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	// Name of the route.
+	Name string `json:"name"`
+	// PathPrefix matched by the request path. If empty, all paths are matched.
+	PathPrefix string `json:"pathPrefix,omitempty"`
+	// Headers are exact header values matched by the request.
+	Headers map[string]string `json:"headers,omitempty"`
+	// +kubebuilder:validation:MinItems=1
+	// Peers of the import sources serving matching requests.
+	Peers []string `json:"peers"`
+}
+
+// +kubebuilder:validation:XValidation:rule="has(self.port) != has(self.ports)",message="exactly one of port and ports must be set"
+// +kubebuilder:validation:XValidation:rule="!has(self.httpRoutes) || self.protocol == 'HTTP'",message="httpRoutes requires the HTTP protocol"
+// +kubebuilder:validation:XValidation:rule="!has(self.connectionLimits) || self.protocol != 'UDP'",message="connectionLimits are not supported for the UDP protocol"
+// +kubebuilder:validation:XValidation:rule="!has(self.authorizationCache) || !has(self.connectionLimits) || !has(self.connectionLimits.perSourceIP) || !has(self.connectionLimits.perSourceIP.connectionsPerSecond)",message="authorizationCache is not supported with a perSourceIP connectionsPerSecond limit"
+
 // ImportSpec contains all attributes of an imported service.
 type ImportSpec struct {
 	// Port of the imported service.
-	Port uint16 `json:"port"`
+	// Mutually exclusive with Ports.
+	Port uint16 `json:"port,omitempty"`
 	// TargetPort of the imported service.
 	// This is the internal (non user-facing) listening port used by the dataplane pods.
 	TargetPort uint16 `json:"targetPort,omitempty"`
+	// +listType=map
+	// +listMapKey=name
+	// Ports are the named ports of a multi-port imported service.
+	// Mutually exclusive with Port.
+	Ports []ImportPort `json:"ports,omitempty"`
 	// Sources to import from.
 	Sources []ImportSource `json:"sources"`
 	// +kubebuilder:default="round-robin"
 	// LBScheme is the load-balancing scheme to use (e.g., random, static, round-robin)
 	LBScheme LBScheme `json:"lbScheme"`
+	// +kubebuilder:validation:Enum=TCP;UDP;HTTP
+	// +kubebuilder:default="TCP"
+	// Protocol of the imported service (TCP, UDP or HTTP).
+	// Must match the protocol of the exported services. If empty, TCP is used.
+	Protocol Protocol `json:"protocol,omitempty"`
+	// HTTPRoutes route HTTP requests to subsets of the import sources, by the first matching route.
+	// Requests not matching any route are load-balanced between all sources.
+	// Applies only to the HTTP protocol.
+	HTTPRoutes []ImportHTTPRoute `json:"httpRoutes,omitempty"`
+	// ConnectionLimits limit the connections to each port of the imported service.
+	ConnectionLimits *ImportConnectionLimits `json:"connectionLimits,omitempty"`
+	// AuthorizationCache configures the caching of authorization results by the dataplanes,
+	// allowing new connections of known clients while the controlplane is unavailable.
+	// If not set, every connection (or HTTP request) is authorized by the controlplane.
+	// Cannot be combined with a PerSourceIP rate limit, which is enforced when authorizing with the controlplane.
+	AuthorizationCache *ImportAuthorizationCache `json:"authorizationCache,omitempty"`
+}
+
+// ImportAuthorizationCache configures the caching of the results of authorizing the connections (or HTTP requests)
+// of an imported service. Results are cached by the dataplanes per client (IP address or SPIFFE ID),
+// and reused for its new connections without authorizing them with the controlplane.
+// Only allowed results are cached. Cached results are bounded by the lifetime of their access token,
+// as granted by the peer of the exported service.
+type ImportAuthorizationCache struct {
+	// +kubebuilder:validation:Minimum=1
+	// TTLSeconds is the time an authorization result is reused for new connections of the same client.
+	TTLSeconds uint32 `json:"ttlSeconds"`
+	// StaleSeconds is the time an authorization result is still reused once its TTL passes,
+	// while the client is re-authorized in the background (stale-while-revalidate).
+	StaleSeconds uint32 `json:"staleSeconds,omitempty"`
+	// FailOpen keeps reusing an authorization result once its TTL passes (for up to FailOpenSeconds)
+	// if the controlplane is unavailable while re-authorizing the client. Otherwise, the result is dropped,
+	// and new connections of the client fail until the controlplane is available.
+	FailOpen bool `json:"failOpen,omitempty"`
+	// FailOpenSeconds is the time an authorization result is still reused once its TTL passes,
+	// if the controlplane is unavailable (stale-if-error). Applies only if FailOpen is set.
+	// If shorter than StaleSeconds, StaleSeconds is used.
+	FailOpenSeconds uint32 `json:"failOpenSeconds,omitempty"`
+}
+
+// ImportConnectionLimits limits the connections to an imported service, in total and per client.
+type ImportConnectionLimits struct {
+	// Total limits all connections to the imported service.
+	Total ConnectionLimits `json:"total,omitempty"`
+	// PerSourceIP limits the connections from each client IP address.
+	// MaxConnections is only supported by the Go dataplane.
+	PerSourceIP ConnectionLimits `json:"perSourceIP,omitempty"`
+}
+
+// ServicePorts returns the ports of the imported service.
+// A single-port imported service has a single unnamed port.
+func (s *ImportSpec) ServicePorts() []ImportPort {
+	if len(s.Ports) == 0 {
+		return []ImportPort{{Port: s.Port, TargetPort: s.TargetPort}}
+	}
+
+	return s.Ports
 }
 
 const (
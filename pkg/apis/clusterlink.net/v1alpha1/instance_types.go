@@ -25,6 +25,8 @@ const (
 	DeploymentReady StatusConditionType = "DeploymentReady"
 	// ServiceReady means the component service is ready to use.
 	ServiceReady StatusConditionType = "ServiceReady"
+	// DataplaneSynced means all dataplanes connected to the controlplane acknowledged their latest configuration.
+	DataplaneSynced StatusConditionType = "DataplaneSynced"
 )
 
 // IngressType represents the ingress type  of the deployed ClusterLink.
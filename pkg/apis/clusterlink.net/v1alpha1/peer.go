@@ -39,10 +39,52 @@ type Endpoint struct {
 	Port uint16 `json:"port"`
 }
 
+// +kubebuilder:validation:XValidation:rule="has(self.gateways) || has(self.gatewaySRV)",message="at least one of gateways and gatewaySRV must be set"
+
 // PeerSpec contains all peer attributes.
 type PeerSpec struct {
 	// Gateways serving the Peer.
-	Gateways []Endpoint `json:"gateways"`
+	Gateways []Endpoint `json:"gateways,omitempty"`
+	// GatewaySRV is a DNS SRV name (e.g., _clusterlink._tcp.peer1.example.com)
+	// whose records point to additional gateways serving the Peer.
+	// The SRV records are periodically re-resolved.
+	GatewaySRV string `json:"gatewaySRV,omitempty"`
+	// HealthCheck configures the detection of unhealthy peer gateways by the dataplanes.
+	// If not set, the default thresholds are used.
+	HealthCheck *PeerHealthCheck `json:"healthCheck,omitempty"`
+}
+
+// PeerHealthCheck configures the active health checks and outlier detection of the peer gateways.
+// Active health checks send heartbeat requests to each gateway, while outlier detection
+// tracks the failures of connections routed to each gateway.
+// Unhealthy gateways are not routed to, until they recover.
+type PeerHealthCheck struct {
+	// Disabled disables both active health checks and outlier detection.
+	Disabled bool `json:"disabled,omitempty"`
+	// IntervalSeconds is the interval between heartbeat requests to each gateway.
+	// If zero, defaults to 5 seconds.
+	IntervalSeconds uint32 `json:"intervalSeconds,omitempty"`
+	// TimeoutSeconds is the timeout of a heartbeat request.
+	// If zero, defaults to 2 seconds.
+	TimeoutSeconds uint32 `json:"timeoutSeconds,omitempty"`
+	// UnhealthyThreshold is the number of consecutive failed heartbeats marking a gateway unhealthy.
+	// If zero, defaults to 3.
+	UnhealthyThreshold uint32 `json:"unhealthyThreshold,omitempty"`
+	// HealthyThreshold is the number of consecutive successful heartbeats marking an unhealthy gateway healthy.
+	// If zero, defaults to 2.
+	HealthyThreshold uint32 `json:"healthyThreshold,omitempty"`
+	// ConsecutiveFailures is the number of consecutive failed connections ejecting a gateway.
+	// If zero, defaults to 5.
+	ConsecutiveFailures uint32 `json:"consecutiveFailures,omitempty"`
+	// BaseEjectionSeconds is the duration a gateway is first ejected for,
+	// multiplied by the number of times it was ejected.
+	// If zero, defaults to 30 seconds.
+	BaseEjectionSeconds uint32 `json:"baseEjectionSeconds,omitempty"`
+	// +kubebuilder:validation:Maximum=100
+	// MaxEjectionPercent is the maximal percentage of the peer gateways which may be ejected.
+	// At least one gateway may be ejected regardless of this value.
+	// If zero, defaults to 50.
+	MaxEjectionPercent uint32 `json:"maxEjectionPercent,omitempty"`
 }
 
 const (
@@ -97,6 +97,13 @@ func (in *AccessPolicySpec) DeepCopyInto(out *AccessPolicySpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.HTTPRequests != nil {
+		in, out := &in.HTTPRequests, &out.HTTPRequests
+		*out = make([]HTTPRequestMatch, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AccessPolicySpec.
@@ -131,6 +138,21 @@ func (in *ComponentStatus) DeepCopy() *ComponentStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConnectionLimits) DeepCopyInto(out *ConnectionLimits) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConnectionLimits.
+func (in *ConnectionLimits) DeepCopy() *ConnectionLimits {
+	if in == nil {
+		return nil
+	}
+	out := new(ConnectionLimits)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DataPlaneSpec) DeepCopyInto(out *DataPlaneSpec) {
 	*out = *in
@@ -166,7 +188,7 @@ func (in *Export) DeepCopyInto(out *Export) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
+	in.Spec.DeepCopyInto(&out.Spec)
 	in.Status.DeepCopyInto(&out.Status)
 }
 
@@ -220,9 +242,34 @@ func (in *ExportList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ExportPort) DeepCopyInto(out *ExportPort) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExportPort.
+func (in *ExportPort) DeepCopy() *ExportPort {
+	if in == nil {
+		return nil
+	}
+	out := new(ExportPort)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ExportSpec) DeepCopyInto(out *ExportSpec) {
 	*out = *in
+	if in.Ports != nil {
+		in, out := &in.Ports, &out.Ports
+		*out = make([]ExportPort, len(*in))
+		copy(*out, *in)
+	}
+	if in.ConnectionLimits != nil {
+		in, out := &in.ConnectionLimits, &out.ConnectionLimits
+		*out = new(ConnectionLimits)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExportSpec.
@@ -257,6 +304,26 @@ func (in *ExportStatus) DeepCopy() *ExportStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HTTPRequestMatch) DeepCopyInto(out *HTTPRequestMatch) {
+	*out = *in
+	if in.Methods != nil {
+		in, out := &in.Methods, &out.Methods
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HTTPRequestMatch.
+func (in *HTTPRequestMatch) DeepCopy() *HTTPRequestMatch {
+	if in == nil {
+		return nil
+	}
+	out := new(HTTPRequestMatch)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Import) DeepCopyInto(out *Import) {
 	*out = *in
@@ -284,6 +351,65 @@ func (in *Import) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImportAuthorizationCache) DeepCopyInto(out *ImportAuthorizationCache) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImportAuthorizationCache.
+func (in *ImportAuthorizationCache) DeepCopy() *ImportAuthorizationCache {
+	if in == nil {
+		return nil
+	}
+	out := new(ImportAuthorizationCache)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImportConnectionLimits) DeepCopyInto(out *ImportConnectionLimits) {
+	*out = *in
+	out.Total = in.Total
+	out.PerSourceIP = in.PerSourceIP
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImportConnectionLimits.
+func (in *ImportConnectionLimits) DeepCopy() *ImportConnectionLimits {
+	if in == nil {
+		return nil
+	}
+	out := new(ImportConnectionLimits)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImportHTTPRoute) DeepCopyInto(out *ImportHTTPRoute) {
+	*out = *in
+	if in.Headers != nil {
+		in, out := &in.Headers, &out.Headers
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Peers != nil {
+		in, out := &in.Peers, &out.Peers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImportHTTPRoute.
+func (in *ImportHTTPRoute) DeepCopy() *ImportHTTPRoute {
+	if in == nil {
+		return nil
+	}
+	out := new(ImportHTTPRoute)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ImportList) DeepCopyInto(out *ImportList) {
 	*out = *in
@@ -316,6 +442,21 @@ func (in *ImportList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImportPort) DeepCopyInto(out *ImportPort) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImportPort.
+func (in *ImportPort) DeepCopy() *ImportPort {
+	if in == nil {
+		return nil
+	}
+	out := new(ImportPort)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ImportSource) DeepCopyInto(out *ImportSource) {
 	*out = *in
@@ -334,11 +475,33 @@ func (in *ImportSource) DeepCopy() *ImportSource {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ImportSpec) DeepCopyInto(out *ImportSpec) {
 	*out = *in
+	if in.Ports != nil {
+		in, out := &in.Ports, &out.Ports
+		*out = make([]ImportPort, len(*in))
+		copy(*out, *in)
+	}
 	if in.Sources != nil {
 		in, out := &in.Sources, &out.Sources
 		*out = make([]ImportSource, len(*in))
 		copy(*out, *in)
 	}
+	if in.HTTPRoutes != nil {
+		in, out := &in.HTTPRoutes, &out.HTTPRoutes
+		*out = make([]ImportHTTPRoute, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ConnectionLimits != nil {
+		in, out := &in.ConnectionLimits, &out.ConnectionLimits
+		*out = new(ImportConnectionLimits)
+		**out = **in
+	}
+	if in.AuthorizationCache != nil {
+		in, out := &in.AuthorizationCache, &out.AuthorizationCache
+		*out = new(ImportAuthorizationCache)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImportSpec.
@@ -538,6 +701,21 @@ func (in *Peer) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PeerHealthCheck) DeepCopyInto(out *PeerHealthCheck) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PeerHealthCheck.
+func (in *PeerHealthCheck) DeepCopy() *PeerHealthCheck {
+	if in == nil {
+		return nil
+	}
+	out := new(PeerHealthCheck)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PeerList) DeepCopyInto(out *PeerList) {
 	*out = *in
@@ -578,6 +756,11 @@ func (in *PeerSpec) DeepCopyInto(out *PeerSpec) {
 		*out = make([]Endpoint, len(*in))
 		copy(*out, *in)
 	}
+	if in.HealthCheck != nil {
+		in, out := &in.HealthCheck, &out.HealthCheck
+		*out = new(PeerHealthCheck)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PeerSpec.
@@ -14,6 +14,7 @@
 package bootstrap
 
 import (
+	"net/url"
 	"os"
 	"path/filepath"
 
@@ -77,6 +78,7 @@ func CreateControlplaneCertificate(peer string, peerCert *Certificate) (*Certifi
 		IsServer: true,
 		IsClient: true,
 		DNSNames: []string{peer, api.GRPCServerName(peer)},
+		URIs:     []*url.URL{api.RoleURI(api.RoleControlplane)},
 	})
 	if err != nil {
 		return nil, err
@@ -93,6 +95,7 @@ func CreateDataplaneCertificate(peer string, peerCert *Certificate) (*Certificat
 		IsServer: true,
 		IsClient: true,
 		DNSNames: []string{dpapi.DataplaneServerName(peer)},
+		URIs:     []*url.URL{api.RoleURI(api.RoleDataplane)},
 	})
 	if err != nil {
 		return nil, err
@@ -103,10 +106,29 @@ func CreateDataplaneCertificate(peer string, peerCert *Certificate) (*Certificat
 
 // CreatePeerCertificate creates a gwctl certificate.
 func CreateGWCTLCertificate(peerCert *Certificate) (*Certificate, error) {
+	return CreateGWCTLUserCertificate("gwctl", []api.UserRole{api.UserRoleAdmin}, nil, peerCert)
+}
+
+// CreateGWCTLUserCertificate creates a gwctl certificate for a specific user.
+// The user is granted the given roles, restricted to the given namespaces (if any).
+func CreateGWCTLUserCertificate(
+	user string,
+	roles []api.UserRole,
+	namespaces []string,
+	peerCert *Certificate,
+) (*Certificate, error) {
+	organizations := make([]string, len(roles))
+	for i, role := range roles {
+		organizations[i] = string(role)
+	}
+
 	cert, err := createCertificate(&certificateConfig{
-		Parent:   peerCert.cert,
-		Name:     "gwctl",
-		IsClient: true,
+		Parent:              peerCert.cert,
+		Name:                user,
+		IsClient:            true,
+		URIs:                []*url.URL{api.RoleURI(api.RoleGWCTL)},
+		Organizations:       organizations,
+		OrganizationalUnits: namespaces,
 	})
 	if err != nil {
 		return nil, err
@@ -23,6 +23,7 @@ import (
 	"fmt"
 	"math/big"
 	mathrand "math/rand"
+	"net/url"
 	"time"
 )
 
@@ -50,6 +51,12 @@ type certificateConfig struct {
 	// DNSNames are the DNS names to be set in the certificate.
 	// For a CA certificate, these are the permitted DNS names.
 	DNSNames []string
+	// URIs are the URIs to be set in the certificate.
+	URIs []*url.URL
+	// Organizations are the organizations to be set in the certificate subject.
+	Organizations []string
+	// OrganizationalUnits are the organizational units to be set in the certificate subject.
+	OrganizationalUnits []string
 
 	// Parent certificate that will sign the certificate.
 	// If nil, certificate will self-sign.
@@ -74,7 +81,11 @@ func createCertificate(config *certificateConfig) (*certificate, error) {
 		NotBefore:    time.Now(),
 		NotAfter:     time.Now().AddDate(10, 0, 0),
 		IsCA:         config.IsCA,
-		Subject:      pkix.Name{CommonName: config.Name},
+		Subject: pkix.Name{
+			CommonName:         config.Name,
+			Organization:       config.Organizations,
+			OrganizationalUnit: config.OrganizationalUnits,
+		},
 	}
 
 	if config.IsCA {
@@ -83,6 +94,7 @@ func createCertificate(config *certificateConfig) (*certificate, error) {
 		cert.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign
 	} else {
 		cert.DNSNames = config.DNSNames
+		cert.URIs = config.URIs
 	}
 
 	if config.IsServer {
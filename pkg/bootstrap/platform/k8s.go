@@ -113,6 +113,15 @@ spec:
         - name: tls
           secret:
             secretName: cl-controlplane
+{{ if (eq .dataplaneType .dataplaneTypeEnvoy) }}
+        - name: dataplane-tls
+          projected:
+            sources:
+              - secret:
+                  name: cl-fabric
+              - secret:
+                  name: cl-dataplane
+{{ end }}
 {{ if not .crdMode }}
         - name: cl-controlplane
           persistentVolumeClaim:
@@ -121,7 +130,7 @@ spec:
       containers:
         - name: cl-controlplane
           image: {{.containerRegistry}}cl-controlplane:{{.tag}}
-          args: ["--log-level", "{{.logLevel}}"{{if .crdMode }}, "--crd-mode"{{ end }}]
+          args: ["--log-level", "{{.logLevel}}", "--dataplane", "{{.dataplaneType}}"{{if .crdMode }}, "--crd-mode"{{ end }}]
           imagePullPolicy: IfNotPresent
           ports:
             - containerPort: {{.controlplanePort}}
@@ -138,6 +147,11 @@ spec:
               mountPath: {{.controlplaneKeyMountPath}}
               subPath: "key"
               readOnly: true
+{{ if (eq .dataplaneType .dataplaneTypeEnvoy) }}
+            - name: dataplane-tls
+              mountPath: {{.controlplaneDataplaneCertMountPath}}
+              readOnly: true
+{{ end }}
 {{ if not .crdMode }}
             - name: cl-controlplane
               mountPath: {{.persistencyDirectoryMountPath}}
@@ -177,10 +191,16 @@ spec:
           image: {{.containerRegistry}}{{
           if (eq .dataplaneType .dataplaneTypeEnvoy) }}cl-dataplane{{
           else }}cl-go-dataplane{{ end }}:{{.tag}}
-          args: ["--log-level", "{{.logLevel}}", "--controlplane-host", "cl-controlplane"]
+          args: ["--log-level", "{{.logLevel}}", "--controlplane-host", "cl-controlplane",
+                 "--listen-addresses", "$({{.podIPsEnvVariable}})"]
           imagePullPolicy: IfNotPresent
           ports:
             - containerPort: {{.dataplanePort}}
+          env:
+            - name: {{ .podIPsEnvVariable }}
+              valueFrom:
+                fieldRef:
+                  fieldPath: status.podIPs
           volumeMounts:
             - name: ca
               mountPath: {{.dataplaneCAMountPath}}
@@ -280,6 +300,9 @@ rules:
 - apiGroups: [""]
   resources: ["pods"]
   verbs: ["get", "list", "watch"]
+- apiGroups: [""]
+  resources: ["configmaps"]
+  verbs: ["get", "create", "update"]
 {{ if .crdMode }}
 - apiGroups: ["clusterlink.net"]
   resources: ["exports", "peers", "accesspolicies", "privilegedaccesspolicies"]
@@ -357,9 +380,12 @@ func K8SConfig(config *Config) ([]byte, error) {
 		"controlplaneCertMountPath": cpapp.CertificateFile,
 		"controlplaneKeyMountPath":  cpapp.KeyFile,
 
+		"controlplaneDataplaneCertMountPath": cpapp.DataplaneCertificateDirectory,
+
 		"dataplaneCAMountPath":   dpapp.CAFile,
 		"dataplaneCertMountPath": dpapp.CertificateFile,
 		"dataplaneKeyMountPath":  dpapp.KeyFile,
+		"podIPsEnvVariable":      dpapp.PodIPsEnvVariable,
 
 		"controlplanePort": cpapi.ListenPort,
 		"dataplanePort":    dpapi.ListenPort,
@@ -16,8 +16,11 @@ package client
 import (
 	"crypto/tls"
 	"encoding/json"
+	"fmt"
+	"net/http"
 
 	"github.com/clusterlink-net/clusterlink/pkg/apis/clusterlink.net/v1alpha1"
+	"github.com/clusterlink-net/clusterlink/pkg/controlplane/api"
 	event "github.com/clusterlink-net/clusterlink/pkg/controlplane/eventmanager"
 	"github.com/clusterlink-net/clusterlink/pkg/util/jsonapi"
 	"github.com/clusterlink-net/clusterlink/pkg/util/rest"
@@ -82,3 +85,23 @@ func (c *Client) GetMetrics() (map[string]event.ConnectionStatusAttr, error) {
 	}
 	return connections, nil
 }
+
+// GetXDS returns a dump of the xDS configuration served by the controlplane.
+func (c *Client) GetXDS() (*api.XDSDump, error) {
+	resp, err := c.client.Get(api.XDSDumpPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get xDS dump: %w", err)
+	}
+
+	if resp.Status != http.StatusOK {
+		return nil, fmt.Errorf("unable to get xDS dump (%d), server returned: %s",
+			resp.Status, resp.Body)
+	}
+
+	var dump api.XDSDump
+	if err := json.Unmarshal(resp.Body, &dump); err != nil {
+		return nil, fmt.Errorf("unable to decode xDS dump: %w", err)
+	}
+
+	return &dump, nil
+}
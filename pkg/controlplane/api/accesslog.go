@@ -0,0 +1,41 @@
+// Copyright 2023 The ClusterLink Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import "fmt"
+
+// AccessLogMode is the destination of dataplane access logs.
+type AccessLogMode string
+
+const (
+	// AccessLogNone disables access logs.
+	AccessLogNone AccessLogMode = "none"
+	// AccessLogJSON writes access logs to the dataplane stdout, one JSON object per line.
+	AccessLogJSON AccessLogMode = "json"
+	// AccessLogGRPC streams access logs to the controlplane gRPC access log service.
+	AccessLogGRPC AccessLogMode = "grpc"
+
+	// AccessLogName is the log name of access logs streamed to the controlplane.
+	AccessLogName = "clusterlink"
+)
+
+// ParseAccessLogMode parses an access log mode name.
+func ParseAccessLogMode(name string) (AccessLogMode, error) {
+	switch mode := AccessLogMode(name); mode {
+	case AccessLogNone, AccessLogJSON, AccessLogGRPC:
+		return mode, nil
+	default:
+		return AccessLogNone, fmt.Errorf("unknown access log mode '%s'", name)
+	}
+}
@@ -13,7 +13,12 @@
 
 package api
 
-import "github.com/lestrrat-go/jwx/jwa"
+import (
+	"path"
+	"strings"
+
+	"github.com/lestrrat-go/jwx/jwa"
+)
 
 const (
 	// RemotePeerAuthorizationPath is the path remote peers use to send an authorization request.
@@ -27,14 +32,31 @@ const (
 	ImportNameHeader = "x-import-name"
 	// ImportNamespaceHeader holds the namespace of the imported service.
 	ImportNamespaceHeader = "x-import-namespace"
+	// ImportPortHeader holds the port name of a multi-port imported service.
+	ImportPortHeader = "x-import-port"
 	// ClientIPHeader holds the IP address of the source client.
 	ClientIPHeader = "x-client-ip"
+	// ClientSPIFFEIDHeader holds the SPIFFE ID of the source client, if authenticated using workload mTLS.
+	ClientSPIFFEIDHeader = "x-client-spiffe-id"
+	// HTTPModeHeader marks a request of an HTTP service, which is authorized and routed per request.
+	HTTPModeHeader = "x-clusterlink-http"
+	// HTTPRouteHeader holds the name of the import HTTP route matched by the request.
+	HTTPRouteHeader = "x-import-http-route"
+	// ConnectionIDHeader holds a unique ID of a client connection (or HTTP request) of an imported service,
+	// propagated to the remote peer for correlating access logs and traces of both peers.
+	ConnectionIDHeader = "x-clusterlink-connection-id"
 
 	// AuthorizationHeader holds a signed token allowing ingress connections to access the dataplane.
 	AuthorizationHeader = "authorization"
+	// HTTPAuthorizationHeader holds a signed token allowing an ingress HTTP request to access the dataplane.
+	// It is separate from AuthorizationHeader, which is left to the application.
+	HTTPAuthorizationHeader = "x-clusterlink-authorization"
 
 	// TargetClusterHeader holds the name of the target cluster.
 	TargetClusterHeader = "host"
+	// AuthorizationCacheHeader holds the caching policy of an egress authorization result,
+	// using the max-age, stale-while-revalidate and stale-if-error directives of Cache-Control.
+	AuthorizationCacheHeader = "cache-control"
 
 	// JWTSignatureAlgorithm defines the signing algorithm for JWT tokens.
 	JWTSignatureAlgorithm = jwa.RS256
@@ -42,6 +64,19 @@ const (
 	ExportNameJWTClaim = "export_name"
 	// ExportNamespaceJWTClaim holds the namespace of the requested exported service.
 	ExportNamespaceJWTClaim = "export_namespace"
+	// ExportPortJWTClaim holds the port name of the requested multi-port exported service.
+	ExportPortJWTClaim = "export_port"
+	// ExportProtocolJWTClaim holds the protocol of the requested exported service.
+	ExportProtocolJWTClaim = "export_protocol"
+	// HTTPMethodJWTClaim holds the method of the authorized HTTP request.
+	HTTPMethodJWTClaim = "http_method"
+	// HTTPPathJWTClaim holds the path of the authorized HTTP request.
+	HTTPPathJWTClaim = "http_path"
+	// PeerJWTClaim holds the name of the remote peer which was issued the token.
+	PeerJWTClaim = "peer"
+	// ConnectionsPerSecondJWTClaim holds the maximal rate of connections of the remote peer
+	// to the exported service, if limited.
+	ConnectionsPerSecondJWTClaim = "connections_per_second"
 )
 
 // AuthorizationRequest represents an authorization request for accessing an exported service.
@@ -50,10 +85,34 @@ type AuthorizationRequest struct {
 	ServiceName string
 	// ServiceNamespace is the namespace of the requested exported service.
 	ServiceNamespace string
+	// ServicePort is the port name of the requested multi-port exported service.
+	ServicePort string
+	// HTTPMethod is the method of the requested HTTP request, if the service uses the HTTP protocol.
+	HTTPMethod string
+	// HTTPPath is the path of the requested HTTP request, if the service uses the HTTP protocol.
+	HTTPPath string
+	// TokenLifetimeSeconds is the requested lifetime of the access token.
+	// If zero, the default lifetime is used.
+	TokenLifetimeSeconds uint32 `json:",omitempty"`
 }
 
 // AuthorizationResponse represents a response for a successful AuthorizationRequest.
 type AuthorizationResponse struct {
 	// AccessToken holds an access token which can be used to access the requested exported service.
 	AccessToken string
+	// TokenLifetimeSeconds is the lifetime of the access token, which may be shorter than requested.
+	// If zero, the default lifetime was used.
+	TokenLifetimeSeconds uint32 `json:",omitempty"`
+}
+
+// NormalizeHTTPPath returns the normalized form of a (decoded) HTTP request path, by which the request is authorized.
+// Dot segments are resolved and repeated slashes are merged, while a trailing slash is kept.
+// Requests are forwarded to exported services with their normalized path, so that it matches the authorized one.
+func NormalizeHTTPPath(p string) string {
+	normalized := path.Clean("/" + p)
+	if strings.HasSuffix(p, "/") && normalized != "/" {
+		normalized += "/"
+	}
+
+	return normalized
 }
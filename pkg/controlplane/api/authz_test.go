@@ -0,0 +1,45 @@
+// Copyright 2023 The ClusterLink Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/clusterlink-net/clusterlink/pkg/controlplane/api"
+)
+
+func TestNormalizeHTTPPath(t *testing.T) {
+	tests := []struct {
+		path       string
+		normalized string
+	}{
+		{path: "", normalized: "/"},
+		{path: "/", normalized: "/"},
+		{path: "/public/index.html", normalized: "/public/index.html"},
+		{path: "/public/", normalized: "/public/"},
+		{path: "/public/../admin", normalized: "/admin"},
+		{path: "/public/./../../admin/", normalized: "/admin/"},
+		{path: "//admin", normalized: "/admin"},
+		{path: "/public//admin", normalized: "/public/admin"},
+		{path: "admin", normalized: "/admin"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			require.Equal(t, tt.normalized, api.NormalizeHTTPPath(tt.path))
+		})
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2023 The ClusterLink Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	// XDSDumpPath is the controlplane path for getting a dump of the xDS configuration pushed to dataplanes.
+	XDSDumpPath = "/debug/xds"
+
+	// XDSStatusConfigMapName is the name of the ConfigMap (in the controlplane namespace)
+	// to which the controlplane publishes the xDS status of the connected dataplanes.
+	XDSStatusConfigMapName = "cl-controlplane-xds-status"
+	// XDSStatusConfigMapKey is the XDSStatusConfigMapName key holding the JSON-encoded list of XDSNodeStatus.
+	XDSStatusConfigMapKey = "nodes"
+)
+
+// XDSDump is a dump of the xDS configuration served by the controlplane.
+type XDSDump struct {
+	// Clusters maps cluster names to their JSON-encoded Envoy configuration.
+	Clusters map[string]json.RawMessage `json:"clusters"`
+	// Endpoints maps cluster names to their JSON-encoded Envoy ClusterLoadAssignment.
+	Endpoints map[string]json.RawMessage `json:"endpoints"`
+	// Listeners maps listener names to their JSON-encoded Envoy configuration.
+	Listeners map[string]json.RawMessage `json:"listeners"`
+	// Nodes is the xDS status of the connected dataplanes.
+	Nodes []XDSNodeStatus `json:"nodes"`
+}
+
+// XDSNodeStatus is the xDS status of a single dataplane.
+type XDSNodeStatus struct {
+	// ID is the node ID of the dataplane.
+	ID string `json:"id"`
+	// ConnectedSince is the time the (earliest) xDS stream of the dataplane was opened.
+	ConnectedSince time.Time `json:"connectedSince"`
+	// Resources maps resource types (cluster, endpoint, listener) to their status.
+	Resources map[string]XDSResourceStatus `json:"resources"`
+}
+
+// XDSResourceStatus is the status of a single resource type subscribed by a dataplane.
+type XDSResourceStatus struct {
+	// SentVersion is the last configuration version sent to the dataplane.
+	SentVersion string `json:"sentVersion,omitempty"`
+	// AckedVersion is the last configuration version acknowledged by the dataplane.
+	AckedVersion string `json:"ackedVersion,omitempty"`
+	// AckTime is the time of the last acknowledgement.
+	AckTime time.Time `json:"ackTime"`
+	// LastNACK is the last configuration rejected by the dataplane, if any.
+	LastNACK *XDSNACK `json:"lastNACK,omitempty"`
+}
+
+// XDSNACK describes a configuration rejected by a dataplane.
+type XDSNACK struct {
+	// Version is the rejected configuration version.
+	Version string `json:"version"`
+	// Message is the error reported by the dataplane.
+	Message string `json:"message"`
+	// Time is the time of the rejection.
+	Time time.Time `json:"time"`
+}
+
+// Synced returns true if the dataplane acknowledged the last configuration version sent to it.
+func (s *XDSResourceStatus) Synced() bool {
+	return s.SentVersion != "" && s.AckedVersion == s.SentVersion
+}
+
+// Rejected returns true if the dataplane rejected the last configuration version sent to it.
+func (s *XDSResourceStatus) Rejected() bool {
+	return s.LastNACK != nil && s.LastNACK.Version == s.SentVersion
+}
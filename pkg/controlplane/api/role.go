@@ -0,0 +1,190 @@
+// Copyright 2023 The ClusterLink Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+
+	dpapi "github.com/clusterlink-net/clusterlink/pkg/dataplane/api"
+	"github.com/clusterlink-net/clusterlink/pkg/util/tls"
+)
+
+// Role is the role of a clusterlink component, as identified by its certificate.
+type Role string
+
+const (
+	// RoleUnknown is the role of a certificate which cannot be associated with any clusterlink component.
+	RoleUnknown Role = ""
+	// RoleControlplane is the role of the local peer controlplane.
+	RoleControlplane Role = "controlplane"
+	// RoleDataplane is the role of a local peer dataplane.
+	RoleDataplane Role = "dataplane"
+	// RoleGWCTL is the role of a local peer gwctl (management) client.
+	RoleGWCTL Role = "gwctl"
+	// RoleRemotePeer is the role of a controlplane of a remote peer.
+	// It is never encoded in a certificate, but rather derived from a controlplane
+	// certificate issued by a different peer.
+	RoleRemotePeer Role = "remote-peer"
+	// RoleRemoteDataplane is the role of a dataplane of a remote peer, health checking the local peer gateways.
+	// It is never encoded in a certificate, but rather derived from a dataplane
+	// certificate issued by a different peer.
+	RoleRemoteDataplane Role = "remote-dataplane"
+
+	// roleURIScheme and roleURIHost define the certificate URI SAN encoding a role,
+	// which is of the form clusterlink://role/<role>.
+	roleURIScheme = "clusterlink"
+	roleURIHost   = "role"
+
+	// gwctlCommonName is the common name used by (legacy) gwctl certificates.
+	gwctlCommonName = "gwctl"
+)
+
+// RoleURI returns the certificate URI SAN encoding the given role.
+func RoleURI(role Role) *url.URL {
+	return &url.URL{
+		Scheme: roleURIScheme,
+		Host:   roleURIHost,
+		Path:   "/" + string(role),
+	}
+}
+
+// CertificateRole returns the role encoded in a certificate.
+// Certificates which do not encode a role are classified according to their DNS names.
+func CertificateRole(cert *x509.Certificate) Role {
+	for _, uri := range cert.URIs {
+		if uri.Scheme != roleURIScheme || uri.Host != roleURIHost {
+			continue
+		}
+
+		switch role := Role(strings.TrimPrefix(uri.Path, "/")); role {
+		case RoleControlplane, RoleDataplane, RoleGWCTL:
+			return role
+		default:
+			return RoleUnknown
+		}
+	}
+
+	// certificates created before roles were encoded
+	switch {
+	case len(cert.DNSNames) == 2 && cert.DNSNames[1] == GRPCServerName(cert.DNSNames[0]):
+		return RoleControlplane
+	case len(cert.DNSNames) == 1:
+		if _, err := dpapi.StripServerPrefix(cert.DNSNames[0]); err == nil {
+			return RoleDataplane
+		}
+	case len(cert.DNSNames) == 0 && cert.Subject.CommonName == gwctlCommonName:
+		return RoleGWCTL
+	}
+
+	return RoleUnknown
+}
+
+// RequestRole returns the role of the client issuing an HTTP request.
+// Only certificates issued by the local peer are classified as local components.
+// A controlplane certificate issued by any other peer is classified as a remote peer,
+// and a dataplane certificate issued by any other peer is classified as a remote dataplane.
+func RequestRole(r *http.Request, localTLS *tls.ParsedCertData) Role {
+	if r.TLS == nil {
+		return RoleUnknown
+	}
+
+	return chainsRole(r.TLS.VerifiedChains, localTLS)
+}
+
+// ContextRole returns the role of the client issuing a gRPC call, classified as in RequestRole.
+func ContextRole(ctx context.Context, localTLS *tls.ParsedCertData) Role {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return RoleUnknown
+	}
+
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok {
+		return RoleUnknown
+	}
+
+	return chainsRole(info.State.VerifiedChains, localTLS)
+}
+
+// chainsRole returns the role of a client, given its verified certificate chains.
+func chainsRole(chains [][]*x509.Certificate, localTLS *tls.ParsedCertData) Role {
+	if len(chains) == 0 {
+		return RoleUnknown
+	}
+
+	chain := chains[0]
+	role := CertificateRole(chain[0])
+	if localTLS.IssuedBySameCA(chain) {
+		return role
+	}
+
+	switch role {
+	case RoleControlplane:
+		return RoleRemotePeer
+	case RoleDataplane:
+		return RoleRemoteDataplane
+	}
+
+	return RoleUnknown
+}
+
+// UserRole is the role of a gwctl user, determining the management operations allowed for the user.
+// User roles are encoded as the organizations of a gwctl certificate subject.
+type UserRole string
+
+const (
+	// UserRoleAdmin allows all management operations.
+	UserRoleAdmin UserRole = "admin"
+	// UserRolePolicyEditor allows reading all objects, and managing access policies.
+	UserRolePolicyEditor UserRole = "policy-editor"
+	// UserRoleReadOnly allows reading all objects.
+	UserRoleReadOnly UserRole = "read-only"
+)
+
+// ParseUserRole parses a user role name.
+func ParseUserRole(name string) (UserRole, error) {
+	switch role := UserRole(name); role {
+	case UserRoleAdmin, UserRolePolicyEditor, UserRoleReadOnly:
+		return role, nil
+	default:
+		return "", fmt.Errorf("unknown user role '%s'", name)
+	}
+}
+
+// UserRoles returns the user roles encoded in a gwctl certificate, and the namespaces they are restricted to.
+// An empty list of namespaces means the roles are not restricted to any namespace.
+// Unknown roles are ignored.
+// gwctl certificates created before user roles were encoded are granted the admin role.
+func UserRoles(cert *x509.Certificate) ([]UserRole, []string) {
+	if len(cert.Subject.Organization) == 0 {
+		return []UserRole{UserRoleAdmin}, nil
+	}
+
+	var roles []UserRole
+	for _, name := range cert.Subject.Organization {
+		if role, err := ParseUserRole(name); err == nil {
+			roles = append(roles, role)
+		}
+	}
+
+	return roles, cert.Subject.OrganizationalUnit
+}
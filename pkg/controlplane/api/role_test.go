@@ -0,0 +1,100 @@
+// Copyright 2023 The ClusterLink Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api_test
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/clusterlink-net/clusterlink/pkg/controlplane/api"
+	dpapi "github.com/clusterlink-net/clusterlink/pkg/dataplane/api"
+)
+
+func TestCertificateRole(t *testing.T) {
+	spiffeURI := &url.URL{Scheme: "spiffe", Host: "cluster.local", Path: "/ns/default/sa/client"}
+
+	tests := []struct {
+		name string
+		cert *x509.Certificate
+		role api.Role
+	}{{
+		name: "controlplane",
+		cert: &x509.Certificate{URIs: []*url.URL{api.RoleURI(api.RoleControlplane)}},
+		role: api.RoleControlplane,
+	}, {
+		name: "dataplane",
+		cert: &x509.Certificate{URIs: []*url.URL{api.RoleURI(api.RoleDataplane)}},
+		role: api.RoleDataplane,
+	}, {
+		name: "gwctl",
+		cert: &x509.Certificate{URIs: []*url.URL{spiffeURI, api.RoleURI(api.RoleGWCTL)}},
+		role: api.RoleGWCTL,
+	}, {
+		name: "derived role encoded",
+		cert: &x509.Certificate{URIs: []*url.URL{api.RoleURI(api.RoleRemotePeer)}},
+		role: api.RoleUnknown,
+	}, {
+		name: "unknown role encoded",
+		cert: &x509.Certificate{URIs: []*url.URL{api.RoleURI("admin")}},
+		role: api.RoleUnknown,
+	}, {
+		name: "encoded role overrides DNS names",
+		cert: &x509.Certificate{
+			URIs:     []*url.URL{api.RoleURI(api.RoleGWCTL)},
+			DNSNames: []string{dpapi.DataplaneServerName("peer1")},
+		},
+		role: api.RoleGWCTL,
+	}, {
+		name: "legacy controlplane",
+		cert: &x509.Certificate{DNSNames: []string{"peer1", api.GRPCServerName("peer1")}},
+		role: api.RoleControlplane,
+	}, {
+		name: "legacy controlplane of another peer name",
+		cert: &x509.Certificate{DNSNames: []string{"peer1", api.GRPCServerName("peer2")}},
+		role: api.RoleUnknown,
+	}, {
+		name: "legacy dataplane",
+		cert: &x509.Certificate{
+			URIs:     []*url.URL{spiffeURI},
+			DNSNames: []string{dpapi.DataplaneServerName("peer1")},
+		},
+		role: api.RoleDataplane,
+	}, {
+		name: "legacy gwctl",
+		cert: &x509.Certificate{Subject: pkix.Name{CommonName: "gwctl"}},
+		role: api.RoleGWCTL,
+	}, {
+		name: "legacy gwctl with DNS names",
+		cert: &x509.Certificate{Subject: pkix.Name{CommonName: "gwctl"}, DNSNames: []string{"gwctl"}},
+		role: api.RoleUnknown,
+	}, {
+		name: "legacy unknown DNS name",
+		cert: &x509.Certificate{DNSNames: []string{"peer1"}},
+		role: api.RoleUnknown,
+	}, {
+		name: "legacy unknown common name",
+		cert: &x509.Certificate{Subject: pkix.Name{CommonName: "user"}},
+		role: api.RoleUnknown,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.Equal(t, test.role, api.CertificateRole(test.cert))
+		})
+	}
+}
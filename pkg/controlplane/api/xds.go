@@ -28,6 +28,8 @@ const (
 	ExportClusterPrefix = "export-"
 	// RemotePeerClusterPrefix is the prefix of clusters representing remote peers.
 	RemotePeerClusterPrefix = "remote-peer-"
+	// TracingCollectorCluster is the cluster name of the OpenTelemetry collector receiving dataplane traces.
+	TracingCollectorCluster = "tracing-collector"
 
 	// listener names.
 
@@ -41,10 +43,40 @@ const (
 	// secret names.
 
 	// ValidationSecret is the secret name of the dataplane certificate validation context
-	// (which includes the CA certificate).
+	// (which includes the CA certificate), served by the controlplane over SDS.
 	ValidationSecret = "validation"
-	// CertificateSecret is the secret name of the dataplane certificate.
+	// CertificateSecret is the secret name of the dataplane certificate, served by the controlplane over SDS.
 	CertificateSecret = "certificate"
+	// BootstrapValidationSecret is the secret name of the validation context used by the dataplane
+	// to connect to the controlplane, loaded from files on dataplane startup.
+	BootstrapValidationSecret = "bootstrap-validation"
+	// BootstrapCertificateSecret is the secret name of the certificate used by the dataplane
+	// to connect to the controlplane, loaded from files on dataplane startup.
+	BootstrapCertificateSecret = "bootstrap-certificate"
+	// WorkloadValidationSecret is the secret name of the validation context for workload SVIDs
+	// (which includes the SPIFFE trust bundle).
+	WorkloadValidationSecret = "workload-validation"
+	// WorkloadCertificateSecret is the secret name of the dataplane SVID, presented to workloads.
+	WorkloadCertificateSecret = "workload-certificate"
+
+	// network filter names.
+
+	// ConnectionLimitFilter is the name of the network filter limiting the concurrent connections of a listener.
+	ConnectionLimitFilter = "envoy.filters.network.connection_limit"
+	// LocalRateLimitFilter is the name of the network filter limiting the connection rate of a listener.
+	LocalRateLimitFilter = "envoy.filters.network.local_ratelimit"
+
+	// metadata.
+
+	// ConnectionLimitsMetadataKey is the filter metadata key of import listeners, holding connection limits
+	// which have no Envoy equivalent, and are only enforced by the Go dataplane.
+	ConnectionLimitsMetadataKey = "clusterlink.connection_limits"
+	// MaxConnectionsPerSourceIPMetadataField is the connection limits metadata field holding
+	// the maximal number of concurrent connections from each client IP address.
+	MaxConnectionsPerSourceIPMetadataField = "max_connections_per_source_ip"
+	// ListenAddressesMetadataKey is the xDS node metadata key holding the list of IP addresses
+	// which import listeners of the dataplane are bound to (e.g. the pod IPs).
+	ListenAddressesMetadataKey = "clusterlink.listen_addresses"
 )
 
 // ExportClusterName returns the cluster name of an exported service.
@@ -52,6 +84,16 @@ func ExportClusterName(name, namespace string) string {
 	return ExportClusterPrefix + namespace + "/" + name
 }
 
+// ExportPortClusterName returns the cluster name of a named port of an exported service.
+// An empty port name returns the cluster name of a single-port exported service.
+func ExportPortClusterName(name, namespace, port string) string {
+	if port == "" {
+		return ExportClusterName(name, namespace)
+	}
+
+	return ExportClusterName(name, namespace) + "/" + port
+}
+
 // RemotePeerClusterName returns the cluster name of a remote peer.
 func RemotePeerClusterName(name string) string {
 	return RemotePeerClusterPrefix + name
@@ -61,3 +103,13 @@ func RemotePeerClusterName(name string) string {
 func ImportListenerName(name, namespace string) string {
 	return ImportListenerPrefix + namespace + "/" + name
 }
+
+// ImportPortListenerName returns the listener name of a named port of an imported service.
+// An empty port name returns the listener name of a single-port imported service.
+func ImportPortListenerName(name, namespace, port string) string {
+	if port == "" {
+		return ImportListenerName(name, namespace)
+	}
+
+	return ImportListenerName(name, namespace) + "/" + port
+}
@@ -15,6 +15,8 @@ package connectivitypdp
 
 import (
 	"fmt"
+	"slices"
+	"strings"
 	"sync"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -36,6 +38,13 @@ const (
 // WorkloadAttrs are the actual key-value attributes attached to any given workload.
 type WorkloadAttrs map[string]string
 
+const (
+	// HTTPMethodAttribute is the destination attribute holding the method of an HTTP request.
+	HTTPMethodAttribute = "clusterlink/request.method"
+	// HTTPPathAttribute is the destination attribute holding the path of an HTTP request.
+	HTTPPathAttribute = "clusterlink/request.path"
+)
+
 // PDP is the main object to maintain a set of access policies and decide
 // whether a given connection is allowed or denied by these policies.
 type PDP struct {
@@ -290,10 +299,45 @@ func accessPolicyMatches(policy *v1alpha1.AccessPolicySpec, src, dest WorkloadAt
 
 	// Check if destination matches any element of the policy's "To" field
 	matched, err = WorkloadSetOrSelectorListMatches(&policy.To, dest)
-	if err != nil {
+	if err != nil || !matched {
 		return false, err
 	}
-	return matched, nil
+
+	return httpRequestsMatch(policy.HTTPRequests, dest), nil
+}
+
+// httpRequestsMatch checks whether the HTTP request attributes of a destination match any of the given
+// request matches. An empty list of request matches matches all connections and requests.
+func httpRequestsMatch(requests []v1alpha1.HTTPRequestMatch, dest WorkloadAttrs) bool {
+	if len(requests) == 0 {
+		return true
+	}
+
+	method, ok := dest[HTTPMethodAttribute]
+	if !ok { // not an HTTP request
+		return false
+	}
+	path := dest[HTTPPathAttribute]
+
+	for i := range requests {
+		if len(requests[i].Methods) > 0 && !slices.Contains(requests[i].Methods, method) {
+			continue
+		}
+		if pathPrefixMatches(path, requests[i].PathPrefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// pathPrefixMatches checks whether a request path matches a path prefix on a segment boundary,
+// so that the prefix "/public" matches "/public" and "/public/index.html", but not "/publicity".
+func pathPrefixMatches(path, prefix string) bool {
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+
+	return len(path) == len(prefix) || strings.HasSuffix(prefix, "/") || path[len(prefix)] == '/'
 }
 
 // checks whether a workload with the given labels matches any item in a slice of WorkloadSetOrSelectors.
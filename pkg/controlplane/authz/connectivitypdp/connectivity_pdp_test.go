@@ -186,6 +186,54 @@ func getNameOfFirstPolicyInPDP(pdp *connectivitypdp.PDP, action v1alpha1.AccessP
 	return ""
 }
 
+func TestHTTPRequests(t *testing.T) {
+	workloadSet := []v1alpha1.WorkloadSetOrSelector{trivialWorkloadSet}
+	httpPol := v1alpha1.AccessPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "http", Namespace: defaultNS},
+		Spec: v1alpha1.AccessPolicySpec{
+			Action: v1alpha1.AccessPolicyActionAllow,
+			From:   workloadSet,
+			To:     workloadSet,
+			HTTPRequests: []v1alpha1.HTTPRequestMatch{
+				{Methods: []string{"GET"}, PathPrefix: "/api/"},
+				{PathPrefix: "/public/"},
+				{Methods: []string{"GET"}, PathPrefix: "/docs"},
+			},
+		},
+	}
+
+	pdp := connectivitypdp.NewPDP()
+	err := pdp.AddOrUpdatePolicy(connectivitypdp.PolicyFromCR(&httpPol))
+	require.Nil(t, err)
+
+	request := func(method, path string) connectivitypdp.WorkloadAttrs {
+		return connectivitypdp.WorkloadAttrs{
+			"key":                               "val",
+			connectivitypdp.HTTPMethodAttribute: method,
+			connectivitypdp.HTTPPathAttribute:   path,
+		}
+	}
+
+	tests := []struct {
+		dest     connectivitypdp.WorkloadAttrs
+		decision connectivitypdp.Decision
+	}{
+		{dest: trivialLabel, decision: connectivitypdp.DecisionDeny}, // not an HTTP request
+		{dest: request("GET", "/api/users"), decision: connectivitypdp.DecisionAllow},
+		{dest: request("POST", "/api/users"), decision: connectivitypdp.DecisionDeny},
+		{dest: request("GET", "/admin"), decision: connectivitypdp.DecisionDeny},
+		{dest: request("DELETE", "/public/file"), decision: connectivitypdp.DecisionAllow},
+		{dest: request("GET", "/docs"), decision: connectivitypdp.DecisionAllow},
+		{dest: request("GET", "/docs/index.html"), decision: connectivitypdp.DecisionAllow},
+		{dest: request("GET", "/docsecret"), decision: connectivitypdp.DecisionDeny}, // not on a segment boundary
+	}
+	for _, tt := range tests {
+		decision, err := pdp.Decide(trivialLabel, tt.dest, defaultNS)
+		require.Nil(t, err)
+		require.Equal(t, tt.decision, decision.Decision, tt.dest)
+	}
+}
+
 func TestDeleteNonexistingPolicies(t *testing.T) {
 	pdp := connectivitypdp.NewPDP()
 	err := pdp.DeletePolicy(types.NamespacedName{Name: "no-such-policy"}, true)
@@ -18,12 +18,15 @@ import (
 	"crypto/rand"
 	"crypto/rsa"
 	"fmt"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/lestrrat-go/jwx/jwk"
 	"github.com/lestrrat-go/jwx/jwt"
 	"github.com/sirupsen/logrus"
+	"github.com/spiffe/go-spiffe/v2/spiffeid"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
@@ -34,16 +37,26 @@ import (
 	cpapi "github.com/clusterlink-net/clusterlink/pkg/controlplane/api"
 	"github.com/clusterlink-net/clusterlink/pkg/controlplane/authz/connectivitypdp"
 	"github.com/clusterlink-net/clusterlink/pkg/controlplane/peer"
+	"github.com/clusterlink-net/clusterlink/pkg/metrics"
+	"github.com/clusterlink-net/clusterlink/pkg/util/audit"
+	"github.com/clusterlink-net/clusterlink/pkg/util/ratelimit"
 	"github.com/clusterlink-net/clusterlink/pkg/util/tls"
 )
 
 const (
-	// the number of seconds a JWT access token is valid before it expires.
-	jwtExpirySeconds = 5
+	// defaultTokenLifetime is the lifetime of a JWT access token, unless a different lifetime is requested.
+	defaultTokenLifetime = 5 * time.Second
+	// DefaultMaxTokenLifetime is the default maximal lifetime of a JWT access token requested by a remote peer.
+	DefaultMaxTokenLifetime = 5 * time.Minute
 
 	ServiceNameLabel      = "clusterlink/metadata.serviceName"
 	ServiceNamespaceLabel = "clusterlink/metadata.serviceNamespace"
 	GatewayNameLabel      = "clusterlink/metadata.gatewayName"
+
+	// workload identity labels, set for clients authenticated using workload mTLS.
+	SPIFFEIDLabel       = "clusterlink/metadata.spiffeID"
+	TrustDomainLabel    = "clusterlink/metadata.trustDomain"
+	ServiceAccountLabel = "clusterlink/metadata.serviceAccount"
 )
 
 // egressAuthorizationRequest (from local dataplane)
@@ -51,8 +64,18 @@ const (
 type egressAuthorizationRequest struct {
 	// ImportName is the name of the requested imported service.
 	ImportName types.NamespacedName
+	// ImportPort is the port name of the requested multi-port imported service.
+	ImportPort string
 	// IP address of the client connecting to the service.
 	IP string
+	// SPIFFEID of the client connecting to the service, if authenticated using workload mTLS.
+	SPIFFEID string
+	// HTTPMethod is the method of the request, if the service uses the HTTP protocol.
+	HTTPMethod string
+	// HTTPPath is the path of the request, if the service uses the HTTP protocol.
+	HTTPPath string
+	// HTTPRoute is the name of the import HTTP route matched by the request, if any.
+	HTTPRoute string
 }
 
 // egressAuthorizationResponse (to local dataplane) represents a response for an egressAuthorizationRequest.
@@ -61,16 +84,30 @@ type egressAuthorizationResponse struct {
 	ServiceExists bool
 	// Allowed is true if the request is allowed.
 	Allowed bool
+	// RateLimited is true if the request exceeds the connection rate limit of its client.
+	RateLimited bool
 	// RemotePeerCluster is the cluster name of the remote peer where the connection should be routed to.
 	RemotePeerCluster string
 	// AccessToken is a token that allows accessing the requested service.
 	AccessToken string
+	// TokenLifetime is the lifetime of the access token.
+	TokenLifetime time.Duration
+	// Cache is the caching policy of the imported service, if its authorization results may be cached.
+	Cache *v1alpha1.ImportAuthorizationCache
 }
 
 // ingressAuthorizationRequest (to remote peer controlplane) represents a request for accessing an exported service.
 type ingressAuthorizationRequest struct {
 	// Service is the name of the requested exported service.
 	ServiceName types.NamespacedName
+	// ServicePort is the port name of the requested multi-port exported service.
+	ServicePort string
+	// HTTPMethod is the method of the request, if the service uses the HTTP protocol.
+	HTTPMethod string
+	// HTTPPath is the path of the request, if the service uses the HTTP protocol.
+	HTTPPath string
+	// TokenLifetime is the requested lifetime of the access token. If zero, the default lifetime is used.
+	TokenLifetime time.Duration
 }
 
 // ingressAuthorizationResponse (from remote peer controlplane) represents a response for an ingressAuthorizationRequest.
@@ -81,6 +118,8 @@ type ingressAuthorizationResponse struct {
 	Allowed bool
 	// AccessToken is a token that allows accessing the requested service.
 	AccessToken string
+	// TokenLifetime is the lifetime of the access token.
+	TokenLifetime time.Duration
 }
 
 type podInfo struct {
@@ -115,6 +154,18 @@ type Manager struct {
 	// callback for getting a peer (for non-CRD mode)
 	getPeerCallback func(name string, pr *v1alpha1.Peer) error
 
+	// workloadMTLS is true if egress clients are identified by their SPIFFE ID, rather than by their IP.
+	workloadMTLS bool
+
+	// rateLimiter limits the connection rate of clients of imported services, and of remote peers.
+	rateLimiter *ratelimit.Limiter
+
+	// maxTokenLifetime is the maximal lifetime of access tokens requested by remote peers.
+	maxTokenLifetime time.Duration
+
+	auditLogger *audit.Logger
+	metrics     *metrics.ControlplaneMetrics
+
 	logger *logrus.Entry
 }
 
@@ -130,6 +181,54 @@ func (m *Manager) SetGetPeerCallback(callback func(name string, pr *v1alpha1.Pee
 	m.getPeerCallback = callback
 }
 
+// SetWorkloadMTLS sets whether egress clients must be identified by their SPIFFE ID.
+func (m *Manager) SetWorkloadMTLS(enabled bool) {
+	m.workloadMTLS = enabled
+}
+
+// SetMaxTokenLifetime sets the maximal lifetime of access tokens requested by remote peers.
+func (m *Manager) SetMaxTokenLifetime(lifetime time.Duration) {
+	m.maxTokenLifetime = lifetime
+}
+
+// SetAuditLogger sets the audit logger for authorization decisions.
+func (m *Manager) SetAuditLogger(auditLogger *audit.Logger) {
+	m.auditLogger = auditLogger
+}
+
+// SetMetrics sets the metrics for authorization decisions and load balancing selections.
+func (m *Manager) SetMetrics(controlplaneMetrics *metrics.ControlplaneMetrics) {
+	m.metrics = controlplaneMetrics
+}
+
+// recordDecision records an authorization decision in the audit log and metrics.
+func (m *Manager) recordDecision(
+	action, actor string,
+	name types.NamespacedName,
+	src, dst connectivitypdp.WorkloadAttrs,
+	allowed bool,
+	policy, reason string,
+) {
+	m.metrics.ObserveAuthorizationDecision(action, allowed, policy)
+
+	outcome := audit.OutcomeDeny
+	if allowed {
+		outcome = audit.OutcomeAllow
+	}
+
+	m.auditLogger.Log(audit.LevelAuthorization, &audit.Record{
+		Category:    audit.CategoryAuthorization,
+		Actor:       actor,
+		Action:      action,
+		Name:        name.String(),
+		Outcome:     outcome,
+		Reason:      reason,
+		Policy:      policy,
+		Source:      src,
+		Destination: dst,
+	})
+}
+
 // AddPeer defines a new route target for egress dataplane connections.
 func (m *Manager) AddPeer(pr *v1alpha1.Peer) {
 	m.logger.Infof("Adding peer '%s'.", pr.Name)
@@ -202,13 +301,35 @@ func (m *Manager) getPodInfoByIP(ip string) *podInfo {
 	return nil
 }
 
-// authorizeEgress authorizes a request for accessing an imported service.
-func (m *Manager) authorizeEgress(ctx context.Context, req *egressAuthorizationRequest) (*egressAuthorizationResponse, error) {
-	m.logger.Infof("Received egress authorization request: %v.", req)
-
+// getSourceAttributes returns the attributes and identity of the client of an egress request.
+func (m *Manager) getSourceAttributes(
+	req *egressAuthorizationRequest,
+) (connectivitypdp.WorkloadAttrs, string, error) {
 	srcAttributes := connectivitypdp.WorkloadAttrs{}
+
+	if m.workloadMTLS {
+		id, err := spiffeid.FromString(req.SPIFFEID)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid client SPIFFE ID '%s': %w", req.SPIFFEID, err)
+		}
+
+		srcAttributes[SPIFFEIDLabel] = id.String()
+		srcAttributes[TrustDomainLabel] = id.TrustDomain().Name()
+
+		// Kubernetes workload IDs issued by SPIRE are of the form spiffe://<trust-domain>/ns/<namespace>/sa/<sa>
+		segments := strings.Split(strings.TrimPrefix(id.Path(), "/"), "/")
+		if len(segments) == 4 && segments[0] == "ns" && segments[2] == "sa" {
+			srcAttributes[ServiceNamespaceLabel] = segments[1]
+			srcAttributes[ServiceAccountLabel] = segments[3]
+		}
+
+		return srcAttributes, id.String(), nil
+	}
+
+	actor := req.IP
 	podInfo := m.getPodInfoByIP(req.IP)
 	if podInfo != nil {
+		actor = types.NamespacedName{Namespace: podInfo.namespace, Name: podInfo.name}.String()
 		srcAttributes[ServiceNamespaceLabel] = podInfo.namespace
 
 		if src, ok := podInfo.labels["app"]; ok { // TODO: Add support for labels other than just the "app" key.
@@ -217,11 +338,66 @@ func (m *Manager) authorizeEgress(ctx context.Context, req *egressAuthorizationR
 		}
 	}
 
+	return srcAttributes, actor, nil
+}
+
+// authorizeEgress authorizes a request for accessing an imported service.
+// Failing to authorize the request is audited as a denial.
+func (m *Manager) authorizeEgress(
+	ctx context.Context,
+	req *egressAuthorizationRequest,
+) (_ *egressAuthorizationResponse, err error) {
+	m.logger.Infof("Received egress authorization request: %v.", req)
+
+	var srcAttributes connectivitypdp.WorkloadAttrs
+	var actor string
+	defer func() {
+		if err != nil {
+			m.recordDecision("egress", actor, req.ImportName, srcAttributes, nil, false, "", err.Error())
+		}
+	}()
+
+	srcAttributes, actor, err = m.getSourceAttributes(req)
+	if err != nil {
+		actor = req.IP
+		if req.SPIFFEID != "" {
+			actor = req.SPIFFEID
+		}
+		return nil, err
+	}
+
 	var imp v1alpha1.Import
 	if err := m.getImport(ctx, req.ImportName, &imp); err != nil {
 		return nil, fmt.Errorf("cannot get import %v: %w", req.ImportName, err)
 	}
 
+	if !importHasPort(&imp, req.ImportPort) {
+		m.logger.Infof("Import %v does not have port '%s'.", req.ImportName, req.ImportPort)
+		m.recordDecision("egress", actor, req.ImportName, srcAttributes, nil, false, "",
+			fmt.Sprintf("import port '%s' not found", req.ImportPort))
+		return &egressAuthorizationResponse{}, nil
+	}
+
+	if req.HTTPRoute != "" {
+		routeImp, ok := importHTTPRoute(&imp, req.HTTPRoute)
+		if !ok {
+			m.logger.Infof("Import %v does not have HTTP route '%s'.", req.ImportName, req.HTTPRoute)
+			m.recordDecision("egress", actor, req.ImportName, srcAttributes, nil, false, "",
+				fmt.Sprintf("import HTTP route '%s' not found", req.HTTPRoute))
+			return &egressAuthorizationResponse{}, nil
+		}
+		imp = *routeImp
+	}
+
+	if limits := imp.Spec.ConnectionLimits; limits != nil {
+		key := fmt.Sprintf("egress/%v/%s/%s", req.ImportName, req.ImportPort, req.IP)
+		if !m.rateLimiter.Allow(key, limits.PerSourceIP.ConnectionsPerSecond) {
+			m.recordDecision("egress", actor, req.ImportName, srcAttributes, nil, false, "", "rate limited")
+			m.metrics.ObserveConnectionRateLimited("egress", req.ImportName.String())
+			return &egressAuthorizationResponse{ServiceExists: true, RateLimited: true}, nil
+		}
+	}
+
 	lbResult := NewLoadBalancingResult(&imp)
 	for {
 		if err := m.loadBalancer.Select(lbResult); err != nil {
@@ -229,6 +405,7 @@ func (m *Manager) authorizeEgress(ctx context.Context, req *egressAuthorizationR
 		}
 
 		importSource := lbResult.Get()
+		m.metrics.ObserveLoadBalancingSelection(req.ImportName.String(), importSource.Peer, sourceExportName(importSource))
 
 		var pr v1alpha1.Peer
 		if err := m.getPeer(ctx, importSource.Peer, &pr); err != nil {
@@ -247,12 +424,16 @@ func (m *Manager) authorizeEgress(ctx context.Context, req *egressAuthorizationR
 			ServiceNamespaceLabel: imp.Namespace,
 			GatewayNameLabel:      importSource.Peer,
 		}
+		addHTTPRequestAttributes(dstAttributes, req.HTTPMethod, req.HTTPPath)
 		decision, err := m.connectivityPDP.Decide(srcAttributes, dstAttributes, req.ImportName.Namespace)
 		if err != nil {
 			return nil, fmt.Errorf("error deciding on an egress connection: %w", err)
 		}
 
 		if decision.Decision != connectivitypdp.DecisionAllow {
+			m.recordDecision(
+				"egress", actor, req.ImportName, srcAttributes, dstAttributes,
+				false, decision.MatchedBy, "denied by local policy")
 			continue
 		}
 
@@ -275,8 +456,12 @@ func (m *Manager) authorizeEgress(ctx context.Context, req *egressAuthorizationR
 		}
 
 		peerResp, err := cl.Authorize(&cpapi.AuthorizationRequest{
-			ServiceName:      DstName,
-			ServiceNamespace: DstNamespace,
+			ServiceName:          DstName,
+			ServiceNamespace:     DstNamespace,
+			ServicePort:          req.ImportPort,
+			HTTPMethod:           req.HTTPMethod,
+			HTTPPath:             req.HTTPPath,
+			TokenLifetimeSeconds: cacheTokenLifetimeSeconds(imp.Spec.AuthorizationCache),
 		})
 		if err != nil {
 			m.logger.Infof("Unable to get access token from peer: %v", err)
@@ -294,23 +479,164 @@ func (m *Manager) authorizeEgress(ctx context.Context, req *egressAuthorizationR
 			m.logger.Infof(
 				"Peer %s did not allow connection to import %v: %v",
 				importSource.Peer, req.ImportName, err)
+			m.recordDecision(
+				"egress", actor, req.ImportName, srcAttributes, dstAttributes,
+				false, decision.MatchedBy, fmt.Sprintf("denied by peer %s", importSource.Peer))
 			continue
 		}
 
+		m.recordDecision(
+			"egress", actor, req.ImportName, srcAttributes, dstAttributes,
+			true, decision.MatchedBy, "")
+
+		tokenLifetime := defaultTokenLifetime
+		if peerResp.TokenLifetimeSeconds > 0 {
+			tokenLifetime = time.Duration(peerResp.TokenLifetimeSeconds) * time.Second
+		}
+
 		return &egressAuthorizationResponse{
 			ServiceExists:     true,
 			Allowed:           true,
 			RemotePeerCluster: cpapi.RemotePeerClusterName(importSource.Peer),
 			AccessToken:       peerResp.AccessToken,
+			TokenLifetime:     tokenLifetime,
+			Cache:             imp.Spec.AuthorizationCache,
 		}, nil
 	}
 }
 
+// cacheTokenLifetimeSeconds returns the access token lifetime to request for an imported service,
+// covering the time its authorization results may be cached, or zero for the default lifetime.
+func cacheTokenLifetimeSeconds(cache *v1alpha1.ImportAuthorizationCache) uint32 {
+	if cache == nil {
+		return 0
+	}
+
+	return cache.TTLSeconds + max(cache.StaleSeconds, cacheFailOpenSeconds(cache))
+}
+
+// cacheFailOpenSeconds returns the time an authorization result of an imported service is still reused
+// once its TTL passes if the controlplane is unavailable, or zero if the imported service does not fail-open.
+func cacheFailOpenSeconds(cache *v1alpha1.ImportAuthorizationCache) uint32 {
+	if !cache.FailOpen {
+		return 0
+	}
+
+	return max(cache.FailOpenSeconds, cache.StaleSeconds)
+}
+
+// tokenLifetime returns the lifetime of an access token, given the requested lifetime.
+func (m *Manager) tokenLifetime(requested time.Duration) time.Duration {
+	if requested <= 0 {
+		return defaultTokenLifetime
+	}
+
+	return min(requested, m.maxTokenLifetime)
+}
+
+// importHasPort returns true if an import has a port with the given name.
+// An empty port name matches a single-port import.
+func importHasPort(imp *v1alpha1.Import, port string) bool {
+	if port == "" {
+		return len(imp.Spec.Ports) == 0
+	}
+
+	for i := range imp.Spec.Ports {
+		if imp.Spec.Ports[i].Name == port {
+			return true
+		}
+	}
+
+	return false
+}
+
+// importHTTPRoute returns a copy of an import, whose sources are restricted to the peers of the given HTTP route.
+func importHTTPRoute(imp *v1alpha1.Import, route string) (*v1alpha1.Import, bool) {
+	for i := range imp.Spec.HTTPRoutes {
+		if imp.Spec.HTTPRoutes[i].Name != route {
+			continue
+		}
+
+		routeImp := imp.DeepCopy()
+		routeImp.Spec.Sources = nil
+		for _, source := range imp.Spec.Sources {
+			if slices.Contains(imp.Spec.HTTPRoutes[i].Peers, source.Peer) {
+				routeImp.Spec.Sources = append(routeImp.Spec.Sources, source)
+			}
+		}
+
+		return routeImp, len(routeImp.Spec.Sources) > 0
+	}
+
+	return nil, false
+}
+
+// addHTTPRequestAttributes adds the method and path of an HTTP request to the destination attributes.
+func addHTTPRequestAttributes(attrs connectivitypdp.WorkloadAttrs, method, path string) {
+	if method == "" {
+		return
+	}
+
+	attrs[connectivitypdp.HTTPMethodAttribute] = method
+	attrs[connectivitypdp.HTTPPathAttribute] = path
+}
+
+// exportHasPort returns true if an export has a port with the given name.
+// An empty port name matches a single-port export.
+func exportHasPort(export *v1alpha1.Export, port string) bool {
+	if port == "" {
+		return len(export.Spec.Ports) == 0
+	}
+
+	for i := range export.Spec.Ports {
+		if export.Spec.Ports[i].Name == port {
+			return true
+		}
+	}
+
+	return false
+}
+
+// exportProtocol returns the protocol of an export, defaulting to TCP.
+func exportProtocol(export *v1alpha1.Export) v1alpha1.Protocol {
+	if export.Spec.Protocol == "" {
+		return v1alpha1.ProtocolDefault
+	}
+
+	return export.Spec.Protocol
+}
+
+// sourceExportName returns the name of the remote export of an import source, or an empty string if not set.
+func sourceExportName(source *v1alpha1.ImportSource) string {
+	if source.ExportName == "" {
+		return ""
+	}
+
+	return types.NamespacedName{Namespace: source.ExportNamespace, Name: source.ExportName}.String()
+}
+
 // parseAuthorizationHeader verifies an access token for an ingress dataplane connection.
+// The token must have been issued for an exported service of the given protocol, which is that of the tunnel
+// (or HTTP request) carrying the connection, so peers cannot reach a service using a protocol it does not export.
+// For requests of HTTP services, the token must have been issued for the given method and path.
+// If the export limits the connection rate of each remote peer, connections exceeding it fail with errRateLimited.
 // On success, returns the parsed target cluster name.
-func (m *Manager) parseAuthorizationHeader(token string) (string, error) {
+// Each verification is audited as an "ingress-token" decision.
+func (m *Manager) parseAuthorizationHeader(
+	token string, protocol v1alpha1.Protocol, httpMethod, httpPath string,
+) (_ string, err error) {
 	m.logger.Debug("Parsing access token.")
 
+	var pr, reason string
+	var export types.NamespacedName
+	defer func() {
+		if err != nil && reason == "" {
+			reason = fmt.Sprintf("invalid access token: %v", err)
+		}
+
+		m.recordDecision("ingress-token", pr, export, nil, nil, err == nil, "", reason)
+	}()
+
 	parsedToken, err := jwt.ParseString(
 		token, jwt.WithVerify(cpapi.JWTSignatureAlgorithm, m.jwkVerifyKey), jwt.WithValidate(true))
 	if err != nil {
@@ -329,15 +655,46 @@ func (m *Manager) parseAuthorizationHeader(token string) (string, error) {
 		return "", fmt.Errorf("token missing '%s' claim", cpapi.ExportNamespaceJWTClaim)
 	}
 
-	return cpapi.ExportClusterName(exportName.(string), exportNamespace.(string)), nil
+	export = types.NamespacedName{Namespace: fmt.Sprint(exportNamespace), Name: fmt.Sprint(exportName)}
+	pr, _ = parsedToken.PrivateClaims()[cpapi.PeerJWTClaim].(string)
+
+	// the port claim is only set for multi-port exported services
+	exportPort, _ := parsedToken.PrivateClaims()[cpapi.ExportPortJWTClaim].(string)
+
+	tokenProtocol, _ := parsedToken.PrivateClaims()[cpapi.ExportProtocolJWTClaim].(string)
+	if v1alpha1.Protocol(tokenProtocol) != protocol {
+		reason = "export protocol mismatch"
+		return "", fmt.Errorf("token was issued for a '%s' service, not '%s'", tokenProtocol, protocol)
+	}
+
+	// the request claims are only set for requests of HTTP services
+	tokenMethod, _ := parsedToken.PrivateClaims()[cpapi.HTTPMethodJWTClaim].(string)
+	tokenPath, _ := parsedToken.PrivateClaims()[cpapi.HTTPPathJWTClaim].(string)
+	if tokenMethod != httpMethod || tokenPath != httpPath {
+		return "", fmt.Errorf("token was issued for request '%s %s'", tokenMethod, tokenPath)
+	}
+
+	// the rate claim is only set for exported services limiting the connection rate of each peer.
+	// numeric claims are parsed as float64.
+	if rate, ok := parsedToken.PrivateClaims()[cpapi.ConnectionsPerSecondJWTClaim].(float64); ok {
+		key := fmt.Sprintf("ingress/%v/%s/%s", export, exportPort, pr)
+		if !m.rateLimiter.Allow(key, uint32(rate)) {
+			reason = "rate limited"
+			m.metrics.ObserveConnectionRateLimited("ingress", export.String())
+			return "", errRateLimited
+		}
+	}
+
+	return cpapi.ExportPortClusterName(exportName.(string), exportNamespace.(string), exportPort), nil
 }
 
 // authorizeIngress authorizes a request for accessing an exported service.
+// Failing to authorize the request is audited as a denial.
 func (m *Manager) authorizeIngress(
 	ctx context.Context,
 	req *ingressAuthorizationRequest,
 	pr string,
-) (*ingressAuthorizationResponse, error) {
+) (_ *ingressAuthorizationResponse, err error) {
 	m.logger.Infof("Received ingress authorization request: %v.", req)
 
 	resp := &ingressAuthorizationResponse{}
@@ -347,15 +704,28 @@ func (m *Manager) authorizeIngress(
 		Namespace: req.ServiceName.Namespace,
 		Name:      req.ServiceName.Name,
 	}
+
+	defer func() {
+		if err != nil {
+			m.recordDecision("ingress", pr, exportName, nil, nil, false, "", err.Error())
+		}
+	}()
 	var export v1alpha1.Export
 	if err := m.getExport(ctx, exportName, &export); err != nil {
 		if errors.IsNotFound(err) || !meta.IsStatusConditionTrue(export.Status.Conditions, v1alpha1.ExportValid) {
+			m.recordDecision("ingress", pr, exportName, nil, nil, false, "", "export not found")
 			return resp, nil
 		}
 
 		return nil, fmt.Errorf("cannot get export %v: %w", exportName, err)
 	}
 
+	if !exportHasPort(&export, req.ServicePort) {
+		m.recordDecision("ingress", pr, exportName, nil, nil, false, "",
+			fmt.Sprintf("export port '%s' not found", req.ServicePort))
+		return resp, nil
+	}
+
 	resp.ServiceExists = true
 
 	srcAttributes := connectivitypdp.WorkloadAttrs{GatewayNameLabel: pr}
@@ -363,24 +733,41 @@ func (m *Manager) authorizeIngress(
 		ServiceNameLabel:      req.ServiceName.Name,
 		ServiceNamespaceLabel: req.ServiceName.Namespace,
 	}
+	addHTTPRequestAttributes(dstAttributes, req.HTTPMethod, req.HTTPPath)
 	decision, err := m.connectivityPDP.Decide(srcAttributes, dstAttributes, req.ServiceName.Namespace)
 	if err != nil {
 		return nil, fmt.Errorf("error deciding on an ingress connection: %w", err)
 	}
 
 	if decision.Decision != connectivitypdp.DecisionAllow {
+		m.recordDecision(
+			"ingress", pr, exportName, srcAttributes, dstAttributes,
+			false, decision.MatchedBy, "denied by local policy")
 		resp.Allowed = false
 		return resp, nil
 	}
-	resp.Allowed = true
 
 	// create access token
-	// TODO: include client name as a claim
-	token, err := jwt.NewBuilder().
-		Expiration(time.Now().Add(time.Second*jwtExpirySeconds)).
+	resp.TokenLifetime = m.tokenLifetime(req.TokenLifetime)
+	builder := jwt.NewBuilder().
+		Expiration(time.Now().Add(resp.TokenLifetime)).
 		Claim(cpapi.ExportNameJWTClaim, req.ServiceName.Name).
 		Claim(cpapi.ExportNamespaceJWTClaim, req.ServiceName.Namespace).
-		Build()
+		Claim(cpapi.ExportProtocolJWTClaim, string(exportProtocol(&export))).
+		Claim(cpapi.PeerJWTClaim, pr)
+	if limits := export.Spec.ConnectionLimits; limits != nil && limits.ConnectionsPerSecond > 0 {
+		// the rate is enforced per dataplane connection, when the token is verified
+		builder = builder.Claim(cpapi.ConnectionsPerSecondJWTClaim, limits.ConnectionsPerSecond)
+	}
+	if req.ServicePort != "" {
+		builder = builder.Claim(cpapi.ExportPortJWTClaim, req.ServicePort)
+	}
+	if req.HTTPMethod != "" {
+		builder = builder.
+			Claim(cpapi.HTTPMethodJWTClaim, req.HTTPMethod).
+			Claim(cpapi.HTTPPathJWTClaim, req.HTTPPath)
+	}
+	token, err := builder.Build()
 	if err != nil {
 		return nil, fmt.Errorf("unable to generate access token: %w", err)
 	}
@@ -391,6 +778,11 @@ func (m *Manager) authorizeIngress(
 		return nil, fmt.Errorf("unable to sign access token: %w", err)
 	}
 	resp.AccessToken = string(signed)
+	resp.Allowed = true
+
+	m.recordDecision(
+		"ingress", pr, exportName, srcAttributes, dstAttributes,
+		true, decision.MatchedBy, "")
 
 	return resp, nil
 }
@@ -443,16 +835,18 @@ func NewManager(peerTLS *tls.ParsedCertData, cl client.Client, namespace string)
 	}
 
 	return &Manager{
-		client:          cl,
-		namespace:       namespace,
-		connectivityPDP: connectivitypdp.NewPDP(),
-		loadBalancer:    NewLoadBalancer(),
-		peerTLS:         peerTLS,
-		peerClient:      make(map[string]*peer.Client),
-		jwkSignKey:      jwkSignKey,
-		jwkVerifyKey:    jwkVerifyKey,
-		ipToPod:         make(map[string]types.NamespacedName),
-		podList:         make(map[types.NamespacedName]podInfo),
-		logger:          logrus.WithField("component", "controlplane.authz.manager"),
+		client:           cl,
+		namespace:        namespace,
+		connectivityPDP:  connectivitypdp.NewPDP(),
+		loadBalancer:     NewLoadBalancer(),
+		peerTLS:          peerTLS,
+		peerClient:       make(map[string]*peer.Client),
+		jwkSignKey:       jwkSignKey,
+		jwkVerifyKey:     jwkVerifyKey,
+		ipToPod:          make(map[string]types.NamespacedName),
+		podList:          make(map[types.NamespacedName]podInfo),
+		rateLimiter:      ratelimit.NewLimiter(),
+		maxTokenLifetime: DefaultMaxTokenLifetime,
+		logger:           logrus.WithField("component", "controlplane.authz.manager"),
 	}, nil
 }
@@ -0,0 +1,208 @@
+// Copyright 2023 The ClusterLink Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package authz
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/clusterlink-net/clusterlink/pkg/apis/clusterlink.net/v1alpha1"
+	cpapi "github.com/clusterlink-net/clusterlink/pkg/controlplane/api"
+	"github.com/clusterlink-net/clusterlink/pkg/controlplane/authz/connectivitypdp"
+	"github.com/clusterlink-net/clusterlink/pkg/util/audit"
+)
+
+func TestAuditFailedDecisions(t *testing.T) {
+	_, signingKey, err := ed25519.GenerateKey(rand.Reader)
+	require.Nil(t, err)
+
+	var buf bytes.Buffer
+	m := &Manager{
+		workloadMTLS: true,
+		auditLogger:  audit.NewLogger(audit.LevelAuthorization, &buf, signingKey),
+		logger:       logrus.WithField("component", "controlplane.authz.manager"),
+	}
+
+	// egress request failing to identify the client
+	_, err = m.authorizeEgress(context.Background(), &egressAuthorizationRequest{
+		ImportName: types.NamespacedName{Namespace: "ns", Name: "svc"},
+		IP:         "10.0.0.1",
+		SPIFFEID:   "not-a-spiffe-id",
+	})
+	require.NotNil(t, err)
+
+	// invalid access token of an ingress connection
+	_, err = m.parseAuthorizationHeader("invalid", v1alpha1.ProtocolTCP, "", "")
+	require.NotNil(t, err)
+
+	var records []audit.Record
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var record audit.Record
+		require.Nil(t, json.Unmarshal([]byte(line), &record))
+		records = append(records, record)
+	}
+
+	require.Len(t, records, 2)
+	require.Equal(t, "egress", records[0].Action)
+	require.Equal(t, "not-a-spiffe-id", records[0].Actor)
+	require.Equal(t, "ns/svc", records[0].Name)
+	require.Equal(t, audit.OutcomeDeny, records[0].Outcome)
+	require.Equal(t, "ingress-token", records[1].Action)
+	require.Equal(t, audit.OutcomeDeny, records[1].Outcome)
+	require.Contains(t, records[1].Reason, "invalid access token")
+}
+
+func TestSPIFFESourceAttributes(t *testing.T) {
+	m := &Manager{workloadMTLS: true}
+
+	tests := []struct {
+		name       string
+		id         string
+		attributes connectivitypdp.WorkloadAttrs
+	}{{
+		name: "kubernetes workload",
+		id:   "spiffe://example.org/ns/default/sa/client",
+		attributes: connectivitypdp.WorkloadAttrs{
+			SPIFFEIDLabel:         "spiffe://example.org/ns/default/sa/client",
+			TrustDomainLabel:      "example.org",
+			ServiceNamespaceLabel: "default",
+			ServiceAccountLabel:   "client",
+		},
+	}, {
+		name: "non-kubernetes workload",
+		id:   "spiffe://example.org/workload/client",
+		attributes: connectivitypdp.WorkloadAttrs{
+			SPIFFEIDLabel:    "spiffe://example.org/workload/client",
+			TrustDomainLabel: "example.org",
+		},
+	}, {
+		name: "partial kubernetes path",
+		id:   "spiffe://example.org/ns/default/sa",
+		attributes: connectivitypdp.WorkloadAttrs{
+			SPIFFEIDLabel:    "spiffe://example.org/ns/default/sa",
+			TrustDomainLabel: "example.org",
+		},
+	}, {
+		name: "extra kubernetes path segments",
+		id:   "spiffe://example.org/ns/default/sa/client/extra",
+		attributes: connectivitypdp.WorkloadAttrs{
+			SPIFFEIDLabel:    "spiffe://example.org/ns/default/sa/client/extra",
+			TrustDomainLabel: "example.org",
+		},
+	}, {
+		name: "missing",
+		id:   "",
+	}, {
+		name: "non-SPIFFE scheme",
+		id:   "https://example.org/ns/default/sa/client",
+	}, {
+		name: "invalid trust domain",
+		id:   "spiffe://Example.org/ns/default/sa/client",
+	}, {
+		name: "empty path segment",
+		id:   "spiffe://example.org/ns//sa/client",
+	}, {
+		name: "trailing slash",
+		id:   "spiffe://example.org/ns/default/sa/client/",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			attributes, actor, err := m.getSourceAttributes(&egressAuthorizationRequest{
+				IP:       "10.0.0.1",
+				SPIFFEID: test.id,
+			})
+			if test.attributes == nil {
+				require.NotNil(t, err)
+				return
+			}
+
+			require.Nil(t, err)
+			require.Equal(t, test.id, actor)
+			require.Equal(t, test.attributes, attributes)
+		})
+	}
+}
+
+func TestAccessTokenProtocol(t *testing.T) {
+	m, err := NewManager(nil, nil, "")
+	require.Nil(t, err)
+
+	sign := func(protocol v1alpha1.Protocol) string {
+		token, err := jwt.NewBuilder().
+			Expiration(time.Now().Add(time.Minute)).
+			Claim(cpapi.ExportNameJWTClaim, "svc").
+			Claim(cpapi.ExportNamespaceJWTClaim, "ns").
+			Claim(cpapi.ExportProtocolJWTClaim, string(protocol)).
+			Build()
+		require.Nil(t, err)
+
+		signed, err := jwt.Sign(token, cpapi.JWTSignatureAlgorithm, m.jwkSignKey)
+		require.Nil(t, err)
+		return string(signed)
+	}
+
+	tests := []struct {
+		name     string
+		exported v1alpha1.Protocol
+		tunneled v1alpha1.Protocol
+		allowed  bool
+	}{{
+		name:     "tcp",
+		exported: v1alpha1.ProtocolTCP,
+		tunneled: v1alpha1.ProtocolTCP,
+		allowed:  true,
+	}, {
+		name:     "udp",
+		exported: v1alpha1.ProtocolUDP,
+		tunneled: v1alpha1.ProtocolUDP,
+		allowed:  true,
+	}, {
+		name:     "udp tunnel to tcp export",
+		exported: v1alpha1.ProtocolTCP,
+		tunneled: v1alpha1.ProtocolUDP,
+	}, {
+		name:     "tcp tunnel to udp export",
+		exported: v1alpha1.ProtocolUDP,
+		tunneled: v1alpha1.ProtocolTCP,
+	}, {
+		name:     "tcp tunnel to http export",
+		exported: v1alpha1.ProtocolHTTP,
+		tunneled: v1alpha1.ProtocolTCP,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster, err := m.parseAuthorizationHeader(sign(tt.exported), tt.tunneled, "", "")
+			if !tt.allowed {
+				require.NotNil(t, err)
+				return
+			}
+
+			require.Nil(t, err)
+			require.Equal(t, cpapi.ExportPortClusterName("svc", "ns", ""), cluster)
+		})
+	}
+}
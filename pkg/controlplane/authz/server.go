@@ -15,14 +15,19 @@ package authz
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"k8s.io/apimachinery/pkg/types"
 
+	"github.com/clusterlink-net/clusterlink/pkg/apis/clusterlink.net/v1alpha1"
 	"github.com/clusterlink-net/clusterlink/pkg/controlplane/api"
+	dpapi "github.com/clusterlink-net/clusterlink/pkg/dataplane/api"
 	utilhttp "github.com/clusterlink-net/clusterlink/pkg/util/http"
 )
 
@@ -30,6 +35,9 @@ const (
 	bearerSchemaPrefix = "Bearer "
 )
 
+// errRateLimited is returned for an ingress dataplane connection exceeding the connection rate limit of its peer.
+var errRateLimited = errors.New("connection rate limit exceeded")
+
 type server struct {
 	manager *Manager
 	logger  *logrus.Entry
@@ -43,17 +51,40 @@ func RegisterHandlers(manager *Manager, srv *utilhttp.Server) {
 		logger:  logrus.WithField("component", "controlplane.authz.server"),
 	}
 
-	router.Post(api.DataplaneEgressAuthorizationPath, server.DataplaneEgressAuthorize)
-	router.Post(api.DataplaneIngressAuthorizationPath, server.DataplaneIngressAuthorize)
+	// tunnels carrying UDP datagrams are authorized by their upgrade (GET) request, whose tunnel path
+	// is appended to the authorization path.
+	// requests of HTTP services are authorized using their original method and path.
+	dataplaneRouter := router.With(server.requireRole(api.RoleDataplane))
+	dataplaneRouter.HandleFunc(api.DataplaneEgressAuthorizationPath+"*", server.DataplaneEgressAuthorize)
+	dataplaneRouter.HandleFunc(api.DataplaneIngressAuthorizationPath+"*", server.DataplaneIngressAuthorize)
+
+	// remote dataplanes send heartbeats to health check the local peer gateways
+	router.With(server.requireRole(api.RoleRemotePeer, api.RoleControlplane, api.RoleRemoteDataplane)).
+		Get(api.HeartbeatPath, server.Heartbeat)
+
+	peerRouter := router.With(server.requireRole(api.RoleRemotePeer, api.RoleControlplane))
+	peerRouter.Post(api.RemotePeerAuthorizationPath, server.PeerAuthorize)
+}
+
+// requireRole returns a middleware which rejects requests originating from clients
+// whose certificate does not match any of the given roles.
+func (s *server) requireRole(roles ...api.Role) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			role := api.RequestRole(r, s.manager.peerTLS)
+			if !slices.Contains(roles, role) {
+				s.logger.Warnf("Rejecting request to '%s' from client with role '%s'.", r.URL.Path, role)
+				http.Error(w, fmt.Sprintf("client role '%s' is not allowed", role), http.StatusForbidden)
+				return
+			}
 
-	router.Get(api.HeartbeatPath, server.Heartbeat)
-	router.Post(api.RemotePeerAuthorizationPath, server.PeerAuthorize)
+			next.ServeHTTP(w, r)
+		})
+	}
 }
 
 // DataplaneEgressAuthorize authorizes access to an imported service.
 func (s *server) DataplaneEgressAuthorize(w http.ResponseWriter, r *http.Request) {
-	// TODO: verify that request originates from local dataplane
-
 	ip := r.Header.Get(api.ClientIPHeader)
 	if ip == "" {
 		http.Error(w, fmt.Sprintf("missing '%s' header", api.ClientIPHeader), http.StatusBadRequest)
@@ -72,13 +103,23 @@ func (s *server) DataplaneEgressAuthorize(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	resp, err := s.manager.authorizeEgress(r.Context(), &egressAuthorizationRequest{
+	req := &egressAuthorizationRequest{
 		ImportName: types.NamespacedName{
 			Namespace: importNamespace,
 			Name:      importName,
 		},
-		IP: ip,
-	})
+		ImportPort: r.Header.Get(api.ImportPortHeader),
+		IP:         ip,
+		SPIFFEID:   r.Header.Get(api.ClientSPIFFEIDHeader),
+	}
+
+	httpMode := r.Header.Get(api.HTTPModeHeader) != ""
+	if httpMode {
+		req.HTTPMethod, req.HTTPPath = httpRequestAttributes(r, api.DataplaneEgressAuthorizationPath)
+		req.HTTPRoute = r.Header.Get(api.HTTPRouteHeader)
+	}
+
+	resp, err := s.manager.authorizeEgress(r.Context(), req)
 
 	switch {
 	case err != nil:
@@ -87,20 +128,40 @@ func (s *server) DataplaneEgressAuthorize(w http.ResponseWriter, r *http.Request
 	case !resp.ServiceExists:
 		w.WriteHeader(http.StatusNotFound)
 		return
+	case resp.RateLimited:
+		w.WriteHeader(http.StatusTooManyRequests)
+		return
 	case !resp.Allowed:
 		w.WriteHeader(http.StatusUnauthorized)
 		return
 	}
 
 	w.Header().Set(api.TargetClusterHeader, resp.RemotePeerCluster)
+	if cacheControl := authorizationCacheControl(resp.Cache, resp.TokenLifetime); cacheControl != "" {
+		w.Header().Set(api.AuthorizationCacheHeader, cacheControl)
+	}
+	if httpMode {
+		// leave the authorization header of the request to the application
+		w.Header().Set(api.HTTPAuthorizationHeader, bearerSchemaPrefix+resp.AccessToken)
+		return
+	}
 	w.Header().Set(api.AuthorizationHeader, bearerSchemaPrefix+resp.AccessToken)
 }
 
 // DataplaneIngressAuthorize authorizes a remote peer dataplane access to an exported service.
 func (s *server) DataplaneIngressAuthorize(w http.ResponseWriter, r *http.Request) {
-	authorization := r.Header.Get(api.AuthorizationHeader)
+	authorizationHeader := api.AuthorizationHeader
+	protocol := tunnelProtocol(r, api.DataplaneIngressAuthorizationPath)
+	var httpMethod, httpPath string
+	if r.Header.Get(api.HTTPModeHeader) != "" {
+		authorizationHeader = api.HTTPAuthorizationHeader
+		protocol = v1alpha1.ProtocolHTTP
+		httpMethod, httpPath = httpRequestAttributes(r, api.DataplaneIngressAuthorizationPath)
+	}
+
+	authorization := r.Header.Get(authorizationHeader)
 	if authorization == "" {
-		http.Error(w, fmt.Sprintf("missing '%s' header", api.AuthorizationHeader), http.StatusBadRequest)
+		http.Error(w, fmt.Sprintf("missing '%s' header", authorizationHeader), http.StatusBadRequest)
 		return
 	}
 
@@ -111,7 +172,11 @@ func (s *server) DataplaneIngressAuthorize(w http.ResponseWriter, r *http.Reques
 	}
 	token := strings.TrimPrefix(authorization, bearerSchemaPrefix)
 
-	targetCluster, err := s.manager.parseAuthorizationHeader(token)
+	targetCluster, err := s.manager.parseAuthorizationHeader(token, protocol, httpMethod, httpPath)
+	if errors.Is(err, errRateLimited) {
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return
+	}
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
 		http.Error(w, err.Error(), http.StatusUnauthorized)
@@ -121,6 +186,47 @@ func (s *server) DataplaneIngressAuthorize(w http.ResponseWriter, r *http.Reques
 	w.Header().Set(api.TargetClusterHeader, targetCluster)
 }
 
+// authorizationCacheControl returns the Cache-Control directives of an egress authorization result,
+// bounding the caching policy of its imported service by the lifetime of its access token.
+// Returns an empty string if the result must not be cached.
+func authorizationCacheControl(cache *v1alpha1.ImportAuthorizationCache, tokenLifetime time.Duration) string {
+	if cache == nil {
+		return ""
+	}
+
+	lifetime := uint32(tokenLifetime / time.Second)
+	maxAge := min(cache.TTLSeconds, lifetime)
+	if maxAge == 0 {
+		return ""
+	}
+
+	directives := []string{fmt.Sprintf("max-age=%d", maxAge)}
+	if stale := min(cache.StaleSeconds, lifetime-maxAge); stale > 0 {
+		directives = append(directives, fmt.Sprintf("stale-while-revalidate=%d", stale))
+	}
+	if failOpen := min(cacheFailOpenSeconds(cache), lifetime-maxAge); failOpen > 0 {
+		directives = append(directives, fmt.Sprintf("stale-if-error=%d", failOpen))
+	}
+
+	return strings.Join(directives, ", ")
+}
+
+// httpRequestAttributes returns the method and normalized path of an HTTP service request,
+// whose path is appended to the given authorization path.
+func httpRequestAttributes(r *http.Request, authorizationPath string) (method, path string) {
+	return r.Method, api.NormalizeHTTPPath(strings.TrimPrefix(r.URL.Path, authorizationPath))
+}
+
+// tunnelProtocol returns the protocol of the connection carried by a dataplane tunnel,
+// whose path is appended to the given authorization path.
+func tunnelProtocol(r *http.Request, authorizationPath string) v1alpha1.Protocol {
+	if strings.HasPrefix("/"+strings.TrimPrefix(r.URL.Path, authorizationPath), dpapi.UDPTunnelPathPrefix) {
+		return v1alpha1.ProtocolUDP
+	}
+
+	return v1alpha1.ProtocolTCP
+}
+
 // Heartbeat returns a response for heartbeat checks from remote peers.
 func (s *server) Heartbeat(w http.ResponseWriter, _ *http.Request) {
 	w.WriteHeader(http.StatusOK)
@@ -141,6 +247,11 @@ func (s *server) PeerAuthorize(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.HTTPMethod != "" {
+		// the request is authorized (and its token issued) for the normalized path
+		req.HTTPPath = api.NormalizeHTTPPath(req.HTTPPath)
+	}
+
 	peerName := r.TLS.PeerCertificates[0].DNSNames[0]
 	resp, err := s.manager.authorizeIngress(
 		r.Context(),
@@ -149,6 +260,10 @@ func (s *server) PeerAuthorize(w http.ResponseWriter, r *http.Request) {
 				Namespace: req.ServiceNamespace,
 				Name:      req.ServiceName,
 			},
+			ServicePort:   req.ServicePort,
+			HTTPMethod:    req.HTTPMethod,
+			HTTPPath:      req.HTTPPath,
+			TokenLifetime: time.Duration(req.TokenLifetimeSeconds) * time.Second,
 		},
 		peerName)
 	switch {
@@ -163,7 +278,10 @@ func (s *server) PeerAuthorize(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	responseBody, err := json.Marshal(api.AuthorizationResponse{AccessToken: resp.AccessToken})
+	responseBody, err := json.Marshal(api.AuthorizationResponse{
+		AccessToken:          resp.AccessToken,
+		TokenLifetimeSeconds: uint32(resp.TokenLifetime / time.Second),
+	})
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -0,0 +1,185 @@
+// Copyright 2023 The ClusterLink Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package authz
+
+import (
+	cryptotls "crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/clusterlink-net/clusterlink/pkg/apis/clusterlink.net/v1alpha1"
+	"github.com/clusterlink-net/clusterlink/pkg/bootstrap"
+	"github.com/clusterlink-net/clusterlink/pkg/controlplane/api"
+	dpapi "github.com/clusterlink-net/clusterlink/pkg/dataplane/api"
+	"github.com/clusterlink-net/clusterlink/pkg/util/tls"
+)
+
+// requestFrom returns a request with TLS state verified against the given client certificate.
+func requestFrom(t *testing.T, cert, fabricCert *bootstrap.Certificate) *http.Request {
+	t.Helper()
+
+	var chain []*x509.Certificate
+	data := append(cert.RawCert(), fabricCert.RawCert()...)
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+
+		parsed, err := x509.ParseCertificate(block.Bytes)
+		require.Nil(t, err)
+		chain = append(chain, parsed)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+	r.TLS = &cryptotls.ConnectionState{VerifiedChains: [][]*x509.Certificate{chain}}
+	return r
+}
+
+func TestRequireRole(t *testing.T) {
+	dir := t.TempDir()
+
+	fabricCert, err := bootstrap.CreateFabricCertificate("fabric")
+	require.Nil(t, err)
+	peerCert, err := bootstrap.CreatePeerCertificate("peer1", fabricCert)
+	require.Nil(t, err)
+	controlplaneCert, err := bootstrap.CreateControlplaneCertificate("peer1", peerCert)
+	require.Nil(t, err)
+	dataplaneCert, err := bootstrap.CreateDataplaneCertificate("peer1", peerCert)
+	require.Nil(t, err)
+	gwctlCert, err := bootstrap.CreateGWCTLCertificate(peerCert)
+	require.Nil(t, err)
+
+	remotePeerCert, err := bootstrap.CreatePeerCertificate("peer2", fabricCert)
+	require.Nil(t, err)
+	remoteControlplaneCert, err := bootstrap.CreateControlplaneCertificate("peer2", remotePeerCert)
+	require.Nil(t, err)
+	remoteDataplaneCert, err := bootstrap.CreateDataplaneCertificate("peer2", remotePeerCert)
+	require.Nil(t, err)
+	remoteGWCTLCert, err := bootstrap.CreateGWCTLCertificate(remotePeerCert)
+	require.Nil(t, err)
+
+	caFile := filepath.Join(dir, "ca.pem")
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	require.Nil(t, os.WriteFile(caFile, fabricCert.RawCert(), 0o600))
+	require.Nil(t, os.WriteFile(certFile, controlplaneCert.RawCert(), 0o600))
+	require.Nil(t, os.WriteFile(keyFile, controlplaneCert.RawKey(), 0o600))
+	peerTLS, err := tls.ParseFiles(caFile, certFile, keyFile)
+	require.Nil(t, err)
+
+	s := &server{
+		manager: &Manager{peerTLS: peerTLS},
+		logger:  logrus.WithField("component", "controlplane.authz.server"),
+	}
+
+	serve := func(r *http.Request, roles ...api.Role) int {
+		w := httptest.NewRecorder()
+		s.requireRole(roles...)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})).ServeHTTP(w, r)
+		return w.Code
+	}
+
+	// dataplane authorization
+	require.Equal(t, http.StatusOK, serve(requestFrom(t, dataplaneCert, fabricCert), api.RoleDataplane))
+	require.Equal(t, http.StatusForbidden, serve(requestFrom(t, gwctlCert, fabricCert), api.RoleDataplane))
+	require.Equal(t, http.StatusForbidden, serve(requestFrom(t, controlplaneCert, fabricCert), api.RoleDataplane))
+	require.Equal(t, http.StatusForbidden, serve(requestFrom(t, remoteDataplaneCert, fabricCert), api.RoleDataplane))
+
+	// remote peer authorization
+	peerRoles := []api.Role{api.RoleRemotePeer, api.RoleControlplane}
+	require.Equal(t, http.StatusOK, serve(requestFrom(t, remoteControlplaneCert, fabricCert), peerRoles...))
+	require.Equal(t, http.StatusOK, serve(requestFrom(t, controlplaneCert, fabricCert), peerRoles...))
+	require.Equal(t, http.StatusForbidden, serve(requestFrom(t, remoteDataplaneCert, fabricCert), peerRoles...))
+	require.Equal(t, http.StatusForbidden, serve(requestFrom(t, remoteGWCTLCert, fabricCert), peerRoles...))
+	require.Equal(t, http.StatusForbidden, serve(requestFrom(t, gwctlCert, fabricCert), peerRoles...))
+
+	// heartbeats
+	heartbeatRoles := append(peerRoles, api.RoleRemoteDataplane)
+	require.Equal(t, http.StatusOK, serve(requestFrom(t, remoteDataplaneCert, fabricCert), heartbeatRoles...))
+	require.Equal(t, http.StatusForbidden, serve(requestFrom(t, dataplaneCert, fabricCert), heartbeatRoles...))
+
+	// no client certificate
+	require.Equal(t, http.StatusForbidden,
+		serve(httptest.NewRequest(http.MethodGet, "/", http.NoBody), api.RoleDataplane))
+}
+
+func TestAuthorizationCacheControl(t *testing.T) {
+	lifetime := 5 * time.Minute
+	require.Empty(t, authorizationCacheControl(nil, lifetime))
+
+	cache := &v1alpha1.ImportAuthorizationCache{TTLSeconds: 30, StaleSeconds: 60}
+	require.Equal(t, "max-age=30, stale-while-revalidate=60", authorizationCacheControl(cache, lifetime))
+
+	// fail-open defaults to the stale-while-revalidate window
+	cache.FailOpen = true
+	require.Equal(t, "max-age=30, stale-while-revalidate=60, stale-if-error=60", authorizationCacheControl(cache, lifetime))
+
+	// fail-open has its own window, bounded by the token lifetime
+	cache.FailOpenSeconds = 240
+	require.Equal(t, "max-age=30, stale-while-revalidate=60, stale-if-error=240", authorizationCacheControl(cache, lifetime))
+	require.Equal(t, "max-age=30, stale-while-revalidate=60, stale-if-error=90",
+		authorizationCacheControl(cache, 2*time.Minute))
+	require.Equal(t, uint32(270), cacheTokenLifetimeSeconds(cache))
+
+	// fail-open without stale-while-revalidate
+	cache.StaleSeconds = 0
+	require.Equal(t, "max-age=30, stale-if-error=240", authorizationCacheControl(cache, lifetime))
+}
+
+func TestHTTPRequestAttributes(t *testing.T) {
+	tests := []struct {
+		target string
+		path   string
+	}{
+		{target: "/public/index.html", path: "/public/index.html"},
+		{target: "/public/", path: "/public/"},
+		// paths which could bypass a policy matching the "/public/" prefix
+		{target: "/public/../admin", path: "/admin"},
+		{target: "//admin", path: "/admin"},
+		{target: "/%2Fadmin", path: "/admin"},
+		{target: "/public%2F..%2Fadmin", path: "/admin"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			target := strings.TrimSuffix(api.DataplaneIngressAuthorizationPath, "/") + tt.target
+			r := httptest.NewRequest(http.MethodGet, target, http.NoBody)
+			method, path := httpRequestAttributes(r, api.DataplaneIngressAuthorizationPath)
+			require.Equal(t, http.MethodGet, method)
+			require.Equal(t, tt.path, path)
+		})
+	}
+}
+
+func TestTunnelProtocol(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, api.DataplaneIngressAuthorizationPath, http.NoBody)
+	require.Equal(t, v1alpha1.ProtocolTCP, tunnelProtocol(r, api.DataplaneIngressAuthorizationPath))
+
+	r = httptest.NewRequest(
+		http.MethodGet, api.DataplaneIngressAuthorizationPath+strings.TrimPrefix(dpapi.UDPTunnelPath("svc", 53), "/"), http.NoBody)
+	require.Equal(t, v1alpha1.ProtocolUDP, tunnelProtocol(r, api.DataplaneIngressAuthorizationPath))
+}
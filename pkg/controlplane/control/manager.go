@@ -14,8 +14,10 @@
 package control
 
 import (
+	"cmp"
 	"context"
 	"reflect"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -33,12 +35,14 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/ptr"
 	k8sstrings "k8s.io/utils/strings"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	dpapp "github.com/clusterlink-net/clusterlink/cmd/cl-dataplane/app"
 	"github.com/clusterlink-net/clusterlink/pkg/apis/clusterlink.net/v1alpha1"
+	"github.com/clusterlink-net/clusterlink/pkg/metrics"
 	"github.com/clusterlink-net/clusterlink/pkg/util/tls"
 )
 
@@ -100,6 +104,36 @@ func (e conflictingServiceError) Is(target error) bool {
 	return ok
 }
 
+type unsupportedConnectionLimitsError struct {
+	// limit describes the unsupported limit
+	limit         string
+	dataplaneType v1alpha1.DataplaneType
+}
+
+func (e unsupportedConnectionLimitsError) Error() string {
+	return fmt.Sprintf(
+		"%s concurrent connection limits are not supported by the '%s' dataplane",
+		e.limit, e.dataplaneType)
+}
+
+func (e unsupportedConnectionLimitsError) Is(target error) bool {
+	_, ok := target.(*unsupportedConnectionLimitsError)
+	return ok
+}
+
+type unsupportedAuthorizationCacheError struct {
+	dataplaneType v1alpha1.DataplaneType
+}
+
+func (e unsupportedAuthorizationCacheError) Error() string {
+	return fmt.Sprintf("authorization caching is not supported by the '%s' dataplane", e.dataplaneType)
+}
+
+func (e unsupportedAuthorizationCacheError) Is(target error) bool {
+	_, ok := target.(*unsupportedAuthorizationCacheError)
+	return ok
+}
+
 type importEndpointSliceName struct {
 	importName                 string
 	dataplaneEndpointSliceName string
@@ -140,11 +174,21 @@ type Manager struct {
 	crdMode   bool
 	ports     *portManager
 
+	// dataplaneType is the type of the dataplanes, which determines the supported import connection limits
+	dataplaneType v1alpha1.DataplaneType
+
+	// portsLock protects portsRestored
+	portsLock sync.Mutex
+	// portsRestored is set once the target ports recorded by the imports are re-leased
+	portsRestored bool
+
 	lock            sync.Mutex
 	serviceToImport map[string]types.NamespacedName
 
 	// callback for getting all merge imports (for non-CRD mode)
 	getMergeImportListCallback func() *v1alpha1.ImportList
+	// callback for getting all imports (for non-CRD mode)
+	getImportListCallback func() *v1alpha1.ImportList
 	// callback for getting an import (for non-CRD mode)
 	getImportCallback func(name string, imp *v1alpha1.Import) error
 	// callback for setting the status of an export (for non-CRD mode)
@@ -153,10 +197,28 @@ type Manager struct {
 	logger *logrus.Entry
 }
 
+// SetMetrics sets the metrics for peer reachability and target port leases.
+// Must be called before any peer or import is added.
+func (m *Manager) SetMetrics(controlplaneMetrics *metrics.ControlplaneMetrics) {
+	m.peerManager.metrics = controlplaneMetrics
+	m.ports.metrics = controlplaneMetrics
+}
+
+// SetDataplaneType sets the type of the dataplanes.
+// Imports limiting the concurrent connections of each source IP, and exports limiting the concurrent
+// connections of each remote peer, are rejected by the Envoy dataplane, which cannot enforce them.
+func (m *Manager) SetDataplaneType(dataplaneType v1alpha1.DataplaneType) {
+	m.dataplaneType = dataplaneType
+}
+
 func (m *Manager) SetGetMergeImportListCallback(callback func() *v1alpha1.ImportList) {
 	m.getMergeImportListCallback = callback
 }
 
+func (m *Manager) SetGetImportListCallback(callback func() *v1alpha1.ImportList) {
+	m.getImportListCallback = callback
+}
+
 func (m *Manager) SetGetImportCallback(callback func(name string, imp *v1alpha1.Import) error) {
 	m.getImportCallback = callback
 }
@@ -207,13 +269,31 @@ func (m *Manager) AddImport(ctx context.Context, imp *v1alpha1.Import) (err erro
 
 		if errors.Is(err, &conflictingServiceError{}) ||
 			errors.Is(err, &conflictingTargetPortError{}) ||
+			errors.Is(err, &unsupportedConnectionLimitsError{}) ||
+			errors.Is(err, &unsupportedAuthorizationCacheError{}) ||
 			errors.Is(err, &importServiceNotExistError{}) {
 			err = reconcile.TerminalError(err)
 		}
 	}()
 
-	err = m.allocateTargetPort(ctx, imp)
-	if err != nil {
+	if limits := imp.Spec.ConnectionLimits; limits != nil && limits.PerSourceIP.MaxConnections > 0 &&
+		m.dataplaneType == v1alpha1.DataplaneTypeEnvoy {
+		// no target port is leased, so no import listeners are created, as the limits cannot be enforced
+		err = unsupportedConnectionLimitsError{limit: "per-source-IP", dataplaneType: m.dataplaneType}
+		targetPortValidCond.Reason = "Error"
+		targetPortValidCond.Message = err.Error()
+		return err
+	}
+
+	if imp.Spec.AuthorizationCache != nil && m.dataplaneType == v1alpha1.DataplaneTypeEnvoy {
+		// envoy authorizes every connection with the controlplane, so it cannot fail-open while it is unavailable
+		err = unsupportedAuthorizationCacheError{dataplaneType: m.dataplaneType}
+		targetPortValidCond.Reason = "Error"
+		targetPortValidCond.Message = err.Error()
+		return err
+	}
+
+	if err := m.allocateTargetPort(ctx, imp); err != nil {
 		targetPortValidCond.Reason = "Error"
 		targetPortValidCond.Message = err.Error()
 		return err
@@ -241,6 +321,17 @@ func (m *Manager) AddImport(ctx context.Context, imp *v1alpha1.Import) (err erro
 		serviceName = SystemServiceName(importName)
 	}
 
+	importPorts := imp.Spec.ServicePorts()
+	servicePorts := make([]v1.ServicePort, len(importPorts))
+	for i := range importPorts {
+		servicePorts[i] = v1.ServicePort{
+			Name:       importPorts[i].Name,
+			Protocol:   serviceProtocol(imp.Spec.Protocol),
+			Port:       int32(importPorts[i].Port),
+			TargetPort: intstr.FromInt32(int32(importPorts[i].TargetPort)),
+		}
+	}
+
 	systemService := &v1.Service{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      serviceName,
@@ -248,13 +339,7 @@ func (m *Manager) AddImport(ctx context.Context, imp *v1alpha1.Import) (err erro
 			Labels:    make(map[string]string),
 		},
 		Spec: v1.ServiceSpec{
-			Ports: []v1.ServicePort{
-				{
-					Protocol:   v1.ProtocolTCP,
-					Port:       int32(imp.Spec.Port),
-					TargetPort: intstr.FromInt32(int32(imp.Spec.TargetPort)),
-				},
-			},
+			Ports:    servicePorts,
 			Selector: map[string]string{"app": dpapp.Name},
 			Type:     v1.ServiceTypeClusterIP,
 		},
@@ -353,11 +438,17 @@ func (m *Manager) AddExport(ctx context.Context, export *v1alpha1.Export) (err e
 			}
 		}
 
-		if errors.Is(err, &exportServiceNotExistError{}) {
+		if errors.Is(err, &exportServiceNotExistError{}) || errors.Is(err, &unsupportedConnectionLimitsError{}) {
 			err = reconcile.TerminalError(err)
 		}
 	}()
 
+	if limits := export.Spec.ConnectionLimits; limits != nil && limits.MaxConnections > 0 &&
+		m.dataplaneType == v1alpha1.DataplaneTypeEnvoy {
+		// envoy circuit breakers would limit the connections of all remote peers combined
+		return unsupportedConnectionLimitsError{limit: "per-peer", dataplaneType: m.dataplaneType}
+	}
+
 	if export.Spec.Host != "" {
 		return nil
 	}
@@ -516,29 +607,105 @@ func (m *Manager) checkImportService(ctx context.Context, name types.NamespacedN
 	return nil
 }
 
+// allocateTargetPort leases a target port for each port of an import.
+// Leased target ports are set in the import spec, which persists them.
 func (m *Manager) allocateTargetPort(ctx context.Context, imp *v1alpha1.Import) error {
+	if err := m.restoreTargetPorts(ctx); err != nil {
+		return fmt.Errorf("cannot restore target ports: %w", err)
+	}
+
 	name := types.NamespacedName{
 		Namespace: imp.Namespace,
 		Name:      imp.Name,
 	}
 
-	leasedPort, err := m.ports.Lease(name, imp.Spec.TargetPort)
+	// a target port is leased per imported port
+	portNames := []string{""}
+	targetPorts := []*uint16{&imp.Spec.TargetPort}
+	if len(imp.Spec.Ports) > 0 {
+		portNames = make([]string, len(imp.Spec.Ports))
+		targetPorts = make([]*uint16, len(imp.Spec.Ports))
+		for i := range imp.Spec.Ports {
+			portNames[i] = imp.Spec.Ports[i].Name
+			targetPorts[i] = &imp.Spec.Ports[i].TargetPort
+		}
+	}
+
+	// release target ports of ports removed from the import
+	m.ports.Release(name, portNames...)
+
+	updated := false
+	for i, portName := range portNames {
+		leaseName := portLeaseName{NamespacedName: name, Port: portName}
+		leasedPort, err := m.ports.Lease(leaseName, *targetPorts[i])
+		if err != nil {
+			return fmt.Errorf("cannot generate listening port: %w", err)
+		}
+
+		if *targetPorts[i] == 0 {
+			*targetPorts[i] = leasedPort
+			updated = true
+		}
+	}
+
+	if updated && m.crdMode {
+		m.logger.Infof("Updating target ports for import %v.", name)
+		if err := m.client.Update(ctx, imp); err != nil {
+			m.ports.Release(name)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// restoreTargetPorts re-leases the target ports set in the spec of all imports, once,
+// before any new target port is leased. This keeps the target ports of imports across controlplane restarts.
+// A target port set by several imports is leased by the oldest import, with ties broken by namespace and name
+// (imports of a non-CRD controlplane have no creation time, so only their names order them).
+// The other imports fail to lease it when they are added, reporting the conflict in their status.
+func (m *Manager) restoreTargetPorts(ctx context.Context) error {
+	m.portsLock.Lock()
+	defer m.portsLock.Unlock()
+
+	if m.portsRestored {
+		return nil
+	}
+
+	importList, err := m.getImportList(ctx)
 	if err != nil {
-		return fmt.Errorf("cannot generate listening port: %w", err)
+		return err
 	}
 
-	if imp.Spec.TargetPort == 0 {
-		imp.Spec.TargetPort = leasedPort
+	imports := importList.Items
+	slices.SortFunc(imports, func(a, b v1alpha1.Import) int {
+		return cmp.Or(
+			a.CreationTimestamp.Time.Compare(b.CreationTimestamp.Time),
+			cmp.Compare(a.Namespace, b.Namespace),
+			cmp.Compare(a.Name, b.Name))
+	})
 
-		if m.crdMode {
-			m.logger.Infof("Updating target port for import %v.", name)
-			if err := m.client.Update(ctx, imp); err != nil {
-				m.ports.Release(name)
-				return err
+	for i := range imports {
+		imp := &imports[i]
+		name := types.NamespacedName{
+			Namespace: imp.Namespace,
+			Name:      imp.Name,
+		}
+
+		for _, port := range imp.Spec.ServicePorts() {
+			if port.TargetPort == 0 {
+				continue
+			}
+
+			leaseName := portLeaseName{NamespacedName: name, Port: port.Name}
+			if _, err := m.ports.Lease(leaseName, port.TargetPort); err != nil {
+				m.logger.Warnf("Cannot restore target port for %v: %v.", leaseName, err)
 			}
 		}
 	}
 
+	m.logger.Infof("Restored target ports of %d imports.", len(imports))
+	m.portsRestored = true
 	return nil
 }
 
@@ -700,8 +867,18 @@ func (m *Manager) checkImportEndpointSlice(
 		importName:                 imp.Name,
 		dataplaneEndpointSliceName: dataplaneEndpointSlice.Name,
 	}).Get()
-	protocol := v1.ProtocolTCP
-	port32 := int32(imp.Spec.TargetPort)
+	protocol := serviceProtocol(imp.Spec.Protocol)
+	importPorts := imp.Spec.ServicePorts()
+	endpointPorts := make([]discv1.EndpointPort, len(importPorts))
+	for i := range importPorts {
+		endpointPorts[i] = discv1.EndpointPort{
+			Port:     ptr.To(int32(importPorts[i].TargetPort)),
+			Protocol: &protocol,
+		}
+		if importPorts[i].Name != "" {
+			endpointPorts[i].Name = ptr.To(importPorts[i].Name)
+		}
+	}
 
 	importEndpointSlice := discv1.EndpointSlice{
 		ObjectMeta: metav1.ObjectMeta{
@@ -715,12 +892,7 @@ func (m *Manager) checkImportEndpointSlice(
 		},
 		AddressType: discv1.AddressTypeIPv4,
 		Endpoints:   dataplaneEndpointSlice.Endpoints,
-		Ports: []discv1.EndpointPort{
-			{
-				Port:     &port32,
-				Protocol: &protocol,
-			},
-		},
+		Ports:       endpointPorts,
 	}
 
 	var oldImportEndpointSlice discv1.EndpointSlice
@@ -816,6 +988,19 @@ func (m *Manager) getImport(ctx context.Context, name types.NamespacedName, imp
 	return m.client.Get(ctx, name, imp)
 }
 
+func (m *Manager) getImportList(ctx context.Context) (*v1alpha1.ImportList, error) {
+	if m.getImportListCallback != nil {
+		return m.getImportListCallback(), nil
+	}
+
+	importList := v1alpha1.ImportList{}
+	if err := m.client.List(ctx, &importList); err != nil {
+		return nil, err
+	}
+
+	return &importList, nil
+}
+
 func (m *Manager) getMergeImportList(ctx context.Context) (*v1alpha1.ImportList, error) {
 	if m.getMergeImportListCallback != nil {
 		return m.getMergeImportListCallback(), nil
@@ -857,6 +1042,15 @@ func SystemServiceName(name types.NamespacedName) string {
 		hash.Sum(nil))
 }
 
+// serviceProtocol returns the service protocol of an imported service protocol.
+func serviceProtocol(protocol v1alpha1.Protocol) v1.Protocol {
+	if protocol == v1alpha1.ProtocolUDP {
+		return v1.ProtocolUDP
+	}
+
+	return v1.ProtocolTCP
+}
+
 func serviceChanged(svc1, svc2 *v1.Service) bool {
 	if svc1.Spec.Type != svc2.Spec.Type {
 		return true
@@ -881,6 +1075,10 @@ func serviceChanged(svc1, svc2 *v1.Service) bool {
 	}
 
 	for i := 0; i < len(svc1.Spec.Ports); i++ {
+		if svc1.Spec.Ports[i].Name != svc2.Spec.Ports[i].Name {
+			return true
+		}
+
 		if svc1.Spec.Ports[i].Protocol != svc2.Spec.Ports[i].Protocol {
 			return true
 		}
@@ -923,6 +1121,19 @@ func endpointSliceChanged(endpointSlice1, endpointSlice2 *discv1.EndpointSlice)
 		return true
 	}
 
+	if len(endpointSlice1.Ports) != len(endpointSlice2.Ports) {
+		return true
+	}
+
+	for i := range endpointSlice1.Ports {
+		port1 := endpointSlice1.Ports[i]
+		port2 := endpointSlice2.Ports[i]
+		if ptr.Deref(port1.Name, "") != ptr.Deref(port2.Name, "") ||
+			!reflect.DeepEqual(port1.Port, port2.Port) || !reflect.DeepEqual(port1.Protocol, port2.Protocol) {
+			return true
+		}
+	}
+
 	if len(endpointSlice1.Endpoints) != len(endpointSlice2.Endpoints) {
 		return true
 	}
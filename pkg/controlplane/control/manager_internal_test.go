@@ -0,0 +1,80 @@
+// Copyright 2023 The ClusterLink Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package control
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/clusterlink-net/clusterlink/pkg/apis/clusterlink.net/v1alpha1"
+)
+
+func TestUnsupportedConnectionLimits(t *testing.T) {
+	m := NewManager(nil, nil, "ns", false)
+	m.SetDataplaneType(v1alpha1.DataplaneTypeEnvoy)
+
+	imp := &v1alpha1.Import{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "svc"},
+		Spec: v1alpha1.ImportSpec{
+			Port: 80,
+			ConnectionLimits: &v1alpha1.ImportConnectionLimits{
+				PerSourceIP: v1alpha1.ConnectionLimits{MaxConnections: 10},
+			},
+		},
+	}
+
+	// the envoy dataplane cannot limit the concurrent connections of each source IP
+	err := m.AddImport(context.Background(), imp)
+	require.True(t, errors.Is(err, &unsupportedConnectionLimitsError{}))
+	require.Zero(t, imp.Spec.TargetPort)
+
+	// the envoy dataplane cannot limit the concurrent connections of each remote peer
+	var status *v1alpha1.Export
+	m.SetExportStatusCallback(func(export *v1alpha1.Export) { status = export })
+	export := &v1alpha1.Export{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "svc"},
+		Spec: v1alpha1.ExportSpec{
+			Port:             80,
+			ConnectionLimits: &v1alpha1.ConnectionLimits{MaxConnections: 10},
+		},
+	}
+	err = m.AddExport(context.Background(), export)
+	require.True(t, errors.Is(err, &unsupportedConnectionLimitsError{}))
+	require.NotNil(t, status)
+	require.False(t, meta.IsStatusConditionTrue(status.Status.Conditions, v1alpha1.ExportValid))
+}
+
+func TestUnsupportedAuthorizationCache(t *testing.T) {
+	m := NewManager(nil, nil, "ns", false)
+	m.SetDataplaneType(v1alpha1.DataplaneTypeEnvoy)
+
+	imp := &v1alpha1.Import{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "svc"},
+		Spec: v1alpha1.ImportSpec{
+			Port:               80,
+			AuthorizationCache: &v1alpha1.ImportAuthorizationCache{TTLSeconds: 30},
+		},
+	}
+
+	// the envoy dataplane does not cache authorization results
+	err := m.AddImport(context.Background(), imp)
+	require.True(t, errors.Is(err, &unsupportedAuthorizationCacheError{}))
+	require.Zero(t, imp.Spec.TargetPort)
+	require.False(t, meta.IsStatusConditionTrue(imp.Status.Conditions, v1alpha1.ImportTargetPortValid))
+}
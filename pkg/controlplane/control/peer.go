@@ -25,6 +25,7 @@ import (
 
 	"github.com/clusterlink-net/clusterlink/pkg/apis/clusterlink.net/v1alpha1"
 	"github.com/clusterlink-net/clusterlink/pkg/controlplane/peer"
+	"github.com/clusterlink-net/clusterlink/pkg/metrics"
 	"github.com/clusterlink-net/clusterlink/pkg/util/tls"
 )
 
@@ -45,6 +46,7 @@ type peerMonitor struct {
 	pr             *v1alpha1.Peer
 	client         *peer.Client
 	statusCallback func(*v1alpha1.Peer)
+	metrics        *metrics.ControlplaneMetrics
 
 	wg     *sync.WaitGroup
 	stopCh chan struct{}
@@ -57,6 +59,7 @@ type peerManager struct {
 	client             client.Client
 	peerTLS            *tls.ParsedCertData
 	peerStatusCallback func(*v1alpha1.Peer)
+	metrics            *metrics.ControlplaneMetrics
 
 	lock     sync.Mutex
 	monitors map[string]*peerMonitor
@@ -91,6 +94,7 @@ func (m *peerMonitor) Start() {
 	defer ticker.Stop()
 
 	healthy := meta.IsStatusConditionTrue(m.pr.Status.Conditions, v1alpha1.PeerReachable)
+	m.metrics.SetPeerReachable(m.pr.Name, healthy)
 	strikeCount := 0
 	threshold := 1 // require a single request on startup
 	reachableCond := metav1.Condition{
@@ -106,7 +110,11 @@ func (m *peerMonitor) Start() {
 			break
 		}
 
+		heartbeatStart := time.Now()
 		heartbeatOK := m.client.GetHeartbeat() == nil
+		if heartbeatOK {
+			m.metrics.ObservePeerHeartbeat(m.pr.Name, time.Since(heartbeatStart))
+		}
 		if healthy == heartbeatOK {
 			if !healthy {
 				ticker.Reset(unhealthyInterval)
@@ -138,6 +146,7 @@ func (m *peerMonitor) Start() {
 
 		strikeCount = 0
 		healthy = heartbeatOK
+		m.metrics.SetPeerReachable(m.pr.Name, healthy)
 
 		m.lock.Lock()
 		meta.SetStatusCondition(&m.pr.Status.Conditions, reachableCond)
@@ -183,6 +192,8 @@ func (m *peerManager) DeletePeer(name string) {
 	m.lock.Lock()
 	defer m.lock.Unlock()
 	delete(m.monitors, name)
+
+	m.metrics.DeletePeer(name)
 }
 
 // Name of the peer monitor runnable.
@@ -260,6 +271,10 @@ func (m *peerManager) queueStatusUpdate(pr *v1alpha1.Peer) {
 }
 
 func peerChanged(pr1, pr2 *v1alpha1.Peer) bool {
+	if pr1.Spec.GatewaySRV != pr2.Spec.GatewaySRV {
+		return true
+	}
+
 	if len(pr1.Spec.Gateways) != len(pr2.Spec.Gateways) {
 		return true
 	}
@@ -296,6 +311,7 @@ func newPeerMonitor(pr *v1alpha1.Peer, manager *peerManager) *peerMonitor {
 		pr:             pr,
 		client:         peer.NewClient(pr, manager.peerTLS.ClientConfig(pr.Name)),
 		statusCallback: manager.queueStatusUpdate,
+		metrics:        manager.metrics,
 		wg:             &manager.monitorWG,
 		stopCh:         make(chan struct{}),
 		logger:         logger,
@@ -16,10 +16,13 @@ package control
 import (
 	"fmt"
 	"math/rand"
+	"slices"
 	"sync"
 
 	"github.com/sirupsen/logrus"
 	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/clusterlink-net/clusterlink/pkg/metrics"
 )
 
 const (
@@ -36,9 +39,24 @@ const (
 	maxRandomTries = 40
 )
 
+// portLeaseName identifies a port lease, held by a port of an imported service.
+type portLeaseName struct {
+	types.NamespacedName
+	// Port is the port name of a multi-port imported service.
+	Port string
+}
+
+func (n portLeaseName) String() string {
+	if n.Port == "" {
+		return n.NamespacedName.String()
+	}
+
+	return n.NamespacedName.String() + "/" + n.Port
+}
+
 type conflictingTargetPortError struct {
 	port      uint16
-	leaseName types.NamespacedName
+	leaseName portLeaseName
 }
 
 func (e conflictingTargetPortError) Error() string {
@@ -53,10 +71,11 @@ func (e conflictingTargetPortError) Is(target error) bool {
 // portManager leases ports for use by imported services.
 type portManager struct {
 	lock         sync.Mutex
-	leasesByPort map[uint16]types.NamespacedName
-	leasesByName map[types.NamespacedName]uint16
+	leasesByPort map[uint16]portLeaseName
+	leasesByName map[portLeaseName]uint16
 
-	logger *logrus.Entry
+	metrics *metrics.ControlplaneMetrics
+	logger  *logrus.Entry
 }
 
 // getRandomFreePort returns a random free port.
@@ -91,7 +110,7 @@ func (m *portManager) getRandomFreePort() uint16 {
 }
 
 // Lease marks a port as taken by the given name. If port is 0, some random free port is returned.
-func (m *portManager) Lease(name types.NamespacedName, port uint16) (uint16, error) {
+func (m *portManager) Lease(name portLeaseName, port uint16) (uint16, error) {
 	m.logger.Infof("Leasing %d for %v.", port, name)
 
 	m.lock.Lock()
@@ -125,21 +144,29 @@ func (m *portManager) Lease(name types.NamespacedName, port uint16) (uint16, err
 	// mark port is leased
 	m.leasesByPort[port] = name
 	m.leasesByName[name] = port
+	m.metrics.SetPortLeases(len(m.leasesByName))
 
 	return port, nil
 }
 
-// Release returns a leased port to be re-used by others.
-func (m *portManager) Release(name types.NamespacedName) {
-	m.logger.Infof("Returning port for: '%v'.", name)
+// Release returns the ports leased by an imported service to be re-used by others,
+// except for the ports leased by the given port names.
+func (m *portManager) Release(name types.NamespacedName, keepPorts ...string) {
+	m.logger.Infof("Returning ports for: '%v' (keeping %v).", name, keepPorts)
 
 	m.lock.Lock()
 	defer m.lock.Unlock()
 
-	if port, ok := m.leasesByName[name]; ok {
-		delete(m.leasesByName, name)
+	for leaseName, port := range m.leasesByName {
+		if leaseName.NamespacedName != name || slices.Contains(keepPorts, leaseName.Port) {
+			continue
+		}
+
+		delete(m.leasesByName, leaseName)
 		delete(m.leasesByPort, port)
 	}
+
+	m.metrics.SetPortLeases(len(m.leasesByName))
 }
 
 // newPortManager returns a new empty portManager.
@@ -153,8 +180,8 @@ func newPortManager() *portManager {
 	).Info("Initialized.")
 
 	return &portManager{
-		leasesByPort: make(map[uint16]types.NamespacedName),
-		leasesByName: make(map[types.NamespacedName]uint16),
+		leasesByPort: make(map[uint16]portLeaseName),
+		leasesByName: make(map[portLeaseName]uint16),
 		logger:       logger,
 	}
 }
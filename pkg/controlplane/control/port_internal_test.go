@@ -0,0 +1,110 @@
+// Copyright 2023 The ClusterLink Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package control
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/clusterlink-net/clusterlink/pkg/apis/clusterlink.net/v1alpha1"
+)
+
+func TestPortManagerNamedPorts(t *testing.T) {
+	m := newPortManager()
+	name := types.NamespacedName{Namespace: "ns", Name: "svc"}
+	http := portLeaseName{NamespacedName: name, Port: "http"}
+	grpc := portLeaseName{NamespacedName: name, Port: "grpc"}
+
+	// each named port leases a different port
+	httpPort, err := m.Lease(http, 0)
+	require.Nil(t, err)
+	grpcPort, err := m.Lease(grpc, 0)
+	require.Nil(t, err)
+	require.NotEqual(t, httpPort, grpcPort)
+
+	// re-leasing returns the existing port
+	port, err := m.Lease(http, 0)
+	require.Nil(t, err)
+	require.Equal(t, httpPort, port)
+
+	// a port leased by another named port of the same service conflicts
+	_, err = m.Lease(grpc, httpPort)
+	require.True(t, errors.Is(err, &conflictingTargetPortError{}))
+
+	// releasing keeps the ports of the given port names
+	m.Release(name, "grpc")
+	require.Len(t, m.leasesByName, 1)
+	port, err = m.Lease(grpc, 0)
+	require.Nil(t, err)
+	require.Equal(t, grpcPort, port)
+
+	// releasing without port names releases all ports of the service
+	m.Release(name)
+	require.Empty(t, m.leasesByName)
+	require.Empty(t, m.leasesByPort)
+}
+
+func TestRestoreTargetPorts(t *testing.T) {
+	newImport := func(name string, created time.Time, spec v1alpha1.ImportSpec) *v1alpha1.Import {
+		return &v1alpha1.Import{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:         "ns",
+				Name:              name,
+				CreationTimestamp: metav1.NewTime(created),
+			},
+			Spec: spec,
+		}
+	}
+
+	now := time.Now()
+	// import with a target port leased before the restart
+	leased := newImport("leased", now, v1alpha1.ImportSpec{Port: 80, TargetPort: 2000})
+	// older import with a target port in its spec
+	older := newImport("older", now.Add(-time.Minute), v1alpha1.ImportSpec{
+		Ports: []v1alpha1.ImportPort{{Name: "http", Port: 80, TargetPort: 3000}},
+	})
+	// import with a target port in its spec, conflicting with the older import
+	conflicting := newImport("conflicting", now, v1alpha1.ImportSpec{
+		Ports: []v1alpha1.ImportPort{{Name: "http", Port: 80, TargetPort: 3000}},
+	})
+	// import without a target port
+	unleased := newImport("unleased", now, v1alpha1.ImportSpec{Port: 80})
+
+	m := NewManager(nil, nil, "ns", false)
+	m.SetGetImportListCallback(func() *v1alpha1.ImportList {
+		return &v1alpha1.ImportList{
+			Items: []v1alpha1.Import{*conflicting, *unleased, *leased, *older},
+		}
+	})
+
+	// allocating for an import without a target port first does not lease any restored port
+	require.Nil(t, m.allocateTargetPort(context.Background(), unleased))
+	require.NotZero(t, unleased.Spec.TargetPort)
+	require.NotContains(t, []uint16{2000, 3000}, unleased.Spec.TargetPort)
+
+	// the target port leased before the restart is re-leased
+	require.Nil(t, m.allocateTargetPort(context.Background(), leased))
+	require.Equal(t, uint16(2000), leased.Spec.TargetPort)
+
+	// a conflicting target port is leased by the older import
+	err := m.allocateTargetPort(context.Background(), conflicting)
+	require.True(t, errors.Is(err, &conflictingTargetPortError{}))
+	require.Nil(t, m.allocateTargetPort(context.Background(), older))
+}
@@ -39,8 +39,8 @@ type ConnectionStatusAttr struct {
 	ConnectionID    string // Unique ID to track a connection from start to end within the gateway
 	SrcService      string // Source application/service initiating the connection
 	DstService      string // Destination application/service receiving the connection
-	IncomingBytes   int
-	OutgoingBytes   int
+	IncomingBytes   int    // Bytes received from the remote peer, and sent to the local application/service
+	OutgoingBytes   int    // Bytes received from the local application/service, and sent to the remote peer
 	DestinationPeer string // The peer(gateway) where the destination/source service is located depending on the Direction
 	StartTstamp     time.Time
 	LastTstamp      time.Time
@@ -14,12 +14,15 @@
 package peer
 
 import (
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"sync"
+	"time"
 
 	"github.com/sirupsen/logrus"
 
@@ -30,9 +33,19 @@ import (
 
 // Client for accessing a remote peer.
 type Client struct {
-	// jsonapi clients for connecting to the remote peer (one per each gateway)
+	peer      *v1alpha1.Peer
+	tlsConfig *tls.Config
+	resolver  Resolver
+
+	lock sync.Mutex
+	// jsonapi clients for connecting to the remote peer (one per each resolved gateway address)
 	clients []*jsonapi.Client
-	logger  *logrus.Entry
+	// resolved is the time the gateways were last resolved
+	resolved time.Time
+	// resolving is closed once an in-progress resolution of the gateways completes
+	resolving chan struct{}
+
+	logger *logrus.Entry
 }
 
 // RemoteServerAuthorizationResponse represents an authorization response received from a remote controlplane server.
@@ -43,6 +56,79 @@ type RemoteServerAuthorizationResponse struct {
 	Allowed bool
 	// AccessToken is a token that allows accessing the requested service.
 	AccessToken string
+	// TokenLifetimeSeconds is the lifetime of the access token, or zero if the default lifetime was used.
+	TokenLifetimeSeconds uint32
+}
+
+// getClients returns the clients of the peer gateways. Gateways are resolved on first use,
+// and once resolved are re-resolved in the background, while the previous clients are returned.
+func (c *Client) getClients() []*jsonapi.Client {
+	c.lock.Lock()
+	clients := c.clients
+	resolving := c.resolving
+	if resolving == nil && (clients == nil || time.Since(c.resolved) >= ResolveInterval) {
+		resolving = make(chan struct{})
+		c.resolving = resolving
+		go c.resolve(resolving)
+	}
+	c.lock.Unlock()
+
+	if clients != nil {
+		return clients
+	}
+
+	// wait for the first resolution
+	<-resolving
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.clients
+}
+
+// resolve resolves the peer gateways, updating the clients and closing done once completed.
+func (c *Client) resolve(done chan struct{}) {
+	defer close(done)
+
+	c.lock.Lock()
+	prevClients := c.clients
+	c.lock.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), resolveTimeout)
+	defer cancel()
+
+	clients := prevClients
+	gateways, err := ResolveGateways(ctx, c.resolver, c.peer)
+	if err != nil {
+		c.logger.Warnf("Unable to resolve gateways: %v.", err)
+	}
+
+	// on errors, keep using the previously resolved gateways
+	if err == nil || prevClients == nil {
+		clientsByURL := make(map[string]*jsonapi.Client, len(prevClients))
+		for _, client := range prevClients {
+			clientsByURL[client.ServerURL()] = client
+		}
+
+		gateways = resolveAddresses(ctx, c.resolver, gateways)
+		clients = make([]*jsonapi.Client, len(gateways))
+		for i, gateway := range gateways {
+			client := jsonapi.NewClient(gateway.Host, gateway.Port, c.tlsConfig)
+			if prevClient, ok := clientsByURL[client.ServerURL()]; ok {
+				// re-use existing client to preserve its open connections
+				client = prevClient
+			}
+			clients[i] = client
+		}
+
+		c.logger.Debugf("Resolved %d gateway addresses.", len(clients))
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.clients = clients
+	c.resolved = time.Now()
+	c.resolving = nil
 }
 
 // getResponse tries all gateways in parallel for a response.
@@ -51,8 +137,12 @@ type RemoteServerAuthorizationResponse struct {
 func (c *Client) getResponse(
 	getRespFunc func(client *jsonapi.Client) (*jsonapi.Response, error),
 ) (*jsonapi.Response, error) {
-	if len(c.clients) == 1 {
-		return getRespFunc(c.clients[0])
+	clients := c.getClients()
+	switch len(clients) {
+	case 0:
+		return nil, fmt.Errorf("no gateways found for peer '%s'", c.peer.Name)
+	case 1:
+		return getRespFunc(clients[0])
 	}
 
 	results := make(chan struct {
@@ -61,7 +151,7 @@ func (c *Client) getResponse(
 	})
 	var done bool
 	var lock sync.Mutex
-	for _, client := range c.clients {
+	for _, client := range clients {
 		go func(currClient *jsonapi.Client) {
 			resp, err := getRespFunc(currClient)
 			lock.Lock()
@@ -77,7 +167,7 @@ func (c *Client) getResponse(
 	}
 
 	var retErr error
-	for range c.clients {
+	for range clients {
 		result := <-results
 		if result.error == nil {
 			lock.Lock()
@@ -128,6 +218,7 @@ func (c *Client) Authorize(req *api.AuthorizationRequest) (*RemoteServerAuthoriz
 
 	resp.Allowed = true
 	resp.AccessToken = authResp.AccessToken
+	resp.TokenLifetimeSeconds = authResp.TokenLifetimeSeconds
 	return resp, nil
 }
 
@@ -149,17 +240,15 @@ func (c *Client) GetHeartbeat() error {
 }
 
 // NewClient returns a new Peer API client.
+// The peer gateways are resolved on first use, and periodically re-resolved in the background.
 func NewClient(peer *v1alpha1.Peer, tlsConfig *tls.Config) *Client {
-	clients := make([]*jsonapi.Client, len(peer.Spec.Gateways))
-	for i, endpoint := range peer.Spec.Gateways {
-		clients[i] = jsonapi.NewClient(endpoint.Host, endpoint.Port, tlsConfig)
-	}
-
 	return &Client{
-		clients: clients,
+		peer:      peer,
+		tlsConfig: tlsConfig,
+		resolver:  net.DefaultResolver,
 		logger: logrus.WithFields(logrus.Fields{
 			"component": "controlplane.peer.client",
-			"peer":      peer,
+			"peer":      peer.Name,
 		}),
 	}
 }
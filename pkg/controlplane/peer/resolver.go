@@ -0,0 +1,106 @@
+// Copyright 2023 The ClusterLink Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package peer
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/clusterlink-net/clusterlink/pkg/apis/clusterlink.net/v1alpha1"
+)
+
+const (
+	// ResolveInterval is the interval between re-resolutions of the peer gateways DNS names.
+	ResolveInterval = 30 * time.Second
+	// resolveTimeout is the timeout for resolving the gateways of a peer.
+	resolveTimeout = 5 * time.Second
+)
+
+// Resolver resolves DNS names of peer gateways.
+type Resolver interface {
+	// LookupHost returns the addresses of a host.
+	LookupHost(ctx context.Context, host string) ([]string, error)
+	// LookupSRV returns the SRV records of a service.
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+}
+
+// ResolveSRVGateways returns the gateways pointed to by the SRV records of a DNS name,
+// ordered by priority and randomized by weight.
+func ResolveSRVGateways(ctx context.Context, resolver Resolver, name string) ([]v1alpha1.Endpoint, error) {
+	_, records, err := resolver.LookupSRV(ctx, "", "", name)
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve SRV name '%s': %w", name, err)
+	}
+
+	gateways := make([]v1alpha1.Endpoint, 0, len(records))
+	for _, record := range records {
+		gateways = append(gateways, v1alpha1.Endpoint{
+			Host: strings.TrimSuffix(record.Target, "."),
+			Port: record.Port,
+		})
+	}
+
+	return gateways, nil
+}
+
+// ResolveGateways returns the static gateways of a peer, followed by the gateways pointed to by its SRV name.
+// If the SRV name cannot be resolved, the static gateways are returned together with the error.
+func ResolveGateways(ctx context.Context, resolver Resolver, peer *v1alpha1.Peer) ([]v1alpha1.Endpoint, error) {
+	gateways := append([]v1alpha1.Endpoint{}, peer.Spec.Gateways...)
+	if peer.Spec.GatewaySRV == "" {
+		return gateways, nil
+	}
+
+	srvGateways, err := ResolveSRVGateways(ctx, resolver, peer.Spec.GatewaySRV)
+	if err != nil {
+		return gateways, err
+	}
+
+	return append(gateways, srvGateways...), nil
+}
+
+// resolveAddresses expands each gateway to a gateway per each address of its host.
+// Gateways whose host cannot be resolved are kept as-is.
+func resolveAddresses(ctx context.Context, resolver Resolver, gateways []v1alpha1.Endpoint) []v1alpha1.Endpoint {
+	addresses := make([]v1alpha1.Endpoint, 0, len(gateways))
+	seen := make(map[v1alpha1.Endpoint]bool, len(gateways))
+	add := func(gateway v1alpha1.Endpoint) {
+		if !seen[gateway] {
+			seen[gateway] = true
+			addresses = append(addresses, gateway)
+		}
+	}
+
+	for _, gateway := range gateways {
+		if net.ParseIP(gateway.Host) != nil {
+			add(gateway)
+			continue
+		}
+
+		hostAddresses, err := resolver.LookupHost(ctx, gateway.Host)
+		if err != nil || len(hostAddresses) == 0 {
+			add(gateway)
+			continue
+		}
+
+		for _, address := range hostAddresses {
+			add(v1alpha1.Endpoint{Host: address, Port: gateway.Port})
+		}
+	}
+
+	return addresses
+}
@@ -0,0 +1,176 @@
+// Copyright 2023 The ClusterLink Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package peer
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/clusterlink-net/clusterlink/pkg/apis/clusterlink.net/v1alpha1"
+)
+
+// fakeResolver resolves names from static maps.
+type fakeResolver struct {
+	hosts map[string][]string
+	srv   map[string][]*net.SRV
+}
+
+func (r *fakeResolver) LookupHost(_ context.Context, host string) ([]string, error) {
+	addresses, ok := r.hosts[host]
+	if !ok {
+		return nil, errors.New("no such host")
+	}
+	return addresses, nil
+}
+
+func (r *fakeResolver) LookupSRV(_ context.Context, _, _, name string) (string, []*net.SRV, error) {
+	records, ok := r.srv[name]
+	if !ok {
+		return "", nil, errors.New("no such host")
+	}
+	return name, records, nil
+}
+
+func TestClientResolveGateways(t *testing.T) {
+	resolver := &fakeResolver{
+		hosts: map[string][]string{
+			"gw.example.com": {"10.0.0.1", "10.0.0.2"},
+			"lb.example.com": {"10.0.1.1"},
+		},
+		srv: map[string][]*net.SRV{
+			"_clusterlink._tcp.example.com": {
+				{Target: "lb.example.com.", Port: 8443},
+				{Target: "unresolved.example.com.", Port: 443},
+			},
+		},
+	}
+
+	pr := &v1alpha1.Peer{
+		ObjectMeta: metav1.ObjectMeta{Name: "peer1"},
+		Spec: v1alpha1.PeerSpec{
+			Gateways:   []v1alpha1.Endpoint{{Host: "gw.example.com", Port: 443}, {Host: "10.0.0.1", Port: 443}},
+			GatewaySRV: "_clusterlink._tcp.example.com",
+		},
+	}
+
+	client := NewClient(pr, nil)
+	client.resolver = resolver
+
+	serverURLs := func() []string {
+		var urls []string
+		for _, cl := range client.getClients() {
+			urls = append(urls, cl.ServerURL())
+		}
+		return urls
+	}
+	// waitResolved waits for a background re-resolution to complete
+	waitResolved := func() {
+		require.Eventually(t, func() bool {
+			client.lock.Lock()
+			defer client.lock.Unlock()
+			return client.resolving == nil
+		}, time.Second, time.Millisecond)
+	}
+
+	// multiple A records expand to multiple gateways, duplicates are removed
+	require.Equal(t, []string{
+		"https://10.0.0.1:443",
+		"https://10.0.0.2:443",
+		"https://10.0.1.1:8443",
+		"https://unresolved.example.com:443",
+	}, serverURLs())
+
+	// gateways are not re-resolved before the resolve interval passes
+	clients := client.getClients()
+	resolver.hosts["lb.example.com"] = []string{"10.0.1.2"}
+	require.Equal(t, clients, client.getClients())
+
+	// re-resolution happens in the background, returning the previous clients meanwhile,
+	// and keeps existing clients
+	client.resolved = client.resolved.Add(-ResolveInterval)
+	require.Equal(t, clients, client.getClients())
+	waitResolved()
+	newClients := client.getClients()
+	require.Equal(t, "https://10.0.1.2:8443", newClients[2].ServerURL())
+	require.Same(t, clients[0], newClients[0])
+
+	// SRV resolution failure keeps the previous gateways
+	delete(resolver.srv, pr.Spec.GatewaySRV)
+	client.resolved = client.resolved.Add(-ResolveInterval)
+	require.Equal(t, newClients, client.getClients())
+	waitResolved()
+	require.Equal(t, newClients, client.getClients())
+
+	// peer without gateways
+	client = NewClient(&v1alpha1.Peer{
+		ObjectMeta: metav1.ObjectMeta{Name: "peer2"},
+		Spec:       v1alpha1.PeerSpec{GatewaySRV: "_clusterlink._tcp.missing.com"},
+	}, nil)
+	client.resolver = resolver
+	require.ErrorContains(t, client.GetHeartbeat(), "no gateways")
+}
+
+// blockingResolver is a fake resolver whose host lookups block until released.
+type blockingResolver struct {
+	fakeResolver
+	release chan struct{}
+}
+
+func (r *blockingResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	<-r.release
+	return r.fakeResolver.LookupHost(ctx, host)
+}
+
+func TestClientResolveInBackground(t *testing.T) {
+	resolver := &blockingResolver{
+		fakeResolver: fakeResolver{hosts: map[string][]string{"gw.example.com": {"10.0.0.1"}}},
+		release:      make(chan struct{}, 1),
+	}
+
+	client := NewClient(&v1alpha1.Peer{
+		ObjectMeta: metav1.ObjectMeta{Name: "peer1"},
+		Spec:       v1alpha1.PeerSpec{Gateways: []v1alpha1.Endpoint{{Host: "gw.example.com", Port: 443}}},
+	}, nil)
+	client.resolver = resolver
+
+	// the first resolution is awaited
+	resolver.release <- struct{}{}
+	clients := client.getClients()
+	require.Len(t, clients, 1)
+
+	// a slow re-resolution does not block getting the clients
+	client.lock.Lock()
+	client.resolved = client.resolved.Add(-ResolveInterval)
+	client.lock.Unlock()
+	for i := 0; i < 3; i++ {
+		require.Equal(t, clients, client.getClients())
+	}
+
+	client.lock.Lock()
+	require.NotNil(t, client.resolving)
+	client.lock.Unlock()
+
+	resolver.release <- struct{}{}
+	require.Eventually(t, func() bool {
+		client.lock.Lock()
+		defer client.lock.Unlock()
+		return client.resolving == nil
+	}, time.Second, time.Millisecond)
+}
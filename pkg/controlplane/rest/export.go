@@ -17,11 +17,13 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
 
 	"github.com/clusterlink-net/clusterlink/pkg/apis/clusterlink.net/v1alpha1"
 	"github.com/clusterlink-net/clusterlink/pkg/controlplane/store"
@@ -38,8 +40,11 @@ func toK8SExport(export *store.Export, namespace string) *v1alpha1.Export {
 			Namespace: namespace,
 		},
 		Spec: v1alpha1.ExportSpec{
-			Host: export.ExportSpec.Host,
-			Port: export.ExportSpec.Port,
+			Host:             export.ExportSpec.Host,
+			Port:             export.ExportSpec.Port,
+			Ports:            export.ExportSpec.Ports,
+			Protocol:         export.ExportSpec.Protocol,
+			ConnectionLimits: export.ExportSpec.ConnectionLimits,
 		},
 		Status: export.Status,
 	}
@@ -165,8 +170,12 @@ func (h *exportHandler) Decode(data []byte) (any, error) {
 		return nil, fmt.Errorf("empty export name")
 	}
 
-	if export.Spec.Port == 0 {
-		return nil, fmt.Errorf("missing service port")
+	if err := validateExportPorts(&export.Spec); err != nil {
+		return nil, err
+	}
+
+	if err := validateProtocol(export.Spec.Protocol); err != nil {
+		return nil, err
 	}
 
 	return store.NewExport(&export), nil
@@ -205,3 +214,56 @@ func (h *exportHandler) List() (any, error) {
 	}
 	return apiExports, nil
 }
+
+// validateExportPorts validates the port of a single-port export, or the named ports of a multi-port export.
+func validateExportPorts(spec *v1alpha1.ExportSpec) error {
+	if len(spec.Ports) == 0 {
+		if spec.Port == 0 {
+			return fmt.Errorf("missing service port")
+		}
+
+		return nil
+	}
+
+	if spec.Port != 0 {
+		return fmt.Errorf("port and ports are mutually exclusive")
+	}
+
+	names := make(map[string]bool, len(spec.Ports))
+	for _, port := range spec.Ports {
+		if err := validatePortName(port.Name, names); err != nil {
+			return err
+		}
+
+		if port.Port == 0 {
+			return fmt.Errorf("missing service port for port '%s'", port.Name)
+		}
+	}
+
+	return nil
+}
+
+// validatePortName validates the name of a port, which must be unique among the given port names.
+func validatePortName(name string, names map[string]bool) error {
+	if errs := validation.IsValidPortName(name); len(errs) > 0 {
+		return fmt.Errorf("invalid port name '%s': %s", name, strings.Join(errs, ", "))
+	}
+
+	if names[name] {
+		return fmt.Errorf("duplicate port name '%s'", name)
+	}
+	names[name] = true
+
+	return nil
+}
+
+// validateProtocol returns an error if a service protocol is not supported.
+// An empty protocol is valid, and defaults to TCP.
+func validateProtocol(protocol v1alpha1.Protocol) error {
+	switch protocol {
+	case "", v1alpha1.ProtocolTCP, v1alpha1.ProtocolUDP, v1alpha1.ProtocolHTTP:
+		return nil
+	default:
+		return fmt.Errorf("unsupported service protocol: %s", protocol)
+	}
+}
@@ -17,6 +17,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -73,6 +74,7 @@ func (m *Manager) CreateImport(imp *store.Import) error {
 		}
 
 		imp.TargetPort = k8sImp.Spec.TargetPort
+		imp.Ports = k8sImp.Spec.Ports
 
 		err = m.imports.Update(imp.Name, func(old *store.Import) *store.Import {
 			return imp
@@ -108,6 +110,7 @@ func (m *Manager) UpdateImport(imp *store.Import) error {
 	}
 
 	imp.TargetPort = k8sImp.Spec.TargetPort
+	imp.Ports = k8sImp.Spec.Ports
 
 	err = m.imports.Update(imp.Name, func(old *store.Import) *store.Import {
 		return imp
@@ -165,6 +168,15 @@ func (m *Manager) GetAllImports() []*store.Import {
 	return m.imports.GetAll()
 }
 
+func (m *Manager) GetK8sImportList() *v1alpha1.ImportList {
+	importList := v1alpha1.ImportList{}
+	for _, imp := range m.imports.GetAll() {
+		importList.Items = append(importList.Items, *toK8SImport(imp, m.namespace))
+	}
+
+	return &importList
+}
+
 func (m *Manager) GetMergeImportList() *v1alpha1.ImportList {
 	mergeImportList := v1alpha1.ImportList{}
 	for _, imp := range m.imports.GetAll() {
@@ -199,14 +211,36 @@ func (h *importHandler) Decode(data []byte) (any, error) {
 		return nil, fmt.Errorf("empty import name")
 	}
 
-	if imp.Spec.Port == 0 {
-		return nil, fmt.Errorf("missing service port")
+	if err := validateImportPorts(&imp.Spec); err != nil {
+		return nil, err
 	}
 
 	if len(imp.Spec.Sources) == 0 {
 		return nil, fmt.Errorf("missing sources")
 	}
 
+	if err := validateProtocol(imp.Spec.Protocol); err != nil {
+		return nil, err
+	}
+
+	if err := validateImportHTTPRoutes(&imp.Spec); err != nil {
+		return nil, err
+	}
+
+	if imp.Spec.ConnectionLimits != nil && imp.Spec.Protocol == v1alpha1.ProtocolUDP {
+		return nil, fmt.Errorf("connection limits are not supported for the %s protocol", v1alpha1.ProtocolUDP)
+	}
+
+	if imp.Spec.AuthorizationCache != nil && imp.Spec.AuthorizationCache.TTLSeconds == 0 {
+		return nil, fmt.Errorf("authorization cache missing TTL")
+	}
+
+	// the per-source rate is enforced when authorizing with the controlplane, which cached results skip
+	if imp.Spec.AuthorizationCache != nil && imp.Spec.ConnectionLimits != nil &&
+		imp.Spec.ConnectionLimits.PerSourceIP.ConnectionsPerSecond > 0 {
+		return nil, fmt.Errorf("authorization cache is not supported with a per-source connections rate limit")
+	}
+
 	return store.NewImport(&imp), nil
 }
 
@@ -243,3 +277,74 @@ func (h *importHandler) List() (any, error) {
 	}
 	return apiImports, nil
 }
+
+// validateImportPorts validates the port of a single-port import, or the named ports of a multi-port import.
+func validateImportPorts(spec *v1alpha1.ImportSpec) error {
+	if len(spec.Ports) == 0 {
+		if spec.Port == 0 {
+			return fmt.Errorf("missing service port")
+		}
+
+		return nil
+	}
+
+	if spec.Port != 0 || spec.TargetPort != 0 {
+		return fmt.Errorf("port and ports are mutually exclusive")
+	}
+
+	names := make(map[string]bool, len(spec.Ports))
+	for _, port := range spec.Ports {
+		if err := validatePortName(port.Name, names); err != nil {
+			return err
+		}
+
+		if port.Port == 0 {
+			return fmt.Errorf("missing service port for port '%s'", port.Name)
+		}
+	}
+
+	return nil
+}
+
+// validateImportHTTPRoutes validates the HTTP routes of an import, whose peers must be import sources.
+func validateImportHTTPRoutes(spec *v1alpha1.ImportSpec) error {
+	if len(spec.HTTPRoutes) == 0 {
+		return nil
+	}
+
+	if spec.Protocol != v1alpha1.ProtocolHTTP {
+		return fmt.Errorf("HTTP routes require the %s protocol", v1alpha1.ProtocolHTTP)
+	}
+
+	peers := make(map[string]bool, len(spec.Sources))
+	for _, source := range spec.Sources {
+		peers[source.Peer] = true
+	}
+
+	names := make(map[string]bool, len(spec.HTTPRoutes))
+	for i := range spec.HTTPRoutes {
+		httpRoute := &spec.HTTPRoutes[i]
+		if httpRoute.Name == "" {
+			return fmt.Errorf("missing HTTP route name")
+		}
+		if names[httpRoute.Name] {
+			return fmt.Errorf("duplicate HTTP route name '%s'", httpRoute.Name)
+		}
+		names[httpRoute.Name] = true
+
+		if httpRoute.PathPrefix != "" && !strings.HasPrefix(httpRoute.PathPrefix, "/") {
+			return fmt.Errorf("path prefix of HTTP route '%s' must start with '/'", httpRoute.Name)
+		}
+
+		if len(httpRoute.Peers) == 0 {
+			return fmt.Errorf("missing peers for HTTP route '%s'", httpRoute.Name)
+		}
+		for _, pr := range httpRoute.Peers {
+			if !peers[pr] {
+				return fmt.Errorf("peer '%s' of HTTP route '%s' is not an import source", pr, httpRoute.Name)
+			}
+		}
+	}
+
+	return nil
+}
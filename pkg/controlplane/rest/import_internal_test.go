@@ -0,0 +1,53 @@
+// Copyright 2023 The ClusterLink Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rest
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/clusterlink-net/clusterlink/pkg/apis/clusterlink.net/v1alpha1"
+)
+
+func TestDecodeImportAuthorizationCache(t *testing.T) {
+	decode := func(limits *v1alpha1.ImportConnectionLimits) error {
+		data, err := json.Marshal(&v1alpha1.Import{
+			ObjectMeta: metav1.ObjectMeta{Name: "web"},
+			Spec: v1alpha1.ImportSpec{
+				Port:               80,
+				Sources:            []v1alpha1.ImportSource{{Peer: "peer1", ExportName: "web", ExportNamespace: "default"}},
+				ConnectionLimits:   limits,
+				AuthorizationCache: &v1alpha1.ImportAuthorizationCache{TTLSeconds: 30},
+			},
+		})
+		require.NoError(t, err)
+
+		_, err = (&importHandler{}).Decode(data)
+		return err
+	}
+
+	require.NoError(t, decode(nil))
+	require.NoError(t, decode(&v1alpha1.ImportConnectionLimits{
+		Total:       v1alpha1.ConnectionLimits{ConnectionsPerSecond: 10},
+		PerSourceIP: v1alpha1.ConnectionLimits{MaxConnections: 10},
+	}))
+
+	// cached results would bypass the per-source rate, enforced by the controlplane
+	require.ErrorContains(t, decode(&v1alpha1.ImportConnectionLimits{
+		PerSourceIP: v1alpha1.ConnectionLimits{ConnectionsPerSecond: 5},
+	}), "per-source connections rate")
+}
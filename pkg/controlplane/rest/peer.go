@@ -30,20 +30,11 @@ type peerHandler struct {
 }
 
 func toK8SPeer(peer *store.Peer) *v1alpha1.Peer {
-	k8sPeer := &v1alpha1.Peer{
+	return &v1alpha1.Peer{
 		ObjectMeta: metav1.ObjectMeta{Name: peer.Name},
-		Spec: v1alpha1.PeerSpec{
-			Gateways: make([]v1alpha1.Endpoint, len(peer.Gateways)),
-		},
-		Status: peer.Status,
-	}
-
-	for i, gw := range peer.PeerSpec.Gateways {
-		k8sPeer.Spec.Gateways[i].Host = gw.Host
-		k8sPeer.Spec.Gateways[i].Port = gw.Port
+		Spec:       *peer.PeerSpec.DeepCopy(),
+		Status:     peer.Status,
 	}
-
-	return k8sPeer
 }
 
 func peerToAPI(peer *store.Peer) *v1alpha1.Peer {
@@ -163,6 +154,10 @@ func (h *peerHandler) Decode(data []byte) (any, error) {
 		return nil, fmt.Errorf("empty peer name")
 	}
 
+	if len(peer.Spec.Gateways) == 0 && peer.Spec.GatewaySRV == "" {
+		return nil, fmt.Errorf("missing gateways or gateway SRV name")
+	}
+
 	for i, ep := range peer.Spec.Gateways {
 		if ep.Host == "" {
 			return nil, fmt.Errorf("gateway #%d missing host", i)
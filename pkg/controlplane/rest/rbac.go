@@ -0,0 +1,88 @@
+// Copyright 2023 The ClusterLink Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rest
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/clusterlink-net/clusterlink/pkg/controlplane/api"
+	"github.com/clusterlink-net/clusterlink/pkg/util/rest"
+	"github.com/clusterlink-net/clusterlink/pkg/util/tls"
+)
+
+// Authorizer authorizes management requests according to the user roles of the requesting gwctl client.
+type Authorizer struct {
+	namespace string
+	peerTLS   *tls.ParsedCertData
+}
+
+// Authorize returns an error if a request is not allowed to perform an operation
+// on the object type served under basePath.
+func (a *Authorizer) Authorize(r *http.Request, basePath string, op rest.Operation) error {
+	if role := api.RequestRole(r, a.peerTLS); role != api.RoleGWCTL {
+		return fmt.Errorf("client role '%s' is not allowed", role)
+	}
+
+	cert := r.TLS.VerifiedChains[0][0]
+	user := cert.Subject.CommonName
+	roles, namespaces := api.UserRoles(cert)
+
+	if len(namespaces) > 0 && !slices.Contains(namespaces, a.namespace) {
+		return fmt.Errorf("user '%s' is not allowed to access namespace '%s'", user, a.namespace)
+	}
+
+	if op == rest.OperationDump {
+		// dumps cover all namespaces, so they are only allowed for admins not restricted to namespaces
+		if len(namespaces) > 0 {
+			return fmt.Errorf("user '%s' is restricted to namespaces", user)
+		}
+		if !slices.Contains(roles, api.UserRoleAdmin) {
+			return fmt.Errorf("user '%s' is not an admin", user)
+		}
+
+		return nil
+	}
+
+	for _, role := range roles {
+		if roleAllows(role, basePath, op) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("user '%s' is not allowed to %s %s", user, op, basePath)
+}
+
+// roleAllows returns true if a user role allows an operation on the object type served under basePath.
+func roleAllows(role api.UserRole, basePath string, op rest.Operation) bool {
+	switch role {
+	case api.UserRoleAdmin:
+		return true
+	case api.UserRolePolicyEditor:
+		return op.IsReadOnly() || basePath == policiesPath
+	case api.UserRoleReadOnly:
+		return op.IsReadOnly()
+	default:
+		return false
+	}
+}
+
+// NewAuthorizer returns a new authorizer for management requests on objects in the given namespace.
+func NewAuthorizer(namespace string, peerTLS *tls.ParsedCertData) *Authorizer {
+	return &Authorizer{
+		namespace: namespace,
+		peerTLS:   peerTLS,
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright 2023 The ClusterLink Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rest_test
+
+import (
+	cryptotls "crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/clusterlink-net/clusterlink/pkg/bootstrap"
+	"github.com/clusterlink-net/clusterlink/pkg/controlplane/api"
+	cprest "github.com/clusterlink-net/clusterlink/pkg/controlplane/rest"
+	"github.com/clusterlink-net/clusterlink/pkg/util/rest"
+	"github.com/clusterlink-net/clusterlink/pkg/util/tls"
+)
+
+const namespace = "clusterlink-system"
+
+// writeCertificate writes a certificate and its key to files under dir, and returns their paths.
+func writeCertificate(t *testing.T, cert *bootstrap.Certificate, dir, name string) (string, string) {
+	t.Helper()
+
+	certFile := filepath.Join(dir, name+".pem")
+	keyFile := filepath.Join(dir, name+".key")
+	require.Nil(t, os.WriteFile(certFile, cert.RawCert(), 0o600))
+	require.Nil(t, os.WriteFile(keyFile, cert.RawKey(), 0o600))
+
+	return certFile, keyFile
+}
+
+// requestFrom returns a request with TLS state verified against the given client certificate chain.
+func requestFrom(t *testing.T, cert *bootstrap.Certificate, fabricCert *bootstrap.Certificate) *http.Request {
+	t.Helper()
+
+	var chain []*x509.Certificate
+	data := append(cert.RawCert(), fabricCert.RawCert()...)
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+
+		parsed, err := x509.ParseCertificate(block.Bytes)
+		require.Nil(t, err)
+		chain = append(chain, parsed)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+	r.TLS = &cryptotls.ConnectionState{VerifiedChains: [][]*x509.Certificate{chain}}
+	return r
+}
+
+func TestAuthorizer(t *testing.T) {
+	dir := t.TempDir()
+
+	fabricCert, err := bootstrap.CreateFabricCertificate("fabric")
+	require.Nil(t, err)
+	peerCert, err := bootstrap.CreatePeerCertificate("peer1", fabricCert)
+	require.Nil(t, err)
+	controlplaneCert, err := bootstrap.CreateControlplaneCertificate("peer1", peerCert)
+	require.Nil(t, err)
+
+	caFile, _ := writeCertificate(t, fabricCert, dir, "ca")
+	certFile, keyFile := writeCertificate(t, controlplaneCert, dir, "controlplane")
+	peerTLS, err := tls.ParseFiles(caFile, certFile, keyFile)
+	require.Nil(t, err)
+
+	authorizer := cprest.NewAuthorizer(namespace, peerTLS)
+
+	createUser := func(peerCert *bootstrap.Certificate, role api.UserRole, namespaces ...string) *http.Request {
+		cert, err := bootstrap.CreateGWCTLUserCertificate("user", []api.UserRole{role}, namespaces, peerCert)
+		require.Nil(t, err)
+		return requestFrom(t, cert, fabricCert)
+	}
+
+	admin := createUser(peerCert, api.UserRoleAdmin)
+	require.Nil(t, authorizer.Authorize(admin, "/peers", rest.OperationCreate))
+	require.Nil(t, authorizer.Authorize(admin, "/policies", rest.OperationDelete))
+
+	policyEditor := createUser(peerCert, api.UserRolePolicyEditor)
+	require.Nil(t, authorizer.Authorize(policyEditor, "/policies", rest.OperationCreate))
+	require.Nil(t, authorizer.Authorize(policyEditor, "/exports", rest.OperationList))
+	require.NotNil(t, authorizer.Authorize(policyEditor, "/exports", rest.OperationCreate))
+
+	readOnly := createUser(peerCert, api.UserRoleReadOnly)
+	require.Nil(t, authorizer.Authorize(readOnly, "/imports", rest.OperationGet))
+	require.NotNil(t, authorizer.Authorize(readOnly, "/policies", rest.OperationUpdate))
+
+	require.Nil(t, authorizer.Authorize(createUser(peerCert, api.UserRoleAdmin, namespace), "/peers", rest.OperationCreate))
+	require.NotNil(t, authorizer.Authorize(createUser(peerCert, api.UserRoleAdmin, "other"), "/peers", rest.OperationGet))
+
+	// dumps are only allowed for admins which are not restricted to namespaces
+	require.Nil(t, authorizer.Authorize(admin, api.XDSDumpPath, rest.OperationDump))
+	require.NotNil(t, authorizer.Authorize(readOnly, api.XDSDumpPath, rest.OperationDump))
+	require.NotNil(t, authorizer.Authorize(policyEditor, api.XDSDumpPath, rest.OperationDump))
+	require.NotNil(t, authorizer.Authorize(
+		createUser(peerCert, api.UserRoleAdmin, namespace), api.XDSDumpPath, rest.OperationDump))
+
+	// legacy gwctl certificate without user roles
+	legacy, err := bootstrap.CreateGWCTLUserCertificate("gwctl", nil, nil, peerCert)
+	require.Nil(t, err)
+	require.Nil(t, authorizer.Authorize(requestFrom(t, legacy, fabricCert), "/peers", rest.OperationCreate))
+
+	// local non-gwctl components
+	require.NotNil(t, authorizer.Authorize(requestFrom(t, controlplaneCert, fabricCert), "/peers", rest.OperationList))
+	dataplaneCert, err := bootstrap.CreateDataplaneCertificate("peer1", peerCert)
+	require.Nil(t, err)
+	require.NotNil(t, authorizer.Authorize(requestFrom(t, dataplaneCert, fabricCert), "/peers", rest.OperationList))
+
+	// gwctl and controlplane of a remote peer
+	remotePeerCert, err := bootstrap.CreatePeerCertificate("peer2", fabricCert)
+	require.Nil(t, err)
+	require.NotNil(t, authorizer.Authorize(createUser(remotePeerCert, api.UserRoleAdmin), "/peers", rest.OperationList))
+	remoteControlplaneCert, err := bootstrap.CreateControlplaneCertificate("peer2", remotePeerCert)
+	require.Nil(t, err)
+	require.NotNil(t, authorizer.Authorize(
+		requestFrom(t, remoteControlplaneCert, fabricCert), "/peers", rest.OperationList))
+
+	// no client certificate
+	require.NotNil(t, authorizer.Authorize(
+		httptest.NewRequest(http.MethodGet, "/", http.NoBody), "/peers", rest.OperationList))
+}
@@ -17,28 +17,35 @@ import (
 	"github.com/clusterlink-net/clusterlink/pkg/util/rest"
 )
 
+const (
+	peersPath    = "/peers"
+	exportsPath  = "/exports"
+	importsPath  = "/imports"
+	policiesPath = "/policies"
+)
+
 // RegisteHandlers registers the HTTP handlers for REST requests.
 func RegisterHandlers(manager *Manager, srv *rest.Server) {
 	srv.AddObjectHandlers(&rest.ServerObjectSpec{
-		BasePath:      "/peers",
+		BasePath:      peersPath,
 		Handler:       &peerHandler{manager: manager},
 		DeleteByValue: false,
 	})
 
 	srv.AddObjectHandlers(&rest.ServerObjectSpec{
-		BasePath:      "/exports",
+		BasePath:      exportsPath,
 		Handler:       &exportHandler{manager: manager},
 		DeleteByValue: false,
 	})
 
 	srv.AddObjectHandlers(&rest.ServerObjectSpec{
-		BasePath:      "/imports",
+		BasePath:      importsPath,
 		Handler:       &importHandler{manager: manager},
 		DeleteByValue: false,
 	})
 
 	srv.AddObjectHandlers(&rest.ServerObjectSpec{
-		BasePath:      "/policies",
+		BasePath:      policiesPath,
 		Handler:       &accessPolicyHandler{manager: manager},
 		DeleteByValue: false,
 	})
@@ -0,0 +1,244 @@
+// Copyright 2023 The ClusterLink Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"maps"
+	"sync"
+
+	accesslog "github.com/envoyproxy/go-control-plane/envoy/config/accesslog/v3"
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	grpcaccesslog "github.com/envoyproxy/go-control-plane/envoy/extensions/access_loggers/grpc/v3"
+	streamaccesslog "github.com/envoyproxy/go-control-plane/envoy/extensions/access_loggers/stream/v3"
+	alsv3 "github.com/envoyproxy/go-control-plane/envoy/service/accesslog/v3"
+	tracing "github.com/envoyproxy/go-control-plane/envoy/type/tracing/v3"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/clusterlink-net/clusterlink/pkg/apis/clusterlink.net/v1alpha1"
+	cpapi "github.com/clusterlink-net/clusterlink/pkg/controlplane/api"
+	utiltls "github.com/clusterlink-net/clusterlink/pkg/util/tls"
+)
+
+const (
+	// stdoutAccessLogger, httpGRPCAccessLogger and tcpGRPCAccessLogger are the Envoy access logger names.
+	stdoutAccessLogger   = "envoy.access_loggers.stdout"
+	httpGRPCAccessLogger = "envoy.access_loggers.http_grpc"
+	tcpGRPCAccessLogger  = "envoy.access_loggers.tcp_grpc"
+)
+
+// SetAccessLog sets the access log mode of import listeners.
+// Must be called before any import is added.
+func (m *Manager) SetAccessLog(mode cpapi.AccessLogMode) {
+	m.accessLog = mode
+}
+
+// makeImportAccessLogs returns the access logs of a port of an imported service.
+// Connections (or HTTP requests) are identified by their stream ID, which is propagated to the remote peer
+// in the connection ID header.
+func (m *Manager) makeImportAccessLogs(imp *v1alpha1.Import, http bool) ([]*accesslog.AccessLog, error) {
+	var name string
+	var config proto.Message
+	switch m.accessLog {
+	case cpapi.AccessLogJSON:
+		format := map[string]any{
+			"connection_id":    "%STREAM_ID%",
+			"import_name":      imp.Name,
+			"import_namespace": imp.Namespace,
+			"client_ip":        "%DOWNSTREAM_REMOTE_ADDRESS_WITHOUT_PORT%",
+			"start_time":       "%START_TIME%",
+			"duration":         "%DURATION%",
+			"bytes_received":   "%BYTES_RECEIVED%",
+			"bytes_sent":       "%BYTES_SENT%",
+			"response_flags":   "%RESPONSE_FLAGS%",
+		}
+		if http {
+			maps.Copy(format, map[string]any{
+				"method":        "%REQ(:METHOD)%",
+				"path":          "%REQ(X-ENVOY-ORIGINAL-PATH?:PATH)%",
+				"response_code": "%RESPONSE_CODE%",
+			})
+		}
+
+		jsonFormat, err := structpb.NewStruct(format)
+		if err != nil {
+			return nil, err
+		}
+
+		name = stdoutAccessLogger
+		config = &streamaccesslog.StdoutAccessLog{
+			AccessLogFormat: &streamaccesslog.StdoutAccessLog_LogFormat{
+				LogFormat: &core.SubstitutionFormatString{
+					Format: &core.SubstitutionFormatString_JsonFormat{JsonFormat: jsonFormat},
+				},
+			},
+		}
+	case cpapi.AccessLogGRPC:
+		commonConfig := &grpcaccesslog.CommonGrpcAccessLogConfig{
+			LogName: cpapi.AccessLogName,
+			GrpcService: &core.GrpcService{
+				TargetSpecifier: &core.GrpcService_EnvoyGrpc_{
+					EnvoyGrpc: &core.GrpcService_EnvoyGrpc{ClusterName: cpapi.ControlplaneGRPCCluster},
+				},
+			},
+			TransportApiVersion: core.ApiVersion_V3,
+			CustomTags: []*tracing.CustomTag{
+				makeLiteralTag("import_name", imp.Name),
+				makeLiteralTag("import_namespace", imp.Namespace),
+			},
+		}
+
+		if http {
+			name = httpGRPCAccessLogger
+			config = &grpcaccesslog.HttpGrpcAccessLogConfig{CommonConfig: commonConfig}
+		} else {
+			name = tcpGRPCAccessLogger
+			config = &grpcaccesslog.TcpGrpcAccessLogConfig{CommonConfig: commonConfig}
+		}
+	default:
+		return nil, nil
+	}
+
+	pb, err := anypb.New(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return []*accesslog.AccessLog{{
+		Name:       name,
+		ConfigType: &accesslog.AccessLog_TypedConfig{TypedConfig: pb},
+	}}, nil
+}
+
+// makeLiteralTag returns a custom tag with a constant value.
+func makeLiteralTag(tag, value string) *tracing.CustomTag {
+	return &tracing.CustomTag{
+		Tag: tag,
+		Type: &tracing.CustomTag_Literal_{
+			Literal: &tracing.CustomTag_Literal{Value: value},
+		},
+	}
+}
+
+// accessLogRecord is an access log entry streamed by a dataplane, written as a single JSON line.
+type accessLogRecord struct {
+	// Dataplane is the ID of the dataplane sending the entry.
+	Dataplane string `json:"dataplane"`
+	// LogName is the name of the access log configured on the dataplane.
+	LogName string `json:"logName"`
+	// HTTP is an HTTP access log entry.
+	HTTP json.RawMessage `json:"http,omitempty"`
+	// TCP is a TCP access log entry.
+	TCP json.RawMessage `json:"tcp,omitempty"`
+}
+
+// accessLogServer is a gRPC access log service, writing the access logs streamed by dataplanes.
+type accessLogServer struct {
+	alsv3.UnimplementedAccessLogServiceServer
+
+	lock   sync.Mutex
+	writer io.Writer
+	// peerTLS, if set, is used to reject streams not opened by dataplanes of the local peer
+	peerTLS *utiltls.ParsedCertData
+
+	logger *logrus.Entry
+}
+
+// write encodes an access log record to the writer.
+func (s *accessLogServer) write(record *accessLogRecord) error {
+	encoded, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	_, err = s.writer.Write(append(encoded, '\n'))
+	return err
+}
+
+// StreamAccessLogs receives a stream of access log entries from a dataplane.
+func (s *accessLogServer) StreamAccessLogs(stream alsv3.AccessLogService_StreamAccessLogsServer) error {
+	if s.peerTLS != nil {
+		if role := cpapi.ContextRole(stream.Context(), s.peerTLS); role != cpapi.RoleDataplane {
+			s.logger.Warnf("Rejecting access log stream from client with role '%s'.", role)
+			return status.Errorf(codes.PermissionDenied, "client role '%s' is not allowed", role)
+		}
+	}
+
+	var dataplane, logName string
+	for {
+		msg, err := stream.Recv()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+
+		// the identifier is only sent on the first message of the stream
+		if identifier := msg.GetIdentifier(); identifier != nil {
+			dataplane = identifier.GetNode().GetId()
+			logName = identifier.GetLogName()
+		}
+
+		var entries []proto.Message
+		for _, entry := range msg.GetHttpLogs().GetLogEntry() {
+			entries = append(entries, entry)
+		}
+		for _, entry := range msg.GetTcpLogs().GetLogEntry() {
+			entries = append(entries, entry)
+		}
+
+		for _, entry := range entries {
+			encoded, err := protojson.Marshal(entry)
+			if err != nil {
+				s.logger.Warnf("Cannot encode access log entry: %v.", err)
+				continue
+			}
+
+			record := &accessLogRecord{Dataplane: dataplane, LogName: logName}
+			if msg.GetHttpLogs() != nil {
+				record.HTTP = encoded
+			} else {
+				record.TCP = encoded
+			}
+
+			if err := s.write(record); err != nil {
+				s.logger.Errorf("Cannot write access log entry: %v.", err)
+			}
+		}
+	}
+}
+
+// RegisterAccessLogService registers a gRPC access log service to the given gRPC server,
+// writing the access logs streamed by dataplanes to the given writer, one JSON object per line.
+// Streams not opened by dataplanes of the local peer, identified using the given peer TLS, are rejected.
+func RegisterAccessLogService(grpcServer *grpc.Server, writer io.Writer, peerTLS *utiltls.ParsedCertData) {
+	alsv3.RegisterAccessLogServiceServer(grpcServer, &accessLogServer{
+		writer:  writer,
+		peerTLS: peerTLS,
+		logger:  logrus.WithField("component", "controlplane.xds.accesslog"),
+	})
+}
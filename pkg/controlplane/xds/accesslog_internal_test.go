@@ -0,0 +1,198 @@
+// Copyright 2023 The ClusterLink Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"strconv"
+	"testing"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	accessdata "github.com/envoyproxy/go-control-plane/envoy/data/accesslog/v3"
+	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	tcpproxy "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/tcp_proxy/v3"
+	alsv3 "github.com/envoyproxy/go-control-plane/envoy/service/accesslog/v3"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	grpcstatus "google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/clusterlink-net/clusterlink/pkg/apis/clusterlink.net/v1alpha1"
+	"github.com/clusterlink-net/clusterlink/pkg/bootstrap"
+	cpapi "github.com/clusterlink-net/clusterlink/pkg/controlplane/api"
+)
+
+func TestImportAccessLogs(t *testing.T) {
+	imp := &v1alpha1.Import{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec:       v1alpha1.ImportSpec{Port: 80, TargetPort: 5000},
+	}
+	listenerName := cpapi.ImportListenerName(imp.Name, imp.Namespace)
+
+	// access logs are disabled by default
+	manager := NewManager(false)
+	require.NoError(t, manager.AddImport(imp))
+	ln := manager.listeners.GetResources()[listenerName].(*listener.Listener)
+	tcpProxy := &tcpproxy.TcpProxy{}
+	filters := ln.FilterChains[0].Filters
+	require.NoError(t, filters[len(filters)-1].GetTypedConfig().UnmarshalTo(tcpProxy))
+	require.Empty(t, tcpProxy.AccessLog)
+
+	// the connection ID is propagated in the tunnel headers
+	var connectionID string
+	for _, header := range tcpProxy.TunnelingConfig.HeadersToAdd {
+		if header.Header.Key == cpapi.ConnectionIDHeader {
+			connectionID = header.Header.Value
+		}
+	}
+	require.Equal(t, "%STREAM_ID%", connectionID)
+
+	manager = NewManager(false)
+	manager.SetAccessLog(cpapi.AccessLogJSON)
+	require.NoError(t, manager.AddImport(imp))
+	ln = manager.listeners.GetResources()[listenerName].(*listener.Listener)
+	require.NoError(t, ln.ValidateAll())
+	filters = ln.FilterChains[0].Filters
+	require.NoError(t, filters[len(filters)-1].GetTypedConfig().UnmarshalTo(tcpProxy))
+	require.Len(t, tcpProxy.AccessLog, 1)
+	require.Equal(t, stdoutAccessLogger, tcpProxy.AccessLog[0].Name)
+
+	imp.Spec.Protocol = v1alpha1.ProtocolHTTP
+	manager = NewManager(false)
+	manager.SetAccessLog(cpapi.AccessLogGRPC)
+	require.NoError(t, manager.AddImport(imp))
+	ln = manager.listeners.GetResources()[listenerName].(*listener.Listener)
+	require.NoError(t, ln.ValidateAll())
+	httpConnectionManager := &hcm.HttpConnectionManager{}
+	filters = ln.FilterChains[0].Filters
+	require.NoError(t, filters[len(filters)-1].GetTypedConfig().UnmarshalTo(httpConnectionManager))
+	require.Len(t, httpConnectionManager.AccessLog, 1)
+	require.Equal(t, httpGRPCAccessLogger, httpConnectionManager.AccessLog[0].Name)
+}
+
+// accessLogStream is a fake stream of access log messages.
+type accessLogStream struct {
+	grpc.ServerStream
+	ctx      context.Context
+	messages []*alsv3.StreamAccessLogsMessage
+}
+
+func (s *accessLogStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *accessLogStream) Recv() (*alsv3.StreamAccessLogsMessage, error) {
+	if len(s.messages) == 0 {
+		return nil, io.EOF
+	}
+
+	msg := s.messages[0]
+	s.messages = s.messages[1:]
+	return msg, nil
+}
+
+func (s *accessLogStream) SendAndClose(*alsv3.StreamAccessLogsResponse) error {
+	return nil
+}
+
+func TestAccessLogServer(t *testing.T) {
+	var output bytes.Buffer
+	server := &accessLogServer{writer: &output, logger: logrus.WithField("component", "test")}
+
+	entry := func(streamID string) *accessdata.TCPAccessLogEntry {
+		return &accessdata.TCPAccessLogEntry{CommonProperties: &accessdata.AccessLogCommon{StreamId: streamID}}
+	}
+
+	stream := &accessLogStream{messages: []*alsv3.StreamAccessLogsMessage{
+		{
+			Identifier: &alsv3.StreamAccessLogsMessage_Identifier{
+				Node:    &core.Node{Id: "dataplane-1"},
+				LogName: cpapi.AccessLogName,
+			},
+			LogEntries: &alsv3.StreamAccessLogsMessage_TcpLogs{
+				TcpLogs: &alsv3.StreamAccessLogsMessage_TCPAccessLogEntries{
+					LogEntry: []*accessdata.TCPAccessLogEntry{entry("1"), entry("2")},
+				},
+			},
+		},
+		{
+			// the identifier is only sent on the first message
+			LogEntries: &alsv3.StreamAccessLogsMessage_TcpLogs{
+				TcpLogs: &alsv3.StreamAccessLogsMessage_TCPAccessLogEntries{
+					LogEntry: []*accessdata.TCPAccessLogEntry{entry("3")},
+				},
+			},
+		},
+	}}
+	require.NoError(t, server.StreamAccessLogs(stream))
+
+	lines := bytes.Split(bytes.TrimSpace(output.Bytes()), []byte("\n"))
+	require.Len(t, lines, 3)
+	for i, line := range lines {
+		var record accessLogRecord
+		require.NoError(t, json.Unmarshal(line, &record))
+		require.Equal(t, "dataplane-1", record.Dataplane)
+		require.Equal(t, cpapi.AccessLogName, record.LogName)
+		require.Empty(t, record.HTTP)
+		tcpEntry := &accessdata.TCPAccessLogEntry{}
+		require.NoError(t, protojson.Unmarshal(record.TCP, tcpEntry))
+		require.Equal(t, strconv.Itoa(i+1), tcpEntry.CommonProperties.StreamId)
+	}
+}
+
+func TestAccessLogServerRole(t *testing.T) {
+	fabricCert, err := bootstrap.CreateFabricCertificate("fabric")
+	require.NoError(t, err)
+	peerCert, err := bootstrap.CreatePeerCertificate("peer", fabricCert)
+	require.NoError(t, err)
+	dataplaneCert, err := bootstrap.CreateDataplaneCertificate("peer", peerCert)
+	require.NoError(t, err)
+	gwctlCert, err := bootstrap.CreateGWCTLCertificate(peerCert)
+	require.NoError(t, err)
+
+	var output bytes.Buffer
+	server := &accessLogServer{
+		writer:  &output,
+		peerTLS: testPeerTLS(t, peerCert, fabricCert),
+		logger:  logrus.WithField("component", "test"),
+	}
+
+	stream := func(ctx context.Context) *accessLogStream {
+		return &accessLogStream{ctx: ctx, messages: []*alsv3.StreamAccessLogsMessage{{
+			Identifier: &alsv3.StreamAccessLogsMessage_Identifier{Node: &core.Node{Id: "dataplane-1"}},
+			LogEntries: &alsv3.StreamAccessLogsMessage_TcpLogs{
+				TcpLogs: &alsv3.StreamAccessLogsMessage_TCPAccessLogEntries{
+					LogEntry: []*accessdata.TCPAccessLogEntry{{}},
+				},
+			},
+		}}}
+	}
+
+	// a gwctl client cannot inject access logs
+	err = server.StreamAccessLogs(stream(peerContext(t, gwctlCert, fabricCert)))
+	require.Equal(t, codes.PermissionDenied, grpcstatus.Code(err))
+	err = server.StreamAccessLogs(stream(context.Background()))
+	require.Equal(t, codes.PermissionDenied, grpcstatus.Code(err))
+	require.Zero(t, output.Len())
+
+	require.NoError(t, server.StreamAccessLogs(stream(peerContext(t, dataplaneCert, fabricCert))))
+	require.NotZero(t, output.Len())
+}
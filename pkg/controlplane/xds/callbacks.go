@@ -0,0 +1,369 @@
+// Copyright 2023 The ClusterLink Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/server/v3"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	cpapi "github.com/clusterlink-net/clusterlink/pkg/controlplane/api"
+	"github.com/clusterlink-net/clusterlink/pkg/metrics"
+	utiltls "github.com/clusterlink-net/clusterlink/pkg/util/tls"
+)
+
+// resourceTypes maps xDS type URLs to the resource type names used in metrics and status.
+var resourceTypes = map[string]string{
+	resource.ClusterType:  clusterResource,
+	resource.EndpointType: endpointResource,
+	resource.ListenerType: listenerResource,
+	resource.SecretType:   secretResource,
+}
+
+// sentResponse is a response sent to a dataplane, awaiting an ACK/NACK.
+type sentResponse struct {
+	nonce   string
+	version string
+}
+
+// typeState is the state of a single resource type subscribed over an xDS stream.
+type typeState struct {
+	// sent responses awaiting an ACK/NACK, ordered by sending time
+	sent   []sentResponse
+	status cpapi.XDSResourceStatus
+}
+
+// streamState is the state of a single xDS stream.
+type streamState struct {
+	node   string
+	opened time.Time
+	types  map[string]*typeState
+}
+
+// callbacks tracks the xDS streams of connected dataplanes,
+// and the configuration versions they acknowledged or rejected.
+type callbacks struct {
+	lock    sync.Mutex
+	streams map[int64]*streamState
+	// generation is incremented on every change of the tracked state
+	generation uint64
+
+	// peerTLS, if set, is used to reject streams not opened by dataplanes of the local peer
+	peerTLS *utiltls.ParsedCertData
+
+	metrics *metrics.ControlplaneMetrics
+	logger  *logrus.Entry
+}
+
+var _ server.Callbacks = &callbacks{}
+
+// stream returns the state of a stream, creating it if needed. Requires the lock.
+func (c *callbacks) stream(streamID int64) *streamState {
+	stream, ok := c.streams[streamID]
+	if !ok {
+		stream = &streamState{
+			opened: time.Now(),
+			types:  make(map[string]*typeState),
+		}
+		c.streams[streamID] = stream
+		c.generation++
+	}
+
+	return stream
+}
+
+// typeState returns the state of a resource type subscribed over a stream. Requires the lock.
+func (c *callbacks) typeState(streamID int64, node *core.Node, typeURL string) *typeState {
+	stream := c.stream(streamID)
+	if id := node.GetId(); id != "" && stream.node != id {
+		stream.node = id
+		c.generation++
+		c.logger.Infof("Dataplane '%s' connected (stream %d).", id, streamID)
+		c.updateConnectedMetric()
+	}
+
+	state, ok := stream.types[typeURL]
+	if !ok {
+		state = &typeState{}
+		stream.types[typeURL] = state
+	}
+
+	return state
+}
+
+// onRequest records an ACK or NACK of a previously sent response.
+func (c *callbacks) onRequest(streamID int64, node *core.Node, typeURL, nonce, errorDetail string, nack bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	state := c.typeState(streamID, node, typeURL)
+	if nonce == "" {
+		// initial request
+		return
+	}
+
+	index := slices.IndexFunc(state.sent, func(sent sentResponse) bool {
+		return sent.nonce == nonce
+	})
+	if index < 0 {
+		// stale nonce
+		return
+	}
+
+	// responses sent before the acknowledged one will not be acknowledged
+	version := state.sent[index].version
+	state.sent = state.sent[index+1:]
+
+	c.generation++
+	now := time.Now()
+	if nack {
+		resourceType := resourceTypeName(typeURL)
+		c.logger.Warnf("Dataplane '%s' rejected %s version '%s': %s.",
+			node.GetId(), resourceType, version, errorDetail)
+		c.metrics.ObserveXDSNACK(resourceType)
+
+		state.status.LastNACK = &cpapi.XDSNACK{
+			Version: version,
+			Message: errorDetail,
+			Time:    now,
+		}
+		return
+	}
+
+	state.status.AckedVersion = version
+	state.status.AckTime = now
+}
+
+// onResponse records a response sent to a dataplane.
+func (c *callbacks) onResponse(streamID int64, node *core.Node, typeURL, nonce, version string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	state := c.typeState(streamID, node, typeURL)
+	state.sent = append(state.sent, sentResponse{nonce: nonce, version: version})
+	state.status.SentVersion = version
+	c.generation++
+}
+
+// onStreamOpen records a newly opened stream, whose node is yet unknown.
+func (c *callbacks) onStreamOpen(streamID int64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.logger.Debugf("Stream %d opened.", streamID)
+	c.stream(streamID)
+}
+
+// onStreamClosed forgets a closed stream.
+func (c *callbacks) onStreamClosed(streamID int64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	stream, ok := c.streams[streamID]
+	if !ok {
+		return
+	}
+
+	delete(c.streams, streamID)
+	c.generation++
+
+	if stream.node != "" {
+		c.logger.Infof("Dataplane '%s' disconnected (stream %d).", stream.node, streamID)
+		c.updateConnectedMetric()
+	}
+}
+
+// updateConnectedMetric updates the number of connected dataplanes. Requires the lock.
+func (c *callbacks) updateConnectedMetric() {
+	nodes := make(map[string]bool)
+	for _, stream := range c.streams {
+		if stream.node != "" {
+			nodes[stream.node] = true
+		}
+	}
+
+	c.metrics.SetXDSConnectedDataplanes(len(nodes))
+}
+
+// authorizeStream rejects an xDS stream, unless opened by a dataplane of the local peer.
+// The stream is authorized if no peer TLS is set.
+func (c *callbacks) authorizeStream(ctx context.Context) error {
+	if c.peerTLS == nil {
+		return nil
+	}
+
+	if role := cpapi.ContextRole(ctx, c.peerTLS); role != cpapi.RoleDataplane {
+		c.logger.Warnf("Rejecting xDS stream from client with role '%s'.", role)
+		return status.Errorf(codes.PermissionDenied, "client role '%s' is not allowed", role)
+	}
+
+	return nil
+}
+
+// OnStreamOpen is called once an xDS stream is opened.
+func (c *callbacks) OnStreamOpen(ctx context.Context, streamID int64, _ string) error {
+	if err := c.authorizeStream(ctx); err != nil {
+		return err
+	}
+
+	c.onStreamOpen(streamID)
+	return nil
+}
+
+// OnStreamClosed is called immediately prior to closing an xDS stream.
+func (c *callbacks) OnStreamClosed(streamID int64, _ *core.Node) {
+	c.onStreamClosed(streamID)
+}
+
+// OnDeltaStreamOpen is called once an incremental xDS stream is opened.
+func (c *callbacks) OnDeltaStreamOpen(ctx context.Context, streamID int64, _ string) error {
+	if err := c.authorizeStream(ctx); err != nil {
+		return err
+	}
+
+	c.onStreamOpen(streamID)
+	return nil
+}
+
+// OnDeltaStreamClosed is called immediately prior to closing an incremental xDS stream.
+func (c *callbacks) OnDeltaStreamClosed(streamID int64, _ *core.Node) {
+	c.onStreamClosed(streamID)
+}
+
+// OnStreamRequest is called once a request is received on a stream.
+func (c *callbacks) OnStreamRequest(streamID int64, req *discovery.DiscoveryRequest) error {
+	c.onRequest(
+		streamID, req.GetNode(), req.GetTypeUrl(), req.GetResponseNonce(),
+		req.GetErrorDetail().GetMessage(), req.GetErrorDetail() != nil)
+	return nil
+}
+
+// OnStreamResponse is called immediately prior to sending a response on a stream.
+func (c *callbacks) OnStreamResponse(
+	_ context.Context,
+	streamID int64,
+	req *discovery.DiscoveryRequest,
+	resp *discovery.DiscoveryResponse,
+) {
+	c.onResponse(streamID, req.GetNode(), resp.GetTypeUrl(), resp.GetNonce(), resp.GetVersionInfo())
+}
+
+// OnStreamDeltaRequest is called once a request is received on an incremental stream.
+func (c *callbacks) OnStreamDeltaRequest(streamID int64, req *discovery.DeltaDiscoveryRequest) error {
+	c.onRequest(
+		streamID, req.GetNode(), req.GetTypeUrl(), req.GetResponseNonce(),
+		req.GetErrorDetail().GetMessage(), req.GetErrorDetail() != nil)
+	return nil
+}
+
+// OnStreamDeltaResponse is called immediately prior to sending a response on an incremental stream.
+func (c *callbacks) OnStreamDeltaResponse(
+	streamID int64,
+	req *discovery.DeltaDiscoveryRequest,
+	resp *discovery.DeltaDiscoveryResponse,
+) {
+	c.onResponse(streamID, req.GetNode(), resp.GetTypeUrl(), resp.GetNonce(), resp.GetSystemVersionInfo())
+}
+
+// OnFetchRequest is called for each REST (fetch) request.
+func (c *callbacks) OnFetchRequest(context.Context, *discovery.DiscoveryRequest) error {
+	return nil
+}
+
+// OnFetchResponse is called immediately prior to sending a REST (fetch) response.
+func (c *callbacks) OnFetchResponse(*discovery.DiscoveryRequest, *discovery.DiscoveryResponse) {}
+
+// nodes returns the xDS status of the connected dataplanes, sorted by node ID.
+func (c *callbacks) nodes() []cpapi.XDSNodeStatus {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	byID := make(map[string]*cpapi.XDSNodeStatus)
+	for _, stream := range c.streams {
+		if stream.node == "" {
+			// no request received yet
+			continue
+		}
+
+		node, ok := byID[stream.node]
+		if !ok {
+			node = &cpapi.XDSNodeStatus{
+				ID:             stream.node,
+				ConnectedSince: stream.opened,
+				Resources:      make(map[string]cpapi.XDSResourceStatus),
+			}
+			byID[stream.node] = node
+		}
+
+		if stream.opened.Before(node.ConnectedSince) {
+			node.ConnectedSince = stream.opened
+		}
+
+		for typeURL, state := range stream.types {
+			name := resourceTypeName(typeURL)
+			status := state.status
+			if status.LastNACK != nil {
+				nack := *status.LastNACK
+				status.LastNACK = &nack
+			}
+			node.Resources[name] = status
+		}
+	}
+
+	nodes := make([]cpapi.XDSNodeStatus, 0, len(byID))
+	for _, node := range byID {
+		nodes = append(nodes, *node)
+	}
+
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].ID < nodes[j].ID
+	})
+
+	return nodes
+}
+
+// getGeneration returns the generation of the tracked state, which changes whenever the state changes.
+func (c *callbacks) getGeneration() uint64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.generation
+}
+
+// resourceTypeName returns the resource type name of an xDS type URL.
+func resourceTypeName(typeURL string) string {
+	if name, ok := resourceTypes[typeURL]; ok {
+		return name
+	}
+
+	return typeURL
+}
+
+// newCallbacks returns new xDS server callbacks.
+func newCallbacks() *callbacks {
+	return &callbacks{
+		streams: make(map[int64]*streamState),
+		logger:  logrus.WithField("component", "controlplane.xds.callbacks"),
+	}
+}
@@ -0,0 +1,192 @@
+// Copyright 2023 The ClusterLink Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"context"
+	cryptotls "crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	grpcstatus "google.golang.org/grpc/status"
+
+	"github.com/clusterlink-net/clusterlink/pkg/bootstrap"
+	utiltls "github.com/clusterlink-net/clusterlink/pkg/util/tls"
+)
+
+// testPeerTLS returns the certificate data of a local peer controlplane, whose certificate is issued by peerCert.
+func testPeerTLS(t *testing.T, peerCert, fabricCert *bootstrap.Certificate) *utiltls.ParsedCertData {
+	t.Helper()
+
+	controlplaneCert, err := bootstrap.CreateControlplaneCertificate("peer", peerCert)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.pem")
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(caFile, fabricCert.RawCert(), 0o600))
+	require.NoError(t, os.WriteFile(certFile, controlplaneCert.RawCert(), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, controlplaneCert.RawKey(), 0o600))
+	peerTLS, err := utiltls.ParseFiles(caFile, certFile, keyFile)
+	require.NoError(t, err)
+	return peerTLS
+}
+
+// peerContext returns a gRPC call context with TLS state verified against the given client certificate.
+func peerContext(t *testing.T, cert, fabricCert *bootstrap.Certificate) context.Context {
+	t.Helper()
+
+	var chain []*x509.Certificate
+	data := append(cert.RawCert(), fabricCert.RawCert()...)
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+
+		parsed, err := x509.ParseCertificate(block.Bytes)
+		require.NoError(t, err)
+		chain = append(chain, parsed)
+	}
+
+	return peer.NewContext(context.Background(), &peer.Peer{
+		AuthInfo: credentials.TLSInfo{
+			State: cryptotls.ConnectionState{VerifiedChains: [][]*x509.Certificate{chain}},
+		},
+	})
+}
+
+func TestAuthorizeStream(t *testing.T) {
+	fabricCert, err := bootstrap.CreateFabricCertificate("fabric")
+	require.NoError(t, err)
+	peerCert, err := bootstrap.CreatePeerCertificate("peer", fabricCert)
+	require.NoError(t, err)
+	dataplaneCert, err := bootstrap.CreateDataplaneCertificate("peer", peerCert)
+	require.NoError(t, err)
+	gwctlCert, err := bootstrap.CreateGWCTLCertificate(peerCert)
+	require.NoError(t, err)
+	remotePeerCert, err := bootstrap.CreatePeerCertificate("remote", fabricCert)
+	require.NoError(t, err)
+	remoteDataplaneCert, err := bootstrap.CreateDataplaneCertificate("remote", remotePeerCert)
+	require.NoError(t, err)
+
+	c := newCallbacks()
+
+	// streams are not authorized without peer TLS
+	require.NoError(t, c.OnStreamOpen(context.Background(), 1, resource.SecretType))
+
+	c.peerTLS = testPeerTLS(t, peerCert, fabricCert)
+
+	dataplaneCtx := peerContext(t, dataplaneCert, fabricCert)
+	require.NoError(t, c.OnStreamOpen(dataplaneCtx, 2, resource.SecretType))
+	require.NoError(t, c.OnDeltaStreamOpen(dataplaneCtx, 3, resource.SecretType))
+
+	for _, ctx := range []context.Context{
+		context.Background(),
+		peerContext(t, gwctlCert, fabricCert),
+		peerContext(t, remoteDataplaneCert, fabricCert),
+	} {
+		err := c.OnStreamOpen(ctx, 4, resource.SecretType)
+		require.Equal(t, codes.PermissionDenied, grpcstatus.Code(err))
+		err = c.OnDeltaStreamOpen(ctx, 5, resource.SecretType)
+		require.Equal(t, codes.PermissionDenied, grpcstatus.Code(err))
+	}
+
+	// rejected streams are not tracked
+	c.lock.Lock()
+	require.Len(t, c.streams, 3)
+	c.lock.Unlock()
+}
+
+func TestCallbacksAckNack(t *testing.T) {
+	c := newCallbacks()
+	node := &core.Node{Id: "dataplane-1"}
+	ctx := context.Background()
+
+	respond := func(version, nonce string) {
+		req := &discovery.DiscoveryRequest{Node: node, TypeUrl: resource.ListenerType}
+		resp := &discovery.DiscoveryResponse{TypeUrl: resource.ListenerType, VersionInfo: version, Nonce: nonce}
+		c.OnStreamResponse(ctx, 1, req, resp)
+	}
+
+	request := func(nonce, errorMessage string) {
+		req := &discovery.DiscoveryRequest{Node: node, TypeUrl: resource.ListenerType, ResponseNonce: nonce}
+		if errorMessage != "" {
+			req.ErrorDetail = &status.Status{Message: errorMessage}
+		}
+		require.NoError(t, c.OnStreamRequest(1, req))
+	}
+
+	// streams are reported once their node is known
+	require.NoError(t, c.OnStreamOpen(ctx, 1, ""))
+	require.Empty(t, c.nodes())
+
+	// initial request
+	generation := c.getGeneration()
+	request("", "")
+	require.Greater(t, c.getGeneration(), generation)
+	nodes := c.nodes()
+	require.Len(t, nodes, 1)
+	require.Equal(t, "dataplane-1", nodes[0].ID)
+	require.False(t, nodes[0].ConnectedSince.IsZero())
+	require.Empty(t, nodes[0].Resources[listenerResource].AckedVersion)
+
+	// ACK
+	respond("1", "a")
+	request("a", "")
+	listeners := c.nodes()[0].Resources[listenerResource]
+	require.Equal(t, "1", listeners.SentVersion)
+	require.Equal(t, "1", listeners.AckedVersion)
+	require.Nil(t, listeners.LastNACK)
+	require.True(t, listeners.Synced())
+
+	// NACK
+	respond("2", "b")
+	request("b", "bad listener")
+	listeners = c.nodes()[0].Resources[listenerResource]
+	require.Equal(t, "2", listeners.SentVersion)
+	require.Equal(t, "1", listeners.AckedVersion)
+	require.NotNil(t, listeners.LastNACK)
+	require.Equal(t, "2", listeners.LastNACK.Version)
+	require.Equal(t, "bad listener", listeners.LastNACK.Message)
+	require.False(t, listeners.Synced())
+	require.True(t, listeners.Rejected())
+
+	// ACK of the latest response, skipping a superseded one
+	respond("3", "c")
+	respond("4", "d")
+	request("d", "")
+	request("c", "")
+	listeners = c.nodes()[0].Resources[listenerResource]
+	require.Equal(t, "4", listeners.AckedVersion)
+	require.True(t, listeners.Synced())
+	require.False(t, listeners.Rejected())
+
+	// closed streams are forgotten
+	c.OnStreamClosed(1, node)
+	require.Empty(t, c.nodes())
+}
@@ -16,6 +16,7 @@ package xds
 import (
 	"context"
 
+	discv1 "k8s.io/api/discovery/v1"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
 
@@ -53,6 +54,25 @@ func CreateControllers(mgr *Manager, controllerManager ctrl.Manager) error {
 		return err
 	}
 
+	if mgr.client != nil {
+		err = controller.AddToManager(controllerManager, &controller.Spec{
+			Name:   "xds.endpointslice",
+			Object: &discv1.EndpointSlice{},
+			AddHandler: func(ctx context.Context, object any) error {
+				endpointSlice := object.(*discv1.EndpointSlice)
+				return mgr.updateEndpoints(
+					ctx, endpointSlice.Namespace, endpointSlice.Labels[discv1.LabelServiceName])
+			},
+			DeleteHandler: func(ctx context.Context, name types.NamespacedName) error {
+				// the service of a deleted endpoint slice is unknown
+				return mgr.updateEndpoints(ctx, name.Namespace, "")
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
 	return controller.AddToManager(controllerManager, &controller.Spec{
 		Name:   "xds.import",
 		Object: &v1alpha1.Import{},
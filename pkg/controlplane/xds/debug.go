@@ -0,0 +1,99 @@
+// Copyright 2023 The ClusterLink Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	cpapi "github.com/clusterlink-net/clusterlink/pkg/controlplane/api"
+	"github.com/clusterlink-net/clusterlink/pkg/util/rest"
+)
+
+// debugServer serves dumps of the xDS configuration to gwctl clients.
+type debugServer struct {
+	manager *Manager
+	logger  *logrus.Entry
+}
+
+// dumpResources returns the JSON encoding of all resources in the given cache.
+func dumpResources(resourceCache resourceCache) (map[string]json.RawMessage, error) {
+	resources := resourceCache.GetResources()
+	dump := make(map[string]json.RawMessage, len(resources))
+	for name, res := range resources {
+		encoded, err := protojson.Marshal(res)
+		if err != nil {
+			return nil, fmt.Errorf("cannot encode resource '%s': %w", name, err)
+		}
+
+		dump[name] = encoded
+	}
+
+	return dump, nil
+}
+
+// Dump returns the current xDS resources, and the xDS status of the connected dataplanes.
+func (m *Manager) Dump() (*cpapi.XDSDump, error) {
+	clusters, err := dumpResources(m.clusters)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoints, err := dumpResources(m.endpoints)
+	if err != nil {
+		return nil, err
+	}
+
+	listeners, err := dumpResources(m.listeners)
+	if err != nil {
+		return nil, err
+	}
+
+	return &cpapi.XDSDump{
+		Clusters:  clusters,
+		Endpoints: endpoints,
+		Listeners: listeners,
+		Nodes:     m.callbacks.nodes(),
+	}, nil
+}
+
+// getDump handles requests for dumping the xDS configuration.
+func (s *debugServer) getDump(w http.ResponseWriter, _ *http.Request) {
+	dump, err := s.manager.Dump()
+	if err != nil {
+		s.logger.Errorf("Cannot dump xDS configuration: %v.", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(dump); err != nil {
+		s.logger.Errorf("Cannot encode xDS dump: %v.", err)
+	}
+}
+
+// RegisterHandlers registers the HTTP handlers for debugging the xDS configuration.
+// Dumps are authorized and audited by the server as a dump operation.
+func RegisterHandlers(manager *Manager, srv *rest.Server) {
+	server := &debugServer{
+		manager: manager,
+		logger:  logrus.WithField("component", "controlplane.xds.debug"),
+	}
+
+	srv.AddOperationHandler(http.MethodGet, cpapi.XDSDumpPath, rest.OperationDump, server.getDump)
+}
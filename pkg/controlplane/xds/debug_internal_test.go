@@ -0,0 +1,87 @@
+// Copyright 2023 The ClusterLink Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	cpapi "github.com/clusterlink-net/clusterlink/pkg/controlplane/api"
+	"github.com/clusterlink-net/clusterlink/pkg/util/audit"
+	"github.com/clusterlink-net/clusterlink/pkg/util/rest"
+)
+
+// dumpAuthorizer allows only dump operations of admins.
+type dumpAuthorizer struct{}
+
+func (dumpAuthorizer) Authorize(r *http.Request, basePath string, op rest.Operation) error {
+	if basePath != cpapi.XDSDumpPath || op != rest.OperationDump {
+		return errors.New("unexpected operation")
+	}
+	if r.Header.Get("X-User") != "admin" {
+		return errors.New("not an admin")
+	}
+
+	return nil
+}
+
+func TestDumpHandler(t *testing.T) {
+	_, signingKey, err := ed25519.GenerateKey(rand.Reader)
+	require.Nil(t, err)
+
+	var buf bytes.Buffer
+	srv := rest.NewServer("test", nil)
+	srv.SetAuthorizer(dumpAuthorizer{})
+	srv.SetAuditLogger(audit.NewLogger(audit.LevelAll, &buf, signingKey))
+	RegisterHandlers(NewManager(false), srv)
+
+	dump := func(user string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, cpapi.XDSDumpPath, http.NoBody)
+		r.Header.Set("X-User", user)
+		w := httptest.NewRecorder()
+		srv.Router().ServeHTTP(w, r)
+		return w
+	}
+
+	require.Equal(t, http.StatusForbidden, dump("reader").Code)
+
+	w := dump("admin")
+	require.Equal(t, http.StatusOK, w.Code)
+	var decoded cpapi.XDSDump
+	require.Nil(t, json.NewDecoder(w.Body).Decode(&decoded))
+
+	// both the denied and the allowed dumps are audited
+	var records []audit.Record
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var record audit.Record
+		require.Nil(t, json.Unmarshal([]byte(line), &record))
+		records = append(records, record)
+	}
+
+	require.Len(t, records, 2)
+	for i, outcome := range []audit.Outcome{audit.OutcomeDeny, audit.OutcomeSuccess} {
+		require.Equal(t, string(rest.OperationDump), records[i].Action)
+		require.Equal(t, cpapi.XDSDumpPath, records[i].Resource)
+		require.Equal(t, outcome, records[i].Outcome)
+	}
+}
@@ -0,0 +1,266 @@
+// Copyright 2023 The ClusterLink Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	"google.golang.org/protobuf/types/known/durationpb"
+	v1 "k8s.io/api/core/v1"
+	discv1 "k8s.io/api/discovery/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/clusterlink-net/clusterlink/pkg/apis/clusterlink.net/v1alpha1"
+	cpapi "github.com/clusterlink-net/clusterlink/pkg/controlplane/api"
+)
+
+// SetEndpointDiscovery enables endpoint discovery, using the given client to read services and endpoint slices.
+// Exports of local services are then routed directly to the service endpoints, published as EDS resources,
+// rather than through the service address. Must be called before any export is added.
+func (m *Manager) SetEndpointDiscovery(cl client.Client) {
+	m.client = cl
+}
+
+// exportService returns the name of the local service of an export, if the export uses endpoint discovery.
+// An export refers to a local service if its host is empty, the service name, or the service DNS name.
+func (m *Manager) exportService(export *v1alpha1.Export) (string, bool) {
+	if m.client == nil {
+		return "", false
+	}
+
+	host := export.Spec.Host
+	if host == "" {
+		return export.Name, true
+	}
+
+	host = strings.TrimSuffix(host, "."+export.Namespace+".svc.cluster.local")
+	if strings.Contains(host, ".") || strings.Contains(host, ":") {
+		// a DNS name or IP address outside of the export namespace
+		return "", false
+	}
+
+	return host, true
+}
+
+// addEDSExport adds the clusters of an export using endpoint discovery, and publishes their endpoints.
+func (m *Manager) addEDSExport(ctx context.Context, export *v1alpha1.Export, service string) error {
+	m.exportsLock.Lock()
+	defer m.exportsLock.Unlock()
+
+	name := types.NamespacedName{Namespace: export.Namespace, Name: export.Name}
+	m.exports[name] = export
+
+	clusters := make(map[string]bool)
+	for _, port := range export.Spec.ServicePorts() {
+		clusterName := cpapi.ExportPortClusterName(export.Name, export.Namespace, port.Name)
+		cc := &cluster.Cluster{
+			Name:           clusterName,
+			ConnectTimeout: durationpb.New(time.Second),
+			ClusterDiscoveryType: &cluster.Cluster_Type{
+				Type: cluster.Cluster_EDS,
+			},
+			EdsClusterConfig: &cluster.Cluster_EdsClusterConfig{
+				EdsConfig: &core.ConfigSource{
+					ResourceApiVersion: core.ApiVersion_V3,
+					ConfigSourceSpecifier: &core.ConfigSource_Ads{
+						Ads: &core.AggregatedConfigSource{},
+					},
+				},
+			},
+		}
+		setExportCircuitBreakers(cc, export)
+
+		if err := m.updateResource(m.clusters, clusterResource, clusterName, cc); err != nil {
+			return err
+		}
+		clusters[clusterName] = true
+	}
+
+	if err := m.updateExportEndpoints(ctx, export, service); err != nil {
+		return err
+	}
+
+	return m.deleteStaleResources(
+		m.clusters, clusterResource, cpapi.ExportClusterName(export.Name, export.Namespace), clusters)
+}
+
+// deleteEDSExport stops publishing the endpoints of an export.
+func (m *Manager) deleteEDSExport(name types.NamespacedName) error {
+	m.exportsLock.Lock()
+	defer m.exportsLock.Unlock()
+
+	delete(m.exports, name)
+	return m.deleteStaleResources(
+		m.endpoints, endpointResource, cpapi.ExportClusterName(name.Name, name.Namespace), nil)
+}
+
+// updateEndpoints publishes the endpoints of the exports of a service.
+// If service is empty, the endpoints of all exports in the namespace are published.
+func (m *Manager) updateEndpoints(ctx context.Context, namespace, service string) error {
+	m.exportsLock.Lock()
+	defer m.exportsLock.Unlock()
+
+	for name, export := range m.exports {
+		if name.Namespace != namespace {
+			continue
+		}
+
+		exportService, _ := m.exportService(export)
+		if service != "" && exportService != service {
+			continue
+		}
+
+		if err := m.updateExportEndpoints(ctx, export, exportService); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// updateExportEndpoints publishes the endpoints of the export ports, read from the endpoint slices of its service.
+// Must be called while holding the exports lock.
+func (m *Manager) updateExportEndpoints(ctx context.Context, export *v1alpha1.Export, service string) error {
+	var svc v1.Service
+	err := m.client.Get(ctx, types.NamespacedName{Namespace: export.Namespace, Name: service}, &svc)
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return fmt.Errorf("cannot get service %s/%s: %w", export.Namespace, service, err)
+	}
+
+	var slices discv1.EndpointSliceList
+	if err == nil {
+		err := m.client.List(
+			ctx, &slices,
+			client.InNamespace(export.Namespace),
+			client.MatchingLabels{discv1.LabelServiceName: service})
+		if err != nil {
+			return fmt.Errorf("cannot list endpoint slices of service %s/%s: %w", export.Namespace, service, err)
+		}
+	}
+
+	assignments := make(map[string]bool)
+	for _, port := range export.Spec.ServicePorts() {
+		clusterName := cpapi.ExportPortClusterName(export.Name, export.Namespace, port.Name)
+
+		// a missing service or port yields no endpoints
+		var lbEndpoints []*endpoint.LbEndpoint
+		for _, servicePort := range svc.Spec.Ports {
+			if servicePort.Port == int32(port.Port) {
+				lbEndpoints = makeLbEndpoints(slices.Items, servicePort.Name)
+				break
+			}
+		}
+
+		cla := &endpoint.ClusterLoadAssignment{
+			ClusterName: clusterName,
+			Endpoints: []*endpoint.LocalityLbEndpoints{{
+				LbEndpoints: lbEndpoints,
+			}},
+		}
+		if err := m.updateResource(m.endpoints, endpointResource, clusterName, cla); err != nil {
+			return err
+		}
+		assignments[clusterName] = true
+	}
+
+	// delete endpoints of ports removed from the export
+	return m.deleteStaleResources(
+		m.endpoints, endpointResource, cpapi.ExportClusterName(export.Name, export.Namespace), assignments)
+}
+
+// makeLbEndpoints returns the ready endpoints of the given endpoint slices, for the given service port name.
+// Dual-stack services have endpoint slices of both address families, listing each endpoint twice,
+// so only IPv4 endpoints are used if there are any, and IPv6 endpoints otherwise.
+// Endpoints are sorted by address, so that unchanged endpoints yield identical resources.
+func makeLbEndpoints(slices []discv1.EndpointSlice, portName string) []*endpoint.LbEndpoint {
+	endpoints := make(map[discv1.AddressType][]*endpoint.LbEndpoint)
+	seen := make(map[string]bool)
+	for i := range slices {
+		slice := &slices[i]
+		if slice.AddressType == discv1.AddressTypeFQDN {
+			continue
+		}
+
+		var port int32
+		for _, slicePort := range slice.Ports {
+			if slicePort.Port != nil && (slicePort.Name == nil && portName == "" ||
+				slicePort.Name != nil && *slicePort.Name == portName) {
+				port = *slicePort.Port
+				break
+			}
+		}
+		if port == 0 {
+			continue
+		}
+
+		for _, ep := range slice.Endpoints {
+			if (ep.Conditions.Ready != nil && !*ep.Conditions.Ready) || len(ep.Addresses) == 0 {
+				continue
+			}
+
+			// the addresses of an endpoint are fungible, and an endpoint may transiently appear in two slices
+			address := ep.Addresses[0]
+			key := net.JoinHostPort(address, strconv.Itoa(int(port)))
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			endpoints[slice.AddressType] = append(endpoints[slice.AddressType], &endpoint.LbEndpoint{
+				HostIdentifier: &endpoint.LbEndpoint_Endpoint{
+					Endpoint: &endpoint.Endpoint{
+						Address: &core.Address{
+							Address: &core.Address_SocketAddress{
+								SocketAddress: &core.SocketAddress{
+									Address: address,
+									PortSpecifier: &core.SocketAddress_PortValue{
+										PortValue: uint32(port),
+									},
+								},
+							},
+						},
+					},
+				},
+				HealthStatus: core.HealthStatus_HEALTHY,
+			})
+		}
+	}
+
+	lbEndpoints := endpoints[discv1.AddressTypeIPv4]
+	if len(lbEndpoints) == 0 {
+		lbEndpoints = endpoints[discv1.AddressTypeIPv6]
+	}
+
+	sort.Slice(lbEndpoints, func(i, j int) bool {
+		a := lbEndpoints[i].GetEndpoint().GetAddress().GetSocketAddress()
+		b := lbEndpoints[j].GetEndpoint().GetAddress().GetSocketAddress()
+		if a.GetAddress() != b.GetAddress() {
+			return a.GetAddress() < b.GetAddress()
+		}
+		return a.GetPortValue() < b.GetPortValue()
+	})
+
+	return lbEndpoints
+}
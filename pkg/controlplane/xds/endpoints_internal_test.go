@@ -0,0 +1,134 @@
+// Copyright 2023 The ClusterLink Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"testing"
+
+	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	discv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/clusterlink-net/clusterlink/pkg/apis/clusterlink.net/v1alpha1"
+	cpapi "github.com/clusterlink-net/clusterlink/pkg/controlplane/api"
+)
+
+func TestEndpointDiscovery(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, v1.AddToScheme(scheme))
+	require.NoError(t, discv1.AddToScheme(scheme))
+
+	service := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{Name: "http", Port: 80}},
+		},
+	}
+	endpointSlice := &discv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "web-abcde",
+			Namespace: "default",
+			Labels:    map[string]string{discv1.LabelServiceName: "web"},
+		},
+		AddressType: discv1.AddressTypeIPv4,
+		Ports:       []discv1.EndpointPort{{Name: ptr.To("http"), Port: ptr.To[int32](8080)}},
+		Endpoints: []discv1.Endpoint{
+			{Addresses: []string{"10.0.0.2"}},
+			{Addresses: []string{"10.0.0.1"}, Conditions: discv1.EndpointConditions{Ready: ptr.To(true)}},
+			{Addresses: []string{"10.0.0.3"}, Conditions: discv1.EndpointConditions{Ready: ptr.To(false)}},
+		},
+	}
+
+	manager := NewManager(true)
+	manager.SetEndpointDiscovery(
+		fake.NewClientBuilder().WithScheme(scheme).WithObjects(service, endpointSlice).Build())
+
+	export := &v1alpha1.Export{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec:       v1alpha1.ExportSpec{Port: 80},
+	}
+	require.NoError(t, manager.AddExport(export))
+
+	clusterName := cpapi.ExportClusterName("web", "default")
+	cc, ok := manager.clusters.GetResources()[clusterName].(*cluster.Cluster)
+	require.True(t, ok)
+	require.Equal(t, cluster.Cluster_EDS, cc.GetType())
+
+	// only ready endpoints are published, using the endpoint port of the exported service port
+	cla, ok := manager.endpoints.GetResources()[clusterName].(*endpoint.ClusterLoadAssignment)
+	require.True(t, ok)
+	lbEndpoints := cla.Endpoints[0].LbEndpoints
+	require.Len(t, lbEndpoints, 2)
+	for i, address := range []string{"10.0.0.1", "10.0.0.2"} {
+		socketAddress := lbEndpoints[i].GetEndpoint().GetAddress().GetSocketAddress()
+		require.Equal(t, address, socketAddress.GetAddress())
+		require.Equal(t, uint32(8080), socketAddress.GetPortValue())
+	}
+
+	// exports of non-local hosts are routed through their address
+	export.Spec.Host = "example.com"
+	require.NoError(t, manager.AddExport(export))
+	cc, ok = manager.clusters.GetResources()[clusterName].(*cluster.Cluster)
+	require.True(t, ok)
+	require.Equal(t, cluster.Cluster_STRICT_DNS, cc.GetType())
+	require.NotContains(t, manager.endpoints.GetResources(), clusterName)
+
+	export.Spec.Host = ""
+	require.NoError(t, manager.AddExport(export))
+	require.NoError(t, manager.DeleteExport(types.NamespacedName{Namespace: "default", Name: "web"}))
+	require.Empty(t, manager.clusters.GetResources())
+	require.Empty(t, manager.endpoints.GetResources())
+}
+
+func TestDualStackEndpoints(t *testing.T) {
+	slice := func(name string, addressType discv1.AddressType, addresses ...string) discv1.EndpointSlice {
+		endpoints := make([]discv1.Endpoint, len(addresses))
+		for i, address := range addresses {
+			endpoints[i] = discv1.Endpoint{Addresses: []string{address}}
+		}
+
+		return discv1.EndpointSlice{
+			ObjectMeta:  metav1.ObjectMeta{Name: name, Namespace: "default"},
+			AddressType: addressType,
+			Ports:       []discv1.EndpointPort{{Port: ptr.To[int32](8080)}},
+			Endpoints:   endpoints,
+		}
+	}
+
+	addresses := func(lbEndpoints []*endpoint.LbEndpoint) []string {
+		result := make([]string, len(lbEndpoints))
+		for i, lbEndpoint := range lbEndpoints {
+			result[i] = lbEndpoint.GetEndpoint().GetAddress().GetSocketAddress().GetAddress()
+		}
+		return result
+	}
+
+	ipv4 := slice("web-ipv4", discv1.AddressTypeIPv4, "10.0.0.2", "10.0.0.1")
+	ipv6 := slice("web-ipv6", discv1.AddressTypeIPv6, "fd00::1", "fd00::2")
+	// an endpoint moving between slices is transiently listed by both
+	moving := slice("web-ipv4-moving", discv1.AddressTypeIPv4, "10.0.0.1")
+
+	// each endpoint is listed once, preferring IPv4 addresses
+	require.Equal(t, []string{"10.0.0.1", "10.0.0.2"}, addresses(makeLbEndpoints(
+		[]discv1.EndpointSlice{ipv6, ipv4, moving}, "")))
+	require.Equal(t, []string{"fd00::1", "fd00::2"}, addresses(makeLbEndpoints(
+		[]discv1.EndpointSlice{ipv6}, "")))
+}
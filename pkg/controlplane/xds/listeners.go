@@ -0,0 +1,292 @@
+// Copyright 2023 The ClusterLink Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	cachetypes "github.com/envoyproxy/go-control-plane/pkg/cache/types"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/server/stream/v3"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/proto"
+
+	cpapi "github.com/clusterlink-net/clusterlink/pkg/controlplane/api"
+)
+
+const (
+	// defaultListenAddress is the address import listeners are bound to,
+	// for dataplanes which do not specify their listen addresses.
+	defaultListenAddress = "0.0.0.0"
+	// boundCacheIdleTimeout is the time after which a cache of listeners bound to specific addresses
+	// is removed, if it has no open watches.
+	boundCacheIdleTimeout = 10 * time.Minute
+)
+
+// resourceCache is a cache of xDS resources of a single type.
+type resourceCache interface {
+	// UpdateResource adds or updates a resource.
+	UpdateResource(name string, res cachetypes.Resource) error
+	// DeleteResource deletes a resource.
+	DeleteResource(name string) error
+	// GetResources returns all resources.
+	GetResources() map[string]cachetypes.Resource
+}
+
+// boundCache is a cache of listeners bound to a specific set of addresses.
+type boundCache struct {
+	addresses []string
+	cache     *cache.LinearCache
+	// watches is the number of open watches
+	watches   int
+	idleSince time.Time
+}
+
+// listenerCache is a cache of import listeners, which are bound to the listen addresses of each dataplane,
+// as specified in the metadata of its xDS node.
+// Dataplanes which do not specify listen addresses are served listeners bound to defaultListenAddress.
+type listenerCache struct {
+	lock sync.Mutex
+	// listeners bound to defaultListenAddress
+	listeners map[string]*listener.Listener
+	// caches maps listen addresses (joined by commas) to caches of listeners bound to them
+	caches map[string]*boundCache
+	// created is the number of created caches, used for versioning
+	created int
+
+	logger *logrus.Entry
+}
+
+var (
+	_ cache.Cache   = &listenerCache{}
+	_ resourceCache = &listenerCache{}
+	_ resourceCache = &cache.LinearCache{}
+)
+
+// bindListener returns a copy of a listener, bound to the given addresses.
+// The unspecified IPv6 address (::) also accepts IPv4 connections.
+func bindListener(ln *listener.Listener, addresses []string) *listener.Listener {
+	if len(addresses) == 0 {
+		return ln
+	}
+
+	bound := proto.Clone(ln).(*listener.Listener)
+	socketAddress := func(address string) *core.Address {
+		sa := proto.Clone(ln.GetAddress().GetSocketAddress()).(*core.SocketAddress)
+		sa.Address = address
+		sa.Ipv4Compat = address == net.IPv6unspecified.String()
+		return &core.Address{
+			Address: &core.Address_SocketAddress{SocketAddress: sa},
+		}
+	}
+
+	bound.Address = socketAddress(addresses[0])
+	bound.AdditionalAddresses = nil
+	for _, address := range addresses[1:] {
+		bound.AdditionalAddresses = append(bound.AdditionalAddresses, &listener.AdditionalAddress{
+			Address: socketAddress(address),
+		})
+	}
+
+	return bound
+}
+
+// nodeListenAddresses returns the listen addresses specified in the metadata of an xDS node.
+// Invalid addresses are ignored.
+func (c *listenerCache) nodeListenAddresses(node *core.Node) []string {
+	values := node.GetMetadata().GetFields()[cpapi.ListenAddressesMetadataKey].GetListValue().GetValues()
+
+	addresses := make([]string, 0, len(values))
+	for _, value := range values {
+		ip := net.ParseIP(value.GetStringValue())
+		if ip == nil {
+			c.logger.Warnf("Ignoring invalid listen address '%s' of node '%s'.",
+				value.GetStringValue(), node.GetId())
+			continue
+		}
+
+		addresses = append(addresses, ip.String())
+	}
+
+	return addresses
+}
+
+// boundCache returns the cache of listeners bound to the listen addresses of an xDS node,
+// creating it if needed. Requires the lock.
+func (c *listenerCache) boundCache(node *core.Node) *boundCache {
+	addresses := c.nodeListenAddresses(node)
+	key := strings.Join(addresses, ",")
+	if bc, ok := c.caches[key]; ok {
+		return bc
+	}
+
+	// remove idle caches of dataplanes which are no longer connected
+	now := time.Now()
+	for idleKey, bc := range c.caches {
+		if idleKey != "" && bc.watches == 0 && now.Sub(bc.idleSince) > boundCacheIdleTimeout {
+			delete(c.caches, idleKey)
+		}
+	}
+
+	resources := make(map[string]cachetypes.Resource, len(c.listeners))
+	for name, ln := range c.listeners {
+		resources[name] = bindListener(ln, addresses)
+	}
+
+	c.created++
+	bc := &boundCache{
+		addresses: addresses,
+		cache: cache.NewLinearCache(
+			resource.ListenerType,
+			cache.WithInitialResources(resources),
+			cache.WithVersionPrefix(fmt.Sprintf("%d-", c.created)),
+			cache.WithLogger(c.logger)),
+		idleSince: now,
+	}
+	c.caches[key] = bc
+
+	c.logger.Infof("Serving import listeners bound to '%s'.", key)
+	return bc
+}
+
+// trackWatch tracks an open watch of a bound cache, given its cancel function (nil if the watch was not opened).
+// Returns a cancel function which stops tracking the watch.
+func (c *listenerCache) trackWatch(bc *boundCache, cancel func()) func() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if cancel == nil {
+		bc.idleSince = time.Now()
+		return nil
+	}
+
+	bc.watches++
+	return func() {
+		cancel()
+
+		c.lock.Lock()
+		defer c.lock.Unlock()
+
+		bc.watches--
+		if bc.watches == 0 {
+			bc.idleSince = time.Now()
+		}
+	}
+}
+
+// getBoundCache returns the cache of listeners bound to the listen addresses of an xDS node.
+func (c *listenerCache) getBoundCache(node *core.Node) *boundCache {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.boundCache(node)
+}
+
+// CreateWatch returns a new open watch from a non-empty request.
+func (c *listenerCache) CreateWatch(
+	request *cache.Request,
+	state stream.StreamState,
+	value chan cache.Response,
+) func() {
+	bc := c.getBoundCache(request.GetNode())
+	return c.trackWatch(bc, bc.cache.CreateWatch(request, state, value))
+}
+
+// CreateDeltaWatch returns a new open incremental xDS watch.
+func (c *listenerCache) CreateDeltaWatch(
+	request *cache.DeltaRequest,
+	state stream.StreamState,
+	value chan cache.DeltaResponse,
+) func() {
+	bc := c.getBoundCache(request.GetNode())
+	return c.trackWatch(bc, bc.cache.CreateDeltaWatch(request, state, value))
+}
+
+// Fetch implements the polling method of the cache.
+func (c *listenerCache) Fetch(ctx context.Context, request *cache.Request) (cache.Response, error) {
+	return c.getBoundCache(request.GetNode()).cache.Fetch(ctx, request)
+}
+
+// UpdateResource adds or updates a listener, pushing it (bound to the relevant addresses) to all dataplanes.
+func (c *listenerCache) UpdateResource(name string, res cachetypes.Resource) error {
+	ln, ok := res.(*listener.Listener)
+	if !ok {
+		return fmt.Errorf("resource '%s' is not a listener", name)
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.listeners[name] = ln
+	for _, bc := range c.caches {
+		if err := bc.cache.UpdateResource(name, bindListener(ln, bc.addresses)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DeleteResource deletes a listener, pushing the deletion to all dataplanes.
+func (c *listenerCache) DeleteResource(name string) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	delete(c.listeners, name)
+	for _, bc := range c.caches {
+		if err := bc.cache.DeleteResource(name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetResources returns all listeners, bound to defaultListenAddress.
+func (c *listenerCache) GetResources() map[string]cachetypes.Resource {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	resources := make(map[string]cachetypes.Resource, len(c.listeners))
+	for name, ln := range c.listeners {
+		resources[name] = ln
+	}
+
+	return resources
+}
+
+// newListenerCache returns a new empty listener cache.
+func newListenerCache(logger *logrus.Entry) *listenerCache {
+	c := &listenerCache{
+		listeners: make(map[string]*listener.Listener),
+		caches:    make(map[string]*boundCache),
+		logger:    logger,
+	}
+
+	// cache of dataplanes which do not specify listen addresses
+	c.created++
+	c.caches[""] = &boundCache{
+		cache: cache.NewLinearCache(resource.ListenerType, cache.WithLogger(logger)),
+	}
+
+	return c
+}
@@ -0,0 +1,142 @@
+// Copyright 2023 The ClusterLink Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"testing"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/server/stream/v3"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	cpapi "github.com/clusterlink-net/clusterlink/pkg/controlplane/api"
+)
+
+// makeNode returns an xDS node specifying the given listen addresses.
+func makeNode(t *testing.T, id string, addresses ...any) *core.Node {
+	t.Helper()
+
+	if len(addresses) == 0 {
+		return &core.Node{Id: id}
+	}
+
+	metadata, err := structpb.NewStruct(map[string]any{
+		cpapi.ListenAddressesMetadataKey: addresses,
+	})
+	require.NoError(t, err)
+	return &core.Node{Id: id, Metadata: metadata}
+}
+
+// watchListeners returns the listeners sent to a node, after the given version.
+func watchListeners(t *testing.T, c *listenerCache, node *core.Node, version string) (map[string]*listener.Listener, string) {
+	t.Helper()
+
+	responses := make(chan cache.Response, 1)
+	request := &cache.Request{Node: node, TypeUrl: resource.ListenerType, VersionInfo: version}
+	cancel := c.CreateWatch(request, stream.NewStreamState(true, nil), responses)
+	if cancel != nil {
+		defer cancel()
+	}
+
+	var response cache.Response
+	select {
+	case response = <-responses:
+	default:
+		require.Fail(t, "no response")
+	}
+
+	raw, ok := response.(*cache.RawResponse)
+	require.True(t, ok)
+
+	listeners := make(map[string]*listener.Listener)
+	for _, res := range raw.Resources {
+		ln, ok := res.Resource.(*listener.Listener)
+		require.True(t, ok)
+		listeners[ln.Name] = ln
+	}
+
+	return listeners, raw.Version
+}
+
+func TestListenerCacheBinding(t *testing.T) {
+	c := newListenerCache(logrus.WithField("component", "test"))
+	ln := &listener.Listener{
+		Name: "import-default/svc",
+		Address: &core.Address{
+			Address: &core.Address_SocketAddress{
+				SocketAddress: &core.SocketAddress{
+					Address:       defaultListenAddress,
+					PortSpecifier: &core.SocketAddress_PortValue{PortValue: 5000},
+				},
+			},
+		},
+	}
+	require.NoError(t, c.UpdateResource(ln.Name, ln))
+
+	// dataplanes not specifying listen addresses bind to the default address
+	listeners, _ := watchListeners(t, c, makeNode(t, "default"), "")
+	require.Len(t, listeners, 1)
+	require.Equal(t, defaultListenAddress, listeners[ln.Name].Address.GetSocketAddress().GetAddress())
+	require.Empty(t, listeners[ln.Name].AdditionalAddresses)
+
+	// dual-stack pod IPs
+	node := makeNode(t, "dual-stack", "10.0.0.1", "fd00::1")
+	listeners, version := watchListeners(t, c, node, "")
+	bound := listeners[ln.Name]
+	require.Equal(t, "10.0.0.1", bound.Address.GetSocketAddress().GetAddress())
+	require.Equal(t, uint32(5000), bound.Address.GetSocketAddress().GetPortValue())
+	require.Len(t, bound.AdditionalAddresses, 1)
+	require.Equal(t, "fd00::1", bound.AdditionalAddresses[0].Address.GetSocketAddress().GetAddress())
+	require.Equal(t, uint32(5000), bound.AdditionalAddresses[0].Address.GetSocketAddress().GetPortValue())
+
+	// the listener resource itself is not modified
+	require.Equal(t, defaultListenAddress, c.GetResources()[ln.Name].(*listener.Listener).Address.GetSocketAddress().GetAddress())
+
+	// updates are pushed to open watches, bound to the node addresses
+	responses := make(chan cache.Response, 1)
+	request := &cache.Request{Node: node, TypeUrl: resource.ListenerType, VersionInfo: version}
+	cancel := c.CreateWatch(request, stream.NewStreamState(true, nil), responses)
+	require.NotNil(t, cancel)
+	require.Equal(t, 1, c.caches["10.0.0.1,fd00::1"].watches)
+
+	ln2 := &listener.Listener{Name: "import-default/svc2", Address: ln.Address}
+	require.NoError(t, c.UpdateResource(ln2.Name, ln2))
+	response := <-responses
+	pushed := response.(*cache.RawResponse).Resources
+	require.Len(t, pushed, 2)
+	for _, res := range pushed {
+		require.Equal(t, "10.0.0.1", res.Resource.(*listener.Listener).Address.GetSocketAddress().GetAddress())
+	}
+
+	cancel()
+	require.Equal(t, 0, c.caches["10.0.0.1,fd00::1"].watches)
+
+	// the unspecified IPv6 address accepts IPv4 connections, and invalid addresses are ignored
+	listeners, _ = watchListeners(t, c, makeNode(t, "any", "::", "invalid"), "")
+	require.Equal(t, "::", listeners[ln.Name].Address.GetSocketAddress().GetAddress())
+	require.True(t, listeners[ln.Name].Address.GetSocketAddress().GetIpv4Compat())
+	require.Empty(t, listeners[ln.Name].AdditionalAddresses)
+
+	// deletions are pushed to all caches
+	require.NoError(t, c.DeleteResource(ln.Name))
+	for _, bc := range c.caches {
+		_, ok := bc.cache.GetResources()[ln.Name]
+		require.False(t, ok)
+	}
+}
@@ -14,110 +14,405 @@
 package xds
 
 import (
+	"context"
 	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
+	xdscore "github.com/cncf/xds/go/xds/core/v3"
+	matcher "github.com/cncf/xds/go/xds/type/matcher/v3"
+	accesslog "github.com/envoyproxy/go-control-plane/envoy/config/accesslog/v3"
 	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
 	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
 	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	router "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/router/v3"
+	connectionlimit "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/connection_limit/v3"
+	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	localratelimit "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/local_ratelimit/v3"
 	tcpproxy "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/tcp_proxy/v3"
+	udpproxy "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/udp/udp_proxy/v3"
 	getaddrinfo "github.com/envoyproxy/go-control-plane/envoy/extensions/network/dns_resolver/getaddrinfo/v3"
 	tls "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	envoymatcher "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
+	envoytype "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	cachetypes "github.com/envoyproxy/go-control-plane/pkg/cache/types"
 	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
 	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
 	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
 	"github.com/sirupsen/logrus"
 	"google.golang.org/protobuf/types/known/anypb"
 	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
 	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/clusterlink-net/clusterlink/pkg/apis/clusterlink.net/v1alpha1"
 	cpapi "github.com/clusterlink-net/clusterlink/pkg/controlplane/api"
+	cppeer "github.com/clusterlink-net/clusterlink/pkg/controlplane/peer"
 	dpapi "github.com/clusterlink-net/clusterlink/pkg/dataplane/api"
+	"github.com/clusterlink-net/clusterlink/pkg/metrics"
+	utiltls "github.com/clusterlink-net/clusterlink/pkg/util/tls"
+)
+
+const (
+	// clusterResource, endpointResource, listenerResource and secretResource are the resource type names used in metrics.
+	clusterResource  = "cluster"
+	endpointResource = "endpoint"
+	listenerResource = "listener"
+	secretResource   = "secret"
+
+	// httpRetryOn are the conditions on which requests of HTTP imports are retried.
+	httpRetryOn = "5xx,reset,connect-failure"
+	// httpNumRetries is the number of retries of a failed request of an HTTP import.
+	httpNumRetries = 2
+
+	// default thresholds of the health checks and outlier detection of remote peer gateways.
+	defaultHealthCheckIntervalSeconds    = 5
+	defaultHealthCheckTimeoutSeconds     = 2
+	defaultHealthCheckUnhealthyThreshold = 3
+	defaultHealthCheckHealthyThreshold   = 2
+	defaultOutlierConsecutiveFailures    = 5
+	defaultOutlierBaseEjectionSeconds    = 30
+	defaultOutlierMaxEjectionPercent     = 50
+
+	// heartbeatTransportSocketMatch is the transport socket match of remote peer health checks.
+	heartbeatTransportSocketMatch = "clusterlink.heartbeat"
 )
 
 // Manager manages the core routing components of the dataplane.
 // It maps the following controlplane types to xDS types:
 // - Peer -> Cluster (whose name starts with a designated prefix)
-// - Export -> Cluster (whose name starts with a designated prefix)
-// - Import -> Listener (whose name starts with a designated prefix)
+// - Export -> Cluster per exported port (whose name starts with a designated prefix)
+// - Export -> ClusterLoadAssignment per exported port, if using endpoint discovery
+// - Import -> Listener per imported port (whose name starts with a designated prefix)
+// In addition, it serves the dataplane certificate and CA as secrets.
 // Note that imported service bindings are handled by the egress authz server.
 type Manager struct {
-	crdMode bool
+	crdMode      bool
+	workloadMTLS bool
+	// dataplaneType is the type of the dataplanes consuming the xDS resources
+	dataplaneType v1alpha1.DataplaneType
+	accessLog     cpapi.AccessLogMode
 
 	clusters  *cache.LinearCache
-	listeners *cache.LinearCache
+	endpoints *cache.LinearCache
+	listeners *listenerCache
+	secrets   *cache.LinearCache
+
+	// client reads services and endpoint slices, if endpoint discovery is enabled
+	client      client.Client
+	exportsLock sync.Mutex
+	// exports using endpoint discovery
+	exports map[types.NamespacedName]*v1alpha1.Export
+
+	// resolver resolves the SRV names of peer gateways
+	resolver  cppeer.Resolver
+	peersLock sync.Mutex
+	// peers whose gateways are resolved from an SRV name
+	srvPeers map[string]*srvPeer
+
+	// callbacks tracks the xDS status of connected dataplanes
+	callbacks *callbacks
+
+	metrics *metrics.ControlplaneMetrics
+	logger  *logrus.Entry
+}
+
+// SetWorkloadMTLS sets whether import listeners require workloads to authenticate using SPIFFE SVIDs.
+// Must be called before any import is added.
+func (m *Manager) SetWorkloadMTLS(enabled bool) {
+	m.workloadMTLS = enabled
+}
+
+// SetDataplaneType sets the type of the dataplanes.
+// Exports limiting the concurrent connections of each remote peer are not served to the Envoy dataplane,
+// whose circuit breakers would limit the connections of all remote peers combined.
+// Must be called before any export is added.
+func (m *Manager) SetDataplaneType(dataplaneType v1alpha1.DataplaneType) {
+	m.dataplaneType = dataplaneType
+}
 
-	logger *logrus.Entry
+// SetPeerTLS sets the certificate data of the local peer, used to reject xDS streams
+// not opened by dataplanes of the local peer, which must not receive the served secrets.
+// Must be called before the xDS service is registered.
+func (m *Manager) SetPeerTLS(peerTLS *utiltls.ParsedCertData) {
+	m.callbacks.peerTLS = peerTLS
+}
+
+// SetMetrics sets the metrics for xDS pushes and connected dataplanes.
+// Must be called before the xDS service is registered.
+func (m *Manager) SetMetrics(controlplaneMetrics *metrics.ControlplaneMetrics) {
+	m.metrics = controlplaneMetrics
+	m.callbacks.metrics = controlplaneMetrics
+}
+
+// updateResource updates a resource in the given cache, pushing it to subscribed dataplanes.
+func (m *Manager) updateResource(
+	resourceCache resourceCache,
+	resourceType, name string,
+	res cachetypes.Resource,
+) error {
+	if err := resourceCache.UpdateResource(name, res); err != nil {
+		return err
+	}
+
+	m.metrics.ObserveXDSPush(resourceType, "update")
+	return nil
+}
+
+// deleteResource deletes a resource from the given cache, pushing the deletion to subscribed dataplanes.
+func (m *Manager) deleteResource(resourceCache resourceCache, resourceType, name string) error {
+	if err := resourceCache.DeleteResource(name); err != nil {
+		return err
+	}
+
+	m.metrics.ObserveXDSPush(resourceType, "delete")
+	return nil
+}
+
+// deleteStaleResources deletes the resources of a service (named after the given base name, or one of its ports)
+// from the given cache, except for the given resources to keep.
+func (m *Manager) deleteStaleResources(
+	resourceCache resourceCache,
+	resourceType, baseName string,
+	keep map[string]bool,
+) error {
+	for name := range resourceCache.GetResources() {
+		if keep[name] || (name != baseName && !strings.HasPrefix(name, baseName+"/")) {
+			continue
+		}
+
+		if err := m.deleteResource(resourceCache, resourceType, name); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 // AddPeer defines a new route target for egress dataplane connections.
+// If the peer has an SRV name, the gateways it points to are added to the static gateways of the peer.
 func (m *Manager) AddPeer(peer *v1alpha1.Peer) error {
 	m.logger.Infof("Adding peer '%s'.", peer.Name)
 
+	m.peersLock.Lock()
+	defer m.peersLock.Unlock()
+
+	if peer.Spec.GatewaySRV == "" {
+		delete(m.srvPeers, peer.Name)
+		return m.addPeerCluster(peer, peer.Spec.Gateways)
+	}
+
+	srvGateways, err := m.resolveSRVGateways(peer.Spec.GatewaySRV)
+	if err != nil {
+		m.logger.Warnf("Cannot resolve gateways of peer '%s': %v.", peer.Name, err)
+
+		// keep the previously resolved gateways, until the next re-resolution
+		if prev, ok := m.srvPeers[peer.Name]; ok && prev.peer.Spec.GatewaySRV == peer.Spec.GatewaySRV {
+			srvGateways = prev.srvGateways
+		}
+	}
+
+	m.srvPeers[peer.Name] = &srvPeer{peer: peer, srvGateways: srvGateways}
+	return m.addPeerCluster(peer, append(append([]v1alpha1.Endpoint{}, peer.Spec.Gateways...), srvGateways...))
+}
+
+// addPeerCluster updates the cluster of a remote peer, routing to the given gateways.
+func (m *Manager) addPeerCluster(peer *v1alpha1.Peer, gateways []v1alpha1.Endpoint) error {
 	clusterName := cpapi.RemotePeerClusterName(peer.Name)
 	dataplaneSNI := dpapi.DataplaneSNI(peer.Name)
-	epc, err := makeEndpointsCluster(clusterName, peer.Spec.Gateways, dataplaneSNI)
+	epc, err := makeEndpointsCluster(clusterName, gateways, dataplaneSNI)
+	if err != nil {
+		return err
+	}
+
+	epc.TransportSocket, err = makePeerTransportSocket(dataplaneSNI)
 	if err != nil {
 		return err
 	}
 
+	if err := setPeerHealthChecks(epc, peer); err != nil {
+		return err
+	}
+
+	return m.updateResource(m.clusters, clusterResource, clusterName, epc)
+}
+
+// makePeerTransportSocket returns a transport socket for connecting to the gateways of a remote peer,
+// using the given SNI.
+func makePeerTransportSocket(sni string) (*core.TransportSocket, error) {
 	tlsConfig := &tls.UpstreamTlsContext{
-		Sni: dataplaneSNI,
+		Sni: sni,
 		CommonTlsContext: &tls.CommonTlsContext{
-			TlsCertificateSdsSecretConfigs: []*tls.SdsSecretConfig{{
-				Name: cpapi.CertificateSecret,
-			}},
+			TlsCertificateSdsSecretConfigs: []*tls.SdsSecretConfig{
+				makeSDSSecretConfig(cpapi.CertificateSecret),
+			},
 			ValidationContextType: &tls.CommonTlsContext_ValidationContextSdsSecretConfig{
-				ValidationContextSdsSecretConfig: &tls.SdsSecretConfig{
-					Name: cpapi.ValidationSecret,
-				},
+				ValidationContextSdsSecretConfig: makeSDSSecretConfig(cpapi.ValidationSecret),
 			},
 		},
 	}
 
 	pb, err := anypb.New(tlsConfig)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	epc.TransportSocket = &core.TransportSocket{
+	return &core.TransportSocket{
 		Name:       wellknown.TransportSocketTLS,
 		ConfigType: &core.TransportSocket_TypedConfig{TypedConfig: pb},
+	}, nil
+}
+
+// setPeerHealthChecks sets the active health checks and outlier detection of a remote peer cluster.
+// Health checks send heartbeat requests to the remote peer controlplane through each gateway,
+// using the SNI of the remote peer controlplane.
+func setPeerHealthChecks(cc *cluster.Cluster, peer *v1alpha1.Peer) error {
+	healthCheck := peer.Spec.HealthCheck
+	if healthCheck == nil {
+		healthCheck = &v1alpha1.PeerHealthCheck{}
+	}
+	if healthCheck.Disabled {
+		return nil
+	}
+
+	transportSocket, err := makePeerTransportSocket(peer.Name)
+	if err != nil {
+		return err
+	}
+
+	matchCriteria, err := structpb.NewStruct(map[string]any{heartbeatTransportSocketMatch: true})
+	if err != nil {
+		return err
+	}
+
+	cc.TransportSocketMatches = []*cluster.Cluster_TransportSocketMatch{{
+		Name:            heartbeatTransportSocketMatch,
+		Match:           matchCriteria,
+		TransportSocket: transportSocket,
+	}}
+
+	cc.HealthChecks = []*core.HealthCheck{{
+		Interval: durationpb.New(
+			time.Duration(withDefault(healthCheck.IntervalSeconds, defaultHealthCheckIntervalSeconds)) * time.Second),
+		Timeout: durationpb.New(
+			time.Duration(withDefault(healthCheck.TimeoutSeconds, defaultHealthCheckTimeoutSeconds)) * time.Second),
+		UnhealthyThreshold: wrapperspb.UInt32(
+			withDefault(healthCheck.UnhealthyThreshold, defaultHealthCheckUnhealthyThreshold)),
+		HealthyThreshold: wrapperspb.UInt32(
+			withDefault(healthCheck.HealthyThreshold, defaultHealthCheckHealthyThreshold)),
+		HealthChecker: &core.HealthCheck_HttpHealthCheck_{
+			HttpHealthCheck: &core.HealthCheck_HttpHealthCheck{
+				Path:            (&url.URL{Path: cpapi.HeartbeatPath}).EscapedPath(),
+				CodecClientType: envoytype.CodecClientType_HTTP1,
+			},
+		},
+		TransportSocketMatchCriteria: matchCriteria,
+	}}
+
+	cc.OutlierDetection = &cluster.OutlierDetection{
+		// connection failures are counted as 5xx errors
+		Consecutive_5Xx: wrapperspb.UInt32(
+			withDefault(healthCheck.ConsecutiveFailures, defaultOutlierConsecutiveFailures)),
+		BaseEjectionTime: durationpb.New(
+			time.Duration(withDefault(healthCheck.BaseEjectionSeconds, defaultOutlierBaseEjectionSeconds)) * time.Second),
+		MaxEjectionPercent: wrapperspb.UInt32(
+			withDefault(healthCheck.MaxEjectionPercent, defaultOutlierMaxEjectionPercent)),
+	}
+
+	return nil
+}
+
+// withDefault returns the given value, or the default value if it is zero.
+func withDefault(value, defaultValue uint32) uint32 {
+	if value == 0 {
+		return defaultValue
 	}
 
-	return m.clusters.UpdateResource(clusterName, epc)
+	return value
 }
 
 // DeletePeer removes the possibility for egress dataplane connections to be routed to a given peer.
 func (m *Manager) DeletePeer(name string) error {
 	m.logger.Infof("Deleting peer '%s'.", name)
 
+	m.peersLock.Lock()
+	defer m.peersLock.Unlock()
+	delete(m.srvPeers, name)
+
 	clusterName := cpapi.RemotePeerClusterName(name)
-	return m.clusters.DeleteResource(clusterName)
+	return m.deleteResource(m.clusters, clusterResource, clusterName)
 }
 
 // AddExport defines a new route target for ingress dataplane connections.
 func (m *Manager) AddExport(export *v1alpha1.Export) error {
 	m.logger.Infof("Adding export '%s/%s'.", export.Namespace, export.Name)
 
+	if limits := export.Spec.ConnectionLimits; limits != nil && limits.MaxConnections > 0 &&
+		m.dataplaneType == v1alpha1.DataplaneTypeEnvoy {
+		// the export is rejected by the control manager, which reports it in the export status
+		m.logger.Warnf("Export '%s/%s' limits the connections of each remote peer, which the %s dataplane cannot enforce.",
+			export.Namespace, export.Name, m.dataplaneType)
+		return m.DeleteExport(types.NamespacedName{Namespace: export.Namespace, Name: export.Name})
+	}
+
+	if service, ok := m.exportService(export); ok {
+		return m.addEDSExport(context.Background(), export, service)
+	}
+
 	host := export.Spec.Host
 	if host == "" {
 		host = fmt.Sprintf("%s.%s.svc.cluster.local", export.Name, export.Namespace)
 	}
 
-	clusterName := cpapi.ExportClusterName(export.Name, export.Namespace)
-	cc, err := makeAddressCluster(
-		clusterName,
-		host,
-		export.Spec.Port, "")
-	if err != nil {
+	// a cluster per exported port
+	clusters := make(map[string]bool)
+	for _, port := range export.Spec.ServicePorts() {
+		clusterName := cpapi.ExportPortClusterName(export.Name, export.Namespace, port.Name)
+		cc, err := makeAddressCluster(clusterName, host, port.Port, "")
+		if err != nil {
+			return err
+		}
+		setExportCircuitBreakers(cc, export)
+
+		if err := m.updateResource(m.clusters, clusterResource, clusterName, cc); err != nil {
+			return err
+		}
+		clusters[clusterName] = true
+	}
+
+	// delete clusters of ports removed from the export
+	if err := m.deleteStaleResources(
+		m.clusters, clusterResource, cpapi.ExportClusterName(export.Name, export.Namespace), clusters); err != nil {
 		return err
 	}
 
-	return m.clusters.UpdateResource(clusterName, cc)
+	// delete endpoints of an export which no longer uses endpoint discovery
+	return m.deleteEDSExport(types.NamespacedName{Namespace: export.Namespace, Name: export.Name})
+}
+
+// setExportCircuitBreakers sets the circuit breaker thresholds of an export cluster, from the export connection limits.
+// The Go dataplane applies the thresholds to the connections of each remote peer.
+func setExportCircuitBreakers(cc *cluster.Cluster, export *v1alpha1.Export) {
+	limits := export.Spec.ConnectionLimits
+	if limits == nil || limits.MaxConnections == 0 {
+		return
+	}
+
+	cc.CircuitBreakers = &cluster.CircuitBreakers{
+		Thresholds: []*cluster.CircuitBreakers_Thresholds{{
+			Priority:       core.RoutingPriority_DEFAULT,
+			MaxConnections: wrapperspb.UInt32(limits.MaxConnections),
+		}},
+	}
 }
 
 // DeleteExport removes the possibility for ingress dataplane connections to access a given service.
@@ -125,7 +420,11 @@ func (m *Manager) DeleteExport(name types.NamespacedName) error {
 	m.logger.Infof("Deleting export '%v'.", name)
 
 	clusterName := cpapi.ExportClusterName(name.Name, name.Namespace)
-	return m.clusters.DeleteResource(clusterName)
+	if err := m.deleteStaleResources(m.clusters, clusterResource, clusterName, nil); err != nil {
+		return err
+	}
+
+	return m.deleteEDSExport(name)
 }
 
 // AddImport adds a listening socket for an imported remote service.
@@ -138,62 +437,347 @@ func (m *Manager) AddImport(imp *v1alpha1.Import) error {
 		return nil
 	}
 
-	listenerName := cpapi.ImportListenerName(imp.Name, imp.Namespace)
+	// a listener per imported port
+	listeners := make(map[string]bool)
+	for _, port := range imp.Spec.ServicePorts() {
+		listenerName := cpapi.ImportPortListenerName(imp.Name, imp.Namespace, port.Name)
+
+		var ln *listener.Listener
+		var err error
+		switch imp.Spec.Protocol {
+		case v1alpha1.ProtocolUDP:
+			ln, err = m.makeUDPImportListener(listenerName, imp, &port)
+		case v1alpha1.ProtocolHTTP:
+			ln, err = m.makeHTTPImportListener(listenerName, imp, &port)
+		default:
+			ln, err = m.makeTCPImportListener(listenerName, imp, &port)
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := m.updateResource(m.listeners, listenerResource, listenerName, ln); err != nil {
+			return err
+		}
+		listeners[listenerName] = true
+	}
+
+	// delete listeners of ports removed from the import
+	return m.deleteStaleResources(
+		m.listeners, listenerResource, cpapi.ImportListenerName(imp.Name, imp.Namespace), listeners)
+}
+
+// makeTCPImportListener returns a TCP listener for a port of an imported service.
+// Each client connection is tunneled over HTTP, routed by the egress router.
+func (m *Manager) makeTCPImportListener(
+	name string,
+	imp *v1alpha1.Import,
+	port *v1alpha1.ImportPort,
+) (*listener.Listener, error) {
 	egressRouterHostname := "egress-router:443"
 
 	tunnelingConfig := &tcpproxy.TcpProxy_TunnelingConfig{
-		Hostname: egressRouterHostname,
-		UsePost:  true,
-		HeadersToAdd: []*core.HeaderValueOption{
-			{
-				Header: &core.HeaderValue{
-					Key:   cpapi.ImportNameHeader,
-					Value: imp.Name,
-				},
-				KeepEmptyValue: true,
+		Hostname:     egressRouterHostname,
+		UsePost:      true,
+		HeadersToAdd: makeImportHeaders(imp, port),
+	}
+
+	var transportSocket *core.TransportSocket
+	if m.workloadMTLS {
+		tunnelingConfig.HeadersToAdd = append(tunnelingConfig.HeadersToAdd, &core.HeaderValueOption{
+			Header: &core.HeaderValue{
+				Key:   cpapi.ClientSPIFFEIDHeader,
+				Value: "%DOWNSTREAM_PEER_URI_SAN%",
 			},
-			{
-				Header: &core.HeaderValue{
-					Key:   cpapi.ImportNamespaceHeader,
-					Value: imp.Namespace,
+			KeepEmptyValue: true,
+		})
+
+		var err error
+		transportSocket, err = makeWorkloadTransportSocket()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	accessLogs, err := m.makeImportAccessLogs(imp, false)
+	if err != nil {
+		return nil, err
+	}
+
+	tcpProxyFilter, err := makeTCPProxyFilter(
+		cpapi.EgressRouterCluster, imp.Name, tunnelingConfig, accessLogs)
+	if err != nil {
+		return nil, err
+	}
+
+	filters, err := makeConnectionLimitFilters(imp)
+	if err != nil {
+		return nil, err
+	}
+
+	// bound to the listen addresses of each dataplane by the listener cache
+	return &listener.Listener{
+		Name: name,
+		Address: &core.Address{
+			Address: &core.Address_SocketAddress{
+				SocketAddress: &core.SocketAddress{
+					Address: defaultListenAddress,
+					PortSpecifier: &core.SocketAddress_PortValue{
+						PortValue: uint32(port.TargetPort),
+					},
 				},
-				KeepEmptyValue: true,
 			},
-			{
-				Header: &core.HeaderValue{
-					Key:   cpapi.ClientIPHeader,
-					Value: "%DOWNSTREAM_REMOTE_ADDRESS_WITHOUT_PORT%",
-				},
-				KeepEmptyValue: true,
+		},
+		FilterChains: []*listener.FilterChain{{
+			Filters:         append(filters, tcpProxyFilter),
+			TransportSocket: transportSocket,
+		}},
+		Metadata: makeConnectionLimitsMetadata(imp),
+	}, nil
+}
+
+// makeHTTPImportListener returns an HTTP listener for a port of an imported service.
+// Each client request is routed by the egress router, using the import HTTP routes,
+// and is separately authorized and load-balanced. Failed requests are retried.
+func (m *Manager) makeHTTPImportListener(
+	name string,
+	imp *v1alpha1.Import,
+	port *v1alpha1.ImportPort,
+) (*listener.Listener, error) {
+	headers := makeImportHeaders(imp, port)
+	headers = append(headers, &core.HeaderValueOption{
+		Header: &core.HeaderValue{
+			Key:   cpapi.HTTPModeHeader,
+			Value: "true",
+		},
+		KeepEmptyValue: true,
+	})
+
+	var transportSocket *core.TransportSocket
+	if m.workloadMTLS {
+		headers = append(headers, &core.HeaderValueOption{
+			Header: &core.HeaderValue{
+				Key:   cpapi.ClientSPIFFEIDHeader,
+				Value: "%DOWNSTREAM_PEER_URI_SAN%",
+			},
+			KeepEmptyValue: true,
+		})
+
+		var err error
+		transportSocket, err = makeWorkloadTransportSocket()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// override any client-provided values
+	for _, header := range headers {
+		header.AppendAction = core.HeaderValueOption_OVERWRITE_IF_EXISTS_OR_ADD
+	}
+
+	routeAction := &route.Route_Route{
+		Route: &route.RouteAction{
+			ClusterSpecifier: &route.RouteAction_Cluster{
+				Cluster: cpapi.EgressRouterCluster,
+			},
+			RetryPolicy: &route.RetryPolicy{
+				RetryOn:    httpRetryOn,
+				NumRetries: wrapperspb.UInt32(httpNumRetries),
 			},
 		},
 	}
 
-	tcpProxyFilter, err := makeTCPProxyFilter(
-		cpapi.EgressRouterCluster, imp.Name, tunnelingConfig)
+	routes := make([]*route.Route, 0, len(imp.Spec.HTTPRoutes)+1)
+	for i := range imp.Spec.HTTPRoutes {
+		routes = append(routes, makeImportHTTPRoute(&imp.Spec.HTTPRoutes[i], routeAction))
+	}
+
+	// requests not matching any import route are load-balanced between all import sources
+	routes = append(routes, &route.Route{
+		Match: &route.RouteMatch{
+			PathSpecifier: &route.RouteMatch_Prefix{Prefix: "/"},
+		},
+		Action:                 routeAction,
+		RequestHeadersToRemove: []string{cpapi.HTTPRouteHeader},
+	})
+
+	routerConfig, err := anypb.New(&router.Router{})
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	accessLogs, err := m.makeImportAccessLogs(imp, true)
+	if err != nil {
+		return nil, err
+	}
+
+	pb, err := anypb.New(&hcm.HttpConnectionManager{
+		StatPrefix: "http-" + imp.Name,
+		AccessLog:  accessLogs,
+		// requests are authorized by their path, so it is normalized as done by cpapi.NormalizeHTTPPath,
+		// and the normalized path is forwarded to the exported service
+		NormalizePath:                wrapperspb.Bool(true),
+		MergeSlashes:                 true,
+		PathWithEscapedSlashesAction: hcm.HttpConnectionManager_UNESCAPE_AND_FORWARD,
+		RouteSpecifier: &hcm.HttpConnectionManager_RouteConfig{
+			RouteConfig: &route.RouteConfiguration{
+				Name:                name,
+				RequestHeadersToAdd: headers,
+				VirtualHosts: []*route.VirtualHost{{
+					Name:    imp.Name,
+					Domains: []string{"*"},
+					Routes:  routes,
+				}},
+			},
+		},
+		HttpFilters: []*hcm.HttpFilter{{
+			Name:       wellknown.Router,
+			ConfigType: &hcm.HttpFilter_TypedConfig{TypedConfig: routerConfig},
+		}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	filters, err := makeConnectionLimitFilters(imp)
+	if err != nil {
+		return nil, err
 	}
 
-	// TODO: listen on a more specific address (i.e. not 0.0.0.0)
-	ln := &listener.Listener{
-		Name: listenerName,
+	// bound to the listen addresses of each dataplane by the listener cache
+	return &listener.Listener{
+		Name: name,
 		Address: &core.Address{
 			Address: &core.Address_SocketAddress{
 				SocketAddress: &core.SocketAddress{
-					Address: "0.0.0.0",
+					Address: defaultListenAddress,
 					PortSpecifier: &core.SocketAddress_PortValue{
-						PortValue: uint32(imp.Spec.TargetPort),
+						PortValue: uint32(port.TargetPort),
 					},
 				},
 			},
 		},
 		FilterChains: []*listener.FilterChain{{
-			Filters: []*listener.Filter{tcpProxyFilter},
+			Filters: append(filters, &listener.Filter{
+				Name:       wellknown.HTTPConnectionManager,
+				ConfigType: &listener.Filter_TypedConfig{TypedConfig: pb},
+			}),
+			TransportSocket: transportSocket,
 		}},
+		Metadata: makeConnectionLimitsMetadata(imp),
+	}, nil
+}
+
+// makeConnectionLimitFilters returns the network filters limiting the total connections to a port of an imported service.
+// The connection rate of each client IP address is limited by the egress authz server.
+func makeConnectionLimitFilters(imp *v1alpha1.Import) ([]*listener.Filter, error) {
+	limits := imp.Spec.ConnectionLimits
+	if limits == nil {
+		return nil, nil
+	}
+
+	var filters []*listener.Filter
+	if rate := limits.Total.ConnectionsPerSecond; rate > 0 {
+		pb, err := anypb.New(&localratelimit.LocalRateLimit{
+			StatPrefix: "local-rate-limit-" + imp.Name,
+			TokenBucket: &envoytype.TokenBucket{
+				MaxTokens:     rate,
+				TokensPerFill: wrapperspb.UInt32(rate),
+				FillInterval:  durationpb.New(time.Second),
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		filters = append(filters, &listener.Filter{
+			Name:       cpapi.LocalRateLimitFilter,
+			ConfigType: &listener.Filter_TypedConfig{TypedConfig: pb},
+		})
+	}
+
+	if maxConnections := limits.Total.MaxConnections; maxConnections > 0 {
+		pb, err := anypb.New(&connectionlimit.ConnectionLimit{
+			StatPrefix:     "connection-limit-" + imp.Name,
+			MaxConnections: wrapperspb.UInt64(uint64(maxConnections)),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		filters = append(filters, &listener.Filter{
+			Name:       cpapi.ConnectionLimitFilter,
+			ConfigType: &listener.Filter_TypedConfig{TypedConfig: pb},
+		})
 	}
 
-	return m.listeners.UpdateResource(listenerName, ln)
+	return filters, nil
+}
+
+// makeConnectionLimitsMetadata returns listener metadata holding the maximal number of connections
+// from each client IP address to an imported service, if limited.
+// Envoy has no per-client connection limit, hence the metadata is only used by the Go dataplane.
+func makeConnectionLimitsMetadata(imp *v1alpha1.Import) *core.Metadata {
+	limits := imp.Spec.ConnectionLimits
+	if limits == nil || limits.PerSourceIP.MaxConnections == 0 {
+		return nil
+	}
+
+	return &core.Metadata{
+		FilterMetadata: map[string]*structpb.Struct{
+			cpapi.ConnectionLimitsMetadataKey: {
+				Fields: map[string]*structpb.Value{
+					cpapi.MaxConnectionsPerSourceIPMetadataField: structpb.NewNumberValue(
+						float64(limits.PerSourceIP.MaxConnections)),
+				},
+			},
+		},
+	}
+}
+
+// makeImportHTTPRoute returns a route matching the requests of an import HTTP route,
+// marking them with the name of the import HTTP route.
+func makeImportHTTPRoute(httpRoute *v1alpha1.ImportHTTPRoute, action *route.Route_Route) *route.Route {
+	prefix := httpRoute.PathPrefix
+	if prefix == "" {
+		prefix = "/"
+	}
+
+	headerNames := make([]string, 0, len(httpRoute.Headers))
+	for headerName := range httpRoute.Headers {
+		headerNames = append(headerNames, headerName)
+	}
+	sort.Strings(headerNames)
+
+	headerMatchers := make([]*route.HeaderMatcher, len(headerNames))
+	for i, headerName := range headerNames {
+		headerMatchers[i] = &route.HeaderMatcher{
+			Name: headerName,
+			HeaderMatchSpecifier: &route.HeaderMatcher_StringMatch{
+				StringMatch: &envoymatcher.StringMatcher{
+					MatchPattern: &envoymatcher.StringMatcher_Exact{
+						Exact: httpRoute.Headers[headerName],
+					},
+				},
+			},
+		}
+	}
+
+	return &route.Route{
+		Name: httpRoute.Name,
+		Match: &route.RouteMatch{
+			PathSpecifier: &route.RouteMatch_Prefix{Prefix: prefix},
+			Headers:       headerMatchers,
+		},
+		Action: action,
+		RequestHeadersToAdd: []*core.HeaderValueOption{{
+			Header: &core.HeaderValue{
+				Key:   cpapi.HTTPRouteHeader,
+				Value: httpRoute.Name,
+			},
+			AppendAction: core.HeaderValueOption_OVERWRITE_IF_EXISTS_OR_ADD,
+		}},
+	}
 }
 
 // DeleteImport removes the listening socket of a previously imported service.
@@ -201,7 +785,123 @@ func (m *Manager) DeleteImport(name types.NamespacedName) error {
 	m.logger.Infof("Deleting import '%v'.", name)
 
 	listenerName := cpapi.ImportListenerName(name.Name, name.Namespace)
-	return m.listeners.DeleteResource(listenerName)
+	return m.deleteStaleResources(m.listeners, listenerResource, listenerName, nil)
+}
+
+// makeImportHeaders returns the headers identifying an imported service port and its client,
+// added to tunnels for egress authorization, and the connection ID correlating access logs and traces.
+func makeImportHeaders(imp *v1alpha1.Import, port *v1alpha1.ImportPort) []*core.HeaderValueOption {
+	headers := []*core.HeaderValueOption{
+		{
+			Header: &core.HeaderValue{
+				Key:   cpapi.ImportNameHeader,
+				Value: imp.Name,
+			},
+			KeepEmptyValue: true,
+		},
+		{
+			Header: &core.HeaderValue{
+				Key:   cpapi.ImportNamespaceHeader,
+				Value: imp.Namespace,
+			},
+			KeepEmptyValue: true,
+		},
+		{
+			Header: &core.HeaderValue{
+				Key:   cpapi.ClientIPHeader,
+				Value: "%DOWNSTREAM_REMOTE_ADDRESS_WITHOUT_PORT%",
+			},
+			KeepEmptyValue: true,
+		},
+		{
+			Header: &core.HeaderValue{
+				Key:   cpapi.ConnectionIDHeader,
+				Value: "%STREAM_ID%",
+			},
+			KeepEmptyValue: true,
+		},
+	}
+
+	if port.Name != "" {
+		headers = append(headers, &core.HeaderValueOption{
+			Header: &core.HeaderValue{
+				Key:   cpapi.ImportPortHeader,
+				Value: port.Name,
+			},
+			KeepEmptyValue: true,
+		})
+	}
+
+	return headers
+}
+
+// makeUDPImportListener returns a UDP listener for a port of an imported service.
+// Each client session is tunneled over HTTP using CONNECT-UDP (RFC 9298), routed by the egress router.
+// Workload mTLS does not apply to UDP imports.
+func (m *Manager) makeUDPImportListener(
+	name string,
+	imp *v1alpha1.Import,
+	port *v1alpha1.ImportPort,
+) (*listener.Listener, error) {
+	routeAction, err := anypb.New(&udpproxy.Route{Cluster: cpapi.EgressRouterCluster})
+	if err != nil {
+		return nil, err
+	}
+
+	accessLogs, err := m.makeImportAccessLogs(imp, false)
+	if err != nil {
+		return nil, err
+	}
+
+	udpProxyConfig := &udpproxy.UdpProxyConfig{
+		StatPrefix: "udp-proxy-" + imp.Name,
+		AccessLog:  accessLogs,
+		RouteSpecifier: &udpproxy.UdpProxyConfig_Matcher{
+			Matcher: &matcher.Matcher{
+				OnNoMatch: &matcher.Matcher_OnMatch{
+					OnMatch: &matcher.Matcher_OnMatch_Action{
+						Action: &xdscore.TypedExtensionConfig{
+							Name:        "route",
+							TypedConfig: routeAction,
+						},
+					},
+				},
+			},
+		},
+		TunnelingConfig: &udpproxy.UdpProxyConfig_UdpTunnelingConfig{
+			ProxyHost:         "egress-router",
+			TargetHost:        imp.Name,
+			DefaultTargetPort: uint32(port.Port),
+			HeadersToAdd:      makeImportHeaders(imp, port),
+		},
+	}
+
+	pb, err := anypb.New(udpProxyConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	// bound to the listen addresses of each dataplane by the listener cache
+	return &listener.Listener{
+		Name: name,
+		Address: &core.Address{
+			Address: &core.Address_SocketAddress{
+				SocketAddress: &core.SocketAddress{
+					Protocol: core.SocketAddress_UDP,
+					Address:  defaultListenAddress,
+					PortSpecifier: &core.SocketAddress_PortValue{
+						PortValue: uint32(port.TargetPort),
+					},
+				},
+			},
+		},
+		ListenerFilters: []*listener.ListenerFilter{{
+			Name: "envoy.filters.udp_listener.udp_proxy",
+			ConfigType: &listener.ListenerFilter_TypedConfig{
+				TypedConfig: pb,
+			},
+		}},
+	}, nil
 }
 
 func makeAddressCluster(name, addr string, port uint16, hostname string) (*cluster.Cluster, error) {
@@ -258,8 +958,36 @@ func makeEndpointsCluster(name string, endpoints []v1alpha1.Endpoint, hostname s
 	return cc, nil
 }
 
+// makeWorkloadTransportSocket returns a transport socket requiring workloads to present a SPIFFE SVID.
+func makeWorkloadTransportSocket() (*core.TransportSocket, error) {
+	tlsConfig := &tls.DownstreamTlsContext{
+		RequireClientCertificate: wrapperspb.Bool(true),
+		CommonTlsContext: &tls.CommonTlsContext{
+			TlsCertificateSdsSecretConfigs: []*tls.SdsSecretConfig{{
+				Name: cpapi.WorkloadCertificateSecret,
+			}},
+			ValidationContextType: &tls.CommonTlsContext_ValidationContextSdsSecretConfig{
+				ValidationContextSdsSecretConfig: &tls.SdsSecretConfig{
+					Name: cpapi.WorkloadValidationSecret,
+				},
+			},
+		},
+	}
+
+	pb, err := anypb.New(tlsConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return &core.TransportSocket{
+		Name:       wellknown.TransportSocketTLS,
+		ConfigType: &core.TransportSocket_TypedConfig{TypedConfig: pb},
+	}, nil
+}
+
 func makeTCPProxyFilter(clusterName, statPrefix string,
 	tunnelingConfig *tcpproxy.TcpProxy_TunnelingConfig,
+	accessLogs []*accesslog.AccessLog,
 ) (*listener.Filter, error) {
 	tcpProxyConfig := &tcpproxy.TcpProxy{
 		StatPrefix: "tcp-proxy-" + statPrefix,
@@ -267,6 +995,7 @@ func makeTCPProxyFilter(clusterName, statPrefix string,
 			Cluster: clusterName,
 		},
 		TunnelingConfig: tunnelingConfig,
+		AccessLog:       accessLogs,
 	}
 
 	pb, err := anypb.New(tcpProxyConfig)
@@ -288,8 +1017,15 @@ func NewManager(crdMode bool) *Manager {
 
 	return &Manager{
 		crdMode:   crdMode,
+		accessLog: cpapi.AccessLogNone,
 		clusters:  cache.NewLinearCache(resource.ClusterType, cache.WithLogger(logger)),
-		listeners: cache.NewLinearCache(resource.ListenerType, cache.WithLogger(logger)),
+		endpoints: cache.NewLinearCache(resource.EndpointType, cache.WithLogger(logger)),
+		listeners: newListenerCache(logger),
+		secrets:   cache.NewLinearCache(resource.SecretType, cache.WithLogger(logger)),
+		exports:   make(map[types.NamespacedName]*v1alpha1.Export),
+		resolver:  net.DefaultResolver,
+		srvPeers:  make(map[string]*srvPeer),
+		callbacks: newCallbacks(),
 		logger:    logger,
 	}
 }
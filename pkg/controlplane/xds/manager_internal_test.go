@@ -0,0 +1,173 @@
+// Copyright 2023 The ClusterLink Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/clusterlink-net/clusterlink/pkg/apis/clusterlink.net/v1alpha1"
+	cpapi "github.com/clusterlink-net/clusterlink/pkg/controlplane/api"
+)
+
+func TestPeerHealthChecks(t *testing.T) {
+	manager := NewManager(true)
+	peer := &v1alpha1.Peer{
+		ObjectMeta: metav1.ObjectMeta{Name: "peer1"},
+		Spec: v1alpha1.PeerSpec{
+			Gateways: []v1alpha1.Endpoint{{Host: "10.0.0.1", Port: 443}, {Host: "10.0.0.2", Port: 443}},
+		},
+	}
+	clusterName := cpapi.RemotePeerClusterName(peer.Name)
+
+	// default thresholds
+	require.NoError(t, manager.AddPeer(peer))
+	cc := manager.clusters.GetResources()[clusterName].(*cluster.Cluster)
+	require.NoError(t, cc.ValidateAll())
+	require.Len(t, cc.HealthChecks, 1)
+	healthCheck := cc.HealthChecks[0]
+	require.Equal(t, "/healthz%20", healthCheck.GetHttpHealthCheck().GetPath())
+	require.Equal(t, 5*time.Second, healthCheck.Interval.AsDuration())
+	require.Equal(t, uint32(3), healthCheck.UnhealthyThreshold.GetValue())
+	require.Equal(t, uint32(5), cc.OutlierDetection.Consecutive_5Xx.GetValue())
+
+	// heartbeats use the SNI of the remote peer controlplane
+	require.Len(t, cc.TransportSocketMatches, 1)
+	require.Equal(t, healthCheck.TransportSocketMatchCriteria.AsMap(), cc.TransportSocketMatches[0].Match.AsMap())
+	require.NotEqual(t, cc.TransportSocket, cc.TransportSocketMatches[0].TransportSocket)
+
+	// custom thresholds
+	peer.Spec.HealthCheck = &v1alpha1.PeerHealthCheck{
+		IntervalSeconds:     10,
+		ConsecutiveFailures: 2,
+		MaxEjectionPercent:  100,
+	}
+	require.NoError(t, manager.AddPeer(peer))
+	cc = manager.clusters.GetResources()[clusterName].(*cluster.Cluster)
+	require.NoError(t, cc.ValidateAll())
+	require.Equal(t, 10*time.Second, cc.HealthChecks[0].Interval.AsDuration())
+	require.Equal(t, 2*time.Second, cc.HealthChecks[0].Timeout.AsDuration())
+	require.Equal(t, uint32(2), cc.OutlierDetection.Consecutive_5Xx.GetValue())
+	require.Equal(t, uint32(100), cc.OutlierDetection.MaxEjectionPercent.GetValue())
+
+	// disabled
+	peer.Spec.HealthCheck.Disabled = true
+	require.NoError(t, manager.AddPeer(peer))
+	cc = manager.clusters.GetResources()[clusterName].(*cluster.Cluster)
+	require.Empty(t, cc.HealthChecks)
+	require.Nil(t, cc.OutlierDetection)
+	require.Empty(t, cc.TransportSocketMatches)
+}
+
+// srvResolver resolves SRV names from a static map.
+type srvResolver map[string][]*net.SRV
+
+func (r srvResolver) LookupHost(_ context.Context, _ string) ([]string, error) {
+	return nil, errors.New("not supported")
+}
+
+func (r srvResolver) LookupSRV(_ context.Context, _, _, name string) (string, []*net.SRV, error) {
+	records, ok := r[name]
+	if !ok {
+		return "", nil, errors.New("no such host")
+	}
+	return name, records, nil
+}
+
+func TestPeerSRVGateways(t *testing.T) {
+	resolver := srvResolver{
+		"_clusterlink._tcp.example.com": {
+			{Target: "gw2.example.com.", Port: 443},
+			{Target: "gw1.example.com.", Port: 443},
+		},
+	}
+	manager := NewManager(true)
+	manager.resolver = resolver
+
+	peer := &v1alpha1.Peer{
+		ObjectMeta: metav1.ObjectMeta{Name: "peer1"},
+		Spec: v1alpha1.PeerSpec{
+			Gateways:   []v1alpha1.Endpoint{{Host: "10.0.0.1", Port: 443}},
+			GatewaySRV: "_clusterlink._tcp.example.com",
+		},
+	}
+	clusterName := cpapi.RemotePeerClusterName(peer.Name)
+
+	hosts := func() []string {
+		cc := manager.clusters.GetResources()[clusterName].(*cluster.Cluster)
+		var addresses []string
+		for _, ep := range cc.LoadAssignment.Endpoints[0].LbEndpoints {
+			addresses = append(addresses, ep.GetEndpoint().Address.GetSocketAddress().Address)
+		}
+		return addresses
+	}
+
+	require.NoError(t, manager.AddPeer(peer))
+	require.Equal(t, []string{"10.0.0.1", "gw1.example.com", "gw2.example.com"}, hosts())
+
+	// unchanged records do not push a new cluster
+	cc := manager.clusters.GetResources()[clusterName]
+	resolver["_clusterlink._tcp.example.com"][0], resolver["_clusterlink._tcp.example.com"][1] =
+		resolver["_clusterlink._tcp.example.com"][1], resolver["_clusterlink._tcp.example.com"][0]
+	manager.resolvePeers()
+	require.Same(t, cc, manager.clusters.GetResources()[clusterName])
+
+	// changed records are pushed
+	resolver["_clusterlink._tcp.example.com"] = []*net.SRV{{Target: "gw3.example.com.", Port: 8443}}
+	manager.resolvePeers()
+	require.Equal(t, []string{"10.0.0.1", "gw3.example.com"}, hosts())
+
+	// resolution failures keep the previous gateways
+	delete(resolver, "_clusterlink._tcp.example.com")
+	manager.resolvePeers()
+	require.NoError(t, manager.AddPeer(peer))
+	require.Equal(t, []string{"10.0.0.1", "gw3.example.com"}, hosts())
+
+	// deleted peers are no longer re-resolved
+	require.NoError(t, manager.DeletePeer(peer.Name))
+	require.Empty(t, manager.srvPeers)
+}
+
+func TestExportConnectionLimits(t *testing.T) {
+	manager := NewManager(false)
+	manager.SetDataplaneType(v1alpha1.DataplaneTypeGo)
+	export := &v1alpha1.Export{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "echo"},
+		Spec:       v1alpha1.ExportSpec{Port: 80},
+	}
+	clusterName := cpapi.ExportPortClusterName(export.Name, export.Namespace, "")
+
+	// the go dataplane limits the connections of each remote peer, using the circuit breaker threshold
+	export.Spec.ConnectionLimits = &v1alpha1.ConnectionLimits{MaxConnections: 10}
+	require.NoError(t, manager.AddExport(export))
+	cc := manager.clusters.GetResources()[clusterName].(*cluster.Cluster)
+	require.NoError(t, cc.ValidateAll())
+	require.Equal(t, uint32(10), cc.CircuitBreakers.Thresholds[0].MaxConnections.GetValue())
+
+	// the envoy dataplane cannot, so the export is not served
+	manager.SetDataplaneType(v1alpha1.DataplaneTypeEnvoy)
+	require.NoError(t, manager.AddExport(export))
+	require.NotContains(t, manager.clusters.GetResources(), clusterName)
+
+	export.Spec.ConnectionLimits = nil
+	require.NoError(t, manager.AddExport(export))
+	require.Contains(t, manager.clusters.GetResources(), clusterName)
+}
@@ -0,0 +1,114 @@
+// Copyright 2023 The ClusterLink Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/clusterlink-net/clusterlink/pkg/apis/clusterlink.net/v1alpha1"
+	cppeer "github.com/clusterlink-net/clusterlink/pkg/controlplane/peer"
+	"github.com/clusterlink-net/clusterlink/pkg/util/runnable"
+)
+
+// srvResolveTimeout is the timeout for resolving the SRV name of a peer.
+const srvResolveTimeout = 5 * time.Second
+
+// srvPeer is a peer whose gateways are resolved from an SRV name.
+type srvPeer struct {
+	peer *v1alpha1.Peer
+	// srvGateways are the gateways last resolved from the SRV name of the peer
+	srvGateways []v1alpha1.Endpoint
+}
+
+// resolveSRVGateways returns the gateways pointed to by an SRV name, sorted so that
+// re-resolutions yielding the same gateways (possibly shuffled by weight) can be detected.
+func (m *Manager) resolveSRVGateways(name string) ([]v1alpha1.Endpoint, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), srvResolveTimeout)
+	defer cancel()
+
+	gateways, err := cppeer.ResolveSRVGateways(ctx, m.resolver, name)
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(gateways, func(a, b v1alpha1.Endpoint) int {
+		if c := strings.Compare(a.Host, b.Host); c != 0 {
+			return c
+		}
+		return int(a.Port) - int(b.Port)
+	})
+
+	return gateways, nil
+}
+
+// resolvePeers re-resolves the SRV names of peers, updating the clusters of peers whose gateways changed.
+func (m *Manager) resolvePeers() {
+	m.peersLock.Lock()
+	peers := make([]*srvPeer, 0, len(m.srvPeers))
+	for _, p := range m.srvPeers {
+		peers = append(peers, p)
+	}
+	m.peersLock.Unlock()
+
+	for _, p := range peers {
+		srvGateways, err := m.resolveSRVGateways(p.peer.Spec.GatewaySRV)
+		if err != nil {
+			m.logger.Warnf("Cannot resolve gateways of peer '%s': %v.", p.peer.Name, err)
+			continue
+		}
+
+		if slices.Equal(srvGateways, p.srvGateways) {
+			continue
+		}
+
+		m.updateSRVPeer(p, srvGateways)
+	}
+}
+
+// updateSRVPeer updates the cluster of a peer with newly resolved SRV gateways,
+// unless the peer was updated or deleted in the meantime.
+func (m *Manager) updateSRVPeer(p *srvPeer, srvGateways []v1alpha1.Endpoint) {
+	m.peersLock.Lock()
+	defer m.peersLock.Unlock()
+
+	if m.srvPeers[p.peer.Name] != p {
+		return
+	}
+
+	m.logger.Infof("Gateways of peer '%s' changed: %v.", p.peer.Name, srvGateways)
+
+	gateways := append(append([]v1alpha1.Endpoint{}, p.peer.Spec.Gateways...), srvGateways...)
+	if err := m.addPeerCluster(p.peer, gateways); err != nil {
+		m.logger.Errorf("Cannot update peer '%s': %v.", p.peer.Name, err)
+		return
+	}
+
+	m.srvPeers[p.peer.Name] = &srvPeer{peer: p.peer, srvGateways: srvGateways}
+}
+
+// PeerResolver periodically re-resolves the SRV names of peer gateways,
+// and pushes the updated peer clusters to the dataplanes.
+type PeerResolver struct {
+	*runnable.Periodic
+}
+
+// NewPeerResolver returns a new re-resolver of the peer gateways of the given manager.
+func NewPeerResolver(manager *Manager) *PeerResolver {
+	return &PeerResolver{
+		Periodic: runnable.NewPeriodic("xdsPeerResolver", cppeer.ResolveInterval, manager.resolvePeers),
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright 2023 The ClusterLink Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"bytes"
+	cryptotls "crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	tls "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	"github.com/sirupsen/logrus"
+
+	cpapi "github.com/clusterlink-net/clusterlink/pkg/controlplane/api"
+	"github.com/clusterlink-net/clusterlink/pkg/util/runnable"
+)
+
+const (
+	// CertificateFileName, KeyFileName and CAFileName are the names of the files in the
+	// dataplane certificate directory, matching the keys of the dataplane and fabric secrets.
+	CertificateFileName = "cert"
+	KeyFileName         = "key"
+	CAFileName          = "ca"
+
+	// certificateCheckInterval is the interval for checking whether the dataplane certificate files have changed.
+	certificateCheckInterval = 10 * time.Second
+)
+
+// makeSDSSecretConfig returns a reference to a secret served by the controlplane over ADS.
+func makeSDSSecretConfig(name string) *tls.SdsSecretConfig {
+	return &tls.SdsSecretConfig{
+		Name: name,
+		SdsConfig: &core.ConfigSource{
+			ResourceApiVersion:    core.ApiVersion_V3,
+			ConfigSourceSpecifier: &core.ConfigSource_Ads{Ads: &core.AggregatedConfigSource{}},
+		},
+	}
+}
+
+// SetDataplaneCertificate sets the certificate, private key and CA (all PEM-encoded)
+// used by the dataplanes for peer connections, pushing them to the dataplanes.
+func (m *Manager) SetDataplaneCertificate(certificate, key, ca []byte) error {
+	if _, err := cryptotls.X509KeyPair(certificate, key); err != nil {
+		return fmt.Errorf("unable to parse certificate keypair: %w", err)
+	}
+
+	if !x509.NewCertPool().AppendCertsFromPEM(ca) {
+		return fmt.Errorf("unable to parse CA")
+	}
+
+	certificateSecret := &tls.Secret{
+		Name: cpapi.CertificateSecret,
+		Type: &tls.Secret_TlsCertificate{
+			TlsCertificate: &tls.TlsCertificate{
+				CertificateChain: &core.DataSource{Specifier: &core.DataSource_InlineBytes{InlineBytes: certificate}},
+				PrivateKey:       &core.DataSource{Specifier: &core.DataSource_InlineBytes{InlineBytes: key}},
+			},
+		},
+	}
+
+	validationSecret := &tls.Secret{
+		Name: cpapi.ValidationSecret,
+		Type: &tls.Secret_ValidationContext{
+			ValidationContext: &tls.CertificateValidationContext{
+				TrustedCa: &core.DataSource{Specifier: &core.DataSource_InlineBytes{InlineBytes: ca}},
+			},
+		},
+	}
+
+	// push the CA first, so that a dataplane never uses a new certificate without its new CA
+	if err := m.updateResource(m.secrets, secretResource, validationSecret.Name, validationSecret); err != nil {
+		return err
+	}
+
+	return m.updateResource(m.secrets, secretResource, certificateSecret.Name, certificateSecret)
+}
+
+// CertificateWatcher watches the dataplane certificate files, and pushes their content
+// to the dataplanes over SDS whenever it changes.
+type CertificateWatcher struct {
+	*runnable.Periodic
+
+	manager   *Manager
+	directory string
+
+	certificate []byte
+	key         []byte
+	ca          []byte
+
+	logger *logrus.Entry
+}
+
+// load reads the certificate files, and sets them in the manager if they have changed.
+func (w *CertificateWatcher) load() error {
+	certificate, err := os.ReadFile(filepath.Join(w.directory, CertificateFileName))
+	if err != nil {
+		return fmt.Errorf("unable to read certificate file: %w", err)
+	}
+
+	key, err := os.ReadFile(filepath.Join(w.directory, KeyFileName))
+	if err != nil {
+		return fmt.Errorf("unable to read private key file: %w", err)
+	}
+
+	ca, err := os.ReadFile(filepath.Join(w.directory, CAFileName))
+	if err != nil {
+		return fmt.Errorf("unable to read CA file: %w", err)
+	}
+
+	if bytes.Equal(certificate, w.certificate) && bytes.Equal(key, w.key) && bytes.Equal(ca, w.ca) {
+		return nil
+	}
+
+	if err := w.manager.SetDataplaneCertificate(certificate, key, ca); err != nil {
+		return err
+	}
+
+	if w.certificate != nil {
+		w.logger.Info("Dataplane certificate updated.")
+	}
+
+	w.certificate = certificate
+	w.key = key
+	w.ca = ca
+	return nil
+}
+
+// reload loads the certificate files, if changed.
+func (w *CertificateWatcher) reload() {
+	// files may be temporarily inconsistent while being replaced, so errors are retried
+	if err := w.load(); err != nil {
+		w.logger.Warnf("Cannot load dataplane certificate: %v.", err)
+	}
+}
+
+// NewCertificateWatcher returns a new watcher of the dataplane certificate files in the given directory,
+// serving them over SDS by the given manager. The files are loaded before returning.
+func NewCertificateWatcher(manager *Manager, directory string) (*CertificateWatcher, error) {
+	w := &CertificateWatcher{
+		manager:   manager,
+		directory: directory,
+		logger:    logrus.WithField("component", "controlplane.xds.certificates"),
+	}
+	w.Periodic = runnable.NewPeriodic("xdsCertificateWatcher", certificateCheckInterval, w.reload)
+
+	if err := w.load(); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
@@ -0,0 +1,82 @@
+// Copyright 2023 The ClusterLink Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	tls "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	"github.com/stretchr/testify/require"
+
+	"github.com/clusterlink-net/clusterlink/pkg/bootstrap"
+	cpapi "github.com/clusterlink-net/clusterlink/pkg/controlplane/api"
+)
+
+// writeCertificate writes a new dataplane certificate to the given directory.
+func writeCertificate(t *testing.T, directory string) *bootstrap.Certificate {
+	t.Helper()
+
+	fabricCert, err := bootstrap.CreateFabricCertificate("fabric")
+	require.NoError(t, err)
+	peerCert, err := bootstrap.CreatePeerCertificate("peer", fabricCert)
+	require.NoError(t, err)
+	dataplaneCert, err := bootstrap.CreateDataplaneCertificate("peer", peerCert)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(filepath.Join(directory, CertificateFileName), dataplaneCert.RawCert(), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(directory, KeyFileName), dataplaneCert.RawKey(), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(directory, CAFileName), fabricCert.RawCert(), 0o600))
+	return dataplaneCert
+}
+
+func TestCertificateWatcher(t *testing.T) {
+	directory := t.TempDir()
+	manager := NewManager(true)
+
+	// missing files
+	_, err := NewCertificateWatcher(manager, directory)
+	require.Error(t, err)
+
+	dataplaneCert := writeCertificate(t, directory)
+	watcher, err := NewCertificateWatcher(manager, directory)
+	require.NoError(t, err)
+
+	secrets := manager.secrets.GetResources()
+	require.Len(t, secrets, 2)
+	certificate, ok := secrets[cpapi.CertificateSecret].(*tls.Secret)
+	require.True(t, ok)
+	require.Equal(t, dataplaneCert.RawCert(), certificate.GetTlsCertificate().GetCertificateChain().GetInlineBytes())
+	require.Equal(t, dataplaneCert.RawKey(), certificate.GetTlsCertificate().GetPrivateKey().GetInlineBytes())
+	validation, ok := secrets[cpapi.ValidationSecret].(*tls.Secret)
+	require.True(t, ok)
+	require.NotEmpty(t, validation.GetValidationContext().GetTrustedCa().GetInlineBytes())
+
+	// unchanged files are not pushed again
+	require.NoError(t, watcher.load())
+	require.Same(t, certificate, manager.secrets.GetResources()[cpapi.CertificateSecret])
+
+	// rotated certificate
+	dataplaneCert = writeCertificate(t, directory)
+	require.NoError(t, watcher.load())
+	certificate = manager.secrets.GetResources()[cpapi.CertificateSecret].(*tls.Secret)
+	require.Equal(t, dataplaneCert.RawCert(), certificate.GetTlsCertificate().GetCertificateChain().GetInlineBytes())
+
+	// invalid key is not pushed
+	require.NoError(t, os.WriteFile(filepath.Join(directory, KeyFileName), []byte("invalid"), 0o600))
+	require.Error(t, watcher.load())
+	certificate = manager.secrets.GetResources()[cpapi.CertificateSecret].(*tls.Secret)
+	require.Equal(t, dataplaneCert.RawKey(), certificate.GetTlsCertificate().GetPrivateKey().GetInlineBytes())
+}
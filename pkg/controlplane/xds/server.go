@@ -25,7 +25,7 @@ import (
 
 // RegisterService registers an xDS service backed by Manager to the given gRPC server.
 func RegisterService(ctx context.Context, manager *Manager, grpcServer *grpc.Server) {
-	// create a combined mux cache of listeners, clusters and secrets
+	// create a combined mux cache of listeners, clusters, endpoints and secrets
 	muxCache := &cache.MuxCache{
 		Classify: func(req *cache.Request) string {
 			return req.TypeUrl
@@ -35,10 +35,12 @@ func RegisterService(ctx context.Context, manager *Manager, grpcServer *grpc.Ser
 		},
 		Caches: map[string]cache.Cache{
 			resource.ClusterType:  manager.clusters,
+			resource.EndpointType: manager.endpoints,
 			resource.ListenerType: manager.listeners,
+			resource.SecretType:   manager.secrets,
 		},
 	}
 
-	srv := server.NewServer(ctx, muxCache, nil)
+	srv := server.NewServer(ctx, muxCache, manager.callbacks)
 	discovery.RegisterAggregatedDiscoveryServiceServer(grpcServer, srv)
 }
@@ -0,0 +1,102 @@
+// Copyright 2023 The ClusterLink Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	cpapi "github.com/clusterlink-net/clusterlink/pkg/controlplane/api"
+	"github.com/clusterlink-net/clusterlink/pkg/util/runnable"
+)
+
+// statusPublishInterval is the interval for checking whether the xDS status of dataplanes has changed.
+const statusPublishInterval = 5 * time.Second
+
+// StatusPublisher publishes the xDS status of the connected dataplanes to a ConfigMap,
+// which the operator reflects in the dataplane sync condition of the ClusterLink instance.
+type StatusPublisher struct {
+	*runnable.Periodic
+
+	manager   *Manager
+	client    client.Client
+	namespace string
+
+	// published and generation track the last xDS status published
+	published  bool
+	generation uint64
+
+	logger *logrus.Entry
+}
+
+// publish writes the current xDS status of the connected dataplanes to the status ConfigMap.
+func (p *StatusPublisher) publish(ctx context.Context) error {
+	nodes, err := json.Marshal(p.manager.callbacks.nodes())
+	if err != nil {
+		return err
+	}
+
+	configMap := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      cpapi.XDSStatusConfigMapName,
+			Namespace: p.namespace,
+		},
+		Data: map[string]string{
+			cpapi.XDSStatusConfigMapKey: string(nodes),
+		},
+	}
+
+	err = p.client.Update(ctx, configMap)
+	if k8serrors.IsNotFound(err) {
+		err = p.client.Create(ctx, configMap)
+	}
+
+	return err
+}
+
+// publishChanges publishes the xDS status, if it changed since last published.
+func (p *StatusPublisher) publishChanges() {
+	current := p.manager.callbacks.getGeneration()
+	if p.published && current == p.generation {
+		return
+	}
+
+	if err := p.publish(context.Background()); err != nil {
+		p.logger.Warnf("Cannot publish xDS status: %v.", err)
+		return
+	}
+
+	p.published = true
+	p.generation = current
+}
+
+// NewStatusPublisher returns a new publisher of the xDS status of the dataplanes connected to the given manager,
+// to a ConfigMap in the given namespace.
+func NewStatusPublisher(manager *Manager, cl client.Client, namespace string) *StatusPublisher {
+	p := &StatusPublisher{
+		manager:   manager,
+		client:    cl,
+		namespace: namespace,
+		logger:    logrus.WithField("component", "controlplane.xds.status"),
+	}
+	p.Periodic = runnable.NewPeriodic("xdsStatusPublisher", statusPublishInterval, p.publishChanges)
+	return p
+}
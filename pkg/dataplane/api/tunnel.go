@@ -0,0 +1,41 @@
+// Copyright 2023 The ClusterLink Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"fmt"
+)
+
+const (
+	// UDPTunnelPathPrefix is the path prefix of tunnels carrying UDP datagrams,
+	// following the URI template of RFC 9298 (Proxying UDP in HTTP).
+	// Datagrams are framed as HTTP datagram capsules (RFC 9297).
+	UDPTunnelPathPrefix = "/.well-known/masque/udp/"
+	// UDPTunnelProtocol is the upgrade token of tunnels carrying UDP datagrams.
+	UDPTunnelProtocol = "connect-udp"
+
+	// TunnelFramingHeader negotiates the framing of the response body of tunnels carried by HTTP/2 streams.
+	// The client requests TunnelFramingLengthPrefixed, and the server echoes it if supported.
+	TunnelFramingHeader = "x-clusterlink-tunnel-framing"
+	// TunnelFramingLengthPrefixed frames the tunneled data as chunks prefixed by their 4-byte (big-endian) length.
+	// An empty chunk closes the write side of the server, allowing it to half-close the tunnel,
+	// which cannot be done by ending the response while still reading the request body.
+	TunnelFramingLengthPrefixed = "length-prefixed"
+)
+
+// UDPTunnelPath returns the path of a tunnel carrying UDP datagrams to the given target.
+// The target is informational only, as the exported service is determined by the access token.
+func UDPTunnelPath(host string, port uint16) string {
+	return fmt.Sprintf("%s%s/%d/", UDPTunnelPathPrefix, host, port)
+}
@@ -16,129 +16,190 @@ package client
 import (
 	"context"
 	"fmt"
-	"strings"
-	"sync"
+	"maps"
 
 	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
 	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
 	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
-	client "github.com/envoyproxy/go-control-plane/pkg/client/sotw/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
 	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
 	"github.com/sirupsen/logrus"
+	"google.golang.org/genproto/googleapis/rpc/status"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/anypb"
 
-	"github.com/clusterlink-net/clusterlink/pkg/controlplane/api"
 	"github.com/clusterlink-net/clusterlink/pkg/dataplane/server"
 )
 
+// deltaStream is an incremental (delta) ADS stream.
+type deltaStream = discovery.AggregatedDiscoveryService_DeltaAggregatedResourcesClient
+
+// fetcher fetches resources of a single type from the controlplane using the incremental (delta) xDS protocol,
+// and applies the added, updated and removed resources to the dataplane.
 type fetcher struct {
-	client       client.ADSClient
 	resourceType string
+	node         *core.Node
 	dataplane    *server.Dataplane
-	logger       *logrus.Entry
-	clusterLock  sync.Mutex
-	listenerLock sync.Mutex
+	// versions maps the names of the resources applied to the dataplane to their versions.
+	// It is kept across connections, so that fetching resumes from the last known versions after reconnecting.
+	versions map[string]string
+	logger   *logrus.Entry
 }
 
-func (f *fetcher) handleClusters(resources []*anypb.Any) error {
-	clusters := make(map[string]bool)
-
-	f.clusterLock.Lock()
-	defer f.clusterLock.Unlock()
+// unmarshalResources unmarshals the given delta xDS resources into messages created by newMessage.
+func unmarshalResources[T proto.Message](resources []*discovery.Resource, newMessage func() T) ([]T, error) {
+	messages := make([]T, 0, len(resources))
 	for _, r := range resources {
-		c := &cluster.Cluster{}
-		err := anypb.UnmarshalTo(r, c, proto.UnmarshalOptions{})
+		m := newMessage()
+		err := anypb.UnmarshalTo(r.GetResource(), m, proto.UnmarshalOptions{})
 		if err != nil {
-			return err
+			return nil, fmt.Errorf("unable to unmarshal resource '%s': %w", r.Name, err)
 		}
 
+		messages = append(messages, m)
+	}
+
+	return messages, nil
+}
+
+// handleClusters updates the dataplane with the fetched clusters, and removes the given clusters.
+func (f *fetcher) handleClusters(resources []*discovery.Resource, removed []string) error {
+	clusters, err := unmarshalResources(resources, func() *cluster.Cluster { return &cluster.Cluster{} })
+	if err != nil {
+		return err
+	}
+
+	for _, c := range clusters {
 		f.logger.Debugf("Cluster: %s.", c.Name)
-		f.dataplane.AddCluster(c)
-		clusters[c.Name] = true
-	}
-	// Delete existing clusters if its not present in the reources fetched
-	for cn := range f.dataplane.GetClusters() {
-		if _, ok := clusters[cn]; ok {
-			// Cluster exists in the resources fetched
-			continue
-		}
-		f.logger.Debugf("Remove Cluster: %s.", cn)
-		f.dataplane.RemoveCluster(cn)
 	}
+
+	f.dataplane.UpdateClustersDelta(clusters, removed)
 	return nil
 }
 
-func (f *fetcher) handleListeners(resources []*anypb.Any) error {
-	listeners := make(map[string]bool)
+// handleEndpoints updates the dataplane with the fetched endpoints, and removes the given endpoints.
+func (f *fetcher) handleEndpoints(resources []*discovery.Resource, removed []string) error {
+	assignments, err := unmarshalResources(resources, func() *endpoint.ClusterLoadAssignment {
+		return &endpoint.ClusterLoadAssignment{}
+	})
+	if err != nil {
+		return err
+	}
 
-	f.listenerLock.Lock()
-	defer f.listenerLock.Unlock()
-	// Add any new listeners created
-	for _, r := range resources {
-		l := &listener.Listener{}
-		err := anypb.UnmarshalTo(r, l, proto.UnmarshalOptions{})
-		if err != nil {
-			return err
-		}
+	for _, cla := range assignments {
+		f.logger.Debugf("Endpoints: %s.", cla.ClusterName)
+	}
+
+	f.dataplane.UpdateEndpointsDelta(assignments, removed)
+	return nil
+}
+
+// handleListeners updates the dataplane with the fetched listeners, and removes the given listeners.
+func (f *fetcher) handleListeners(resources []*discovery.Resource, removed []string) error {
+	listeners, err := unmarshalResources(resources, func() *listener.Listener { return &listener.Listener{} })
+	if err != nil {
+		return err
+	}
+
+	for _, l := range listeners {
 		f.logger.Debugf("Listener: %s.", l.Name)
-		f.dataplane.AddListener(l)
-		listeners[strings.TrimPrefix(l.Name, api.ImportListenerPrefix)] = true
-	}
-	// Delete existing listeners if its not present in the reources fetched
-	for ln := range f.dataplane.GetListeners() {
-		if _, ok := listeners[ln]; ok {
-			// Listener exists in the resources fetched
-			continue
-		}
-		f.logger.Debugf("Remove Listener: %s.", ln)
-		f.dataplane.RemoveListener(ln)
 	}
+
+	f.dataplane.UpdateListenersDelta(listeners, removed)
+	return nil
+}
+
+// handleResponse applies a delta xDS response to the dataplane.
+// If any of the resources cannot be handled, none are applied.
+func (f *fetcher) handleResponse(resp *discovery.DeltaDiscoveryResponse) error {
+	if resp.TypeUrl != f.resourceType {
+		return fmt.Errorf("unexpected resource type '%s'", resp.TypeUrl)
+	}
+
+	var err error
+	switch f.resourceType {
+	case resource.ClusterType:
+		err = f.handleClusters(resp.Resources, resp.RemovedResources)
+	case resource.EndpointType:
+		err = f.handleEndpoints(resp.Resources, resp.RemovedResources)
+	case resource.ListenerType:
+		err = f.handleListeners(resp.Resources, resp.RemovedResources)
+	default:
+		err = fmt.Errorf("unknown resource type")
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, r := range resp.Resources {
+		f.versions[r.Name] = r.Version
+	}
+	for _, name := range resp.RemovedResources {
+		delete(f.versions, name)
+	}
+
 	return nil
 }
 
-func (f *fetcher) Run() error {
+// connect opens a delta xDS stream over the given connection, subscribing to all resources of the fetcher type.
+// The versions of the resources already applied to the dataplane are sent, so that only changed resources are fetched.
+func (f *fetcher) connect(ctx context.Context, conn *grpc.ClientConn) (deltaStream, error) {
+	stream, err := discovery.NewAggregatedDiscoveryServiceClient(conn).DeltaAggregatedResources(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = stream.Send(&discovery.DeltaDiscoveryRequest{
+		Node:                    f.node,
+		TypeUrl:                 f.resourceType,
+		InitialResourceVersions: maps.Clone(f.versions),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return stream, nil
+}
+
+// Run fetches resources over the given stream, acknowledging (or rejecting) every response, until the stream fails.
+func (f *fetcher) Run(stream deltaStream) error {
 	for {
-		resp, err := f.client.Fetch()
+		resp, err := stream.Recv()
 		if err != nil {
 			f.logger.Errorf("Failed to fetch %s: %v.", f.resourceType, err)
 			return err
 		}
-		f.logger.Debugf("Fetched %s -> %+v", f.resourceType, resp.Resources)
+		f.logger.Debugf("Fetched %s -> %d resources, %d removed (nonce %s).",
+			f.resourceType, len(resp.Resources), len(resp.RemovedResources), resp.Nonce)
 
-		switch f.resourceType {
-		case resource.ClusterType:
-			err := f.handleClusters(resp.Resources)
-			if err != nil {
-				f.logger.Errorf("Failed to handle clusters: %v.", err)
-			}
-		case resource.ListenerType:
-			err := f.handleListeners(resp.Resources)
-			if err != nil {
-				f.logger.Errorf("Failed to handle listeners: %v.", err)
+		req := &discovery.DeltaDiscoveryRequest{
+			TypeUrl:       f.resourceType,
+			ResponseNonce: resp.Nonce,
+		}
+		if err := f.handleResponse(resp); err != nil {
+			f.logger.Errorf("Failed to handle %s: %v.", f.resourceType, err)
+			req.ErrorDetail = &status.Status{
+				Code:    int32(codes.InvalidArgument),
+				Message: err.Error(),
 			}
-		default:
-			return fmt.Errorf("unknown resource type")
 		}
 
-		err = f.client.Ack()
-		if err != nil {
+		if err := stream.Send(req); err != nil {
 			f.logger.Errorf("Failed to ack: %v.", err)
+			return err
 		}
 	}
 }
 
-func newFetcher(ctx context.Context, conn *grpc.ClientConn, resourceType string, dp *server.Dataplane) (*fetcher, error) {
-	cl := client.NewADSClient(ctx, &core.Node{Id: dp.ID}, resourceType)
-	err := cl.InitConnect(conn)
-	if err != nil {
-		return nil, err
-	}
+func newFetcher(resourceType string, node *core.Node, dp *server.Dataplane) *fetcher {
 	return &fetcher{
-		client:       cl,
 		resourceType: resourceType,
+		node:         node,
 		dataplane:    dp,
+		versions:     make(map[string]string),
 		logger:       logrus.WithField("component", "fetcher.xds.client"),
-	}, nil
+	}
 }
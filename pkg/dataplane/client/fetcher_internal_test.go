@@ -0,0 +1,145 @@
+// Copyright 2023 The ClusterLink Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/clusterlink-net/clusterlink/pkg/apis/clusterlink.net/v1alpha1"
+	cpapi "github.com/clusterlink-net/clusterlink/pkg/controlplane/api"
+	"github.com/clusterlink-net/clusterlink/pkg/controlplane/xds"
+	"github.com/clusterlink-net/clusterlink/pkg/dataplane/server"
+	utiltls "github.com/clusterlink-net/clusterlink/pkg/util/tls"
+)
+
+func testPeer(name string) *v1alpha1.Peer {
+	return &v1alpha1.Peer{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: v1alpha1.PeerSpec{
+			Gateways: []v1alpha1.Endpoint{{Host: "10.0.0.1", Port: 443}},
+		},
+	}
+}
+
+func TestFetcherDelta(t *testing.T) {
+	manager := xds.NewManager(true)
+	require.NoError(t, manager.AddPeer(testPeer("peer1")))
+
+	lis := bufconn.Listen(1 << 20)
+	grpcServer := grpc.NewServer()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	xds.RegisterService(ctx, manager, grpcServer)
+	go func() {
+		_ = grpcServer.Serve(lis)
+	}()
+	t.Cleanup(grpcServer.Stop)
+
+	dp := server.NewDataplane("dataplane", "", "peer", &utiltls.ParsedCertData{})
+	f := newFetcher(resource.ClusterType, &core.Node{Id: dp.ID}, dp)
+
+	// runs the fetcher over a new connection, returning a function closing the connection
+	run := func() func() {
+		conn, err := grpc.Dial("bufnet",
+			grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+				return lis.Dial()
+			}),
+			grpc.WithTransportCredentials(insecure.NewCredentials()))
+		require.NoError(t, err)
+
+		stream, err := f.connect(ctx, conn)
+		require.NoError(t, err)
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			_ = f.Run(stream)
+		}()
+
+		return func() {
+			require.NoError(t, conn.Close())
+			<-done
+		}
+	}
+	hasCluster := func(peer string) func() bool {
+		return func() bool {
+			_, err := dp.GetClusterTarget(cpapi.RemotePeerClusterName(peer))
+			return err == nil
+		}
+	}
+
+	closeConn := run()
+	require.Eventually(t, hasCluster("peer1"), time.Second, 10*time.Millisecond)
+
+	// incremental updates
+	require.NoError(t, manager.AddPeer(testPeer("peer2")))
+	require.Eventually(t, hasCluster("peer2"), time.Second, 10*time.Millisecond)
+	require.True(t, hasCluster("peer1")())
+
+	// resume after reconnecting, receiving only the changes made while disconnected
+	closeConn()
+	require.NoError(t, manager.DeletePeer("peer1"))
+	require.NoError(t, manager.AddPeer(testPeer("peer3")))
+	closeConn = run()
+	require.Eventually(t, hasCluster("peer3"), time.Second, 10*time.Millisecond)
+	require.Eventually(t, func() bool { return !hasCluster("peer1")() }, time.Second, 10*time.Millisecond)
+	require.True(t, hasCluster("peer2")())
+
+	closeConn()
+	require.Len(t, f.versions, 2)
+}
+
+func TestFetcherNACK(t *testing.T) {
+	dp := server.NewDataplane("dataplane", "", "peer", &utiltls.ParsedCertData{})
+	f := newFetcher(resource.ClusterType, &core.Node{Id: dp.ID}, dp)
+
+	valid, err := anypb.New(&cluster.Cluster{Name: "cluster1"})
+	require.NoError(t, err)
+	invalid, err := anypb.New(wrapperspb.String("cluster2"))
+	require.NoError(t, err)
+
+	// no resources are applied if any of them cannot be unmarshalled
+	require.Error(t, f.handleResponse(&discovery.DeltaDiscoveryResponse{
+		TypeUrl: resource.ClusterType,
+		Resources: []*discovery.Resource{
+			{Name: "cluster1", Version: "1", Resource: valid},
+			{Name: "cluster2", Version: "1", Resource: invalid},
+		},
+	}))
+	require.Empty(t, f.versions)
+
+	require.NoError(t, f.handleResponse(&discovery.DeltaDiscoveryResponse{
+		TypeUrl:   resource.ClusterType,
+		Resources: []*discovery.Resource{{Name: "cluster1", Version: "1", Resource: valid}},
+	}))
+	require.Equal(t, map[string]string{"cluster1": "1"}, f.versions)
+
+	// unexpected resource types are rejected
+	require.Error(t, f.handleResponse(&discovery.DeltaDiscoveryResponse{TypeUrl: resource.ListenerType}))
+}
@@ -21,31 +21,42 @@ import (
 	"sync"
 	"time"
 
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
 	"github.com/sirupsen/logrus"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/backoff"
 	"google.golang.org/grpc/credentials"
+	"google.golang.org/protobuf/types/known/structpb"
 
+	cpapi "github.com/clusterlink-net/clusterlink/pkg/controlplane/api"
 	"github.com/clusterlink-net/clusterlink/pkg/dataplane/server"
 )
 
 // resources indicate the xDS resources that would be fetched.
-var resources = [...]string{resource.ClusterType, resource.ListenerType}
+var resources = [...]string{resource.ClusterType, resource.EndpointType, resource.ListenerType}
 
-// XDSClient implements the client which fetches clusters and listeners.
+// XDSClient implements the client which fetches clusters, endpoints and listeners.
 type XDSClient struct {
 	dataplane          *server.Dataplane
 	controlplaneTarget string
 	tlsConfig          *tls.Config
+	node               *core.Node
 	lock               sync.Mutex
 	errors             map[string]error
 	logger             *logrus.Entry
-	clustersReady      chan bool
+	clustersReady      chan struct{}
+	clustersReadyOnce  sync.Once
+	ctx                context.Context
+	cancel             context.CancelFunc
+	fetchers           sync.WaitGroup
 }
 
+// runFetcher runs a fetcher of the given resource type, until the client is stopped.
+// The fetcher is re-connected on failure, resuming from the resources it already fetched.
 func (x *XDSClient) runFetcher(resourceType string) error {
-	for {
+	fetcher := newFetcher(resourceType, x.node, x.dataplane)
+	for x.ctx.Err() == nil {
 		conn, err := grpc.Dial(
 			x.controlplaneTarget,
 			grpc.WithTransportCredentials(credentials.NewTLS(x.tlsConfig)),
@@ -55,48 +66,110 @@ func (x *XDSClient) runFetcher(resourceType string) error {
 			}))
 		if err != nil {
 			x.logger.Errorf("Failed to dial controlplane xDS server: %v.", err)
-			time.Sleep(1 * time.Second)
+			select {
+			case <-x.ctx.Done():
+			case <-time.After(1 * time.Second):
+			}
 			continue
 		}
 
-		fetcher, err := newFetcher(context.Background(), conn, resourceType, x.dataplane)
-		if err != nil {
-			x.logger.Errorf("Failed to initialize %s fetcher: %v.", resourceType, err)
-			continue
+		x.runFetcherConn(conn, fetcher)
+		if err := conn.Close(); err != nil {
+			x.logger.Warnf("Failed to close connection of %s fetcher: %v.", resourceType, err)
 		}
-		x.logger.Infof("Successfully initialized client for %s type.", resourceType)
-
-		// If the resource type is listener, it shouldn't run until the cluster fetcher is running
-		switch resourceType {
-		case resource.ClusterType:
-			x.clustersReady <- true
-		case resource.ListenerType:
-			<-x.clustersReady
-			x.logger.Infof("Done waiting for cluster fetcher")
+	}
+
+	return nil
+}
+
+// runFetcherConn runs a fetcher over a connection to the controlplane,
+// until the fetcher fails or the client is stopped.
+func (x *XDSClient) runFetcherConn(conn *grpc.ClientConn, fetcher *fetcher) {
+	resourceType := fetcher.resourceType
+	stream, err := fetcher.connect(x.ctx, conn)
+	if err != nil {
+		x.logger.Errorf("Failed to initialize %s fetcher: %v.", resourceType, err)
+		return
+	}
+	x.logger.Infof("Successfully initialized client for %s type.", resourceType)
+
+	// If the resource type is listener, it shouldn't run until the cluster fetcher is running.
+	// The gate is opened once, so cluster fetcher reconnects do not block.
+	switch resourceType {
+	case resource.ClusterType:
+		x.clustersReadyOnce.Do(func() { close(x.clustersReady) })
+	case resource.ListenerType:
+		select {
+		case <-x.clustersReady:
+		case <-x.ctx.Done():
+			return
 		}
-		x.logger.Infof("Starting to run %s fetcher.", resourceType)
-		err = fetcher.Run()
-		x.logger.Infof("Fetcher '%s' stopped: %v.", resourceType, err)
+		x.logger.Infof("Done waiting for cluster fetcher")
+	}
+	x.logger.Infof("Starting to run %s fetcher.", resourceType)
+	err = fetcher.Run(stream)
+	x.logger.Infof("Fetcher '%s' stopped: %v.", resourceType, err)
+}
+
+// SetListenAddresses sets the IP addresses which import listeners are bound to.
+// Must be called before the client is started.
+func (x *XDSClient) SetListenAddresses(addresses []string) {
+	if len(addresses) == 0 {
+		x.node.Metadata = nil
+		return
+	}
+
+	values := make([]*structpb.Value, 0, len(addresses))
+	for _, address := range addresses {
+		values = append(values, structpb.NewStringValue(address))
+	}
+
+	x.node.Metadata = &structpb.Struct{
+		Fields: map[string]*structpb.Value{
+			cpapi.ListenAddressesMetadataKey: structpb.NewListValue(&structpb.ListValue{Values: values}),
+		},
 	}
 }
 
+// Name returns the name of the xDS client.
+func (x *XDSClient) Name() string {
+	return "xds-client"
+}
+
+// Start runs the xDS client, until stopped.
+func (x *XDSClient) Start() error {
+	return x.Run()
+}
+
+// Stop the xDS client.
+func (x *XDSClient) Stop() error {
+	x.cancel()
+	return nil
+}
+
+// GracefulStop stops the xDS client, and waits for its fetchers to stop,
+// so that clusters and listeners are no longer updated.
+func (x *XDSClient) GracefulStop() error {
+	x.cancel()
+	x.fetchers.Wait()
+	return nil
+}
+
 // Run starts the running xDS client which fetches clusters and listeners from the controlplane.
 func (x *XDSClient) Run() error {
-	var wg sync.WaitGroup
-
-	wg.Add(len(resources))
+	x.fetchers.Add(len(resources))
 	for _, res := range resources {
 		go func(res string) {
-			defer wg.Done()
+			defer x.fetchers.Done()
 			err := x.runFetcher(res)
-			x.logger.Errorf("Fetcher (%s) stopped: %v", res, err)
+			x.logger.Infof("Fetcher (%s) stopped: %v", res, err)
 
 			x.lock.Lock()
 			x.errors[res] = err
 			x.lock.Unlock()
 		}(res)
 	}
-	wg.Wait()
+	x.fetchers.Wait()
 
 	var errs []error
 	for resource, err := range x.errors {
@@ -111,12 +184,16 @@ func (x *XDSClient) Run() error {
 
 // NewXDSClient returns am xDS client which can fetch clusters and listeners from the controlplane.
 func NewXDSClient(dataplane *server.Dataplane, controlplaneTarget string, tlsConfig *tls.Config) *XDSClient {
+	ctx, cancel := context.WithCancel(context.Background())
 	return &XDSClient{
+		ctx:                ctx,
+		cancel:             cancel,
 		dataplane:          dataplane,
 		controlplaneTarget: controlplaneTarget,
 		tlsConfig:          tlsConfig,
+		node:               &core.Node{Id: dataplane.ID},
 		errors:             make(map[string]error),
 		logger:             logrus.WithField("component", "xds.client"),
-		clustersReady:      make(chan bool, 1),
+		clustersReady:      make(chan struct{}),
 	}
 }
@@ -0,0 +1,96 @@
+// Copyright 2023 The ClusterLink Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+
+	cpapi "github.com/clusterlink-net/clusterlink/pkg/controlplane/api"
+	"github.com/clusterlink-net/clusterlink/pkg/controlplane/xds"
+	"github.com/clusterlink-net/clusterlink/pkg/dataplane/server"
+	utiltls "github.com/clusterlink-net/clusterlink/pkg/util/tls"
+)
+
+func TestClusterFetcherReconnect(t *testing.T) {
+	manager := xds.NewManager(true)
+	require.NoError(t, manager.AddPeer(testPeer("peer1")))
+
+	lis := bufconn.Listen(1 << 20)
+	grpcServer := grpc.NewServer()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	xds.RegisterService(ctx, manager, grpcServer)
+	go func() {
+		_ = grpcServer.Serve(lis)
+	}()
+	t.Cleanup(grpcServer.Stop)
+
+	dp := server.NewDataplane("dataplane", "", "peer", &utiltls.ParsedCertData{})
+	x := NewXDSClient(dp, "bufnet", nil)
+	t.Cleanup(x.cancel)
+	f := newFetcher(resource.ClusterType, &core.Node{Id: dp.ID}, dp)
+
+	// runs the cluster fetcher over a new connection, returning a function closing the connection
+	run := func() func() {
+		conn, err := grpc.Dial("bufnet",
+			grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+				return lis.Dial()
+			}),
+			grpc.WithTransportCredentials(insecure.NewCredentials()))
+		require.NoError(t, err)
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			x.runFetcherConn(conn, f)
+		}()
+
+		return func() {
+			require.NoError(t, conn.Close())
+			<-done
+		}
+	}
+	hasCluster := func(peer string) func() bool {
+		return func() bool {
+			_, err := dp.GetClusterTarget(cpapi.RemotePeerClusterName(peer))
+			return err == nil
+		}
+	}
+
+	closeConn := run()
+	require.Eventually(t, hasCluster("peer1"), time.Second, 10*time.Millisecond)
+	closeConn()
+
+	// reconnecting must not block on the listeners gate, which is already open
+	require.NoError(t, manager.AddPeer(testPeer("peer2")))
+	closeConn = run()
+	require.Eventually(t, hasCluster("peer2"), time.Second, 10*time.Millisecond)
+	closeConn()
+
+	select {
+	case <-x.clustersReady:
+	default:
+		require.Fail(t, "listeners gate is closed")
+	}
+}
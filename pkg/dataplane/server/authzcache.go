@@ -0,0 +1,235 @@
+// Copyright 2023 The ClusterLink Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// authorizationCacheSize is the maximal number of cached egress authorization results.
+const authorizationCacheSize = 10000
+
+// authorizationKey identifies the client and request of an egress authorization result.
+type authorizationKey struct {
+	// listener is the name of the import listener
+	listener string
+	sourceIP string
+	spiffeID string
+	// httpRequest holds the attributes of an HTTP request, and is empty for connections
+	httpRequest httpRequestInfo
+}
+
+// newAuthorizationKey returns the key of an egress authorization of a connection (or HTTP request).
+func newAuthorizationKey(name, sourceIP, spiffeID string, httpRequest *httpRequestInfo) authorizationKey {
+	key := authorizationKey{listener: name, sourceIP: sourceIP, spiffeID: spiffeID}
+	if httpRequest != nil {
+		key.httpRequest = *httpRequest
+	}
+
+	return key
+}
+
+// authorization is an egress authorization result.
+type authorization struct {
+	targetCluster string
+	accessToken   string
+}
+
+// cachedAuthorization is a cached egress authorization result.
+type cachedAuthorization struct {
+	authorization
+	// freshUntil is the time until which the result is reused
+	freshUntil time.Time
+	// staleUntil is the time until which the result is reused, while the client is re-authorized in the background
+	staleUntil time.Time
+	// staleIfErrorUntil is the time until which the result is reused if re-authorizing the client fails
+	// since the controlplane is unavailable
+	staleIfErrorUntil time.Time
+	// revalidating is true while the client is re-authorized in the background
+	revalidating bool
+}
+
+// expired returns true if the result can no longer be reused.
+func (a *cachedAuthorization) expired(now time.Time) bool {
+	return !now.Before(a.staleUntil) && !now.Before(a.staleIfErrorUntil)
+}
+
+// cacheState is the state of a cache lookup.
+type cacheState int
+
+const (
+	// cacheMiss indicates that the client must be authorized.
+	cacheMiss cacheState = iota
+	// cacheHit indicates that the cached result can be used.
+	cacheHit
+	// cacheRevalidate indicates that the cached result can be used, and that the client must be re-authorized.
+	cacheRevalidate
+)
+
+// authorizationCache caches allowed egress authorization results, following the Cache-Control directives
+// (max-age, stale-while-revalidate and stale-if-error) returned by the controlplane.
+// Once the cache is full, expired results are evicted, or otherwise a random result.
+type authorizationCache struct {
+	lock       sync.Mutex
+	entries    map[authorizationKey]*cachedAuthorization
+	maxEntries int
+}
+
+// get looks up a cached result. If the result is stale, a single caller is asked to re-authorize the client.
+func (c *authorizationCache) get(key authorizationKey, now time.Time) (authorization, cacheState) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	entry, ok := c.entries[key]
+	switch {
+	case !ok:
+		return authorization{}, cacheMiss
+	case now.Before(entry.freshUntil):
+		return entry.authorization, cacheHit
+	case now.Before(entry.staleUntil):
+		if entry.revalidating {
+			return entry.authorization, cacheHit
+		}
+
+		entry.revalidating = true
+		return entry.authorization, cacheRevalidate
+	}
+
+	if entry.expired(now) {
+		delete(c.entries, key)
+	}
+
+	return authorization{}, cacheMiss
+}
+
+// getIfError looks up a cached result which can be reused since the controlplane is unavailable.
+func (c *authorizationCache) getIfError(key authorizationKey, now time.Time) (authorization, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok || !now.Before(entry.staleIfErrorUntil) {
+		return authorization{}, false
+	}
+
+	return entry.authorization, true
+}
+
+// set caches a result following the given Cache-Control directives, relative to the time the result was requested.
+// A result without a max-age directive is not cached, and replaces a previously cached result.
+func (c *authorizationCache) set(key authorizationKey, auth authorization, cacheControl string, requested time.Time) {
+	directives := parseCacheControl(cacheControl)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	maxAge, ok := directives["max-age"]
+	if !ok || maxAge == 0 {
+		delete(c.entries, key)
+		return
+	}
+
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.maxEntries {
+		c.evict(requested)
+	}
+
+	freshUntil := requested.Add(maxAge)
+	c.entries[key] = &cachedAuthorization{
+		authorization:     auth,
+		freshUntil:        freshUntil,
+		staleUntil:        freshUntil.Add(directives["stale-while-revalidate"]),
+		staleIfErrorUntil: freshUntil.Add(directives["stale-if-error"]),
+	}
+}
+
+// fail handles a failed (re-)authorization of a client. The cached result is kept only if the controlplane
+// is unavailable, and the result may be reused in this case.
+func (c *authorizationCache) fail(key authorizationKey, now time.Time, unavailable bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return
+	}
+
+	if unavailable && now.Before(entry.staleIfErrorUntil) {
+		entry.revalidating = false
+		return
+	}
+
+	delete(c.entries, key)
+}
+
+// deleteListener deletes the cached results of an import listener.
+func (c *authorizationCache) deleteListener(name string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for key := range c.entries {
+		if key.listener == name {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// evict evicts expired results, or a random result if none expired. Requires the lock.
+func (c *authorizationCache) evict(now time.Time) {
+	for key, entry := range c.entries {
+		if entry.expired(now) {
+			delete(c.entries, key)
+		}
+	}
+
+	if len(c.entries) < c.maxEntries {
+		return
+	}
+
+	for key := range c.entries {
+		delete(c.entries, key)
+		return
+	}
+}
+
+// parseCacheControl parses the (integer) directives of a Cache-Control header to durations.
+// Directives without an integer value are ignored.
+func parseCacheControl(header string) map[string]time.Duration {
+	directives := make(map[string]time.Duration)
+	for _, directive := range strings.Split(header, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(directive), "=")
+		if !ok {
+			continue
+		}
+
+		seconds, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			continue
+		}
+
+		directives[strings.ToLower(name)] = time.Duration(seconds) * time.Second
+	}
+
+	return directives
+}
+
+// newAuthorizationCache returns an empty cache, holding up to the given number of results.
+func newAuthorizationCache(maxEntries int) *authorizationCache {
+	return &authorizationCache{
+		entries:    make(map[authorizationKey]*cachedAuthorization),
+		maxEntries: maxEntries,
+	}
+}
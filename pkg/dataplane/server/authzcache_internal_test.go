@@ -0,0 +1,178 @@
+// Copyright 2023 The ClusterLink Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/clusterlink-net/clusterlink/pkg/controlplane/api"
+	utiltls "github.com/clusterlink-net/clusterlink/pkg/util/tls"
+)
+
+func TestAuthorizationCache(t *testing.T) {
+	cache := newAuthorizationCache(2)
+	key := newAuthorizationKey("default/echo", "10.0.0.1", "", nil)
+	auth := authorization{targetCluster: "remote-peer-peer1", accessToken: "token"}
+	now := time.Now()
+
+	// results without max-age are not cached
+	cache.set(key, auth, "", now)
+	_, state := cache.get(key, now)
+	require.Equal(t, cacheMiss, state)
+
+	cache.set(key, auth, "max-age=10, stale-while-revalidate=5", now)
+	cached, state := cache.get(key, now.Add(9*time.Second))
+	require.Equal(t, cacheHit, state)
+	require.Equal(t, auth, cached)
+
+	// a single caller re-authorizes a stale result
+	_, state = cache.get(key, now.Add(11*time.Second))
+	require.Equal(t, cacheRevalidate, state)
+	_, state = cache.get(key, now.Add(11*time.Second))
+	require.Equal(t, cacheHit, state)
+
+	// failed re-authorization drops the result, unless stale-if-error allows it
+	cache.fail(key, now.Add(11*time.Second), true)
+	_, state = cache.get(key, now.Add(11*time.Second))
+	require.Equal(t, cacheMiss, state)
+
+	cache.set(key, auth, "max-age=10, stale-while-revalidate=5, stale-if-error=5", now)
+	_, state = cache.get(key, now.Add(11*time.Second))
+	require.Equal(t, cacheRevalidate, state)
+	cache.fail(key, now.Add(11*time.Second), true)
+	_, state = cache.get(key, now.Add(12*time.Second))
+	require.Equal(t, cacheRevalidate, state)
+
+	// denied re-authorization always drops the result
+	cache.fail(key, now.Add(12*time.Second), false)
+	_, state = cache.get(key, now.Add(12*time.Second))
+	require.Equal(t, cacheMiss, state)
+
+	// past stale-while-revalidate, the result is reused only if the controlplane is unavailable
+	cache.set(key, auth, "max-age=10, stale-while-revalidate=5, stale-if-error=30", now)
+	_, state = cache.get(key, now.Add(20*time.Second))
+	require.Equal(t, cacheMiss, state)
+	cache.fail(key, now.Add(20*time.Second), true)
+	cached, ok := cache.getIfError(key, now.Add(20*time.Second))
+	require.True(t, ok)
+	require.Equal(t, auth, cached)
+	_, ok = cache.getIfError(key, now.Add(40*time.Second))
+	require.False(t, ok)
+	cache.fail(key, now.Add(20*time.Second), false)
+	_, ok = cache.getIfError(key, now.Add(20*time.Second))
+	require.False(t, ok)
+
+	// expired results are evicted first
+	key2 := newAuthorizationKey("default/echo", "10.0.0.2", "", nil)
+	key3 := newAuthorizationKey("default/other", "10.0.0.1", "", nil)
+	cache.set(key, auth, "max-age=1", now)
+	cache.set(key2, auth, "max-age=10", now)
+	cache.set(key3, auth, "max-age=10", now.Add(2*time.Second))
+	require.Len(t, cache.entries, 2)
+	require.NotContains(t, cache.entries, key)
+
+	// results of removed listeners are deleted
+	cache.deleteListener("default/other")
+	require.Len(t, cache.entries, 1)
+	require.Contains(t, cache.entries, key2)
+}
+
+func TestEgressAuthorizationCache(t *testing.T) {
+	var requests atomic.Int32
+	var status atomic.Int32
+	status.Store(http.StatusOK)
+	controlplane := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests.Add(1)
+		if code := int(status.Load()); code != http.StatusOK {
+			w.WriteHeader(code)
+			return
+		}
+
+		w.Header().Set(api.TargetClusterHeader, testExportCluster)
+		w.Header().Set(api.AuthorizationHeader, "Bearer token")
+		w.Header().Set(api.AuthorizationCacheHeader, "max-age=60, stale-while-revalidate=60, stale-if-error=120")
+	}))
+	t.Cleanup(controlplane.Close)
+
+	d := NewDataplane("dataplane", controlplane.Listener.Addr().String(), "peer", &utiltls.ParsedCertData{})
+	d.apiClient = controlplane.Client()
+
+	// cached results are reused
+	for i := 0; i < 3; i++ {
+		target, token, err := d.getEgressAuth("default/echo", "10.0.0.1", "", nil)
+		require.NoError(t, err)
+		require.Equal(t, testExportCluster, target)
+		require.Equal(t, "Bearer token", token)
+	}
+	require.Equal(t, int32(1), requests.Load())
+
+	// new clients are authorized
+	_, _, err := d.getEgressAuth("default/echo", "10.0.0.2", "", nil)
+	require.NoError(t, err)
+	require.Equal(t, int32(2), requests.Load())
+
+	// known stale clients are allowed while the controlplane is unavailable
+	status.Store(http.StatusServiceUnavailable)
+	key := newAuthorizationKey("default/echo", "10.0.0.1", "", nil)
+	// expire moves the cached result past its TTL, and its stale-while-revalidate window to the given time
+	expire := func(staleUntil time.Time) {
+		d.authzCache.lock.Lock()
+		defer d.authzCache.lock.Unlock()
+		d.authzCache.entries[key].freshUntil = time.Now()
+		d.authzCache.entries[key].staleUntil = staleUntil
+	}
+	// revalidated waits for the background re-authorization to complete
+	revalidated := func(n int32) {
+		require.Eventually(t, func() bool {
+			d.authzCache.lock.Lock()
+			defer d.authzCache.lock.Unlock()
+			entry, ok := d.authzCache.entries[key]
+			return requests.Load() == n && (!ok || !entry.revalidating)
+		}, time.Second, time.Millisecond)
+	}
+
+	expire(time.Now().Add(time.Minute))
+	_, _, err = d.getEgressAuth("default/echo", "10.0.0.1", "", nil)
+	require.NoError(t, err)
+	revalidated(3)
+	_, _, err = d.getEgressAuth("default/echo", "10.0.0.1", "", nil)
+	require.NoError(t, err)
+	revalidated(4)
+	_, _, err = d.getEgressAuth("default/echo", "10.0.0.3", "", nil)
+	require.Error(t, err)
+
+	// past stale-while-revalidate, known clients are re-authorized before reusing their result,
+	// which is still allowed while the controlplane is unavailable
+	expire(time.Now())
+	target, token, err := d.getEgressAuth("default/echo", "10.0.0.1", "", nil)
+	require.NoError(t, err)
+	require.Equal(t, testExportCluster, target)
+	require.Equal(t, "Bearer token", token)
+	require.Equal(t, int32(6), requests.Load())
+
+	// denied clients are dropped
+	status.Store(http.StatusUnauthorized)
+	expire(time.Now().Add(time.Minute))
+	_, _, err = d.getEgressAuth("default/echo", "10.0.0.1", "", nil)
+	require.NoError(t, err)
+	revalidated(7)
+	_, _, err = d.getEgressAuth("default/echo", "10.0.0.1", "", nil)
+	require.Error(t, err)
+}
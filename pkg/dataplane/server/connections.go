@@ -0,0 +1,160 @@
+// Copyright 2023 The ClusterLink Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	event "github.com/clusterlink-net/clusterlink/pkg/controlplane/eventmanager"
+	"github.com/clusterlink-net/clusterlink/pkg/metrics"
+)
+
+const (
+	// connectionReportInterval is the interval between metric reports of an active connection.
+	connectionReportInterval = 10 * time.Second
+)
+
+// connection tracks the metrics of a forwarded connection.
+type connection struct {
+	status event.ConnectionStatusAttr
+
+	// reportedIncomingBytes and reportedOutgoingBytes are the byte counts already reported to the controlplane.
+	reportedIncomingBytes int64
+	reportedOutgoingBytes int64
+	// observedIncomingBytes and observedOutgoingBytes are the byte counts already added to the dataplane metrics.
+	observedIncomingBytes int64
+	observedOutgoingBytes int64
+}
+
+// metricsDirection returns the connection direction, as used in the dataplane metrics.
+func (c *connection) metricsDirection() string {
+	if c.status.Direction == event.Incoming {
+		return "ingress"
+	}
+
+	return "egress"
+}
+
+// newConnection returns a new connection, starting now.
+func newConnection(direction event.Direction, srcService, dstService, peer string) *connection {
+	now := time.Now()
+	return &connection{
+		status: event.ConnectionStatusAttr{
+			ConnectionID:    uuid.New().String(),
+			SrcService:      srcService,
+			DstService:      dstService,
+			DestinationPeer: peer,
+			StartTstamp:     now,
+			LastTstamp:      now,
+			Direction:       direction,
+			State:           event.Ongoing,
+		},
+	}
+}
+
+// runForwarder runs a forwarder of a connection.
+// The connection metrics are reported to the controlplane periodically, and once the connection is closed.
+func (d *Dataplane) runForwarder(f *forwarder, conn *connection) {
+	d.metrics.ConnectionStarted(conn.metricsDirection(), conn.status.DstService)
+	defer d.metrics.ConnectionEnded(conn.metricsDirection(), conn.status.DstService)
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		ticker := time.NewTicker(connectionReportInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				d.reportConnection(f, conn, event.Ongoing)
+			}
+		}
+	}()
+
+	f.run()
+	close(done)
+	wg.Wait()
+
+	d.reportConnection(f, conn, event.Complete)
+}
+
+// reportConnection adds the bytes forwarded on a connection to the dataplane metrics,
+// and reports the bytes forwarded since the previous successful report to the controlplane.
+func (d *Dataplane) reportConnection(f *forwarder, conn *connection, state event.ConnectionState) {
+	incomingBytes := f.incomingBytes.Load()
+	outgoingBytes := f.outgoingBytes.Load()
+
+	d.metrics.AddBytes(
+		conn.metricsDirection(), conn.status.DstService, conn.status.DestinationPeer,
+		incomingBytes-conn.observedIncomingBytes, outgoingBytes-conn.observedOutgoingBytes)
+	conn.observedIncomingBytes = incomingBytes
+	conn.observedOutgoingBytes = outgoingBytes
+
+	status := conn.status
+	status.IncomingBytes = int(incomingBytes - conn.reportedIncomingBytes)
+	status.OutgoingBytes = int(outgoingBytes - conn.reportedOutgoingBytes)
+	status.LastTstamp = time.Now()
+	status.State = state
+
+	if err := d.postConnectionStatus(&status); err != nil {
+		d.logger.Warnf("Cannot report metrics of connection %s: %v.", status.ConnectionID, err)
+		return
+	}
+
+	conn.reportedIncomingBytes = incomingBytes
+	conn.reportedOutgoingBytes = outgoingBytes
+	d.logger.Debugf("Connection %s: %d bytes in, %d bytes out, duration %v.",
+		status.ConnectionID, incomingBytes, outgoingBytes, status.LastTstamp.Sub(status.StartTstamp))
+}
+
+// postConnectionStatus posts a connection status to the controlplane metrics endpoint.
+func (d *Dataplane) postConnectionStatus(status *event.ConnectionStatusAttr) error {
+	body, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+
+	url := httpSchemaPrefix + d.controlplaneTarget + metrics.ConnectionStatusPath
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := d.apiClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected response status: %s", resp.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,391 @@
+// Copyright 2023 The ClusterLink Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// maxDatagramSize is the maximal size of a UDP datagram payload.
+	maxDatagramSize = 64 * 1024
+	// udpSessionIdleTimeout is the time after which a UDP session without datagrams from its client is ended.
+	udpSessionIdleTimeout = 60 * time.Second
+	// udpSessionQueueSize is the number of datagrams queued for a UDP session, before datagrams are dropped.
+	udpSessionQueueSize = 64
+
+	// datagramCapsuleType is the type of HTTP datagram capsules (RFC 9297).
+	datagramCapsuleType = 0x00
+	// udpContextID is the context ID of HTTP datagrams carrying UDP payloads (RFC 9298).
+	udpContextID = 0x00
+)
+
+// errMalformedCapsule is returned when reading a malformed capsule from a tunnel.
+var errMalformedCapsule = errors.New("malformed capsule")
+
+// appendVarint appends a variable-length integer, encoded as defined by RFC 9000 (Section 16).
+func appendVarint(b []byte, v uint64) []byte {
+	switch {
+	case v < 1<<6:
+		return append(b, byte(v))
+	case v < 1<<14:
+		return append(b, byte(v>>8)|0x40, byte(v))
+	case v < 1<<30:
+		return append(b, byte(v>>24)|0x80, byte(v>>16), byte(v>>8), byte(v))
+	default:
+		return append(b, byte(v>>56)|0xc0, byte(v>>48), byte(v>>40), byte(v>>32),
+			byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+	}
+}
+
+// readVarint reads a variable-length integer, encoded as defined by RFC 9000 (Section 16).
+// Returns the integer value and its encoded length.
+func readVarint(r io.ByteReader) (uint64, int, error) {
+	first, err := r.ReadByte()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	length := 1 << (first >> 6)
+	v := uint64(first & 0x3f)
+	for i := 1; i < length; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, 0, unexpectedEOF(err)
+		}
+
+		v = v<<8 | uint64(b)
+	}
+
+	return v, length, nil
+}
+
+// unexpectedEOF returns io.ErrUnexpectedEOF for an EOF in the middle of a capsule.
+func unexpectedEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+
+	return err
+}
+
+// datagramConn carries UDP datagrams over a tunnel, framed as HTTP datagram capsules (RFC 9297),
+// as used by CONNECT-UDP (RFC 9298).
+// Each Write sends a single datagram, and each Read returns a single datagram, truncated to the read buffer.
+type datagramConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+// discard skips the given number of bytes of the current capsule.
+func (c *datagramConn) discard(n uint64) error {
+	if _, err := io.CopyN(io.Discard, c.reader, int64(n)); err != nil {
+		return unexpectedEOF(err)
+	}
+
+	return nil
+}
+
+func (c *datagramConn) Read(b []byte) (int, error) {
+	for {
+		capsuleType, _, err := readVarint(c.reader)
+		if err != nil {
+			return 0, err
+		}
+
+		length, _, err := readVarint(c.reader)
+		if err != nil {
+			return 0, unexpectedEOF(err)
+		}
+
+		if capsuleType != datagramCapsuleType {
+			// unknown capsules are skipped
+			if err := c.discard(length); err != nil {
+				return 0, err
+			}
+			continue
+		}
+
+		contextID, contextIDLength, err := readVarint(c.reader)
+		if err != nil {
+			return 0, unexpectedEOF(err)
+		}
+
+		if uint64(contextIDLength) > length {
+			return 0, errMalformedCapsule
+		}
+
+		length -= uint64(contextIDLength)
+		if contextID != udpContextID {
+			// datagrams of unknown contexts are dropped
+			if err := c.discard(length); err != nil {
+				return 0, err
+			}
+			continue
+		}
+
+		n := int(min(length, uint64(len(b))))
+		if _, err := io.ReadFull(c.reader, b[:n]); err != nil {
+			return 0, unexpectedEOF(err)
+		}
+
+		if err := c.discard(length - uint64(n)); err != nil {
+			return 0, err
+		}
+
+		return n, nil
+	}
+}
+
+func (c *datagramConn) Write(b []byte) (int, error) {
+	capsule := make([]byte, 0, len(b)+16)
+	capsule = appendVarint(capsule, datagramCapsuleType)
+	capsule = appendVarint(capsule, uint64(len(b))+1)
+	capsule = appendVarint(capsule, udpContextID)
+	capsule = append(capsule, b...)
+
+	if _, err := c.Conn.Write(capsule); err != nil {
+		return 0, err
+	}
+
+	return len(b), nil
+}
+
+// CloseWrite closes the write side of the tunnel, if supported, or otherwise the tunnel.
+func (c *datagramConn) CloseWrite() error {
+	if cw, ok := c.Conn.(closeWriter); ok {
+		return cw.CloseWrite()
+	}
+
+	return c.Conn.Close()
+}
+
+// newDatagramConn returns a connection carrying datagrams over the given tunnel.
+// reader is an optional buffered reader of the tunnel, holding data already read from it.
+func newDatagramConn(conn net.Conn, reader *bufio.Reader) *datagramConn {
+	if reader == nil {
+		reader = bufio.NewReader(conn)
+	}
+
+	return &datagramConn{
+		Conn:   conn,
+		reader: reader,
+	}
+}
+
+// idleConn is a connected UDP socket of an exported service, tunneled from a remote peer.
+// Reads return io.EOF once no datagram was exchanged in either direction for the idle timeout,
+// ending the tunnel rather than waiting indefinitely for the exported service or the peer.
+type idleConn struct {
+	net.Conn
+	timeout time.Duration
+	// lastActive is the time (in Unix nanoseconds) a datagram was last exchanged
+	lastActive atomic.Int64
+}
+
+func (c *idleConn) Read(b []byte) (int, error) {
+	for {
+		if err := c.Conn.SetReadDeadline(c.idleDeadline()); err != nil {
+			return 0, err
+		}
+
+		n, err := c.Conn.Read(b)
+		var netErr net.Error
+		switch {
+		case err == nil:
+			c.lastActive.Store(time.Now().UnixNano())
+			return n, nil
+		case !errors.As(err, &netErr) || !netErr.Timeout():
+			return n, err
+		case !time.Now().Before(c.idleDeadline()):
+			return 0, io.EOF
+		}
+		// datagrams were written while waiting, extending the deadline
+	}
+}
+
+func (c *idleConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	if err == nil {
+		c.lastActive.Store(time.Now().UnixNano())
+	}
+
+	return n, err
+}
+
+// idleDeadline returns the time at which the connection becomes idle.
+func (c *idleConn) idleDeadline() time.Time {
+	return time.Unix(0, c.lastActive.Load()).Add(c.timeout)
+}
+
+// newIdleConn returns a connected UDP socket, whose reads end after the given idle timeout.
+func newIdleConn(conn net.Conn, timeout time.Duration) *idleConn {
+	c := &idleConn{
+		Conn:    conn,
+		timeout: timeout,
+	}
+	c.lastActive.Store(time.Now().UnixNano())
+	return c
+}
+
+// udpSession is a connection of datagrams exchanged with a single client of a UDP listener.
+// Reads return io.EOF once no datagram was received from the client for udpSessionIdleTimeout.
+type udpSession struct {
+	packetConn net.PacketConn
+	clientAddr net.Addr
+	datagrams  chan []byte
+	done       chan struct{}
+	closeOnce  sync.Once
+	onClose    func()
+}
+
+// deliver queues a datagram received from the client.
+// If the session is closed, or its queue is full, the datagram is dropped.
+func (s *udpSession) deliver(datagram []byte) {
+	select {
+	case s.datagrams <- datagram:
+	case <-s.done:
+	default:
+	}
+}
+
+func (s *udpSession) Read(b []byte) (int, error) {
+	timer := time.NewTimer(udpSessionIdleTimeout)
+	defer timer.Stop()
+
+	select {
+	case datagram := <-s.datagrams:
+		return copy(b, datagram), nil
+	case <-s.done:
+		return 0, net.ErrClosed
+	case <-timer.C:
+		return 0, io.EOF
+	}
+}
+
+func (s *udpSession) Write(b []byte) (int, error) {
+	select {
+	case <-s.done:
+		return 0, net.ErrClosed
+	default:
+	}
+
+	return s.packetConn.WriteTo(b, s.clientAddr)
+}
+
+func (s *udpSession) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.done)
+		if s.onClose != nil {
+			s.onClose()
+		}
+	})
+
+	return nil
+}
+
+func (s *udpSession) LocalAddr() net.Addr {
+	return s.packetConn.LocalAddr()
+}
+
+func (s *udpSession) RemoteAddr() net.Addr {
+	return s.clientAddr
+}
+
+func (s *udpSession) SetDeadline(_ time.Time) error {
+	return errDeadlineNotSupported
+}
+
+func (s *udpSession) SetReadDeadline(_ time.Time) error {
+	return errDeadlineNotSupported
+}
+
+func (s *udpSession) SetWriteDeadline(_ time.Time) error {
+	return errDeadlineNotSupported
+}
+
+// serveEgressDatagrams serves the clients of a UDP listener for an imported service.
+// Datagrams of each client address form a session, which is tunneled to the imported service.
+// Sessions are tracked by the given tracker until they end. While draining, datagrams of new clients are dropped.
+func (d *Dataplane) serveEgressDatagrams(name string, packetConn net.PacketConn, tracker *connectionTracker) error {
+	var lock sync.Mutex
+	sessions := make(map[string]*udpSession)
+	defer func() {
+		lock.Lock()
+		active := make([]*udpSession, 0, len(sessions))
+		for _, session := range sessions {
+			active = append(active, session)
+		}
+		lock.Unlock()
+
+		for _, session := range active {
+			session.Close()
+		}
+	}()
+
+	d.logger.Infof("Serving for imported service %s at %s (UDP).", name, packetConn.LocalAddr())
+	buf := make([]byte, maxDatagramSize)
+	for {
+		n, addr, err := packetConn.ReadFrom(buf)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				d.logger.Error("Failed to read egress datagram", err)
+			}
+			return err
+		}
+
+		key := addr.String()
+
+		lock.Lock()
+		session, ok := sessions[key]
+		if !ok {
+			session = &udpSession{
+				packetConn: packetConn,
+				clientAddr: addr,
+				datagrams:  make(chan []byte, udpSessionQueueSize),
+				done:       make(chan struct{}),
+			}
+			session.onClose = func() {
+				lock.Lock()
+				defer lock.Unlock()
+
+				if sessions[key] == session {
+					delete(sessions, key)
+				}
+			}
+			if !tracker.add(session) {
+				// the listener is draining
+				lock.Unlock()
+				continue
+			}
+			sessions[key] = session
+
+			d.logger.Debugf("Received a UDP session at listener for imported service %s from %s.", name, key)
+			go func() {
+				defer tracker.remove(session)
+				d.serveEgressConnection(name, session, true)
+			}()
+		}
+		lock.Unlock()
+
+		session.deliver(bytes.Clone(buf[:n]))
+	}
+}
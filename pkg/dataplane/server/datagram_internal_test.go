@@ -0,0 +1,159 @@
+// Copyright 2023 The ClusterLink Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	utiltls "github.com/clusterlink-net/clusterlink/pkg/util/tls"
+)
+
+func TestDatagramConn(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+
+	sender := newDatagramConn(client, nil)
+	receiver := newDatagramConn(server, nil)
+
+	go func() {
+		defer client.Close()
+
+		_, _ = sender.Write([]byte("first"))
+		// unknown capsules, and datagrams of unknown contexts are skipped
+		_, _ = client.Write(appendVarint(appendVarint(nil, 0x2028d7ee), 3))
+		_, _ = client.Write([]byte("abc"))
+		_, _ = client.Write([]byte{datagramCapsuleType, 4, 1, 'a', 'b', 'c'})
+		_, _ = sender.Write(make([]byte, 1000))
+		_, _ = sender.Write([]byte("truncated"))
+	}()
+
+	buf := make([]byte, 1024)
+	n, err := receiver.Read(buf)
+	require.Nil(t, err)
+	require.Equal(t, "first", string(buf[:n]))
+
+	n, err = receiver.Read(buf)
+	require.Nil(t, err)
+	require.Equal(t, 1000, n)
+
+	n, err = receiver.Read(buf[:5])
+	require.Nil(t, err)
+	require.Equal(t, "trunc", string(buf[:n]))
+
+	_, err = receiver.Read(buf)
+	require.ErrorIs(t, err, io.EOF)
+}
+
+func TestVarint(t *testing.T) {
+	for _, v := range []uint64{0, 63, 64, 16383, 16384, 1<<30 - 1, 1 << 30, 1<<62 - 1} {
+		encoded := appendVarint(nil, v)
+
+		decoded, length, err := readVarint(bytes.NewReader(encoded))
+		require.Nil(t, err)
+		require.Equal(t, v, decoded)
+		require.Equal(t, len(encoded), length)
+	}
+}
+
+func TestIdleConn(t *testing.T) {
+	service, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer service.Close()
+
+	dialed, err := net.Dial("udp", service.LocalAddr().String())
+	require.Nil(t, err)
+
+	timeout := 200 * time.Millisecond
+	conn := newIdleConn(dialed, timeout)
+	defer conn.Close()
+
+	// datagrams sent to the service keep the connection active, even without replies
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 4; i++ {
+			time.Sleep(timeout / 2)
+			_, _ = conn.Write([]byte("ping"))
+		}
+	}()
+
+	buf := make([]byte, 16)
+	n, addr, err := service.ReadFrom(buf)
+	require.Nil(t, err)
+	require.Equal(t, "ping", string(buf[:n]))
+	_, err = service.WriteTo([]byte("pong"), addr)
+	require.Nil(t, err)
+
+	n, err = conn.Read(buf)
+	require.Nil(t, err)
+	require.Equal(t, "pong", string(buf[:n]))
+
+	start := time.Now()
+	_, err = conn.Read(buf)
+	require.ErrorIs(t, err, io.EOF)
+	<-done
+	// the later datagrams sent to the service extended the idle deadline
+	require.GreaterOrEqual(t, time.Since(start), 2*timeout)
+}
+
+func TestUDPListenerRebind(t *testing.T) {
+	// the address is still bound, as by a previous listener draining its sessions
+	previous, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	address := previous.LocalAddr().String()
+
+	d := NewDataplane("dataplane", "", "peer", &utiltls.ParsedCertData{})
+	end := make(chan bool)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		d.createUDPListener("default/dns", address, end)
+	}()
+
+	time.Sleep(2 * udpBindRetryInterval)
+	require.NoError(t, previous.Close())
+
+	// the new listener binds once the address is released
+	require.Eventually(t, func() bool {
+		conn, err := net.ListenPacket("udp", address)
+		if err != nil {
+			return true
+		}
+		conn.Close()
+		return false
+	}, 5*udpBindRetryInterval, 10*time.Millisecond)
+
+	close(end)
+	<-done
+
+	// a listener waiting for the address ends once signaled
+	previous, err = net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer previous.Close()
+
+	end = make(chan bool)
+	done = make(chan struct{})
+	go func() {
+		defer close(done)
+		d.createUDPListener("default/dns", previous.LocalAddr().String(), end)
+	}()
+	close(end)
+	<-done
+}
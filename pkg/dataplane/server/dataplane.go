@@ -14,23 +14,24 @@
 package server
 
 import (
-	"fmt"
+	"crypto/tls"
 	"net/http"
-	"strconv"
-	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
 	"github.com/go-chi/chi"
 	"github.com/sirupsen/logrus"
 
-	"github.com/clusterlink-net/clusterlink/pkg/controlplane/api"
+	"github.com/clusterlink-net/clusterlink/pkg/metrics"
+	"github.com/clusterlink-net/clusterlink/pkg/util/spiffe"
 	utiltls "github.com/clusterlink-net/clusterlink/pkg/util/tls"
 )
 
 // Dataplane implements the server and api client which sends authorization to the control plane.
-// Assumption: The caller implements lock mechanism which operating with clusters and listeners.
+// Its routing table is updated by the xDS client, and is safe for concurrent use by connection handlers.
 type Dataplane struct {
 	ID                 string
 	peerName           string
@@ -38,73 +39,82 @@ type Dataplane struct {
 	apiClient          *http.Client
 	parsedCertData     *utiltls.ParsedCertData
 	controlplaneTarget string
-	clusters           map[string]*cluster.Cluster
-	listeners          map[string]*listener.Listener
-	listenerEnd        map[string]chan bool
+	routes             atomic.Pointer[routingTable]
+	activeListeners    sync.WaitGroup
+	ingressConns       *connectionTracker
+	drainTimeout       time.Duration
+	workloadSource     spiffe.Source
+	serviceTransport   *http.Transport
+	metrics            *metrics.DataplaneMetrics
 	logger             *logrus.Entry
+
+	// authzCache caches egress authorization results, as allowed by the imports
+	authzCache *authorizationCache
+
+	// updateLock serializes routing table updates, and guards the listener state.
+	updateLock       sync.Mutex
+	listenerEnd      map[string]chan bool
+	listenerLimiters map[string]*connectionLimiter
+
+	tunnelsLock sync.Mutex
+	tunnels     map[string]*tunnelPool
 }
 
-// GetClusterTarget returns the cluster address:port from the cluster map.
-func (d *Dataplane) GetClusterTarget(name string) (string, error) {
-	if _, ok := d.clusters[name]; !ok {
-		return "", fmt.Errorf("unable to find %s in cluster map", name)
+// getTunnelPool returns the tunnel pool for a peer cluster.
+// If the cluster target or server name changed, the previous pool is drained and replaced.
+func (d *Dataplane) getTunnelPool(name, target string, tlsConfig *tls.Config) *tunnelPool {
+	d.tunnelsLock.Lock()
+	defer d.tunnelsLock.Unlock()
+
+	pool, ok := d.tunnels[name]
+	if ok && pool.target == target && pool.tlsConfig.ServerName == tlsConfig.ServerName {
+		return pool
 	}
-	ep := d.clusters[name].LoadAssignment.GetEndpoints()[0].LbEndpoints[0].GetEndpoint().Address.GetSocketAddress()
-	return ep.GetAddress() + ":" + strconv.Itoa(int(ep.GetPortValue())), nil
-}
 
-// GetClusterHost returns the cluster hostname after trimming ":".
-func (d *Dataplane) GetClusterHost(name string) (string, error) {
-	if _, ok := d.clusters[name]; !ok {
-		return "", fmt.Errorf("unable to find %s in cluster map", name)
+	if ok {
+		pool.drain()
 	}
-	return strings.Split(
-		d.clusters[name].LoadAssignment.GetEndpoints()[0].LbEndpoints[0].GetEndpoint().Hostname, ":")[0], nil
+
+	pool = newTunnelPool(target, tlsConfig)
+	d.tunnels[name] = pool
+	return pool
 }
 
-// AddCluster adds/updates a cluster to the map.
-func (d *Dataplane) AddCluster(c *cluster.Cluster) {
-	d.clusters[c.Name] = c
+// drainTunnelPool drains and removes the tunnel pool of a peer cluster, if exists.
+func (d *Dataplane) drainTunnelPool(name string) {
+	d.tunnelsLock.Lock()
+	defer d.tunnelsLock.Unlock()
+
+	if pool, ok := d.tunnels[name]; ok {
+		pool.drain()
+		delete(d.tunnels, name)
+	}
 }
 
-// RemoveCluster adds a cluster to the map.
-func (d *Dataplane) RemoveCluster(name string) {
-	delete(d.clusters, name)
+// SetWorkloadSource sets the SVID source used for listeners requiring workload mTLS.
+func (d *Dataplane) SetWorkloadSource(source spiffe.Source) {
+	d.workloadSource = source
 }
 
-// GetClusters returns the clusters map.
-func (d *Dataplane) GetClusters() map[string]*cluster.Cluster {
-	return d.clusters
+// SetMetrics sets the metrics for forwarded connections.
+func (d *Dataplane) SetMetrics(dataplaneMetrics *metrics.DataplaneMetrics) {
+	d.metrics = dataplaneMetrics
 }
 
-// AddListener adds a listener to the map.
-func (d *Dataplane) AddListener(ln *listener.Listener) {
-	listenerName := strings.TrimPrefix(ln.Name, api.ImportListenerPrefix)
-	if le, ok := d.listeners[listenerName]; ok {
-		// Check if there is an update to the listener address/port
-		if ln.Address.GetSocketAddress().GetAddress() == le.Address.GetSocketAddress().GetAddress() &&
-			ln.Address.GetSocketAddress().GetPortValue() == le.Address.GetSocketAddress().GetPortValue() {
-			return
+// requiresWorkloadMTLS returns true if a listener requires clients to authenticate using workload mTLS.
+func requiresWorkloadMTLS(ln *listener.Listener) bool {
+	for _, fc := range ln.FilterChains {
+		if fc.TransportSocket != nil {
+			return true
 		}
-		d.listenerEnd[listenerName] <- true
 	}
-	d.listeners[listenerName] = ln
-	go func() {
-		d.CreateListener(listenerName,
-			ln.Address.GetSocketAddress().GetAddress(),
-			ln.Address.GetSocketAddress().GetPortValue())
-	}()
-}
 
-// RemoveListener removes a listener.
-func (d *Dataplane) RemoveListener(name string) {
-	delete(d.listeners, name)
-	d.listenerEnd[name] <- true
+	return false
 }
 
-// GetListeners returns the listeners map.
-func (d *Dataplane) GetListeners() map[string]*listener.Listener {
-	return d.listeners
+// isUDPListener returns true if a listener serves UDP datagrams.
+func isUDPListener(ln *listener.Listener) bool {
+	return ln.Address.GetSocketAddress().GetProtocol() == core.SocketAddress_UDP
 }
 
 // NewDataplane returns a new dataplane HTTP server.
@@ -121,11 +131,16 @@ func NewDataplane(dataplaneID, controlplaneTarget, peerName string, parsedCertDa
 		},
 		parsedCertData:     parsedCertData,
 		controlplaneTarget: controlplaneTarget,
-		clusters:           make(map[string]*cluster.Cluster),
-		listeners:          make(map[string]*listener.Listener),
+		ingressConns:       newConnectionTracker(),
+		drainTimeout:       DefaultDrainTimeout,
+		serviceTransport:   http.DefaultTransport.(*http.Transport).Clone(),
 		listenerEnd:        make(map[string]chan bool),
+		listenerLimiters:   make(map[string]*connectionLimiter),
+		tunnels:            make(map[string]*tunnelPool),
+		authzCache:         newAuthorizationCache(authorizationCacheSize),
 		logger:             logrus.WithField("component", "dataplane.server.http"),
 	}
+	dp.routes.Store(newRoutingTable())
 
 	dp.addAuthzHandlers()
 	return dp
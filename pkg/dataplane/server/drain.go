@@ -0,0 +1,150 @@
+// Copyright 2023 The ClusterLink Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// DefaultDrainTimeout is the default time allowed for connections to end,
+// once their listener is removed or the dataplane is stopped.
+// It is shorter than the default termination grace period of Kubernetes pods (30 seconds).
+const DefaultDrainTimeout = 20 * time.Second
+
+// connectionTracker tracks the active connections of a listener (or of the dataplane server), for draining.
+type connectionTracker struct {
+	lock     sync.Mutex
+	conns    map[net.Conn]struct{}
+	draining bool
+	// idle is closed once draining, and no connection is active.
+	idle chan struct{}
+}
+
+// add tracks a new connection.
+// Returns false if the tracker is draining, in which case the connection should be rejected.
+func (t *connectionTracker) add(conn net.Conn) bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.draining {
+		return false
+	}
+
+	t.conns[conn] = struct{}{}
+	return true
+}
+
+// remove stops tracking a connection which ended.
+// Returns false if the connection was not tracked.
+func (t *connectionTracker) remove(conn net.Conn) bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if _, ok := t.conns[conn]; !ok {
+		return false
+	}
+
+	delete(t.conns, conn)
+	if t.draining && len(t.conns) == 0 {
+		close(t.idle)
+	}
+	return true
+}
+
+// drain rejects new connections, and waits up to the given timeout for the active connections to end.
+// Connections still active at the deadline are closed.
+// Returns the number of connections which ended by the deadline, and the number of connections closed.
+func (t *connectionTracker) drain(timeout time.Duration) (completed, closed int) {
+	t.lock.Lock()
+	if t.draining {
+		t.lock.Unlock()
+		return 0, 0
+	}
+
+	t.draining = true
+	active := len(t.conns)
+	if active == 0 {
+		close(t.idle)
+	}
+	t.lock.Unlock()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-t.idle:
+		return active, 0
+	case <-timer.C:
+	}
+
+	t.lock.Lock()
+	remaining := make([]net.Conn, 0, len(t.conns))
+	for conn := range t.conns {
+		remaining = append(remaining, conn)
+	}
+	t.lock.Unlock()
+
+	for _, conn := range remaining {
+		conn.Close()
+	}
+
+	return active - len(remaining), len(remaining)
+}
+
+// newConnectionTracker returns a new connection tracker.
+func newConnectionTracker() *connectionTracker {
+	return &connectionTracker{
+		conns: make(map[net.Conn]struct{}),
+		idle:  make(chan struct{}),
+	}
+}
+
+// drainConnections drains the connections of a tracker, and reports the result.
+// direction is either egress (for a listener of an imported service) or ingress (for the dataplane server).
+func (d *Dataplane) drainConnections(direction, name string, tracker *connectionTracker) {
+	completed, closed := tracker.drain(d.drainTimeout)
+	d.metrics.ConnectionsDrained(direction, completed, closed)
+
+	if closed > 0 {
+		d.logger.Warnf("Drained %s connections of %s: %d connections ended, %d connections closed after %v.",
+			direction, name, completed, closed, d.drainTimeout)
+		return
+	}
+
+	d.logger.Infof("Drained %s connections of %s: %d connections ended.", direction, name, completed)
+}
+
+// drain removes all listeners, and drains their connections and the ingress connections.
+// Returns once all connections ended or were closed.
+func (d *Dataplane) drain() {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		d.drainConnections("ingress", "the dataplane server", d.ingressConns)
+	}()
+
+	d.UpdateListeners(nil)
+
+	d.activeListeners.Wait()
+	wg.Wait()
+}
+
+// SetDrainTimeout sets the time allowed for connections to end, once their listener is removed
+// or the dataplane is stopped. Connections still active after this time are closed.
+func (d *Dataplane) SetDrainTimeout(timeout time.Duration) {
+	d.drainTimeout = timeout
+}
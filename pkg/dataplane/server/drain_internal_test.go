@@ -0,0 +1,78 @@
+// Copyright 2023 The ClusterLink Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnectionTrackerDrain(t *testing.T) {
+	tracker := newConnectionTracker()
+
+	completedConn, completedPeer := net.Pipe()
+	defer completedPeer.Close()
+	activeConn, activePeer := net.Pipe()
+	defer activePeer.Close()
+
+	require.True(t, tracker.add(completedConn))
+	require.True(t, tracker.add(activeConn))
+
+	// a connection ending while draining completes the drain
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		tracker.remove(completedConn)
+	}()
+
+	completed, closed := tracker.drain(100 * time.Millisecond)
+	require.Equal(t, 1, completed)
+	require.Equal(t, 1, closed)
+
+	// connections still active at the deadline are closed
+	_, err := activeConn.Read(make([]byte, 1))
+	require.ErrorIs(t, err, io.ErrClosedPipe)
+
+	// new connections are rejected while draining
+	newConn, newPeer := net.Pipe()
+	defer newConn.Close()
+	defer newPeer.Close()
+	require.False(t, tracker.add(newConn))
+}
+
+func TestConnectionTrackerDrainCompleted(t *testing.T) {
+	tracker := newConnectionTracker()
+
+	// draining without connections does not wait
+	completed, closed := tracker.drain(time.Hour)
+	require.Equal(t, 0, completed)
+	require.Equal(t, 0, closed)
+
+	tracker = newConnectionTracker()
+	conn, peer := net.Pipe()
+	defer peer.Close()
+	require.True(t, tracker.add(conn))
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		tracker.remove(conn)
+	}()
+
+	completed, closed = tracker.drain(time.Hour)
+	require.Equal(t, 1, completed)
+	require.Equal(t, 0, closed)
+}
@@ -19,21 +19,34 @@ import (
 	"net"
 	"sync"
 	"sync/atomic"
-	"time"
 
 	"github.com/sirupsen/logrus"
 )
 
 const (
 	dataBufferSize = 64 * 1024
-	readDeadline   = 10 * time.Millisecond
+	// spliceChunkSize bounds each spliced copy, so that byte counters advance while data is flowing.
+	spliceChunkSize = 1024 * 1024
 )
 
+// bufferPool holds buffers for copying data between connections which cannot be spliced.
+var bufferPool = sync.Pool{
+	New: func() any {
+		buf := make([]byte, dataBufferSize)
+		return &buf
+	},
+}
+
 type forwarder struct {
 	workloadConn net.Conn
 	peerConn     net.Conn
-	closeSignal  atomic.Bool
+	closeOnce    sync.Once
 	logger       *logrus.Entry
+
+	// outgoingBytes counts the bytes forwarded from the workload to the peer.
+	outgoingBytes atomic.Int64
+	// incomingBytes counts the bytes forwarded from the peer to the workload.
+	incomingBytes atomic.Int64
 }
 
 type connDialer struct {
@@ -44,77 +57,88 @@ func (cd connDialer) Dial(_, _ string) (net.Conn, error) {
 	return cd.c, nil
 }
 
-func (f *forwarder) peerToWorkload() error {
-	bufData := make([]byte, dataBufferSize)
-	var err error
-	numBytes := 0
-	for {
-		if f.closeSignal.Load() {
-			return nil
-		}
-		err = f.peerConn.SetReadDeadline(time.Now().Add(readDeadline))
-		if err != nil {
-			return err
-		}
-		numBytes, err = f.peerConn.Read(bufData)
-		if err != nil {
-			var netErr net.Error
-			if errors.As(err, &netErr) && netErr.Timeout() {
-				continue
+// closeWriter is a connection which supports closing its write side (e.g. *net.TCPConn and *tls.Conn).
+type closeWriter interface {
+	CloseWrite() error
+}
+
+// readerOnly hides an io.WriterTo implementation, forcing io.CopyBuffer to use the given buffer.
+type readerOnly struct {
+	io.Reader
+}
+
+// countingWriter counts the bytes written.
+// It also hides an io.ReaderFrom implementation, forcing io.CopyBuffer to use the given buffer.
+type countingWriter struct {
+	io.Writer
+	count *atomic.Int64
+}
+
+func (w countingWriter) Write(p []byte) (int, error) {
+	n, err := w.Writer.Write(p)
+	w.count.Add(int64(n))
+	return n, err
+}
+
+// copyConn copies data from src to dst until EOF or an error occurs, adding the copied bytes to count.
+// TCP to TCP copies are spliced by the kernel (on Linux), other copies use a pooled buffer.
+func copyConn(dst, src net.Conn, count *atomic.Int64) error {
+	if _, ok := dst.(*net.TCPConn); ok {
+		if _, ok := src.(*net.TCPConn); ok {
+			for {
+				// a limited reader is still spliced, and returns less than the limit only on EOF or error
+				n, err := io.Copy(dst, io.LimitReader(src, spliceChunkSize))
+				count.Add(n)
+				if err != nil || n < spliceChunkSize {
+					return err
+				}
 			}
-			break
-		}
-		_, err = f.workloadConn.Write(bufData[:numBytes]) // TODO: track actually written byte count
-		if err != nil {
-			break
 		}
 	}
-	f.closeSignal.Swap(true)
-	if errors.Is(err, io.EOF) { // don't log EOF
-		return nil
-	}
+
+	buf := bufferPool.Get().(*[]byte)
+	defer bufferPool.Put(buf)
+
+	_, err := io.CopyBuffer(countingWriter{Writer: dst, count: count}, readerOnly{src}, *buf)
 	return err
 }
 
-func (f *forwarder) workloadToPeer() error {
-	bufData := make([]byte, dataBufferSize)
-	var err error
-	numBytes := 0
-	for {
-		if f.closeSignal.Load() {
+// forward copies data from src to dst.
+// Once src reaches EOF, the write side of dst is closed, signaling EOF to its remote end,
+// while the other direction keeps flowing.
+// On error, both connections are closed, which also terminates the other direction.
+func (f *forwarder) forward(dst, src net.Conn, count *atomic.Int64) error {
+	err := copyConn(dst, src, count)
+	if err != nil {
+		f.closeConnections()
+		if errors.Is(err, net.ErrClosed) { // connections closed by the other direction
 			return nil
 		}
-		err = f.workloadConn.SetReadDeadline(time.Now().Add(readDeadline))
-		if err != nil {
+		return err
+	}
+
+	if cw, ok := dst.(closeWriter); ok {
+		if err := cw.CloseWrite(); err != nil && !errors.Is(err, net.ErrClosed) {
+			f.closeConnections()
 			return err
 		}
-		numBytes, err = f.workloadConn.Read(bufData)
-		if err != nil {
-			var netErr net.Error
-			if errors.As(err, &netErr) && netErr.Timeout() {
-				continue
-			}
-			break
-		}
-		_, err = f.peerConn.Write(bufData[:numBytes]) // TODO: track actually written byte count
-		if err != nil {
-			break
-		}
-	}
-	f.closeSignal.Swap(true)
-	if errors.Is(err, io.EOF) { // don't log EOF
 		return nil
 	}
-	return err
+
+	// half-close is not supported, close both directions
+	f.closeConnections()
+	return nil
 }
 
 func (f *forwarder) closeConnections() {
-	if f.peerConn != nil {
-		f.peerConn.Close()
-	}
-	if f.workloadConn != nil {
-		f.workloadConn.Close()
-	}
+	f.closeOnce.Do(func() {
+		if f.peerConn != nil {
+			f.peerConn.Close()
+		}
+		if f.workloadConn != nil {
+			f.workloadConn.Close()
+		}
+	})
 }
 
 func (f *forwarder) run() {
@@ -123,7 +147,7 @@ func (f *forwarder) run() {
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		err := f.workloadToPeer()
+		err := f.forward(f.peerConn, f.workloadConn, &f.outgoingBytes)
 		if err != nil {
 			f.logger.Errorf("Error in workload to peer connection: %v.", err)
 		}
@@ -132,7 +156,7 @@ func (f *forwarder) run() {
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		err := f.peerToWorkload()
+		err := f.forward(f.workloadConn, f.peerConn, &f.incomingBytes)
 		if err != nil {
 			f.logger.Errorf("Error in peer to workload connection: %v.", err)
 		}
@@ -0,0 +1,329 @@
+// Copyright 2023 The ClusterLink Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build unix
+
+package server
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+// pollingForwarder is the previous forwarder implementation, which polls for closure using read deadlines.
+// It is kept for benchmark comparison only.
+type pollingForwarder struct {
+	workloadConn net.Conn
+	peerConn     net.Conn
+	closeSignal  atomic.Bool
+}
+
+func (f *pollingForwarder) copy(dst, src net.Conn) {
+	bufData := make([]byte, dataBufferSize)
+	for !f.closeSignal.Load() {
+		if err := src.SetReadDeadline(time.Now().Add(10 * time.Millisecond)); err != nil {
+			break
+		}
+
+		numBytes, err := src.Read(bufData)
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				continue
+			}
+			break
+		}
+
+		if _, err := dst.Write(bufData[:numBytes]); err != nil {
+			break
+		}
+	}
+	f.closeSignal.Store(true)
+}
+
+func (f *pollingForwarder) run() {
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		f.copy(f.peerConn, f.workloadConn)
+	}()
+	go func() {
+		defer wg.Done()
+		f.copy(f.workloadConn, f.peerConn)
+	}()
+	wg.Wait()
+	f.workloadConn.Close()
+	f.peerConn.Close()
+}
+
+type forwardFunc func(workloadConn, peerConn net.Conn)
+
+var forwarders = []struct {
+	name    string
+	forward forwardFunc
+}{
+	{
+		name: "event-driven",
+		forward: func(workloadConn, peerConn net.Conn) {
+			newForwarder(workloadConn, peerConn).run()
+		},
+	},
+	{
+		name: "polling",
+		forward: func(workloadConn, peerConn net.Conn) {
+			(&pollingForwarder{workloadConn: workloadConn, peerConn: peerConn}).run()
+		},
+	},
+}
+
+// connPair returns both ends of a loopback TCP connection.
+func connPair(tb testing.TB) (net.Conn, net.Conn) {
+	tb.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(tb, err)
+	defer listener.Close()
+
+	client, err := net.Dial("tcp", listener.Addr().String())
+	require.Nil(tb, err)
+
+	server, err := listener.Accept()
+	require.Nil(tb, err)
+
+	return client, server
+}
+
+// forwardedPair returns a client and server connection, whose traffic is forwarded.
+func forwardedPair(tb testing.TB, forward forwardFunc) (net.Conn, net.Conn) {
+	tb.Helper()
+
+	client, workloadConn := connPair(tb)
+	peerConn, server := connPair(tb)
+	go forward(workloadConn, peerConn)
+
+	return client, server
+}
+
+// tunneledPair returns a client and server connection, whose traffic is forwarded over a tunnel,
+// from an egress forwarder to an ingress forwarder.
+func tunneledPair(t *testing.T, enableHTTP2 bool) (net.Conn, net.Conn) {
+	t.Helper()
+
+	d := &Dataplane{logger: logrus.WithField("component", "test")}
+	appConns := make(chan net.Conn, 1)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var peerConn net.Conn
+		var err error
+		if r.ProtoMajor == 1 {
+			peerConn, _, err = d.hijackConn(w, tunnelResponse)
+		} else {
+			peerConn, err = acceptStreamTunnel(w, r)
+		}
+		if err != nil {
+			return
+		}
+
+		appConn, server := connPair(t)
+		appConns <- server
+		newForwarder(appConn, peerConn).run()
+	}))
+	server.EnableHTTP2 = enableHTTP2
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	tunnel, err := newTestTunnelPool(server).openTunnel("token")
+	require.Nil(t, err)
+
+	client, workloadConn := connPair(t)
+	go newForwarder(workloadConn, tunnel).run()
+
+	return client, <-appConns
+}
+
+// cpuTime returns the CPU time consumed by the process.
+func cpuTime(tb testing.TB) time.Duration {
+	tb.Helper()
+
+	var usage syscall.Rusage
+	require.Nil(tb, syscall.Getrusage(syscall.RUSAGE_SELF, &usage))
+	return time.Duration(usage.Utime.Nano() + usage.Stime.Nano())
+}
+
+func TestForwarderHalfClose(t *testing.T) {
+	pairs := []struct {
+		name string
+		pair func(t *testing.T) (net.Conn, net.Conn)
+	}{{
+		name: "tcp",
+		pair: func(t *testing.T) (net.Conn, net.Conn) { return forwardedPair(t, forwarders[0].forward) },
+	}, {
+		name: "http2-tunnel",
+		pair: func(t *testing.T) (net.Conn, net.Conn) { return tunneledPair(t, true) },
+	}, {
+		name: "http1-tunnel",
+		pair: func(t *testing.T) (net.Conn, net.Conn) { return tunneledPair(t, false) },
+	}}
+
+	for _, pair := range pairs {
+		t.Run(pair.name, func(t *testing.T) {
+			client, server := pair.pair(t)
+			defer client.Close()
+			defer server.Close()
+
+			// client sends a request and closes its write side
+			_, err := client.Write([]byte("request"))
+			require.Nil(t, err)
+			require.Nil(t, client.(*net.TCPConn).CloseWrite())
+
+			request, err := io.ReadAll(server)
+			require.Nil(t, err)
+			require.Equal(t, "request", string(request))
+
+			// server can still respond after the client half-closed
+			_, err = server.Write([]byte("response"))
+			require.Nil(t, err)
+			require.Nil(t, server.Close())
+
+			response, err := io.ReadAll(client)
+			require.Nil(t, err)
+			require.Equal(t, "response", string(response))
+		})
+
+		t.Run(pair.name+"-server-half-close", func(t *testing.T) {
+			client, server := pair.pair(t)
+			defer client.Close()
+			defer server.Close()
+
+			// server sends a greeting and closes its write side
+			_, err := server.Write([]byte("greeting"))
+			require.Nil(t, err)
+			require.Nil(t, server.(*net.TCPConn).CloseWrite())
+
+			greeting, err := io.ReadAll(client)
+			require.Nil(t, err)
+			require.Equal(t, "greeting", string(greeting))
+
+			// client can still send after the server half-closed
+			_, err = client.Write([]byte("request"))
+			require.Nil(t, err)
+			require.Nil(t, client.Close())
+
+			request, err := io.ReadAll(server)
+			require.Nil(t, err)
+			require.Equal(t, "request", string(request))
+		})
+	}
+}
+
+func BenchmarkForwarderThroughput(b *testing.B) {
+	chunk := make([]byte, dataBufferSize)
+
+	for _, fw := range forwarders {
+		b.Run(fw.name, func(b *testing.B) {
+			client, server := forwardedPair(b, fw.forward)
+			defer server.Close()
+
+			b.SetBytes(int64(len(chunk)))
+			b.ResetTimer()
+			startCPU := cpuTime(b)
+
+			go func() {
+				for i := 0; i < b.N; i++ {
+					if _, err := client.Write(chunk); err != nil {
+						break
+					}
+				}
+				client.Close()
+			}()
+
+			received, err := io.Copy(io.Discard, server)
+			require.Nil(b, err)
+			require.Equal(b, int64(b.N*len(chunk)), received)
+
+			b.ReportMetric(float64(cpuTime(b)-startCPU)/float64(b.N), "cpu-ns/op")
+		})
+	}
+}
+
+func BenchmarkForwarderIdle(b *testing.B) {
+	const (
+		connections = 100
+		idlePeriod  = 10 * time.Millisecond
+	)
+
+	for _, fw := range forwarders {
+		b.Run(fw.name, func(b *testing.B) {
+			for i := 0; i < connections; i++ {
+				client, server := forwardedPair(b, fw.forward)
+				defer client.Close()
+				defer server.Close()
+			}
+
+			b.ResetTimer()
+			startCPU := cpuTime(b)
+
+			for i := 0; i < b.N; i++ {
+				time.Sleep(idlePeriod)
+			}
+
+			b.ReportMetric(float64(cpuTime(b)-startCPU)/float64(b.N), "cpu-ns/op")
+		})
+	}
+}
+
+func TestForwarderByteCount(t *testing.T) {
+	client, workloadConn := connPair(t)
+	peerConn, server := connPair(t)
+
+	fw := newForwarder(workloadConn, peerConn)
+	done := make(chan struct{})
+	go func() {
+		fw.run()
+		close(done)
+	}()
+
+	request := make([]byte, 3*spliceChunkSize+1)
+	go func() {
+		_, _ = client.Write(request)
+		_ = client.(*net.TCPConn).CloseWrite()
+	}()
+
+	received, err := io.Copy(io.Discard, server)
+	require.Nil(t, err)
+	require.Equal(t, int64(len(request)), received)
+
+	_, err = server.Write([]byte("response"))
+	require.Nil(t, err)
+	require.Nil(t, server.Close())
+
+	response, err := io.ReadAll(client)
+	require.Nil(t, err)
+	require.Equal(t, "response", string(response))
+	require.Nil(t, client.Close())
+
+	<-done
+	require.Equal(t, int64(len(request)), fw.outgoingBytes.Load())
+	require.Equal(t, int64(len("response")), fw.incomingBytes.Load())
+}
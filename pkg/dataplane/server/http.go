@@ -0,0 +1,366 @@
+// Copyright 2023 The ClusterLink Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"time"
+
+	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+
+	cpapi "github.com/clusterlink-net/clusterlink/pkg/controlplane/api"
+	"github.com/clusterlink-net/clusterlink/pkg/util/spiffe"
+)
+
+const (
+	// httpNumRetries is the number of retries of a failed request of an HTTP import, matching the xDS routes.
+	httpNumRetries = 2
+)
+
+// internalHTTPHeaders are headers set by the dataplane, which are removed from client requests.
+var internalHTTPHeaders = []string{
+	cpapi.ImportNameHeader,
+	cpapi.ImportNamespaceHeader,
+	cpapi.ImportPortHeader,
+	cpapi.ClientIPHeader,
+	cpapi.ClientSPIFFEIDHeader,
+	cpapi.HTTPModeHeader,
+	cpapi.HTTPRouteHeader,
+	cpapi.HTTPAuthorizationHeader,
+}
+
+// httpRoute routes the matching requests of an HTTP import to a subset of the import sources.
+type httpRoute struct {
+	name       string
+	pathPrefix string
+	headers    map[string]string
+}
+
+// matches returns true if a request matches the route path prefix and headers.
+func (r *httpRoute) matches(req *http.Request) bool {
+	if !strings.HasPrefix(req.URL.Path, r.pathPrefix) {
+		return false
+	}
+
+	for name, value := range r.headers {
+		if req.Header.Get(name) != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+// matchHTTPRoute returns the name of the first route matching a request,
+// or an empty string if no route matches.
+func matchHTTPRoute(routes []*httpRoute, req *http.Request) string {
+	for _, r := range routes {
+		if r.matches(req) {
+			return r.name
+		}
+	}
+
+	return ""
+}
+
+// parseHTTPRoutes returns the import routes of an HTTP listener.
+// Returns false if the listener is not an HTTP listener.
+func parseHTTPRoutes(ln *listener.Listener) ([]*httpRoute, bool) {
+	for _, fc := range ln.FilterChains {
+		for _, filter := range fc.Filters {
+			if filter.Name != wellknown.HTTPConnectionManager {
+				continue
+			}
+
+			var manager hcm.HttpConnectionManager
+			if err := filter.GetTypedConfig().UnmarshalTo(&manager); err != nil {
+				return nil, false
+			}
+
+			routes := []*httpRoute{}
+			for _, vh := range manager.GetRouteConfig().GetVirtualHosts() {
+				for _, r := range vh.Routes {
+					// the unnamed default route matches requests not matching any import route
+					if r.Name == "" {
+						continue
+					}
+
+					routes = append(routes, &httpRoute{
+						name:       r.Name,
+						pathPrefix: r.Match.GetPrefix(),
+						headers:    parseHeaderMatchers(r.Match.Headers),
+					})
+				}
+			}
+
+			return routes, true
+		}
+	}
+
+	return nil, false
+}
+
+// parseHeaderMatchers returns the exact header values matched by a route.
+func parseHeaderMatchers(matchers []*route.HeaderMatcher) map[string]string {
+	headers := make(map[string]string, len(matchers))
+	for _, matcher := range matchers {
+		headers[matcher.Name] = matcher.GetStringMatch().GetExact()
+	}
+
+	return headers
+}
+
+// normalizeRequestPath normalizes the path of a forwarded request, by which it is routed and authorized.
+// Escaped slashes are decoded, as done by the Envoy dataplane.
+func normalizeRequestPath(u *url.URL) {
+	u.Path = cpapi.NormalizeHTTPPath(u.Path)
+	u.RawPath = ""
+}
+
+// httpRequestInfo holds the attributes of a request of an HTTP import, used for egress authorization.
+type httpRequestInfo struct {
+	method string
+	path   string
+	route  string
+}
+
+// spiffeIDKey is the request context key holding the SPIFFE ID of an authenticated workload.
+type spiffeIDKey struct{}
+
+// newEgressHTTPServer returns a server for the requests of an HTTP import.
+// Each request is separately authorized and routed, and failed requests are retried.
+// Client connections are admitted by the given limiter, and tracked by the given tracker until they are closed.
+func (d *Dataplane) newEgressHTTPServer(
+	name string,
+	routes []*httpRoute,
+	conns *connectionTracker,
+	limiter *connectionLimiter,
+) *http.Server {
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			for _, header := range internalHTTPHeaders {
+				pr.Out.Header.Del(header)
+			}
+
+			pr.Out.URL.Scheme = "https"
+			pr.Out.URL.Host = pr.In.Host
+			normalizeRequestPath(pr.Out.URL)
+		},
+		Transport: &egressHTTPTransport{
+			dataplane: d,
+			name:      name,
+			routes:    routes,
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			d.logger.Infof("Failed forwarding request of imported service %s: %v.", name, err)
+			w.WriteHeader(http.StatusBadGateway)
+		},
+	}
+
+	return &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.TLS != nil {
+				id, err := spiffe.PeerID(r.TLS)
+				if err != nil {
+					d.logger.Infof("Failed workload authentication: %v.", err)
+					w.WriteHeader(http.StatusForbidden)
+					return
+				}
+
+				r = r.WithContext(context.WithValue(r.Context(), spiffeIDKey{}, id.String()))
+			}
+
+			proxy.ServeHTTP(w, r)
+		}),
+		ReadHeaderTimeout: workloadHandshakeTimeout,
+		ConnState: func(conn net.Conn, state http.ConnState) {
+			switch state {
+			case http.StateNew:
+				sourceIP := connectionSourceIP(conn)
+				if !d.acquireEgressConnection(name, sourceIP, limiter) {
+					conn.Close()
+					return
+				}
+
+				if !conns.add(conn) {
+					// the listener is draining
+					limiter.release(sourceIP)
+					conn.Close()
+				}
+			case http.StateHijacked, http.StateClosed:
+				// only admitted connections are tracked
+				if conns.remove(conn) {
+					limiter.release(connectionSourceIP(conn))
+				}
+			default:
+			}
+		},
+	}
+}
+
+// egressHTTPTransport sends the requests of an HTTP import to the peer gateways.
+type egressHTTPTransport struct {
+	dataplane *Dataplane
+	name      string
+	routes    []*httpRoute
+}
+
+// RoundTrip authorizes and sends a request to a peer gateway.
+// Failed requests (5xx responses or transport errors) without a body are retried,
+// each time routed to a newly authorized peer.
+func (t *egressHTTPTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	info := &httpRequestInfo{
+		method: req.Method,
+		path:   req.URL.EscapedPath(),
+		route:  matchHTTPRoute(t.routes, req),
+	}
+
+	attempts := 1
+	if req.Body == nil || req.Body == http.NoBody {
+		attempts += httpNumRetries
+	}
+
+	var resp *http.Response
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		resp, err = t.roundTripOnce(req, info)
+		if err == nil && resp.StatusCode < http.StatusInternalServerError {
+			return resp, nil
+		}
+
+		var authErr *egressAuthorizationError
+		if errors.As(err, &authErr) && authErr.statusCode < http.StatusInternalServerError {
+			// the request was denied or not routable, no need to retry
+			return &http.Response{
+				StatusCode: authErr.statusCode,
+				Header:     make(http.Header),
+				Body:       http.NoBody,
+				Request:    req,
+			}, nil
+		}
+
+		if attempt < attempts && resp != nil {
+			resp.Body.Close()
+		}
+	}
+
+	return resp, err
+}
+
+// roundTripOnce authorizes and sends a request to a peer gateway.
+func (t *egressHTTPTransport) roundTripOnce(req *http.Request, info *httpRequestInfo) (*http.Response, error) {
+	d := t.dataplane
+
+	sourceIP, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return nil, err
+	}
+	spiffeID, _ := req.Context().Value(spiffeIDKey{}).(string)
+
+	targetPeer, accessToken, err := d.getEgressAuth(t.name, sourceIP, spiffeID, info)
+	if err != nil {
+		return nil, err
+	}
+
+	targetHost, err := d.GetClusterHost(targetPeer)
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := d.GetClusterTarget(targetPeer)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := d.getTunnelPool(targetPeer, target, d.parsedCertData.ClientConfig(targetHost))
+
+	out := req.Clone(req.Context())
+	out.URL.Host = target
+	out.Host = ""
+	out.Header.Set(cpapi.HTTPModeHeader, "true")
+	out.Header.Set(cpapi.HTTPAuthorizationHeader, accessToken)
+
+	return pool.roundTrip(out)
+}
+
+// serveIngressHTTP is a middleware which serves requests of HTTP services, marked by the HTTP mode header.
+// Each request is authorized by the controlplane, using its method and path, and is forwarded to the exported service.
+func (d *Dataplane) serveIngressHTTP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(cpapi.HTTPModeHeader) == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		header := make(http.Header)
+		header.Set(cpapi.HTTPModeHeader, r.Header.Get(cpapi.HTTPModeHeader))
+		header.Set(cpapi.HTTPAuthorizationHeader, r.Header.Get(cpapi.HTTPAuthorizationHeader))
+
+		path := cpapi.DataplaneIngressAuthorizationPath + strings.TrimPrefix(r.URL.EscapedPath(), "/")
+		targetCluster, err := d.getIngressAuth(r.Method, path, header)
+		if err != nil {
+			d.logger.Infof("Failed ingress authorization: %v.", err)
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		serviceTarget, err := d.GetClusterTarget(targetCluster)
+		if err != nil {
+			d.logger.Errorf("Unable to get cluster target: %v.", err)
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		// concurrent requests are limited as connections
+		release, ok := d.acquireExportConnection(targetCluster, requestPeer(r))
+		if !ok {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		defer release()
+
+		// responses are not limited by the write timeout of the dataplane server
+		if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+			d.logger.Debugf("Failed to clear write deadline: %v.", err)
+		}
+
+		proxy := &httputil.ReverseProxy{
+			Rewrite: func(pr *httputil.ProxyRequest) {
+				for _, header := range internalHTTPHeaders {
+					pr.Out.Header.Del(header)
+				}
+
+				pr.Out.URL.Scheme = "http"
+				pr.Out.URL.Host = serviceTarget
+				pr.Out.Host = ""
+				normalizeRequestPath(pr.Out.URL)
+			},
+			Transport: d.serviceTransport,
+			ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+				d.logger.Infof("Failed forwarding request to %s: %v.", serviceTarget, err)
+				w.WriteHeader(http.StatusBadGateway)
+			},
+		}
+		proxy.ServeHTTP(w, r)
+	})
+}
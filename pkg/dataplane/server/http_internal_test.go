@@ -0,0 +1,120 @@
+// Copyright 2023 The ClusterLink Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	matcher "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+func TestHTTPRoutes(t *testing.T) {
+	// not an HTTP listener
+	_, ok := parseHTTPRoutes(&listener.Listener{})
+	require.False(t, ok)
+
+	pb, err := anypb.New(&hcm.HttpConnectionManager{
+		RouteSpecifier: &hcm.HttpConnectionManager_RouteConfig{
+			RouteConfig: &route.RouteConfiguration{
+				VirtualHosts: []*route.VirtualHost{{
+					Routes: []*route.Route{
+						{
+							Name: "canary",
+							Match: &route.RouteMatch{
+								PathSpecifier: &route.RouteMatch_Prefix{Prefix: "/api/"},
+								Headers: []*route.HeaderMatcher{{
+									Name: "x-canary",
+									HeaderMatchSpecifier: &route.HeaderMatcher_StringMatch{
+										StringMatch: &matcher.StringMatcher{
+											MatchPattern: &matcher.StringMatcher_Exact{Exact: "true"},
+										},
+									},
+								}},
+							},
+						},
+						{
+							Name: "api",
+							Match: &route.RouteMatch{
+								PathSpecifier: &route.RouteMatch_Prefix{Prefix: "/api/"},
+							},
+						},
+						{
+							Match: &route.RouteMatch{
+								PathSpecifier: &route.RouteMatch_Prefix{Prefix: "/"},
+							},
+						},
+					},
+				}},
+			},
+		},
+	})
+	require.Nil(t, err)
+
+	routes, ok := parseHTTPRoutes(&listener.Listener{
+		FilterChains: []*listener.FilterChain{{
+			Filters: []*listener.Filter{{
+				Name:       wellknown.HTTPConnectionManager,
+				ConfigType: &listener.Filter_TypedConfig{TypedConfig: pb},
+			}},
+		}},
+	})
+	require.True(t, ok)
+	require.Len(t, routes, 2)
+
+	request := func(path string, header http.Header) *http.Request {
+		req, err := http.NewRequest(http.MethodGet, "http://service"+path, http.NoBody)
+		require.Nil(t, err)
+		req.Header = header
+		return req
+	}
+
+	// routes are matched in order
+	require.Equal(t, "canary", matchHTTPRoute(routes, request("/api/users", http.Header{"X-Canary": {"true"}})))
+	require.Equal(t, "api", matchHTTPRoute(routes, request("/api/users", http.Header{"X-Canary": {"false"}})))
+	require.Equal(t, "api", matchHTTPRoute(routes, request("/api/users", http.Header{})))
+	// requests not matching any route are not routed by an import route
+	require.Equal(t, "", matchHTTPRoute(routes, request("/users", http.Header{"X-Canary": {"true"}})))
+}
+
+func TestNormalizeRequestPath(t *testing.T) {
+	tests := []struct {
+		path       string
+		normalized string
+	}{
+		{path: "/public/index.html", normalized: "/public/index.html"},
+		{path: "/public/../admin", normalized: "/admin"},
+		{path: "//admin", normalized: "/admin"},
+		{path: "/%2Fadmin", normalized: "/admin"},
+		{path: "/public%2F..%2Fadmin", normalized: "/admin"},
+		{path: "/public/a%20file", normalized: "/public/a%20file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			u, err := url.Parse("http://service" + tt.path)
+			require.Nil(t, err)
+
+			normalizeRequestPath(u)
+			require.Equal(t, tt.normalized, u.EscapedPath())
+		})
+	}
+}
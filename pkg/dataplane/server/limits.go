@@ -0,0 +1,173 @@
+// Copyright 2023 The ClusterLink Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"strings"
+	"sync"
+
+	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	connectionlimit "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/connection_limit/v3"
+	localratelimit "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/local_ratelimit/v3"
+
+	"github.com/clusterlink-net/clusterlink/pkg/controlplane/api"
+	"github.com/clusterlink-net/clusterlink/pkg/util/ratelimit"
+)
+
+// reasons for rejecting a connection, used in metrics.
+const (
+	// maxConnectionsReason is the reason for rejecting a connection exceeding the total concurrent connections.
+	maxConnectionsReason = "max_connections"
+	// maxClientConnectionsReason is the reason for rejecting a connection exceeding the concurrent connections
+	// of its client (source IP or remote peer).
+	maxClientConnectionsReason = "max_client_connections"
+	// rateLimitReason is the reason for rejecting a connection exceeding the total connection rate.
+	rateLimitReason = "rate_limit"
+	// clientRateLimitReason is the reason for rejecting a connection exceeding the connection rate of its client,
+	// as enforced by the controlplane.
+	clientRateLimitReason = "client_rate_limit"
+)
+
+// connectionLimits are the connection limits of an imported or exported service. Zero values are not limited.
+type connectionLimits struct {
+	// maxConnections is the maximal number of concurrent connections.
+	maxConnections uint32
+	// connectionsPerSecond is the maximal rate of new connections.
+	connectionsPerSecond uint32
+	// maxClientConnections is the maximal number of concurrent connections of each client.
+	maxClientConnections uint32
+}
+
+// connectionLimiter enforces the connection limits of an imported or exported service.
+type connectionLimiter struct {
+	lock        sync.Mutex
+	limits      connectionLimits
+	connections int
+	clients     map[string]int
+	rateLimiter *ratelimit.Limiter
+}
+
+// acquire admits a new connection of the given client, unless it exceeds the limits.
+// Returns the reason for rejecting the connection, and false if rejected.
+// An admitted connection must be released once it ends.
+func (l *connectionLimiter) acquire(client string) (string, bool) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.limits.maxConnections > 0 && l.connections >= int(l.limits.maxConnections) {
+		return maxConnectionsReason, false
+	}
+
+	if l.limits.maxClientConnections > 0 && l.clients[client] >= int(l.limits.maxClientConnections) {
+		return maxClientConnectionsReason, false
+	}
+
+	if !l.rateLimiter.Allow("", l.limits.connectionsPerSecond) {
+		return rateLimitReason, false
+	}
+
+	l.connections++
+	l.clients[client]++
+	return "", true
+}
+
+// release releases an admitted connection of the given client.
+func (l *connectionLimiter) release(client string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.connections--
+	l.clients[client]--
+	if l.clients[client] <= 0 {
+		delete(l.clients, client)
+	}
+}
+
+// setLimits updates the limits. Connections admitted before the update are not affected.
+func (l *connectionLimiter) setLimits(limits connectionLimits) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.limits = limits
+}
+
+// newConnectionLimiter returns a new connection limiter.
+func newConnectionLimiter() *connectionLimiter {
+	return &connectionLimiter{
+		clients:     make(map[string]int),
+		rateLimiter: ratelimit.NewLimiter(),
+	}
+}
+
+// parseListenerLimits returns the connection limits of an import listener, where clients are source IPs.
+// The total limits are parsed from the listener network filters, and the per-client limit from its metadata.
+func parseListenerLimits(ln *listener.Listener) connectionLimits {
+	var limits connectionLimits
+	for _, fc := range ln.FilterChains {
+		for _, filter := range fc.Filters {
+			switch filter.Name {
+			case api.ConnectionLimitFilter:
+				var config connectionlimit.ConnectionLimit
+				if err := filter.GetTypedConfig().UnmarshalTo(&config); err == nil {
+					limits.maxConnections = uint32(config.GetMaxConnections().GetValue())
+				}
+			case api.LocalRateLimitFilter:
+				var config localratelimit.LocalRateLimit
+				if err := filter.GetTypedConfig().UnmarshalTo(&config); err == nil {
+					limits.connectionsPerSecond = config.GetTokenBucket().GetTokensPerFill().GetValue()
+				}
+			}
+		}
+	}
+
+	metadata := ln.GetMetadata().GetFilterMetadata()[api.ConnectionLimitsMetadataKey]
+	if value, ok := metadata.GetFields()[api.MaxConnectionsPerSourceIPMetadataField]; ok {
+		limits.maxClientConnections = uint32(value.GetNumberValue())
+	}
+
+	return limits
+}
+
+// parseExportClusterLimits returns the connection limits of an export cluster, where clients are remote peers.
+// The circuit breaker connection threshold applies to each remote peer.
+func parseExportClusterLimits(c *cluster.Cluster) connectionLimits {
+	var limits connectionLimits
+	for _, threshold := range c.GetCircuitBreakers().GetThresholds() {
+		if threshold.GetPriority() == core.RoutingPriority_DEFAULT {
+			limits.maxClientConnections = threshold.GetMaxConnections().GetValue()
+		}
+	}
+
+	return limits
+}
+
+// acquireExportConnection admits a new ingress connection (or HTTP request) of a remote peer to an export cluster,
+// unless it exceeds the connection limits of the peer. The returned function releases an admitted connection.
+func (d *Dataplane) acquireExportConnection(clusterName, peer string) (func(), bool) {
+	limiter, ok := d.routingTable().exportLimiters[clusterName]
+	if !ok {
+		return func() {}, true
+	}
+
+	reason, ok := limiter.acquire(peer)
+	if !ok {
+		d.logger.Infof("Rejecting a connection of peer %s to %s: %s.", peer, clusterName, reason)
+		d.metrics.ConnectionRejected("ingress", strings.TrimPrefix(clusterName, api.ExportClusterPrefix), reason)
+		return nil, false
+	}
+
+	return func() { limiter.release(peer) }, true
+}
@@ -0,0 +1,82 @@
+// Copyright 2023 The ClusterLink Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnectionLimiter(t *testing.T) {
+	limiter := newConnectionLimiter()
+	limiter.setLimits(connectionLimits{maxConnections: 3, maxClientConnections: 2})
+
+	_, ok := limiter.acquire("a")
+	require.True(t, ok)
+	_, ok = limiter.acquire("a")
+	require.True(t, ok)
+
+	// a client exceeding its limit is rejected
+	reason, ok := limiter.acquire("a")
+	require.False(t, ok)
+	require.Equal(t, maxClientConnectionsReason, reason)
+
+	_, ok = limiter.acquire("b")
+	require.True(t, ok)
+
+	// any client is rejected once the total limit is reached
+	reason, ok = limiter.acquire("c")
+	require.False(t, ok)
+	require.Equal(t, maxConnectionsReason, reason)
+
+	// released connections admit new connections
+	limiter.release("a")
+	_, ok = limiter.acquire("a")
+	require.True(t, ok)
+
+	// updated limits apply to new connections
+	limiter.setLimits(connectionLimits{connectionsPerSecond: 1})
+	_, ok = limiter.acquire("c")
+	require.True(t, ok)
+	reason, ok = limiter.acquire("c")
+	require.False(t, ok)
+	require.Equal(t, rateLimitReason, reason)
+}
+
+// addrConn is a connection with a given remote address.
+type addrConn struct {
+	net.Conn
+	addr net.Addr
+}
+
+func (c *addrConn) RemoteAddr() net.Addr {
+	return c.addr
+}
+
+func TestConnectionSourceIP(t *testing.T) {
+	tests := []struct {
+		addr net.Addr
+		ip   string
+	}{
+		{addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 5000}, ip: "10.0.0.1"},
+		{addr: &net.TCPAddr{IP: net.ParseIP("fd00::1"), Port: 5000}, ip: "fd00::1"},
+		{addr: &net.UnixAddr{Name: "@", Net: "unix"}, ip: "@"},
+	}
+
+	for _, tt := range tests {
+		require.Equal(t, tt.ip, connectionSourceIP(&addrConn{addr: tt.addr}))
+	}
+}
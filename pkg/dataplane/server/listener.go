@@ -14,41 +14,162 @@
 package server
 
 import (
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
 	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/clusterlink-net/clusterlink/pkg/controlplane/api"
+	event "github.com/clusterlink-net/clusterlink/pkg/controlplane/eventmanager"
+	"github.com/clusterlink-net/clusterlink/pkg/util/spiffe"
 )
 
-// CreateListener starts a listener to an imported service.
-func (d *Dataplane) CreateListener(name, ip string, port uint32) {
-	listenTarget := ip + ":" + strconv.Itoa(int(port))
-	d.listenerEnd[name] = make(chan bool)
+const (
+	// workloadHandshakeTimeout is the time allowed for a workload to complete a TLS handshake.
+	workloadHandshakeTimeout = 10 * time.Second
+	// udpBindRetryInterval is the interval between attempts to bind a UDP listener to an address
+	// still bound by a previous listener, which keeps its socket until its sessions are drained.
+	udpBindRetryInterval = 500 * time.Millisecond
+)
+
+// egressAuthorizationError is returned when the controlplane does not authorize an egress connection or request.
+type egressAuthorizationError struct {
+	statusCode int
+	status     string
+}
+
+func (e *egressAuthorizationError) Error() string {
+	return "failed egress authorization:" + e.status
+}
+
+// CreateListener starts a listener to an imported service, until signaled to end.
+// If workloadMTLS is true, clients must authenticate using a SPIFFE SVID.
+// If udp is true, the listener serves UDP datagrams, and workloadMTLS does not apply.
+// If httpRoutes is non-nil, the listener serves HTTP requests, routed using the given import routes.
+// Client connections are admitted by the given limiter, where clients are identified by their source IP.
+// Once ended, the listener stops accepting, and its active connections are drained.
+func (d *Dataplane) CreateListener(
+	name, ip string,
+	port uint32,
+	workloadMTLS, udp bool,
+	httpRoutes []*httpRoute,
+	limiter *connectionLimiter,
+	end <-chan bool,
+) {
+	listenTarget := net.JoinHostPort(ip, strconv.Itoa(int(port)))
+	if udp {
+		d.createUDPListener(name, listenTarget, end)
+		return
+	}
+
 	d.logger.Infof("Starting a listener for imported service %s at %s.", name, listenTarget)
 	acceptor, err := net.Listen("tcp", listenTarget)
 	if err != nil {
 		d.logger.Infof("Error listening to por: %v.", err)
 		return
 	}
+
+	if workloadMTLS {
+		if d.workloadSource == nil {
+			d.logger.Errorf("Listener for imported service %s requires workload mTLS, but no SVID source is set.", name)
+			acceptor.Close()
+			return
+		}
+
+		acceptor = tls.NewListener(acceptor, spiffe.ServerConfig(d.workloadSource))
+	}
+
+	conns := newConnectionTracker()
+	var httpServer *http.Server
+	if httpRoutes != nil {
+		httpServer = d.newEgressHTTPServer(name, httpRoutes, conns, limiter)
+	}
+
 	go func() {
-		if err = d.serveEgressConnections(name, acceptor); err != nil {
+		var err error
+		if httpServer != nil {
+			err = httpServer.Serve(acceptor)
+		} else {
+			err = d.serveEgressConnections(name, acceptor, conns, limiter)
+		}
+		if err != nil && !errors.Is(err, net.ErrClosed) {
 			d.logger.Errorf("Failed to serve egress connection on %s: %+v.", listenTarget, err)
 		}
 	}()
-	<-d.listenerEnd[name]
+	<-end
 	d.logger.Infof("Ending the listener for imported service %s at %s.", name, listenTarget)
 	acceptor.Close()
+	if httpServer != nil {
+		// idle connections are closed, and active connections are closed once their request completes
+		httpServer.SetKeepAlivesEnabled(false)
+	}
+	d.drainConnections("egress", name, conns)
 }
 
-func (d *Dataplane) serveEgressConnections(name string, listener net.Listener) error {
+// createUDPListener starts a UDP listener to an imported service, until signaled to end.
+// Once ended, datagrams of new clients are dropped, and the listener is closed after its sessions are drained.
+func (d *Dataplane) createUDPListener(name, listenTarget string, end <-chan bool) {
+	d.logger.Infof("Starting a UDP listener for imported service %s at %s.", name, listenTarget)
+	packetConn := d.listenUDP(listenTarget, end)
+	if packetConn == nil {
+		return
+	}
+
+	sessions := newConnectionTracker()
+	go func() {
+		if err := d.serveEgressDatagrams(name, packetConn, sessions); err != nil && !errors.Is(err, net.ErrClosed) {
+			d.logger.Errorf("Failed to serve egress datagrams on %s: %+v.", listenTarget, err)
+		}
+	}()
+	<-end
+	d.logger.Infof("Ending the UDP listener for imported service %s at %s.", name, listenTarget)
+	d.drainConnections("egress", name, sessions)
+	packetConn.Close()
+}
+
+// listenUDP binds a UDP socket to the given address. While the address is in use, such as by a previous
+// listener draining its sessions, binding is retried until signaled to end. Returns nil on failure.
+func (d *Dataplane) listenUDP(listenTarget string, end <-chan bool) net.PacketConn {
+	for {
+		packetConn, err := net.ListenPacket("udp", listenTarget)
+		if err == nil {
+			return packetConn
+		}
+
+		if !errors.Is(err, syscall.EADDRINUSE) {
+			d.logger.Errorf("Error listening to port: %v.", err)
+			return nil
+		}
+
+		d.logger.Errorf("Error listening to port, retrying in %v: %v.", udpBindRetryInterval, err)
+		select {
+		case <-end:
+			return nil
+		case <-time.After(udpBindRetryInterval):
+		}
+	}
+}
+
+// serveEgressConnections accepts connections to an imported service, until the listener is closed.
+// Accepted connections are admitted by the given limiter, and tracked by the given tracker until they end.
+func (d *Dataplane) serveEgressConnections(
+	name string,
+	listener net.Listener,
+	conns *connectionTracker,
+	limiter *connectionLimiter,
+) error {
 	for {
 		d.logger.Infof("Serving for imported service %s at %s.", name, listener.Addr())
 		conn, err := listener.Accept()
 		if err != nil {
-			d.logger.Error("Failed to accept egress connection", err)
+			if !errors.Is(err, net.ErrClosed) {
+				d.logger.Error("Failed to accept egress connection", err)
+			}
 			return err
 		}
 
@@ -56,55 +177,253 @@ func (d *Dataplane) serveEgressConnections(name string, listener net.Listener) e
 			"Received an egress connection at listener for imported service %s from %s.", name, conn.RemoteAddr().String())
 		d.logger.Debugf("Connection: %+v.", conn)
 
-		targetPeer, accessToken, err := d.getEgressAuth(name, strings.Split(conn.RemoteAddr().String(), ":")[0])
-		if err != nil {
-			d.logger.Infof("Failed egress authorization: %v.", err)
+		sourceIP := connectionSourceIP(conn)
+		if !d.acquireEgressConnection(name, sourceIP, limiter) {
 			conn.Close()
 			continue
 		}
-		d.logger.Infof("Received auth from controlplane: target peer: %s with %s", targetPeer, accessToken)
 
-		targetHost, err := d.GetClusterHost(targetPeer)
-		if err != nil {
-			d.logger.Errorf("Unable to get cluster host :%v.", err)
+		if !conns.add(conn) {
+			// the listener is draining
+			limiter.release(sourceIP)
 			conn.Close()
 			continue
 		}
-		tlsConfig := d.parsedCertData.ClientConfig(targetHost)
 
 		go func() {
-			err := d.initiateEgressConnection(targetPeer, accessToken, conn, tlsConfig)
-			if err != nil {
-				d.logger.Errorf("Failed to initiate egress connection: %v.", err)
-				conn.Close()
-			}
+			defer limiter.release(sourceIP)
+			defer conns.remove(conn)
+			d.serveEgressConnection(name, conn, false)
 		}()
 	}
 }
 
+// serveEgressConnection tunnels a connection to an imported service.
+// If udp is true, the connection is a UDP session, tunneled as datagrams.
+func (d *Dataplane) serveEgressConnection(name string, conn net.Conn, udp bool) {
+	var spiffeID string
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		id, err := d.authenticateWorkload(tlsConn)
+		if err != nil {
+			d.logger.Infof("Failed workload authentication: %v.", err)
+			conn.Close()
+			return
+		}
+
+		spiffeID = id
+	}
+
+	sourceIP := connectionSourceIP(conn)
+	targetPeer, accessToken, err := d.getEgressAuth(name, sourceIP, spiffeID, nil)
+	if err != nil {
+		d.logger.Infof("Failed egress authorization: %v.", err)
+		conn.Close()
+		return
+	}
+	d.logger.Infof("Received auth from controlplane: target peer: %s with %s", targetPeer, accessToken)
+
+	targetHost, err := d.GetClusterHost(targetPeer)
+	if err != nil {
+		d.logger.Errorf("Unable to get cluster host :%v.", err)
+		conn.Close()
+		return
+	}
+	tlsConfig := d.parsedCertData.ClientConfig(targetHost)
+
+	tracked := newConnection(
+		event.Outgoing, sourceIP, name, strings.TrimPrefix(targetPeer, api.RemotePeerClusterPrefix))
+	err = d.initiateEgressConnection(targetPeer, accessToken, conn, tlsConfig, tracked, udp)
+	if err != nil {
+		d.logger.Errorf("Failed to initiate egress connection: %v.", err)
+		conn.Close()
+	}
+}
+
+// connectionSourceIP returns the source IP of a client connection.
+func connectionSourceIP(conn net.Conn) string {
+	host, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+	if err != nil {
+		return conn.RemoteAddr().String()
+	}
+
+	return host
+}
+
+// acquireEgressConnection admits a new client connection to an imported service, unless it exceeds the limits.
+// An admitted connection must be released once it ends.
+func (d *Dataplane) acquireEgressConnection(name, sourceIP string, limiter *connectionLimiter) bool {
+	reason, ok := limiter.acquire(sourceIP)
+	if !ok {
+		d.logger.Infof("Rejecting a connection from %s to imported service %s: %s.", sourceIP, name, reason)
+		d.metrics.ConnectionRejected("egress", name, reason)
+	}
+
+	return ok
+}
+
+// authenticateWorkload completes the TLS handshake of a workload connection, and returns the workload SPIFFE ID.
+func (d *Dataplane) authenticateWorkload(conn *tls.Conn) (string, error) {
+	if err := conn.SetDeadline(time.Now().Add(workloadHandshakeTimeout)); err != nil {
+		return "", err
+	}
+
+	if err := conn.Handshake(); err != nil {
+		return "", fmt.Errorf("TLS handshake failed: %w", err)
+	}
+
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		return "", err
+	}
+
+	state := conn.ConnectionState()
+	id, err := spiffe.PeerID(&state)
+	if err != nil {
+		return "", err
+	}
+
+	return id.String(), nil
+}
+
 // getEgressAuth returns the target cluster and authorization token for the outgoing connection.
-func (d *Dataplane) getEgressAuth(name, sourceIP string) (string, string, error) { //nolint:gocritic // unnamedResult
-	url := "https://" + d.controlplaneTarget + api.DataplaneEgressAuthorizationPath
-	egressAuthReq, err := http.NewRequest(http.MethodPost, url, http.NoBody)
+// For a request of an HTTP import, the request attributes are given, and the token authorizes only this request.
+// Cached authorization results are reused, and stale results are re-authorized in the background.
+// If the controlplane is unavailable while re-authorizing, a stale result is reused, as allowed by the import.
+func (d *Dataplane) getEgressAuth( //nolint:gocritic // unnamedResult
+	name, sourceIP, spiffeID string,
+	httpRequest *httpRequestInfo,
+) (string, string, error) {
+	key := newAuthorizationKey(name, sourceIP, spiffeID, httpRequest)
+	now := time.Now()
+
+	auth, state := d.authzCache.get(key, now)
+	switch state {
+	case cacheHit:
+		d.logger.Debugf("Using cached egress authorization of %s for %s.", sourceIP, name)
+		return auth.targetCluster, auth.accessToken, nil
+	case cacheRevalidate:
+		d.logger.Debugf("Re-authorizing stale egress authorization of %s for %s.", sourceIP, name)
+		go func() {
+			_, _ = d.authorizeEgress(key, name, sourceIP, spiffeID, httpRequest)
+		}()
+		return auth.targetCluster, auth.accessToken, nil
+	}
+
+	auth, err := d.authorizeEgress(key, name, sourceIP, spiffeID, httpRequest)
 	if err != nil {
+		// past its stale-while-revalidate window, a stale result is only reused if the controlplane is unavailable
+		if stale, ok := d.authzCache.getIfError(key, time.Now()); ok {
+			d.logger.Warnf("Using stale egress authorization of %s for %s: %v.", sourceIP, name, err)
+			return stale.targetCluster, stale.accessToken, nil
+		}
+
 		return "", "", err
 	}
+
+	return auth.targetCluster, auth.accessToken, nil
+}
+
+// authorizeEgress authorizes an outgoing connection (or HTTP request) with the controlplane,
+// and caches the result.
+func (d *Dataplane) authorizeEgress(
+	key authorizationKey,
+	name, sourceIP, spiffeID string,
+	httpRequest *httpRequestInfo,
+) (authorization, error) {
+	requested := time.Now()
+	auth, cacheControl, err := d.requestEgressAuth(name, sourceIP, spiffeID, httpRequest)
+	if err != nil {
+		d.authzCache.fail(key, time.Now(), controlplaneUnavailable(err))
+		return authorization{}, err
+	}
+
+	d.authzCache.set(key, auth, cacheControl, requested)
+	return auth, nil
+}
+
+// controlplaneUnavailable returns true if an egress authorization failed since the controlplane is unavailable,
+// rather than since it did not authorize the connection.
+func controlplaneUnavailable(err error) bool {
+	var authzErr *egressAuthorizationError
+	if errors.As(err, &authzErr) {
+		return authzErr.statusCode >= http.StatusInternalServerError
+	}
+
+	return true
+}
+
+// requestEgressAuth requests the controlplane to authorize an outgoing connection (or HTTP request).
+// Returns the authorization, and its Cache-Control directives.
+func (d *Dataplane) requestEgressAuth(
+	name, sourceIP, spiffeID string,
+	httpRequest *httpRequestInfo,
+) (authorization, string, error) {
+	method := http.MethodPost
+	url := "https://" + d.controlplaneTarget + api.DataplaneEgressAuthorizationPath
+	authorizationHeader := api.AuthorizationHeader
+	if httpRequest != nil {
+		method = httpRequest.method
+		url += strings.TrimPrefix(httpRequest.path, "/")
+		authorizationHeader = api.HTTPAuthorizationHeader
+	}
+
+	egressAuthReq, err := http.NewRequest(method, url, http.NoBody)
+	if err != nil {
+		return authorization{}, "", err
+	}
 	egressAuthReq.Close = true
 
-	components := strings.SplitN(name, "/", 2)
+	importNamespace, importName, importPort := parseImportListenerName(name)
 
 	egressAuthReq.Header.Add(api.ClientIPHeader, sourceIP)
-	egressAuthReq.Header.Add(api.ImportNamespaceHeader, components[0])
-	egressAuthReq.Header.Add(api.ImportNameHeader, components[1])
+	if spiffeID != "" {
+		egressAuthReq.Header.Add(api.ClientSPIFFEIDHeader, spiffeID)
+	}
+	egressAuthReq.Header.Add(api.ImportNamespaceHeader, importNamespace)
+	egressAuthReq.Header.Add(api.ImportNameHeader, importName)
+	if importPort != "" {
+		egressAuthReq.Header.Add(api.ImportPortHeader, importPort)
+	}
+	if httpRequest != nil {
+		egressAuthReq.Header.Add(api.HTTPModeHeader, "true")
+		if httpRequest.route != "" {
+			egressAuthReq.Header.Add(api.HTTPRouteHeader, httpRequest.route)
+		}
+	}
 	egressAuthResp, err := d.apiClient.Do(egressAuthReq)
 	if err != nil {
 		d.logger.Errorf("Unable to send auth/egress request: %v.", err)
-		return "", "", err
+		return authorization{}, "", err
 	}
 	defer egressAuthResp.Body.Close()
 	if egressAuthResp.StatusCode != http.StatusOK {
 		d.logger.Infof("Failed to obtain egress authorization: %s", egressAuthResp.Status)
-		return "", "", fmt.Errorf("failed egress authorization:%s", egressAuthResp.Status)
+		if egressAuthResp.StatusCode == http.StatusTooManyRequests {
+			d.metrics.ConnectionRejected("egress", name, clientRateLimitReason)
+		}
+		return authorization{}, "", &egressAuthorizationError{
+			statusCode: egressAuthResp.StatusCode,
+			status:     egressAuthResp.Status,
+		}
+	}
+
+	auth := authorization{
+		targetCluster: egressAuthResp.Header.Get(api.TargetClusterHeader),
+		accessToken:   egressAuthResp.Header.Get(authorizationHeader),
+	}
+	return auth, egressAuthResp.Header.Get(api.AuthorizationCacheHeader), nil
+}
+
+// parseImportListenerName parses a listener name (without the import listener prefix)
+// to the namespace, name and port name (for multi-port imports) of the imported service.
+func parseImportListenerName(listenerName string) (namespace, name, port string) {
+	components := strings.SplitN(listenerName, "/", 3)
+	if len(components) < 2 {
+		return "", listenerName, ""
 	}
-	return egressAuthResp.Header.Get(api.TargetClusterHeader), egressAuthResp.Header.Get(api.AuthorizationHeader), nil
+
+	if len(components) == 3 {
+		port = components[2]
+	}
+
+	return components[0], components[1], port
 }
@@ -0,0 +1,369 @@
+// Copyright 2023 The ClusterLink Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"fmt"
+	"maps"
+	"math/rand"
+	"net"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+
+	"github.com/clusterlink-net/clusterlink/pkg/controlplane/api"
+)
+
+// routingTable is an immutable snapshot of the routing state of the dataplane, as received from the controlplane.
+// A new snapshot is built on every xDS update, and is atomically swapped with the previous one,
+// so that connection handlers observe a consistent state without locking.
+type routingTable struct {
+	// clusters maps cluster names to clusters (of remote peers and exported services).
+	clusters map[string]*cluster.Cluster
+	// endpoints maps cluster names to the endpoints of clusters using endpoint discovery (EDS).
+	endpoints map[string]*endpoint.ClusterLoadAssignment
+	// listeners maps listener names (without the import listener prefix) to listeners of imported services.
+	listeners map[string]*listener.Listener
+	// exportLimiters maps cluster names of exported services to their connection limiters.
+	exportLimiters map[string]*connectionLimiter
+}
+
+// endpoint returns an endpoint of a cluster.
+// For clusters using endpoint discovery, a random endpoint is selected. Otherwise, the first endpoint is returned.
+func (t *routingTable) endpoint(name string) (*endpoint.Endpoint, error) {
+	c, ok := t.clusters[name]
+	if !ok {
+		return nil, fmt.Errorf("unable to find %s in cluster map", name)
+	}
+
+	if c.GetType() != cluster.Cluster_EDS {
+		localities := c.GetLoadAssignment().GetEndpoints()
+		if len(localities) == 0 || len(localities[0].LbEndpoints) == 0 {
+			return nil, fmt.Errorf("cluster %s has no endpoints", name)
+		}
+
+		return localities[0].LbEndpoints[0].GetEndpoint(), nil
+	}
+
+	edsName := c.GetEdsClusterConfig().GetServiceName()
+	if edsName == "" {
+		edsName = name
+	}
+
+	var lbEndpoints []*endpoint.LbEndpoint
+	for _, locality := range t.endpoints[edsName].GetEndpoints() {
+		lbEndpoints = append(lbEndpoints, locality.LbEndpoints...)
+	}
+	if len(lbEndpoints) == 0 {
+		return nil, fmt.Errorf("cluster %s has no endpoints", name)
+	}
+
+	return lbEndpoints[rand.Intn(len(lbEndpoints))].GetEndpoint(), nil //nolint:gosec // not used for security
+}
+
+// newRoutingTable returns an empty routing table.
+func newRoutingTable() *routingTable {
+	return &routingTable{
+		clusters:       make(map[string]*cluster.Cluster),
+		endpoints:      make(map[string]*endpoint.ClusterLoadAssignment),
+		listeners:      make(map[string]*listener.Listener),
+		exportLimiters: make(map[string]*connectionLimiter),
+	}
+}
+
+// routingTable returns the current routing table snapshot.
+func (d *Dataplane) routingTable() *routingTable {
+	return d.routes.Load()
+}
+
+// GetClusterTarget returns the cluster address:port from the cluster map.
+func (d *Dataplane) GetClusterTarget(name string) (string, error) {
+	ep, err := d.routingTable().endpoint(name)
+	if err != nil {
+		return "", err
+	}
+
+	address := ep.GetAddress().GetSocketAddress()
+	return net.JoinHostPort(address.GetAddress(), strconv.Itoa(int(address.GetPortValue()))), nil
+}
+
+// GetClusterHost returns the cluster hostname after trimming ":".
+func (d *Dataplane) GetClusterHost(name string) (string, error) {
+	ep, err := d.routingTable().endpoint(name)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.Split(ep.Hostname, ":")[0], nil
+}
+
+// UpdateClusters replaces the clusters of the routing table with the given clusters.
+// Tunnels to removed clusters are drained.
+func (d *Dataplane) UpdateClusters(clusters []*cluster.Cluster) {
+	d.updateLock.Lock()
+	defer d.updateLock.Unlock()
+
+	names := make([]string, len(clusters))
+	for i, c := range clusters {
+		names[i] = c.Name
+	}
+
+	d.updateClusters(clusters, removedNames(d.routingTable().clusters, names))
+}
+
+// UpdateClustersDelta adds or updates the given clusters in the routing table,
+// and removes the clusters with the given names. Tunnels to removed clusters are drained.
+func (d *Dataplane) UpdateClustersDelta(updated []*cluster.Cluster, removed []string) {
+	d.updateLock.Lock()
+	defer d.updateLock.Unlock()
+
+	d.updateClusters(updated, removed)
+}
+
+// updateClusters adds, updates and removes clusters of the routing table.
+// Must be called while holding the update lock.
+func (d *Dataplane) updateClusters(updated []*cluster.Cluster, removed []string) {
+	previous := d.routingTable()
+	table := &routingTable{
+		clusters:       maps.Clone(previous.clusters),
+		endpoints:      previous.endpoints,
+		listeners:      previous.listeners,
+		exportLimiters: maps.Clone(previous.exportLimiters),
+	}
+
+	for _, name := range removed {
+		delete(table.clusters, name)
+		delete(table.exportLimiters, name)
+	}
+
+	for _, c := range updated {
+		table.clusters[c.Name] = c
+		if !strings.HasPrefix(c.Name, api.ExportClusterPrefix) {
+			continue
+		}
+
+		// limiters are kept across updates, tracking the connections admitted by previous snapshots
+		limiter, ok := table.exportLimiters[c.Name]
+		if !ok {
+			limiter = newConnectionLimiter()
+		}
+		limiter.setLimits(parseExportClusterLimits(c))
+		table.exportLimiters[c.Name] = limiter
+	}
+
+	d.routes.Store(table)
+
+	for _, name := range removed {
+		_, existed := previous.clusters[name]
+		if _, ok := table.clusters[name]; existed && !ok {
+			d.logger.Debugf("Removed cluster: %s.", name)
+			d.drainTunnelPool(name)
+		}
+	}
+}
+
+// UpdateEndpoints replaces the endpoints of the routing table with the given endpoints,
+// of clusters using endpoint discovery.
+func (d *Dataplane) UpdateEndpoints(assignments []*endpoint.ClusterLoadAssignment) {
+	d.updateLock.Lock()
+	defer d.updateLock.Unlock()
+
+	names := make([]string, len(assignments))
+	for i, cla := range assignments {
+		names[i] = cla.ClusterName
+	}
+
+	d.updateEndpoints(assignments, removedNames(d.routingTable().endpoints, names))
+}
+
+// UpdateEndpointsDelta adds or updates the given endpoints in the routing table,
+// and removes the endpoints of the clusters with the given names.
+func (d *Dataplane) UpdateEndpointsDelta(updated []*endpoint.ClusterLoadAssignment, removed []string) {
+	d.updateLock.Lock()
+	defer d.updateLock.Unlock()
+
+	d.updateEndpoints(updated, removed)
+}
+
+// updateEndpoints adds, updates and removes endpoints of the routing table.
+// Must be called while holding the update lock.
+func (d *Dataplane) updateEndpoints(updated []*endpoint.ClusterLoadAssignment, removed []string) {
+	previous := d.routingTable()
+	table := &routingTable{
+		clusters:       previous.clusters,
+		endpoints:      maps.Clone(previous.endpoints),
+		listeners:      previous.listeners,
+		exportLimiters: previous.exportLimiters,
+	}
+
+	for _, name := range removed {
+		delete(table.endpoints, name)
+	}
+
+	for _, cla := range updated {
+		table.endpoints[cla.ClusterName] = cla
+	}
+
+	d.routes.Store(table)
+}
+
+// UpdateListeners replaces the listeners of the routing table with the given listeners.
+// New listeners are started, and removed listeners are ended. Listeners whose address, protocol,
+// workload mTLS or HTTP routes changed are restarted.
+func (d *Dataplane) UpdateListeners(listeners []*listener.Listener) {
+	d.updateLock.Lock()
+	defer d.updateLock.Unlock()
+
+	names := make([]string, len(listeners))
+	for i, ln := range listeners {
+		names[i] = strings.TrimPrefix(ln.Name, api.ImportListenerPrefix)
+	}
+
+	d.updateListeners(listeners, removedNames(d.routingTable().listeners, names))
+}
+
+// UpdateListenersDelta adds or updates the given listeners in the routing table,
+// and removes the listeners with the given names. Listeners are started, restarted and ended
+// as in UpdateListeners.
+func (d *Dataplane) UpdateListenersDelta(updated []*listener.Listener, removed []string) {
+	d.updateLock.Lock()
+	defer d.updateLock.Unlock()
+
+	names := make([]string, len(removed))
+	for i, name := range removed {
+		names[i] = strings.TrimPrefix(name, api.ImportListenerPrefix)
+	}
+
+	d.updateListeners(updated, names)
+}
+
+// updateListeners adds, updates and removes listeners of the routing table,
+// given the names of the removed listeners without the import listener prefix.
+// Must be called while holding the update lock.
+func (d *Dataplane) updateListeners(updated []*listener.Listener, removed []string) {
+	previous := d.routingTable()
+	table := &routingTable{
+		clusters:       previous.clusters,
+		endpoints:      previous.endpoints,
+		listeners:      maps.Clone(previous.listeners),
+		exportLimiters: previous.exportLimiters,
+	}
+
+	for _, ln := range updated {
+		name := strings.TrimPrefix(ln.Name, api.ImportListenerPrefix)
+		table.listeners[name] = ln
+		d.updateListener(name, ln, previous.listeners[name])
+	}
+
+	for _, name := range removed {
+		if _, ok := table.listeners[name]; ok {
+			d.logger.Debugf("Removed listener: %s.", name)
+			delete(table.listeners, name)
+			d.endListener(name)
+			d.authzCache.deleteListener(name)
+		}
+	}
+
+	d.routes.Store(table)
+}
+
+// removedNames returns the names of the given routing table entries which are missing from the given names.
+func removedNames[T any](entries map[string]T, names []string) []string {
+	keep := make(map[string]bool, len(names))
+	for _, name := range names {
+		keep[name] = true
+	}
+
+	var removed []string
+	for name := range entries {
+		if !keep[name] {
+			removed = append(removed, name)
+		}
+	}
+
+	return removed
+}
+
+// updateListener starts a listener, or restarts it if it changed from the given previous listener.
+// Must be called while holding the update lock.
+func (d *Dataplane) updateListener(name string, ln, previous *listener.Listener) {
+	httpRoutes, _ := parseHTTPRoutes(ln)
+
+	// connection limits are updated without restarting the listener
+	limiter, ok := d.listenerLimiters[name]
+	if !ok {
+		limiter = newConnectionLimiter()
+		d.listenerLimiters[name] = limiter
+	}
+	limiter.setLimits(parseListenerLimits(ln))
+
+	addresses := listenerAddresses(ln)
+	if previous != nil {
+		// Check if there is an update to the listener addresses/port/protocol, workload mTLS or HTTP routes
+		previousHTTPRoutes, _ := parseHTTPRoutes(previous)
+		if slices.Equal(addresses, listenerAddresses(previous)) &&
+			ln.Address.GetSocketAddress().GetPortValue() == previous.Address.GetSocketAddress().GetPortValue() &&
+			isUDPListener(ln) == isUDPListener(previous) &&
+			requiresWorkloadMTLS(ln) == requiresWorkloadMTLS(previous) &&
+			reflect.DeepEqual(httpRoutes, previousHTTPRoutes) {
+			return
+		}
+		// the previous listener stops accepting, and its connections are drained
+		close(d.listenerEnd[name])
+	}
+
+	// closed to end the listener on all of its addresses
+	end := make(chan bool)
+	d.listenerEnd[name] = end
+
+	for _, address := range addresses {
+		d.activeListeners.Add(1)
+		go func(address string) {
+			defer d.activeListeners.Done()
+			d.CreateListener(name,
+				address,
+				ln.Address.GetSocketAddress().GetPortValue(),
+				requiresWorkloadMTLS(ln),
+				isUDPListener(ln),
+				httpRoutes,
+				limiter,
+				end)
+		}(address)
+	}
+}
+
+// listenerAddresses returns the IP addresses a listener is bound to.
+func listenerAddresses(ln *listener.Listener) []string {
+	addresses := []string{ln.GetAddress().GetSocketAddress().GetAddress()}
+	for _, additional := range ln.GetAdditionalAddresses() {
+		addresses = append(addresses, additional.GetAddress().GetSocketAddress().GetAddress())
+	}
+
+	return addresses
+}
+
+// endListener ends a listener. The listener stops accepting connections, and its active connections are drained.
+// Must be called while holding the update lock.
+func (d *Dataplane) endListener(name string) {
+	delete(d.listenerLimiters, name)
+	if end, ok := d.listenerEnd[name]; ok {
+		delete(d.listenerEnd, name)
+		close(end)
+	}
+}
@@ -0,0 +1,286 @@
+// Copyright 2023 The ClusterLink Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/clusterlink-net/clusterlink/pkg/controlplane/api"
+	utiltls "github.com/clusterlink-net/clusterlink/pkg/util/tls"
+)
+
+const (
+	testExportCluster = api.ExportClusterPrefix + "default/echo"
+	testChurnRounds   = 200
+)
+
+// echoServer starts a TCP server echoing back the data of its connections.
+func echoServer(t *testing.T) net.Listener {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+				_, _ = io.Copy(conn, conn)
+			}()
+		}
+	}()
+
+	return ln
+}
+
+// testDataplane returns a dataplane serving ingress connections, whose controlplane authorizes
+// every connection to the test export cluster.
+func testDataplane(t *testing.T) (*Dataplane, *httptest.Server) {
+	t.Helper()
+
+	controlplane := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set(api.TargetClusterHeader, testExportCluster)
+	}))
+	t.Cleanup(controlplane.Close)
+
+	d := NewDataplane("dataplane", controlplane.Listener.Addr().String(), "peer", &utiltls.ParsedCertData{})
+	d.apiClient = controlplane.Client()
+	d.SetDrainTimeout(10 * time.Millisecond)
+
+	server := httptest.NewServer(d.router)
+	t.Cleanup(server.Close)
+
+	return d, server
+}
+
+// exportCluster returns an export cluster targeting the given address,
+// limiting the concurrent connections of each remote peer to maxConnections.
+func exportCluster(addr *net.TCPAddr, maxConnections uint32) *cluster.Cluster {
+	return &cluster.Cluster{
+		Name: testExportCluster,
+		LoadAssignment: &endpoint.ClusterLoadAssignment{
+			Endpoints: []*endpoint.LocalityLbEndpoints{{
+				LbEndpoints: []*endpoint.LbEndpoint{{
+					HostIdentifier: &endpoint.LbEndpoint_Endpoint{
+						Endpoint: &endpoint.Endpoint{
+							Address: &core.Address{
+								Address: &core.Address_SocketAddress{
+									SocketAddress: &core.SocketAddress{
+										Address:       addr.IP.String(),
+										PortSpecifier: &core.SocketAddress_PortValue{PortValue: uint32(addr.Port)},
+									},
+								},
+							},
+							Hostname: "echo:443",
+						},
+					},
+				}},
+			}},
+		},
+		CircuitBreakers: &cluster.CircuitBreakers{
+			Thresholds: []*cluster.CircuitBreakers_Thresholds{{
+				MaxConnections: wrapperspb.UInt32(maxConnections),
+			}},
+		},
+	}
+}
+
+// importListener returns a TCP listener of an imported service, listening on an ephemeral port of the given address.
+func importListener(name, address string) *listener.Listener {
+	return &listener.Listener{
+		Name: api.ImportListenerPrefix + name,
+		Address: &core.Address{
+			Address: &core.Address_SocketAddress{
+				SocketAddress: &core.SocketAddress{
+					Address:       address,
+					PortSpecifier: &core.SocketAddress_PortValue{PortValue: 0},
+				},
+			},
+		},
+	}
+}
+
+// tunnelEcho opens an ingress tunnel through the dataplane server, and verifies data is echoed back.
+// Returns false if the tunnel was rejected.
+func tunnelEcho(server *httptest.Server) (bool, error) {
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	if _, err := fmt.Fprintf(conn, "POST / HTTP/1.1\r\nHost: echo\r\n%s: Bearer token\r\n\r\n",
+		api.AuthorizationHeader); err != nil {
+		return false, err
+	}
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		return false, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return false, nil
+	}
+
+	if _, err := conn.Write([]byte("ping")); err != nil {
+		return false, err
+	}
+
+	data := make([]byte, 4)
+	if _, err := io.ReadFull(reader, data); err != nil {
+		return false, err
+	}
+	if string(data) != "ping" {
+		return false, fmt.Errorf("unexpected echo: %s", data)
+	}
+
+	return true, nil
+}
+
+func TestRoutingTableChurn(t *testing.T) {
+	backend := echoServer(t)
+	d, server := testDataplane(t)
+	addr := backend.Addr().(*net.TCPAddr)
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+
+	// cluster churn
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < testChurnRounds; i++ {
+			d.UpdateClusters([]*cluster.Cluster{exportCluster(addr, uint32(i%3))})
+			d.UpdateClusters(nil)
+		}
+	}()
+
+	// listener churn, restarting listeners by changing their address
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < testChurnRounds; i++ {
+			d.UpdateListeners([]*listener.Listener{
+				importListener("default/a", "127.0.0.1"),
+				importListener("default/b", fmt.Sprintf("127.0.0.%d", i%2+1)),
+			})
+			if i%10 == 0 {
+				d.UpdateListeners(nil)
+			}
+		}
+	}()
+
+	// connections set up during the churn
+	errs := make(chan error, 4)
+	for i := 0; i < 4; i++ {
+		go func() {
+			for {
+				select {
+				case <-done:
+					errs <- nil
+					return
+				default:
+				}
+
+				if _, err := tunnelEcho(server); err != nil {
+					errs <- err
+					return
+				}
+				_, _ = d.GetClusterHost(testExportCluster)
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(done)
+	for i := 0; i < 4; i++ {
+		require.NoError(t, <-errs)
+	}
+
+	// the final routing table is used once the churn ends
+	d.UpdateClusters([]*cluster.Cluster{exportCluster(addr, 0)})
+	ok, err := tunnelEcho(server)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	host, err := d.GetClusterHost(testExportCluster)
+	require.NoError(t, err)
+	require.Equal(t, "echo", host)
+
+	// IPv6 endpoint addresses are bracketed
+	d.UpdateClusters([]*cluster.Cluster{exportCluster(&net.TCPAddr{IP: net.ParseIP("fd00::1"), Port: 8080}, 0)})
+	target, err := d.GetClusterTarget(testExportCluster)
+	require.NoError(t, err)
+	require.Equal(t, "[fd00::1]:8080", target)
+
+	d.UpdateClusters(nil)
+	_, err = d.GetClusterTarget(testExportCluster)
+	require.Error(t, err)
+
+	d.drain()
+	require.Empty(t, d.routingTable().listeners)
+	require.Empty(t, d.listenerEnd)
+}
+
+func TestRoutingTableExportLimits(t *testing.T) {
+	backend := echoServer(t)
+	d, server := testDataplane(t)
+	d.UpdateClusters([]*cluster.Cluster{exportCluster(backend.Addr().(*net.TCPAddr), 1)})
+
+	release, ok := d.acquireExportConnection(testExportCluster, "")
+	require.True(t, ok)
+
+	// the limit applies to the connections of each remote peer
+	otherRelease, ok := d.acquireExportConnection(testExportCluster, "other-peer")
+	require.True(t, ok)
+	otherRelease()
+
+	// the limit of the (unknown) peer is reached
+	ok, err := tunnelEcho(server)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	// limiters are kept across updates of the routing table
+	d.UpdateClusters([]*cluster.Cluster{exportCluster(backend.Addr().(*net.TCPAddr), 1)})
+	ok, err = tunnelEcho(server)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	release()
+	ok, err = tunnelEcho(server)
+	require.NoError(t, err)
+	require.True(t, ok)
+}
@@ -14,193 +14,325 @@
 package server
 
 import (
+	"bufio"
+	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
-	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	cpapi "github.com/clusterlink-net/clusterlink/pkg/controlplane/api"
+	event "github.com/clusterlink-net/clusterlink/pkg/controlplane/eventmanager"
 	"github.com/clusterlink-net/clusterlink/pkg/dataplane/api"
 	"github.com/clusterlink-net/clusterlink/pkg/util/sniproxy"
+	"github.com/clusterlink-net/clusterlink/pkg/util/tcp"
 )
 
 const (
 	httpSchemaPrefix = "https://"
+
+	// tunnelResponse is the response accepting a tunnel over a dedicated HTTP/1.1 connection.
+	tunnelResponse = "HTTP/1.1 200 OK\r\nContent-Type: text/plain\r\n\r\n"
+	// udpTunnelResponse is the response accepting a CONNECT-UDP tunnel, carrying datagrams as capsules.
+	udpTunnelResponse = "HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\n" +
+		"Upgrade: " + api.UDPTunnelProtocol + "\r\nCapsule-Protocol: ?1\r\n\r\n"
 )
 
-// StartDataplaneServer starts the Dataplane server.
-func (d *Dataplane) StartDataplaneServer(dataplaneServerAddress string) error {
-	d.logger.Infof("Dataplane server starting at %s.", dataplaneServerAddress)
-	server := &http.Server{
-		Addr:              dataplaneServerAddress,
-		Handler:           d.router,
-		ReadHeaderTimeout: 2 * time.Second,
-		ReadTimeout:       time.Duration(0), // use header timeout only
-		WriteTimeout:      2 * time.Second,
-		IdleTimeout:       120 * time.Second,
-		MaxHeaderBytes:    10 * 1024,
-		TLSConfig:         d.parsedCertData.ServerConfig(),
-	}
-
-	return server.ListenAndServeTLS("", "")
+// Server is the dataplane HTTP server, accepting ingress dataplane connections.
+// A graceful stop of the server also drains the import listeners and the connections of the dataplane.
+type Server struct {
+	tcp.Listener
+
+	dataplane *Dataplane
+	server    *http.Server
+	stopped   chan struct{}
+	stopOnce  sync.Once
 }
 
-// StartSNIServer starts the SNI Proxy in the dataplane.
-func (d *Dataplane) StartSNIServer(dataplaneServerAddress string) error {
-	dataplaneListenAddress := ":" + strconv.Itoa(api.ListenPort)
-	sniProxy := sniproxy.NewServer(map[string]string{
-		d.peerName:                          d.controlplaneTarget,
-		api.DataplaneServerName(d.peerName): dataplaneServerAddress,
+// Start the server.
+// Once the server is closed, returns only after the server is stopped.
+func (s *Server) Start() error {
+	s.dataplane.logger.Infof("Dataplane server starting at %s.", s.GetAddress())
+	err := s.server.ServeTLS(s.GetListener(), "", "")
+	if errors.Is(err, http.ErrServerClosed) {
+		<-s.stopped
+		return nil
+	}
+	return err
+}
+
+// stop marks the server as stopped.
+func (s *Server) stop() {
+	s.stopOnce.Do(func() {
+		close(s.stopped)
 	})
+}
 
-	d.logger.Infof("SNI proxy starting at %s.", dataplaneListenAddress)
-	err := sniProxy.Listen(dataplaneListenAddress)
-	if err != nil {
-		return fmt.Errorf("unable to create listener for server on %s: %w",
-			dataplaneListenAddress, err)
+// Stop the server.
+func (s *Server) Stop() error {
+	defer s.stop()
+	return s.server.Close()
+}
+
+// GracefulStop does a graceful stop of the server.
+// The server and the import listeners stop accepting, and active connections are drained.
+func (s *Server) GracefulStop() error {
+	defer s.stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.dataplane.drainTimeout)
+	defer cancel()
+
+	shutdown := make(chan error, 1)
+	go func() {
+		shutdown <- s.server.Shutdown(ctx)
+	}()
+
+	s.dataplane.drain()
+
+	if err := <-shutdown; err != nil {
+		// requests still active at the drain deadline are closed
+		return s.server.Close()
+	}
+	return nil
+}
+
+// NewServer returns a new dataplane server.
+func NewServer(dataplane *Dataplane) *Server {
+	return &Server{
+		Listener:  tcp.NewListener("dataplane-server"),
+		dataplane: dataplane,
+		server: &http.Server{
+			Handler:           dataplane.router,
+			ReadHeaderTimeout: 2 * time.Second,
+			ReadTimeout:       time.Duration(0), // use header timeout only
+			WriteTimeout:      2 * time.Second,
+			IdleTimeout:       120 * time.Second,
+			MaxHeaderBytes:    10 * 1024,
+			TLSConfig:         dataplane.parsedCertData.ServerConfig(),
+		},
+		stopped: make(chan struct{}),
 	}
-	return sniProxy.Start()
+}
+
+// NewSNIProxy returns the SNI Proxy of the dataplane, routing connections to the controlplane
+// and to the dataplane server.
+func (d *Dataplane) NewSNIProxy(dataplaneServerAddress string) *sniproxy.Server {
+	return sniproxy.NewServer(map[string]string{
+		d.peerName:                          d.controlplaneTarget,
+		api.DataplaneServerName(d.peerName): dataplaneServerAddress,
+	})
 }
 
 func (d *Dataplane) addAuthzHandlers() {
+	// requests of HTTP services are served on any path
+	d.router.Use(d.serveIngressHTTP)
 	d.router.Post("/", d.dataplaneIngressAuthorize)
+	d.router.Get(api.UDPTunnelPathPrefix+"*", d.dataplaneIngressAuthorize)
 }
 
 func (d *Dataplane) dataplaneIngressAuthorize(w http.ResponseWriter, r *http.Request) {
-	forwardingURL := httpSchemaPrefix + d.controlplaneTarget + cpapi.DataplaneIngressAuthorizationPath
-
-	forwardingReq, err := http.NewRequest(r.Method, forwardingURL, r.Body)
-	if err != nil {
-		d.logger.Error("Forwarding error in NewRequest", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	udp := strings.HasPrefix(r.URL.Path, api.UDPTunnelPathPrefix)
+	if udp && !strings.EqualFold(r.Header.Get("Upgrade"), api.UDPTunnelProtocol) {
+		http.Error(w, "expected an upgrade to "+api.UDPTunnelProtocol, http.StatusBadRequest)
 		return
 	}
-	forwardingReq.ContentLength = r.ContentLength
-	for key, values := range r.Header {
-		for _, value := range values {
-			forwardingReq.Header.Add(key, value)
-		}
-	}
 
-	resp, err := d.apiClient.Do(forwardingReq)
+	// the tunnel path is appended to the authorization path, determining the protocol of the connection
+	path := cpapi.DataplaneIngressAuthorizationPath + strings.TrimPrefix(r.URL.EscapedPath(), "/")
+	targetCluster, err := d.getIngressAuth(r.Method, path, r.Header)
 	if err != nil {
-		d.logger.Error("Forwarding error in sending operation", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		d.logger.Infof("Failed to obtain ingress authorization: %s.", resp.Status)
+	serviceTarget, err := d.GetClusterTarget(targetCluster)
+	if err != nil {
+		d.logger.Errorf("Unable to get cluster target: %v.", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	d.logger.Infof("Got authorization to use service: %s.", resp.Header.Get(cpapi.TargetClusterHeader))
-
-	serviceTarget, err := d.GetClusterTarget(resp.Header.Get(cpapi.TargetClusterHeader))
-	if err != nil {
-		d.logger.Errorf("Unable to get cluster target: %v.", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	release, ok := d.acquireExportConnection(targetCluster, requestPeer(r))
+	if !ok {
+		http.Error(w, "connection limit exceeded", http.StatusServiceUnavailable)
 		return
 	}
+	defer release()
 
 	d.logger.Infof("Initiating connection with %s.", serviceTarget)
 
-	appConn, err := net.DialTimeout("tcp", serviceTarget, time.Second)
+	network := "tcp"
+	if udp {
+		network = "udp"
+	}
+
+	appConn, err := net.DialTimeout(network, serviceTarget, time.Second)
 	if err != nil {
 		d.logger.Errorf("Dial to export service failed: %v.", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if udp {
+		// UDP has no end of session, so the tunnel ends once no datagrams are exchanged
+		appConn = newIdleConn(appConn, udpSessionIdleTimeout)
+	}
 
-	// hijack connection
-	peerConn, err := d.hijackConn(w)
+	// HTTP/1.1 tunnels take over the connection, while HTTP/2 tunnels are carried by the request stream
+	var peerConn net.Conn
+	switch {
+	case udp:
+		var reader *bufio.Reader
+		peerConn, reader, err = d.hijackConn(w, udpTunnelResponse)
+		if err == nil {
+			peerConn = newDatagramConn(peerConn, reader)
+		}
+	case r.ProtoMajor == 1:
+		peerConn, _, err = d.hijackConn(w, tunnelResponse)
+	default:
+		peerConn, err = acceptStreamTunnel(w, r)
+	}
 	if err != nil {
-		d.logger.Errorf("Hijacking failed: %v.", err)
-		http.Error(w, "hijacking failed", http.StatusInternalServerError)
+		d.logger.Errorf("Accepting tunnel failed: %v.", err)
+		http.Error(w, "accepting tunnel failed", http.StatusInternalServerError)
 		appConn.Close()
 		return
 	}
 
-	forward := newForwarder(appConn, peerConn)
-	forward.run()
+	if !d.ingressConns.add(appConn) {
+		// the dataplane is draining
+		appConn.Close()
+		peerConn.Close()
+		return
+	}
+	defer d.ingressConns.remove(appConn)
+
+	conn := newConnection(
+		event.Incoming, "", strings.TrimPrefix(targetCluster, cpapi.ExportClusterPrefix), requestPeer(r))
+	d.runForwarder(newForwarder(appConn, peerConn), conn)
+}
+
+// getIngressAuth forwards an ingress authorization request to the controlplane, with the given method,
+// path and headers. Returns the target cluster of the authorized connection or request.
+func (d *Dataplane) getIngressAuth(method, path string, header http.Header) (string, error) {
+	forwardingURL := httpSchemaPrefix + d.controlplaneTarget + path
+
+	// the request body is not forwarded, as it carries the tunneled data of HTTP/2 tunnels
+	forwardingReq, err := http.NewRequest(method, forwardingURL, http.NoBody)
+	if err != nil {
+		d.logger.Error("Forwarding error in NewRequest", err)
+		return "", err
+	}
+	for key, values := range header {
+		for _, value := range values {
+			forwardingReq.Header.Add(key, value)
+		}
+	}
+
+	resp, err := d.apiClient.Do(forwardingReq)
+	if err != nil {
+		d.logger.Error("Forwarding error in sending operation", err)
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		d.logger.Infof("Failed to obtain ingress authorization: %s.", resp.Status)
+		return "", fmt.Errorf("failed ingress authorization: %s", resp.Status)
+	}
+
+	targetCluster := resp.Header.Get(cpapi.TargetClusterHeader)
+	d.logger.Infof("Got authorization to use service: %s.", targetCluster)
+	return targetCluster, nil
 }
 
-func (d *Dataplane) hijackConn(w http.ResponseWriter) (net.Conn, error) {
+// requestPeer returns the name of the remote peer whose dataplane sent a request, or an empty string if unknown.
+func requestPeer(r *http.Request) string {
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 || len(r.TLS.PeerCertificates[0].DNSNames) == 0 {
+		return ""
+	}
+
+	peer, err := api.StripServerPrefix(r.TLS.PeerCertificates[0].DNSNames[0])
+	if err != nil {
+		return ""
+	}
+
+	return peer
+}
+
+// hijackConn takes over an HTTP/1.1 connection for a tunnel, after sending the given response.
+// Returns the connection, and a buffered reader holding data already read from it.
+func (d *Dataplane) hijackConn(w http.ResponseWriter, response string) (net.Conn, *bufio.Reader, error) {
 	d.logger.Debugf("Starting to hijack connection.")
 	hj, ok := w.(http.Hijacker)
 	if !ok {
-		return nil, fmt.Errorf("server doesn't support hijacking")
+		return nil, nil, fmt.Errorf("server doesn't support hijacking")
 	}
 	// Hijack the connection
-	peerConn, _, err := hj.Hijack()
+	peerConn, bufrw, err := hj.Hijack()
 	if err != nil {
-		return nil, fmt.Errorf("hijacking failed: %w", err)
+		return nil, nil, fmt.Errorf("hijacking failed: %w", err)
 	}
 
 	if err = peerConn.SetDeadline(time.Time{}); err != nil {
-		return nil, fmt.Errorf("failed to clear deadlines on connection: %w", err)
+		return nil, nil, fmt.Errorf("failed to clear deadlines on connection: %w", err)
 	}
 
 	if _, err := peerConn.Write([]byte{}); err != nil {
 		_ = peerConn.Close() // close the connection ignoring errors
-		return nil, fmt.Errorf("failed to write to connection: %w", err)
+		return nil, nil, fmt.Errorf("failed to write to connection: %w", err)
 	}
 
-	fmt.Fprintf(peerConn, "HTTP/1.1 200 OK\r\nContent-Type: text/plain\r\n\r\n")
+	fmt.Fprint(peerConn, response)
 	d.logger.Debugf("Connection hijacked %v->%v.", peerConn.RemoteAddr().String(), peerConn.LocalAddr().String())
-	return peerConn, nil
+	return peerConn, bufrw.Reader, nil
 }
 
-func (d *Dataplane) initiateEgressConnection(targetCluster, authToken string, appConn net.Conn, tlsConfig *tls.Config) error {
+func (d *Dataplane) initiateEgressConnection(
+	targetCluster, authToken string,
+	appConn net.Conn,
+	tlsConfig *tls.Config,
+	conn *connection,
+	udp bool,
+) error {
 	target, err := d.GetClusterTarget(targetCluster)
 	if err != nil {
 		d.logger.Error(err)
 		return err
 	}
-	url := httpSchemaPrefix + target
-	d.logger.Debugf("Starting to initiate egress connection to: %s.", url)
+	d.logger.Debugf("Starting to initiate egress connection to: %s.", target)
 
-	peerConn, err := tls.Dial("tcp", target, tlsConfig)
-	if err != nil {
-		d.logger.Infof("Error in connecting.. %+v", err)
-		return err
-	}
+	pool := d.getTunnelPool(targetCluster, target, tlsConfig)
 
-	client := &http.Client{
-		Transport: &http.Transport{
-			TLSClientConfig: tlsConfig,
-			DialTLS:         connDialer{peerConn}.Dial,
-		},
+	var peerConn net.Conn
+	if udp {
+		peerConn, err = pool.openDatagramTunnel(udpTunnelPath(conn.status.DstService, appConn), authToken)
+	} else {
+		peerConn, err = pool.openTunnel(authToken)
 	}
-
-	egressReq, err := http.NewRequest(http.MethodPost, url, http.NoBody)
 	if err != nil {
+		d.logger.Infof("Error in establishing a tunnel to %s: %v.", target, err)
 		return err
 	}
 
-	egressReq.Header.Add(cpapi.AuthorizationHeader, authToken)
-	d.logger.Debugf("Setting %s header to %s.", cpapi.AuthorizationHeader, authToken)
-
-	resp, err := client.Do(egressReq)
-	if resp != nil {
-		defer resp.Body.Close()
-	}
-	if err != nil {
-		d.logger.Infof("Error in TLS connection: %v.", err)
-		return err
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("got HTTP %d while trying to establish dataplane connection", resp.StatusCode)
-	}
-
 	d.logger.Infof("Connection established successfully!")
 
 	forward := newForwarder(appConn, peerConn)
-	forward.run()
+	d.runForwarder(forward, conn)
 	return nil
 }
+
+// udpTunnelPath returns the path of a tunnel for a UDP session with an imported service.
+// The path is informational only, and consists of the imported service name and listening port.
+func udpTunnelPath(name string, session net.Conn) string {
+	var port uint16
+	if addr, ok := session.LocalAddr().(*net.UDPAddr); ok {
+		port = uint16(addr.Port)
+	}
+
+	_, importName, _ := parseImportListenerName(name)
+	return api.UDPTunnelPath(importName, port)
+}
@@ -0,0 +1,634 @@
+// Copyright 2023 The ClusterLink Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/net/http2"
+
+	cpapi "github.com/clusterlink-net/clusterlink/pkg/controlplane/api"
+	dpapi "github.com/clusterlink-net/clusterlink/pkg/dataplane/api"
+)
+
+const (
+	// tunnelDialTimeout is the timeout for establishing a connection to a peer gateway.
+	tunnelDialTimeout = 5 * time.Second
+	// tunnelHealthCheckInterval is the idle time after which a pooled connection is health checked using a ping.
+	tunnelHealthCheckInterval = 15 * time.Second
+	// tunnelHealthCheckTimeout is the time to wait for a ping response, before closing a pooled connection.
+	tunnelHealthCheckTimeout = 5 * time.Second
+	// tunnelIdleTimeout is the time a pooled connection without any tunnels is kept open.
+	tunnelIdleTimeout = 5 * time.Minute
+	// tunnelDrainTimeout is the time given to tunnels of a draining connection to complete, before it is closed.
+	tunnelDrainTimeout = 5 * time.Minute
+)
+
+// errDeadlineNotSupported is returned when setting deadlines on a tunnel carried by an HTTP/2 stream.
+var errDeadlineNotSupported = errors.New("deadlines are not supported on HTTP/2 tunnels")
+
+// pooledConn is an HTTP/2 connection to a peer gateway.
+type pooledConn struct {
+	cc         *http2.ClientConn
+	localAddr  net.Addr
+	remoteAddr net.Addr
+
+	// dedicated is true for a connection established by a draining pool, which is used for a single tunnel.
+	dedicated bool
+}
+
+// tunnelPool holds long-lived HTTP/2 connections to a peer gateway.
+// Each tunneled connection is carried as a stream over a pooled connection.
+// Similar to Envoy tunneling (using use_post), a tunnel is a POST request whose body and response body carry
+// the tunneled data.
+// Pooled connections are health checked using HTTP/2 pings, and closed once idle.
+// If the gateway does not negotiate HTTP/2, each tunnel uses a dedicated HTTP/1.1 connection.
+type tunnelPool struct {
+	target    string
+	tlsConfig *tls.Config
+	transport *http2.Transport
+
+	lock     sync.Mutex
+	conns    []*pooledConn
+	draining bool
+
+	logger *logrus.Entry
+}
+
+// reserveConn returns a pooled connection with a stream reserved for a new tunnel,
+// or nil if no pooled connection can take a new stream.
+// Closed connections are removed from the pool.
+func (p *tunnelPool) reserveConn() *pooledConn {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	var reserved *pooledConn
+	live := p.conns[:0]
+	for _, conn := range p.conns {
+		if state := conn.cc.State(); state.Closed || state.Closing {
+			continue
+		}
+
+		live = append(live, conn)
+		if reserved == nil && conn.cc.ReserveNewRequest() {
+			reserved = conn
+		}
+	}
+
+	clear(p.conns[len(live):])
+	p.conns = live
+	return reserved
+}
+
+// dial establishes a new connection to the peer gateway.
+// If HTTP/2 is negotiated, the new connection is pooled, and returned with a stream reserved for a new tunnel.
+// Otherwise, the TLS connection is returned for a dedicated HTTP/1.1 tunnel.
+func (p *tunnelPool) dial() (*pooledConn, *tls.Conn, error) {
+	dialer := &tls.Dialer{
+		NetDialer: &net.Dialer{Timeout: tunnelDialTimeout},
+		Config:    p.tlsConfig,
+	}
+
+	conn, err := dialer.Dial("tcp", p.target)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tlsConn := conn.(*tls.Conn)
+	if tlsConn.ConnectionState().NegotiatedProtocol != http2.NextProtoTLS {
+		return nil, tlsConn, nil
+	}
+
+	cc, err := p.transport.NewClientConn(tlsConn)
+	if err != nil {
+		tlsConn.Close()
+		return nil, nil, err
+	}
+
+	if !cc.ReserveNewRequest() {
+		cc.Close()
+		return nil, nil, fmt.Errorf("new connection to %s cannot take a new stream", p.target)
+	}
+
+	pooled := &pooledConn{
+		cc:         cc,
+		localAddr:  tlsConn.LocalAddr(),
+		remoteAddr: tlsConn.RemoteAddr(),
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.draining {
+		pooled.dedicated = true
+	} else {
+		p.conns = append(p.conns, pooled)
+		p.logger.Infof("Established a pooled connection (total %d).", len(p.conns))
+	}
+
+	return pooled, nil, nil
+}
+
+// openTunnel opens a tunnel to the peer gateway, authorized by the given access token.
+func (p *tunnelPool) openTunnel(authToken string) (net.Conn, error) {
+	conn := p.reserveConn()
+	if conn == nil {
+		var tlsConn *tls.Conn
+		var err error
+		conn, tlsConn, err = p.dial()
+		if err != nil {
+			return nil, err
+		}
+
+		if tlsConn != nil {
+			return p.openHTTP1Tunnel(tlsConn, authToken)
+		}
+	}
+
+	return p.openStreamTunnel(conn, authToken)
+}
+
+// openStreamTunnel opens a tunnel carried by a new stream of a pooled connection.
+func (p *tunnelPool) openStreamTunnel(conn *pooledConn, authToken string) (net.Conn, error) {
+	bodyReader, bodyWriter := io.Pipe()
+	req, err := http.NewRequest(http.MethodPost, httpSchemaPrefix+p.target+"/", bodyReader)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Add(cpapi.AuthorizationHeader, authToken)
+	req.Header.Add(dpapi.TunnelFramingHeader, dpapi.TunnelFramingLengthPrefixed)
+
+	resp, err := conn.cc.RoundTrip(req)
+	if conn.dedicated {
+		// close the connection once its single tunnel completes
+		go drainConn(conn.cc)
+	}
+	if err != nil {
+		bodyWriter.Close()
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		bodyWriter.Close()
+		return nil, fmt.Errorf("got HTTP %d while trying to establish dataplane connection", resp.StatusCode)
+	}
+
+	// servers not supporting framing (e.g. Envoy) send the tunneled data as is
+	var reader io.Reader = resp.Body
+	if resp.Header.Get(dpapi.TunnelFramingHeader) == dpapi.TunnelFramingLengthPrefixed {
+		reader = &frameReader{reader: resp.Body}
+	}
+
+	return &clientStreamConn{
+		streamConn: streamConn{
+			reader: reader,
+			writer: bodyWriter,
+			closeFunc: func() error {
+				bodyWriter.CloseWithError(net.ErrClosed)
+				return resp.Body.Close()
+			},
+			localAddr:  conn.localAddr,
+			remoteAddr: conn.remoteAddr,
+		},
+		bodyWriter: bodyWriter,
+	}, nil
+}
+
+// openHTTP1Tunnel opens a tunnel over a dedicated HTTP/1.1 connection, which is upgraded to carry the tunneled data.
+func (p *tunnelPool) openHTTP1Tunnel(conn *tls.Conn, authToken string) (net.Conn, error) {
+	client := &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: p.tlsConfig,
+			DialTLS:         connDialer{conn}.Dial,
+		},
+	}
+
+	req, err := http.NewRequest(http.MethodPost, httpSchemaPrefix+p.target, http.NoBody)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	req.Header.Add(cpapi.AuthorizationHeader, authToken)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		conn.Close()
+		return nil, fmt.Errorf("got HTTP %d while trying to establish dataplane connection", resp.StatusCode)
+	}
+
+	return &http1TunnelConn{Conn: conn, body: resp.Body}, nil
+}
+
+// roundTrip sends a request of an HTTP service to the peer gateway, over a pooled connection.
+// If the gateway does not negotiate HTTP/2, the request is sent over a dedicated HTTP/1.1 connection.
+func (p *tunnelPool) roundTrip(req *http.Request) (*http.Response, error) {
+	conn := p.reserveConn()
+	if conn == nil {
+		var tlsConn *tls.Conn
+		var err error
+		conn, tlsConn, err = p.dial()
+		if err != nil {
+			return nil, err
+		}
+
+		if tlsConn != nil {
+			transport := &http.Transport{
+				TLSClientConfig:   p.tlsConfig,
+				DialTLS:           connDialer{tlsConn}.Dial,
+				DisableKeepAlives: true,
+			}
+
+			resp, err := transport.RoundTrip(req)
+			if err != nil {
+				tlsConn.Close()
+			}
+			return resp, err
+		}
+	}
+
+	resp, err := conn.cc.RoundTrip(req)
+	if conn.dedicated {
+		// close the connection once its single request completes
+		go drainConn(conn.cc)
+	}
+	return resp, err
+}
+
+// openDatagramTunnel opens a tunnel carrying UDP datagrams over a dedicated HTTP/1.1 connection,
+// which is upgraded using CONNECT-UDP (RFC 9298), as also supported by Envoy peers.
+func (p *tunnelPool) openDatagramTunnel(path, authToken string) (net.Conn, error) {
+	tlsConfig := p.tlsConfig.Clone()
+	tlsConfig.NextProtos = []string{"http/1.1"}
+
+	dialer := &tls.Dialer{
+		NetDialer: &net.Dialer{Timeout: tunnelDialTimeout},
+		Config:    tlsConfig,
+	}
+
+	conn, err := dialer.Dial("tcp", p.target)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodGet, httpSchemaPrefix+p.target+path, http.NoBody)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", dpapi.UDPTunnelProtocol)
+	req.Header.Set("Capsule-Protocol", "?1")
+	req.Header.Add(cpapi.AuthorizationHeader, authToken)
+
+	if err := conn.SetDeadline(time.Now().Add(tunnelDialTimeout)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	// the buffered reader may hold datagrams sent right after the response
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		conn.Close()
+		return nil, fmt.Errorf("got HTTP %d while trying to establish dataplane connection", resp.StatusCode)
+	}
+
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return newDatagramConn(conn, reader), nil
+}
+
+// http1TunnelConn is a tunnel over a dedicated HTTP/1.1 connection.
+// The response body is kept open while the tunnel is used, since closing it closes the connection.
+type http1TunnelConn struct {
+	*tls.Conn
+	body io.Closer
+}
+
+func (c *http1TunnelConn) Close() error {
+	err := c.Conn.Close()
+	c.body.Close()
+	return err
+}
+
+// drain stops pooling connections.
+// Existing tunnels are given tunnelDrainTimeout to complete, after which their connections are closed.
+func (p *tunnelPool) drain() {
+	p.lock.Lock()
+	conns := p.conns
+	p.conns = nil
+	p.draining = true
+	p.lock.Unlock()
+
+	p.logger.Infof("Draining %d pooled connections.", len(conns))
+	for _, conn := range conns {
+		go drainConn(conn.cc)
+	}
+}
+
+// drainConn gracefully shuts down a connection, closing it once its streams complete or tunnelDrainTimeout expires.
+func drainConn(cc *http2.ClientConn) {
+	ctx, cancel := context.WithTimeout(context.Background(), tunnelDrainTimeout)
+	defer cancel()
+
+	if err := cc.Shutdown(ctx); err != nil {
+		cc.Close()
+	}
+}
+
+// newTunnelPool returns a new tunnel pool for the given peer gateway.
+func newTunnelPool(target string, tlsConfig *tls.Config) *tunnelPool {
+	tlsConfig = tlsConfig.Clone()
+	tlsConfig.NextProtos = []string{http2.NextProtoTLS, "http/1.1"}
+
+	return &tunnelPool{
+		target:    target,
+		tlsConfig: tlsConfig,
+		transport: &http2.Transport{
+			TLSClientConfig: tlsConfig,
+			ReadIdleTimeout: tunnelHealthCheckInterval,
+			PingTimeout:     tunnelHealthCheckTimeout,
+			IdleConnTimeout: tunnelIdleTimeout,
+		},
+		logger: logrus.WithFields(logrus.Fields{
+			"component": "dataplane.tunnel-pool",
+			"target":    target,
+		}),
+	}
+}
+
+// streamConn is a tunneled connection carried by an HTTP/2 stream.
+type streamConn struct {
+	reader    io.Reader
+	writer    io.Writer
+	flush     func() error
+	closeFunc func() error
+	closeOnce sync.Once
+	closed    atomic.Bool
+
+	localAddr  net.Addr
+	remoteAddr net.Addr
+}
+
+// streamError returns net.ErrClosed for errors caused by closing the connection.
+func (c *streamConn) streamError(err error) error {
+	if err != nil && err != io.EOF && c.closed.Load() {
+		return net.ErrClosed
+	}
+
+	return err
+}
+
+func (c *streamConn) Read(b []byte) (int, error) {
+	n, err := c.reader.Read(b)
+	return n, c.streamError(err)
+}
+
+func (c *streamConn) Write(b []byte) (int, error) {
+	n, err := c.writer.Write(b)
+	if err == nil && c.flush != nil {
+		err = c.flush()
+	}
+
+	return n, c.streamError(err)
+}
+
+func (c *streamConn) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		c.closed.Store(true)
+		err = c.closeFunc()
+	})
+
+	return err
+}
+
+func (c *streamConn) LocalAddr() net.Addr {
+	return c.localAddr
+}
+
+func (c *streamConn) RemoteAddr() net.Addr {
+	return c.remoteAddr
+}
+
+func (c *streamConn) SetDeadline(_ time.Time) error {
+	return errDeadlineNotSupported
+}
+
+func (c *streamConn) SetReadDeadline(_ time.Time) error {
+	return errDeadlineNotSupported
+}
+
+func (c *streamConn) SetWriteDeadline(_ time.Time) error {
+	return errDeadlineNotSupported
+}
+
+// clientStreamConn is the client side of a tunnel carried by an HTTP/2 stream.
+// Unlike the server side, it supports closing its write side, by ending the request body.
+type clientStreamConn struct {
+	streamConn
+	bodyWriter *io.PipeWriter
+}
+
+func (c *clientStreamConn) CloseWrite() error {
+	return c.bodyWriter.Close()
+}
+
+// serverStreamConn is the server side of a tunnel carried by an HTTP/2 stream, whose response body is framed.
+// It supports closing its write side, by sending an empty frame.
+type serverStreamConn struct {
+	streamConn
+	frameWriter *frameWriter
+}
+
+func (c *serverStreamConn) CloseWrite() error {
+	if err := c.frameWriter.CloseWrite(); err != nil {
+		return c.streamError(err)
+	}
+
+	return c.streamError(c.flush())
+}
+
+// errWriteClosed is returned when writing to a tunnel whose write side is closed.
+var errWriteClosed = errors.New("tunnel write side is closed")
+
+// frameWriter writes length-prefixed frames (see dpapi.TunnelFramingLengthPrefixed).
+type frameWriter struct {
+	writer io.Writer
+	closed bool
+}
+
+func (w *frameWriter) Write(b []byte) (int, error) {
+	if w.closed {
+		return 0, errWriteClosed
+	}
+
+	if len(b) == 0 {
+		return 0, nil
+	}
+
+	var header [4]byte
+	binary.BigEndian.PutUint32(header[:], uint32(len(b)))
+	if _, err := w.writer.Write(header[:]); err != nil {
+		return 0, err
+	}
+
+	return w.writer.Write(b)
+}
+
+// CloseWrite writes the empty frame, signaling EOF to the reader.
+func (w *frameWriter) CloseWrite() error {
+	if w.closed {
+		return nil
+	}
+
+	w.closed = true
+	var header [4]byte
+	_, err := w.writer.Write(header[:])
+	return err
+}
+
+// frameReader reads length-prefixed frames (see dpapi.TunnelFramingLengthPrefixed).
+type frameReader struct {
+	reader    io.Reader
+	remaining uint32
+	eof       bool
+}
+
+func (r *frameReader) Read(b []byte) (int, error) {
+	if r.eof {
+		return 0, io.EOF
+	}
+
+	if r.remaining == 0 {
+		var header [4]byte
+		if _, err := io.ReadFull(r.reader, header[:]); err != nil {
+			// the response ended without an empty frame, once the server closed the tunnel
+			return 0, err
+		}
+
+		r.remaining = binary.BigEndian.Uint32(header[:])
+		if r.remaining == 0 {
+			r.eof = true
+			return 0, io.EOF
+		}
+	}
+
+	if uint32(len(b)) > r.remaining {
+		b = b[:r.remaining]
+	}
+
+	n, err := r.reader.Read(b)
+	r.remaining -= uint32(n)
+	if err == io.EOF && r.remaining > 0 {
+		err = io.ErrUnexpectedEOF
+	}
+
+	return n, err
+}
+
+// stringAddr is a net.Addr given by its string representation.
+type stringAddr string
+
+func (a stringAddr) Network() string {
+	return "tcp"
+}
+
+func (a stringAddr) String() string {
+	return string(a)
+}
+
+// acceptStreamTunnel accepts a tunnel carried by an HTTP/2 stream.
+// The response header is sent, after which the request body and response body carry the tunneled data.
+// If requested by the client, the response body is framed, allowing to half-close the tunnel.
+// The returned connection must be closed before the handler returns.
+func acceptStreamTunnel(w http.ResponseWriter, r *http.Request) (net.Conn, error) {
+	rc := http.NewResponseController(w)
+	if err := rc.SetReadDeadline(time.Time{}); err != nil {
+		return nil, fmt.Errorf("failed to clear read deadline: %w", err)
+	}
+
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		return nil, fmt.Errorf("failed to clear write deadline: %w", err)
+	}
+
+	framed := r.Header.Get(dpapi.TunnelFramingHeader) == dpapi.TunnelFramingLengthPrefixed
+	if framed {
+		w.Header().Set(dpapi.TunnelFramingHeader, dpapi.TunnelFramingLengthPrefixed)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		return nil, fmt.Errorf("failed to send response header: %w", err)
+	}
+
+	var localAddr net.Addr = stringAddr("")
+	if addr, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr); ok {
+		localAddr = addr
+	}
+
+	conn := &serverStreamConn{
+		streamConn: streamConn{
+			reader:     r.Body,
+			writer:     w,
+			flush:      rc.Flush,
+			closeFunc:  r.Body.Close,
+			localAddr:  localAddr,
+			remoteAddr: stringAddr(r.RemoteAddr),
+		},
+	}
+	if !framed {
+		return &conn.streamConn, nil
+	}
+
+	conn.frameWriter = &frameWriter{writer: w}
+	conn.writer = conn.frameWriter
+	return conn, nil
+}
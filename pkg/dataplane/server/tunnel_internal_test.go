@@ -0,0 +1,167 @@
+// Copyright 2023 The ClusterLink Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/clusterlink-net/clusterlink/pkg/dataplane/api"
+)
+
+// echoTunnelServer starts a server accepting tunnels which echo back their data.
+// Returns the server and a counter of accepted TCP connections.
+func echoTunnelServer(t *testing.T, enableHTTP2 bool) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+
+	d := &Dataplane{logger: logrus.WithField("component", "test")}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var conn net.Conn
+		var err error
+		switch {
+		case strings.HasPrefix(r.URL.Path, api.UDPTunnelPathPrefix):
+			var reader *bufio.Reader
+			conn, reader, err = d.hijackConn(w, udpTunnelResponse)
+			if err == nil {
+				conn = newDatagramConn(conn, reader)
+			}
+		case r.ProtoMajor == 1:
+			conn, _, err = d.hijackConn(w, tunnelResponse)
+		default:
+			conn, err = acceptStreamTunnel(w, r)
+		}
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		_, _ = io.Copy(conn, conn)
+	}))
+
+	var connections atomic.Int32
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			connections.Add(1)
+		}
+	}
+
+	server.EnableHTTP2 = enableHTTP2
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	return server, &connections
+}
+
+// newTestTunnelPool returns a tunnel pool to the given test server.
+func newTestTunnelPool(server *httptest.Server) *tunnelPool {
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(server.Certificate())
+
+	return newTunnelPool(server.Listener.Addr().String(), &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		RootCAs:    rootCAs,
+		ServerName: "example.com",
+	})
+}
+
+// requireEcho sends data over a tunnel, half-closes it, and verifies the data is echoed back.
+func requireEcho(t *testing.T, conn net.Conn, data string) {
+	t.Helper()
+
+	_, err := conn.Write([]byte(data))
+	require.Nil(t, err)
+	require.Nil(t, conn.(closeWriter).CloseWrite())
+
+	echoed, err := io.ReadAll(conn)
+	require.Nil(t, err)
+	require.Equal(t, data, string(echoed))
+	require.Nil(t, conn.Close())
+}
+
+func TestTunnelPoolHTTP2(t *testing.T) {
+	server, connections := echoTunnelServer(t, true)
+	pool := newTestTunnelPool(server)
+
+	// concurrent tunnels share a single connection
+	tunnel1, err := pool.openTunnel("token")
+	require.Nil(t, err)
+	tunnel2, err := pool.openTunnel("token")
+	require.Nil(t, err)
+
+	requireEcho(t, tunnel1, "first")
+	requireEcho(t, tunnel2, "second")
+	require.Equal(t, int32(1), connections.Load())
+
+	// a draining pool keeps serving existing tunnels, but no longer pools connections
+	tunnel3, err := pool.openTunnel("token")
+	require.Nil(t, err)
+	pool.drain()
+	requireEcho(t, tunnel3, "third")
+	require.Nil(t, pool.reserveConn())
+
+	tunnel4, err := pool.openTunnel("token")
+	require.Nil(t, err)
+	requireEcho(t, tunnel4, "fourth")
+	require.Equal(t, int32(2), connections.Load())
+	require.Nil(t, pool.reserveConn())
+}
+
+func TestTunnelPoolHTTP1(t *testing.T) {
+	server, connections := echoTunnelServer(t, false)
+	pool := newTestTunnelPool(server)
+
+	// each tunnel uses a dedicated connection
+	for _, data := range []string{"first", "second"} {
+		tunnel, err := pool.openTunnel("token")
+		require.Nil(t, err)
+		requireEcho(t, tunnel, data)
+	}
+
+	require.Equal(t, int32(2), connections.Load())
+}
+
+func TestDatagramTunnel(t *testing.T) {
+	// datagram tunnels use a dedicated HTTP/1.1 connection, even if HTTP/2 is supported
+	server, connections := echoTunnelServer(t, true)
+	pool := newTestTunnelPool(server)
+
+	tunnel, err := pool.openDatagramTunnel(api.UDPTunnelPath("svc", 53), "token")
+	require.Nil(t, err)
+
+	// datagram boundaries are preserved
+	buf := make([]byte, maxDatagramSize)
+	for _, datagram := range []string{"first", "second datagram"} {
+		_, err = tunnel.Write([]byte(datagram))
+		require.Nil(t, err)
+
+		n, err := tunnel.Read(buf)
+		require.Nil(t, err)
+		require.Equal(t, datagram, string(buf[:n]))
+	}
+
+	require.Nil(t, tunnel.Close())
+	require.Equal(t, int32(1), connections.Load())
+	require.Nil(t, pool.reserveConn())
+}
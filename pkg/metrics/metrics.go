@@ -18,49 +18,86 @@ package metrics
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"sync"
+	"time"
 
-	"github.com/go-chi/chi"
 	"github.com/sirupsen/logrus"
 
+	"github.com/clusterlink-net/clusterlink/pkg/controlplane/api"
 	event "github.com/clusterlink-net/clusterlink/pkg/controlplane/eventmanager"
+	utilhttp "github.com/clusterlink-net/clusterlink/pkg/util/http"
+	"github.com/clusterlink-net/clusterlink/pkg/util/tls"
 )
 
-var (
-	mlog             = logrus.WithField("component", "Metrics")
-	MyMetricsManager Metrics
+const (
+	// ConnectionStatusPath is the path for posting and getting connection-level metrics.
+	ConnectionStatusPath = "/metrics/" + event.ConnectionStatus
+
+	// completedRetention is the time metrics of completed connections are kept.
+	completedRetention = time.Hour
+	// pruneInterval is the minimal interval between pruning metrics of completed connections.
+	pruneInterval = time.Minute
 )
 
+var mlog = logrus.WithField("component", "Metrics")
+
+// Metrics aggregates the connection-level metrics reported by dataplanes.
 type Metrics struct {
+	lock           sync.Mutex
 	ConnectionFlow map[string]*event.ConnectionStatusAttr
+	lastPrune      time.Time
+
+	peerTLS *tls.ParsedCertData
 }
 
-func (m *Metrics) Routes(r *chi.Mux) chi.Router {
-	r.Route("/"+event.ConnectionStatus, func(r chi.Router) {
-		r.Get("/", m.GetConnectionMetrics)   // Get Metrics from the metrics manager
-		r.Post("/", m.PostConnectionMetrics) // Post Metrics to the metrics manager
-	})
+// RegisterHandlers registers the HTTP handlers for posting metrics (by dataplanes) and getting metrics (by gwctl).
+func RegisterHandlers(m *Metrics, srv *utilhttp.Server) {
+	router := srv.Router()
+	router.Get(ConnectionStatusPath, m.GetConnectionMetrics)   // Get Metrics from the metrics manager
+	router.Post(ConnectionStatusPath, m.PostConnectionMetrics) // Post Metrics to the metrics manager
 	// TODO : Add more endpoints to support query
-	return r
 }
 
-func (m *Metrics) init(router *chi.Mux) {
-	m.ConnectionFlow = make(map[string]*event.ConnectionStatusAttr)
-
-	routes := m.Routes(router)
+// allowRole returns true if the request originates from a client with the given role.
+// Otherwise, the request is rejected.
+func (m *Metrics) allowRole(w http.ResponseWriter, r *http.Request, role api.Role) bool {
+	if requestRole := api.RequestRole(r, m.peerTLS); requestRole != role {
+		mlog.Warnf("Rejecting request to '%s' from client with role '%s'.", r.URL.Path, requestRole)
+		http.Error(w, fmt.Sprintf("client role '%s' is not allowed", requestRole), http.StatusForbidden)
+		return false
+	}
 
-	router.Mount("/metrics", routes)
+	return true
 }
 
-func (m *Metrics) GetConnectionMetrics(w http.ResponseWriter, _ *http.Request) {
+func (m *Metrics) GetConnectionMetrics(w http.ResponseWriter, r *http.Request) {
+	if !m.allowRole(w, r, api.RoleGWCTL) {
+		return
+	}
+
+	m.lock.Lock()
+	encoded, err := json.Marshal(m.ConnectionFlow)
+	m.lock.Unlock()
+	if err != nil {
+		mlog.Errorf("Error happened in JSON encode. Err: %s", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(m.ConnectionFlow); err != nil {
-		mlog.Errorf("Error happened in JSON encode. Err: %s", err)
+	if _, err := w.Write(encoded); err != nil {
+		mlog.Errorf("Cannot write http response: %v.", err)
 	}
 }
 
 func (m *Metrics) PostConnectionMetrics(w http.ResponseWriter, r *http.Request) {
+	if !m.allowRole(w, r, api.RoleDataplane) {
+		return
+	}
+
 	var connectionStatus event.ConnectionStatusAttr
 	err := json.NewDecoder(r.Body).Decode(&connectionStatus)
 	if err != nil {
@@ -71,10 +108,14 @@ func (m *Metrics) PostConnectionMetrics(w http.ResponseWriter, r *http.Request)
 	m.aggregateMetrics(&connectionStatus)
 }
 
+// aggregateMetrics adds a connection status report to the metrics of its connection.
+// Reported byte counts are the bytes transferred since the previous report of the connection.
 func (m *Metrics) aggregateMetrics(connectionStatus *event.ConnectionStatusAttr) {
-	if _, exists := m.ConnectionFlow[connectionStatus.ConnectionID]; exists {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if flow, exists := m.ConnectionFlow[connectionStatus.ConnectionID]; exists {
 		// Update existing metrics
-		flow := m.ConnectionFlow[connectionStatus.ConnectionID]
 		flow.IncomingBytes += connectionStatus.IncomingBytes
 		flow.OutgoingBytes += connectionStatus.OutgoingBytes
 		flow.LastTstamp = connectionStatus.LastTstamp
@@ -82,9 +123,33 @@ func (m *Metrics) aggregateMetrics(connectionStatus *event.ConnectionStatusAttr)
 	} else {
 		m.ConnectionFlow[connectionStatus.ConnectionID] = connectionStatus
 	}
+
+	m.pruneCompleted()
 }
 
-func StartMetricsManager(router *chi.Mux) {
+// pruneCompleted removes the metrics of connections which completed more than completedRetention ago.
+// Pruning is done at most once per pruneInterval.
+func (m *Metrics) pruneCompleted() {
+	now := time.Now()
+	if now.Sub(m.lastPrune) < pruneInterval {
+		return
+	}
+	m.lastPrune = now
+
+	before := now.Add(-completedRetention)
+	for id, flow := range m.ConnectionFlow {
+		if flow.State != event.Ongoing && flow.LastTstamp.Before(before) {
+			delete(m.ConnectionFlow, id)
+		}
+	}
+}
+
+// NewMetrics returns a new metrics manager.
+// peerTLS is used to verify that metrics are posted by local dataplanes, and read by gwctl.
+func NewMetrics(peerTLS *tls.ParsedCertData) *Metrics {
 	mlog.Infof("Metrics Manager started")
-	MyMetricsManager.init(router)
+	return &Metrics{
+		ConnectionFlow: make(map[string]*event.ConnectionStatusAttr),
+		peerTLS:        peerTLS,
+	}
 }
@@ -0,0 +1,357 @@
+// Copyright 2023 The ClusterLink Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	// Path is the path of the Prometheus metrics endpoint.
+	Path = "/metrics"
+
+	// prometheusNamespace is the namespace of all ClusterLink Prometheus metrics.
+	prometheusNamespace = "clusterlink"
+)
+
+// ControlplaneMetrics holds the Prometheus metrics of the controlplane.
+// A nil ControlplaneMetrics discards all observations.
+type ControlplaneMetrics struct {
+	authorizationDecisions  *prometheus.CounterVec
+	loadBalancingSelections *prometheus.CounterVec
+	peerReachable           *prometheus.GaugeVec
+	peerHeartbeatRTT        *prometheus.HistogramVec
+	xdsPushes               *prometheus.CounterVec
+	xdsConnectedDataplanes  prometheus.Gauge
+	xdsNACKs                *prometheus.CounterVec
+	portLeases              prometheus.Gauge
+	rateLimitedConnections  *prometheus.CounterVec
+}
+
+// ObserveAuthorizationDecision counts an authorization decision.
+// direction is either egress, ingress, or ingress-token (verifying the access token of an ingress connection),
+// and policy is the name of the policy deciding the result (if any).
+func (m *ControlplaneMetrics) ObserveAuthorizationDecision(direction string, allowed bool, policy string) {
+	if m == nil {
+		return
+	}
+
+	result := "deny"
+	if allowed {
+		result = "allow"
+	}
+
+	m.authorizationDecisions.WithLabelValues(direction, result, policy).Inc()
+}
+
+// ObserveLoadBalancingSelection counts a selection of an import source by the load balancer.
+func (m *ControlplaneMetrics) ObserveLoadBalancingSelection(importName, peer, exportName string) {
+	if m == nil {
+		return
+	}
+
+	m.loadBalancingSelections.WithLabelValues(importName, peer, exportName).Inc()
+}
+
+// SetPeerReachable sets the reachability of a peer.
+func (m *ControlplaneMetrics) SetPeerReachable(peer string, reachable bool) {
+	if m == nil {
+		return
+	}
+
+	value := 0.0
+	if reachable {
+		value = 1
+	}
+
+	m.peerReachable.WithLabelValues(peer).Set(value)
+}
+
+// ObservePeerHeartbeat observes the round-trip time of a successful heartbeat to a peer.
+func (m *ControlplaneMetrics) ObservePeerHeartbeat(peer string, rtt time.Duration) {
+	if m == nil {
+		return
+	}
+
+	m.peerHeartbeatRTT.WithLabelValues(peer).Observe(rtt.Seconds())
+}
+
+// DeletePeer removes the metrics of a deleted peer.
+func (m *ControlplaneMetrics) DeletePeer(peer string) {
+	if m == nil {
+		return
+	}
+
+	m.peerReachable.DeleteLabelValues(peer)
+	m.peerHeartbeatRTT.DeleteLabelValues(peer)
+}
+
+// ObserveXDSPush counts an update of an xDS resource, pushed to all subscribed dataplanes.
+// resourceType is the xDS resource type (e.g. cluster or listener), and operation is either update or delete.
+func (m *ControlplaneMetrics) ObserveXDSPush(resourceType, operation string) {
+	if m == nil {
+		return
+	}
+
+	m.xdsPushes.WithLabelValues(resourceType, operation).Inc()
+}
+
+// SetXDSConnectedDataplanes sets the number of dataplanes connected to the xDS server.
+func (m *ControlplaneMetrics) SetXDSConnectedDataplanes(count int) {
+	if m == nil {
+		return
+	}
+
+	m.xdsConnectedDataplanes.Set(float64(count))
+}
+
+// ObserveXDSNACK counts an xDS configuration rejected (NACKed) by a dataplane.
+// resourceType is the xDS resource type (e.g. cluster or listener).
+func (m *ControlplaneMetrics) ObserveXDSNACK(resourceType string) {
+	if m == nil {
+		return
+	}
+
+	m.xdsNACKs.WithLabelValues(resourceType).Inc()
+}
+
+// SetPortLeases sets the number of target ports leased to imported services.
+func (m *ControlplaneMetrics) SetPortLeases(count int) {
+	if m == nil {
+		return
+	}
+
+	m.portLeases.Set(float64(count))
+}
+
+// ObserveConnectionRateLimited counts a connection (or HTTP request) rejected by a per-client rate limit.
+// direction is either egress (service is an import, limited per source IP)
+// or ingress (service is an export, limited per remote peer).
+func (m *ControlplaneMetrics) ObserveConnectionRateLimited(direction, service string) {
+	if m == nil {
+		return
+	}
+
+	m.rateLimitedConnections.WithLabelValues(direction, service).Inc()
+}
+
+// NewControlplaneMetrics returns new controlplane metrics, registered to the given registerer.
+func NewControlplaneMetrics(registerer prometheus.Registerer) (*ControlplaneMetrics, error) {
+	m := &ControlplaneMetrics{
+		authorizationDecisions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: prometheusNamespace,
+			Subsystem: "controlplane",
+			Name:      "authorization_decisions_total",
+			Help:      "Number of authorization decisions, by direction (egress/ingress/ingress-token), result and deciding policy.",
+		}, []string{"direction", "result", "policy"}),
+		loadBalancingSelections: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: prometheusNamespace,
+			Subsystem: "controlplane",
+			Name:      "load_balancing_selections_total",
+			Help:      "Number of import sources selected by the load balancer, by import and source peer and export.",
+		}, []string{"import", "peer", "export"}),
+		peerReachable: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: prometheusNamespace,
+			Subsystem: "controlplane",
+			Name:      "peer_reachable",
+			Help:      "Whether a peer is reachable (1) or not (0), according to heartbeats.",
+		}, []string{"peer"}),
+		peerHeartbeatRTT: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: prometheusNamespace,
+			Subsystem: "controlplane",
+			Name:      "peer_heartbeat_rtt_seconds",
+			Help:      "Round-trip time of successful heartbeats to peers.",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 2, 14),
+		}, []string{"peer"}),
+		xdsPushes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: prometheusNamespace,
+			Subsystem: "controlplane",
+			Name:      "xds_pushes_total",
+			Help:      "Number of xDS resource updates pushed to dataplanes, by resource type and operation.",
+		}, []string{"type", "operation"}),
+		xdsConnectedDataplanes: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: prometheusNamespace,
+			Subsystem: "controlplane",
+			Name:      "xds_connected_dataplanes",
+			Help:      "Number of dataplanes connected to the xDS server.",
+		}),
+		xdsNACKs: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: prometheusNamespace,
+			Subsystem: "controlplane",
+			Name:      "xds_nacks_total",
+			Help:      "Number of xDS configurations rejected by dataplanes, by resource type.",
+		}, []string{"type"}),
+		portLeases: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: prometheusNamespace,
+			Subsystem: "controlplane",
+			Name:      "port_leases",
+			Help:      "Number of target ports leased to imported services.",
+		}),
+		rateLimitedConnections: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: prometheusNamespace,
+			Subsystem: "controlplane",
+			Name:      "rate_limited_connections_total",
+			Help: "Number of connections rejected by a per-client rate limit, by direction (egress/ingress) " +
+				"and imported/exported service.",
+		}, []string{"direction", "service"}),
+	}
+
+	collectors := []prometheus.Collector{
+		m.authorizationDecisions,
+		m.loadBalancingSelections,
+		m.peerReachable,
+		m.peerHeartbeatRTT,
+		m.xdsPushes,
+		m.xdsConnectedDataplanes,
+		m.xdsNACKs,
+		m.portLeases,
+		m.rateLimitedConnections,
+	}
+	for _, collector := range collectors {
+		if err := registerer.Register(collector); err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+// DataplaneMetrics holds the Prometheus metrics of the Go dataplane.
+// A nil DataplaneMetrics discards all observations.
+type DataplaneMetrics struct {
+	activeConnections  *prometheus.GaugeVec
+	bytes              *prometheus.CounterVec
+	drainedConnections *prometheus.CounterVec
+	rejected           *prometheus.CounterVec
+}
+
+// ConnectionStarted counts a new active connection.
+// direction is either egress (service is an import) or ingress (service is an export).
+func (m *DataplaneMetrics) ConnectionStarted(direction, service string) {
+	if m == nil {
+		return
+	}
+
+	m.activeConnections.WithLabelValues(direction, service).Inc()
+}
+
+// ConnectionEnded counts the end of an active connection.
+func (m *DataplaneMetrics) ConnectionEnded(direction, service string) {
+	if m == nil {
+		return
+	}
+
+	m.activeConnections.WithLabelValues(direction, service).Dec()
+}
+
+// AddBytes counts bytes forwarded on a connection.
+// incomingBytes are received from the remote peer, and outgoingBytes are sent to the remote peer.
+func (m *DataplaneMetrics) AddBytes(direction, service, peer string, incomingBytes, outgoingBytes int64) {
+	if m == nil {
+		return
+	}
+
+	m.bytes.WithLabelValues(direction, service, peer, "incoming").Add(float64(incomingBytes))
+	m.bytes.WithLabelValues(direction, service, peer, "outgoing").Add(float64(outgoingBytes))
+}
+
+// ConnectionRejected counts a connection rejected by a connection limit.
+// reason is the exceeded limit: max_connections, max_client_connections, rate_limit or client_rate_limit.
+func (m *DataplaneMetrics) ConnectionRejected(direction, service, reason string) {
+	if m == nil {
+		return
+	}
+
+	m.rejected.WithLabelValues(direction, service, reason).Inc()
+}
+
+// ConnectionsDrained counts the connections drained when a listener is removed or the dataplane is stopped.
+// completed connections ended by the drain deadline, and the remaining closed connections were closed at the deadline.
+func (m *DataplaneMetrics) ConnectionsDrained(direction string, completed, closed int) {
+	if m == nil {
+		return
+	}
+
+	m.drainedConnections.WithLabelValues(direction, "completed").Add(float64(completed))
+	m.drainedConnections.WithLabelValues(direction, "closed").Add(float64(closed))
+}
+
+// NewDataplaneMetrics returns new dataplane metrics, registered to the given registerer.
+func NewDataplaneMetrics(registerer prometheus.Registerer) (*DataplaneMetrics, error) {
+	m := &DataplaneMetrics{
+		activeConnections: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: prometheusNamespace,
+			Subsystem: "dataplane",
+			Name:      "active_connections",
+			Help:      "Number of active connections, by direction (egress/ingress) and imported/exported service.",
+		}, []string{"direction", "service"}),
+		bytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: prometheusNamespace,
+			Subsystem: "dataplane",
+			Name:      "bytes_total",
+			Help: "Number of bytes forwarded, by connection direction (egress/ingress), imported/exported service, " +
+				"remote peer, and stream (incoming from or outgoing to the remote peer).",
+		}, []string{"direction", "service", "peer", "stream"}),
+		drainedConnections: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: prometheusNamespace,
+			Subsystem: "dataplane",
+			Name:      "drained_connections_total",
+			Help: "Number of connections drained by a listener removal or a dataplane stop, by direction (egress/ingress) " +
+				"and result (completed before the drain deadline, or closed at the deadline).",
+		}, []string{"direction", "result"}),
+		rejected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: prometheusNamespace,
+			Subsystem: "dataplane",
+			Name:      "rejected_connections_total",
+			Help: "Number of connections rejected by connection limits, by direction (egress/ingress), " +
+				"imported/exported service and exceeded limit.",
+		}, []string{"direction", "service", "reason"}),
+	}
+
+	collectors := []prometheus.Collector{m.activeConnections, m.bytes, m.drainedConnections, m.rejected}
+	for _, collector := range collectors {
+		if err := registerer.Register(collector); err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+// NewRegistry returns a new Prometheus registry, including the Go runtime and process metrics.
+func NewRegistry() *prometheus.Registry {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}))
+	return registry
+}
+
+// ListenAndServe serves the metrics gathered by the given gatherer at Path, over plain HTTP.
+func ListenAndServe(address string, gatherer prometheus.Gatherer) error {
+	mux := http.NewServeMux()
+	mux.Handle(Path, promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{}))
+
+	server := &http.Server{
+		Addr:              address,
+		Handler:           mux,
+		ReadHeaderTimeout: 2 * time.Second,
+	}
+
+	return server.ListenAndServe()
+}
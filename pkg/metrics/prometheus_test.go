@@ -0,0 +1,168 @@
+// Copyright 2023 The ClusterLink Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/stretchr/testify/require"
+
+	"github.com/clusterlink-net/clusterlink/pkg/metrics"
+)
+
+// scrape scrapes the metrics endpoint serving the given registry, and parses the Prometheus text format.
+func scrape(t *testing.T, registry *prometheus.Registry) map[string]*dto.MetricFamily {
+	t.Helper()
+
+	server := httptest.NewServer(promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + metrics.Path)
+	require.Nil(t, err)
+	defer resp.Body.Close()
+
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(resp.Body)
+	require.Nil(t, err)
+	return families
+}
+
+// sample returns the value of the sample of a metric family with the given labels.
+func sample(t *testing.T, families map[string]*dto.MetricFamily, name string, labels map[string]string) float64 {
+	t.Helper()
+
+	family, ok := families[name]
+	require.True(t, ok, "missing metric %s", name)
+
+	for _, metric := range family.GetMetric() {
+		matched := 0
+		for _, label := range metric.GetLabel() {
+			if value, ok := labels[label.GetName()]; ok && value == label.GetValue() {
+				matched++
+			}
+		}
+		if matched != len(labels) {
+			continue
+		}
+
+		switch family.GetType() {
+		case dto.MetricType_COUNTER:
+			return metric.GetCounter().GetValue()
+		case dto.MetricType_GAUGE:
+			return metric.GetGauge().GetValue()
+		case dto.MetricType_HISTOGRAM:
+			return float64(metric.GetHistogram().GetSampleCount())
+		default:
+			require.Fail(t, "unexpected metric type", "%s: %v", name, family.GetType())
+		}
+	}
+
+	require.Fail(t, "missing sample", "%s%v", name, labels)
+	return 0
+}
+
+func TestControlplaneMetrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	m, err := metrics.NewControlplaneMetrics(registry)
+	require.Nil(t, err)
+
+	m.ObserveAuthorizationDecision("egress", true, "default/allow-all")
+	m.ObserveAuthorizationDecision("egress", true, "default/allow-all")
+	m.ObserveAuthorizationDecision("ingress", false, "")
+	m.ObserveLoadBalancingSelection("default/svc", "peer1", "default/svc")
+	m.SetPeerReachable("peer1", true)
+	m.SetPeerReachable("peer2", false)
+	m.ObservePeerHeartbeat("peer1", 5*time.Millisecond)
+	m.ObserveXDSPush("cluster", "update")
+	m.ObserveXDSPush("listener", "delete")
+	m.SetXDSConnectedDataplanes(2)
+	m.ObserveXDSNACK("listener")
+	m.SetPortLeases(3)
+	m.ObserveConnectionRateLimited("ingress", "default/svc")
+
+	families := scrape(t, registry)
+	require.Equal(t, 2.0, sample(t, families, "clusterlink_controlplane_authorization_decisions_total",
+		map[string]string{"direction": "egress", "result": "allow", "policy": "default/allow-all"}))
+	require.Equal(t, 1.0, sample(t, families, "clusterlink_controlplane_authorization_decisions_total",
+		map[string]string{"direction": "ingress", "result": "deny", "policy": ""}))
+	require.Equal(t, 1.0, sample(t, families, "clusterlink_controlplane_load_balancing_selections_total",
+		map[string]string{"import": "default/svc", "peer": "peer1", "export": "default/svc"}))
+	require.Equal(t, 1.0, sample(t, families, "clusterlink_controlplane_peer_reachable",
+		map[string]string{"peer": "peer1"}))
+	require.Equal(t, 0.0, sample(t, families, "clusterlink_controlplane_peer_reachable",
+		map[string]string{"peer": "peer2"}))
+	require.Equal(t, 1.0, sample(t, families, "clusterlink_controlplane_peer_heartbeat_rtt_seconds",
+		map[string]string{"peer": "peer1"}))
+	require.Equal(t, 1.0, sample(t, families, "clusterlink_controlplane_xds_pushes_total",
+		map[string]string{"type": "cluster", "operation": "update"}))
+	require.Equal(t, 1.0, sample(t, families, "clusterlink_controlplane_xds_pushes_total",
+		map[string]string{"type": "listener", "operation": "delete"}))
+	require.Equal(t, 2.0, sample(t, families, "clusterlink_controlplane_xds_connected_dataplanes", nil))
+	require.Equal(t, 1.0, sample(t, families, "clusterlink_controlplane_xds_nacks_total",
+		map[string]string{"type": "listener"}))
+	require.Equal(t, 3.0, sample(t, families, "clusterlink_controlplane_port_leases", nil))
+	require.Equal(t, 1.0, sample(t, families, "clusterlink_controlplane_rate_limited_connections_total",
+		map[string]string{"direction": "ingress", "service": "default/svc"}))
+
+	// deleted peer metrics are removed
+	m.DeletePeer("peer2")
+	families = scrape(t, registry)
+	require.Len(t, families["clusterlink_controlplane_peer_reachable"].GetMetric(), 1)
+
+	// nil metrics discard observations
+	var nilMetrics *metrics.ControlplaneMetrics
+	nilMetrics.ObserveAuthorizationDecision("egress", true, "")
+	nilMetrics.SetPortLeases(1)
+}
+
+func TestDataplaneMetrics(t *testing.T) {
+	registry := metrics.NewRegistry()
+	m, err := metrics.NewDataplaneMetrics(registry)
+	require.Nil(t, err)
+
+	m.ConnectionStarted("egress", "default/svc")
+	m.ConnectionStarted("egress", "default/svc")
+	m.ConnectionEnded("egress", "default/svc")
+	m.AddBytes("egress", "default/svc", "peer1", 100, 20)
+	m.AddBytes("egress", "default/svc", "peer1", 50, 0)
+	m.ConnectionsDrained("egress", 3, 1)
+	m.ConnectionRejected("ingress", "default/svc", "max_client_connections")
+
+	families := scrape(t, registry)
+	require.Equal(t, 1.0, sample(t, families, "clusterlink_dataplane_active_connections",
+		map[string]string{"direction": "egress", "service": "default/svc"}))
+	require.Equal(t, 150.0, sample(t, families, "clusterlink_dataplane_bytes_total",
+		map[string]string{"direction": "egress", "service": "default/svc", "peer": "peer1", "stream": "incoming"}))
+	require.Equal(t, 20.0, sample(t, families, "clusterlink_dataplane_bytes_total",
+		map[string]string{"direction": "egress", "service": "default/svc", "peer": "peer1", "stream": "outgoing"}))
+	require.Equal(t, 1.0, sample(t, families, "clusterlink_dataplane_drained_connections_total",
+		map[string]string{"direction": "egress", "result": "closed"}))
+	require.Equal(t, 1.0, sample(t, families, "clusterlink_dataplane_rejected_connections_total",
+		map[string]string{"direction": "ingress", "service": "default/svc", "reason": "max_client_connections"}))
+
+	// runtime metrics are included
+	_, ok := families["go_goroutines"]
+	require.True(t, ok)
+
+	// metrics cannot be registered twice
+	_, err = metrics.NewDataplaneMetrics(registry)
+	require.NotNil(t, err)
+}
@@ -15,8 +15,11 @@ package controller
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"reflect"
+	"sort"
+	"strings"
 	"time"
 
 	appsv1 "k8s.io/api/apps/v1"
@@ -28,9 +31,12 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	cpapp "github.com/clusterlink-net/clusterlink/cmd/cl-controlplane/app"
 	dpapp "github.com/clusterlink-net/clusterlink/cmd/cl-dataplane/app"
@@ -52,6 +58,7 @@ const (
 	StatusModeNotExist    = "NotExist"
 	StatusModeProgressing = "ProgressingMode"
 	StatusModeReady       = "Ready"
+	StatusModeRejected    = "Rejected"
 )
 
 // InstanceReconciler reconciles a ClusterLink instance object.
@@ -69,6 +76,7 @@ type InstanceReconciler struct {
 // +kubebuilder:rbac:groups="discovery.k8s.io",resources=endpointslices,verbs=list;get;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups="",resources=nodes,verbs=list;get;watch
 // +kubebuilder:rbac:groups="",resources=pods,verbs=list;get;watch
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=list;get;watch;create;update
 // +kubebuilder:rbac:groups=clusterlink.net,resources=exports;peers;accesspolicies;privilegedaccesspolicies,verbs=list;get;watch
 // +kubebuilder:rbac:groups=clusterlink.net,resources=imports,verbs=get;list;watch;update
 // +kubebuilder:rbac:groups=clusterlink.net,resources=peers/status;exports/status;imports/status,verbs=update
@@ -98,9 +106,39 @@ func (r *InstanceReconciler) SetupWithManager(mgr ctrl.Manager) error {
 			},
 			&handler.EnqueueRequestForObject{},
 		).
+		Watches(
+			&corev1.ConfigMap{},
+			handler.EnqueueRequestsFromMapFunc(r.namespaceInstances),
+			builder.WithPredicates(predicate.NewPredicateFuncs(func(object client.Object) bool {
+				return object.GetName() == cpapi.XDSStatusConfigMapName
+			})),
+		).
 		Complete(r)
 }
 
+// namespaceInstances returns reconcile requests for the instances deployed to the namespace of an object.
+func (r *InstanceReconciler) namespaceInstances(ctx context.Context, object client.Object) []reconcile.Request {
+	instanceList := &clusterlink.InstanceList{}
+	if err := r.List(ctx, instanceList, client.InNamespace(OperatorNamespace)); err != nil {
+		r.Logger.Errorf("Cannot list instances: %v", err)
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for i := range instanceList.Items {
+		if instanceList.Items[i].Spec.Namespace == object.GetNamespace() {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Name:      instanceList.Items[i].Name,
+					Namespace: instanceList.Items[i].Namespace,
+				},
+			})
+		}
+	}
+
+	return requests
+}
+
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
 // The reconcile get instance YAML and creates the ClusterLink components.
@@ -241,7 +279,11 @@ func (r *InstanceReconciler) applyControlplane(ctx context.Context, instance *cl
 				Name:            ControlPlaneName,
 				Image:           instance.Spec.ContainerRegistry + ControlPlaneName + ":" + instance.Spec.Tag,
 				ImagePullPolicy: corev1.PullIfNotPresent,
-				Args:            []string{"--log-level", instance.Spec.LogLevel, "--crd-mode"},
+				Args: []string{
+					"--log-level", instance.Spec.LogLevel,
+					"--dataplane", string(instance.Spec.DataPlane.Type),
+					"--crd-mode",
+				},
 				Ports: []corev1.ContainerPort{
 					{
 						ContainerPort: cpapi.ListenPort,
@@ -280,6 +322,36 @@ func (r *InstanceReconciler) applyControlplane(ctx context.Context, instance *cl
 			},
 		},
 	}
+
+	if instance.Spec.DataPlane.Type != clusterlink.DataplaneTypeGo {
+		// the controlplane serves the dataplane certificate to envoy dataplanes over SDS
+		podSpec := &cpDeployment.Spec.Template.Spec
+		podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+			Name: "dataplane-tls",
+			VolumeSource: corev1.VolumeSource{
+				Projected: &corev1.ProjectedVolumeSource{
+					Sources: []corev1.VolumeProjection{
+						{
+							Secret: &corev1.SecretProjection{
+								LocalObjectReference: corev1.LocalObjectReference{Name: "cl-fabric"},
+							},
+						},
+						{
+							Secret: &corev1.SecretProjection{
+								LocalObjectReference: corev1.LocalObjectReference{Name: DataPlaneName},
+							},
+						},
+					},
+				},
+			},
+		})
+		podSpec.Containers[0].VolumeMounts = append(podSpec.Containers[0].VolumeMounts, corev1.VolumeMount{
+			Name:      "dataplane-tls",
+			MountPath: cpapp.DataplaneCertificateDirectory,
+			ReadOnly:  true,
+		})
+	}
+
 	return r.createOrUpdateResource(ctx, &cpDeployment)
 }
 
@@ -317,6 +389,7 @@ func (r *InstanceReconciler) applyDataplane(ctx context.Context, instance *clust
 				Args: []string{
 					"--log-level", instance.Spec.LogLevel,
 					"--controlplane-host", ControlPlaneName,
+					"--listen-addresses", "$(" + dpapp.PodIPsEnvVariable + ")",
 				},
 				ImagePullPolicy: corev1.PullIfNotPresent,
 				Ports: []corev1.ContainerPort{
@@ -324,6 +397,16 @@ func (r *InstanceReconciler) applyDataplane(ctx context.Context, instance *clust
 						ContainerPort: dpapi.ListenPort,
 					},
 				},
+				Env: []corev1.EnvVar{
+					{
+						Name: dpapp.PodIPsEnvVariable,
+						ValueFrom: &corev1.EnvVarSource{
+							FieldRef: &corev1.ObjectFieldSelector{
+								FieldPath: "status.podIPs",
+							},
+						},
+					},
+				},
 				VolumeMounts: []corev1.VolumeMount{
 					{
 						Name:      "ca",
@@ -427,6 +510,11 @@ func (r *InstanceReconciler) createAccessControl(ctx context.Context, name, name
 				Resources: []string{"pods"},
 				Verbs:     []string{"get", "list", "watch"},
 			},
+			{
+				APIGroups: []string{""},
+				Resources: []string{"configmaps"},
+				Verbs:     []string{"get", "create", "update"},
+			},
 			{
 				APIGroups: []string{"clusterlink.net"},
 				Resources: []string{"peers", "exports", "accesspolicies", "privilegedaccesspolicies"},
@@ -584,6 +672,11 @@ func (r *InstanceReconciler) deleteClusterLink(ctx context.Context, namespace st
 		return err
 	}
 
+	xdsStatusObj := metav1.ObjectMeta{Name: cpapi.XDSStatusConfigMapName, Namespace: namespace}
+	if err := r.deleteResource(ctx, &corev1.ConfigMap{ObjectMeta: xdsStatusObj}); err != nil {
+		return err
+	}
+
 	// Delete dataplane Resources
 	dpObj := metav1.ObjectMeta{Name: DataPlaneName, Namespace: namespace}
 	if err := r.deleteResource(ctx, &appsv1.Deployment{ObjectMeta: dpObj}); err != nil {
@@ -673,14 +766,94 @@ func (r *InstanceReconciler) checkDataplaneStatus(ctx context.Context, instance
 		return false, err
 	}
 
+	syncStatus, err := r.checkDataplaneSyncStatus(ctx, instance.Spec.Namespace)
+	if err != nil {
+		return false, err
+	}
+
 	if instance.Status.Dataplane.Conditions == nil {
 		instance.Status.Dataplane.Conditions = make(map[string]metav1.Condition)
 	}
 
-	updateFlag := r.updateCondition(instance.Status.Dataplane.Conditions, []metav1.Condition{deploymentStatus, serviceStatus})
+	updateFlag := r.updateCondition(
+		instance.Status.Dataplane.Conditions,
+		[]metav1.Condition{deploymentStatus, serviceStatus, syncStatus})
 	return updateFlag, nil
 }
 
+// checkDataplaneSyncStatus checks whether the dataplanes acknowledged their latest configuration,
+// according to the xDS status published by the controlplane.
+func (r *InstanceReconciler) checkDataplaneSyncStatus(ctx context.Context, namespace string) (metav1.Condition, error) {
+	status := metav1.Condition{
+		Type:               string(clusterlink.DataplaneSynced),
+		Status:             metav1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+	}
+
+	configMap := &corev1.ConfigMap{}
+	name := types.NamespacedName{Name: cpapi.XDSStatusConfigMapName, Namespace: namespace}
+	if err := r.Get(ctx, name, configMap); err != nil {
+		if errors.IsNotFound(err) {
+			status.Reason = StatusModeNotExist
+			status.Message = "Dataplane sync status was not published by the controlplane"
+			return status, nil
+		}
+		return metav1.Condition{}, err
+	}
+
+	var nodes []cpapi.XDSNodeStatus
+	if err := json.Unmarshal([]byte(configMap.Data[cpapi.XDSStatusConfigMapKey]), &nodes); err != nil {
+		status.Reason = StatusModeNotExist
+		status.Message = fmt.Sprintf("Cannot decode dataplane sync status: %v", err)
+		return status, nil
+	}
+
+	if len(nodes) == 0 {
+		status.Reason = StatusModeProgressing
+		status.Message = "No dataplane is connected to the controlplane"
+		return status, nil
+	}
+
+	var rejected, progressing []string
+	for i := range nodes {
+		resourceTypes := make([]string, 0, len(nodes[i].Resources))
+		for resourceType := range nodes[i].Resources {
+			resourceTypes = append(resourceTypes, resourceType)
+		}
+		sort.Strings(resourceTypes)
+
+		synced := true
+		for _, resourceType := range resourceTypes {
+			resourceStatus := nodes[i].Resources[resourceType]
+			if resourceStatus.Rejected() {
+				rejected = append(rejected, fmt.Sprintf("%s rejected %s version %s: %s",
+					nodes[i].ID, resourceType, resourceStatus.LastNACK.Version, resourceStatus.LastNACK.Message))
+			}
+			synced = synced && resourceStatus.Synced()
+		}
+
+		if !synced {
+			progressing = append(progressing, nodes[i].ID)
+		}
+	}
+
+	switch {
+	case len(rejected) > 0:
+		status.Reason = StatusModeRejected
+		status.Message = strings.Join(rejected, "; ")
+	case len(progressing) > 0:
+		status.Reason = StatusModeProgressing
+		status.Message = fmt.Sprintf("%d of %d dataplanes are not synced: %s",
+			len(progressing), len(nodes), strings.Join(progressing, ", "))
+	default:
+		status.Status = metav1.ConditionTrue
+		status.Reason = StatusModeReady
+		status.Message = fmt.Sprintf("All %d dataplanes are synced", len(nodes))
+	}
+
+	return status, nil
+}
+
 // checkIngressStatus check the status of the ingress components.
 func (r *InstanceReconciler) checkIngressStatus(ctx context.Context, instance *clusterlink.Instance) (bool, error) {
 	ingress := types.NamespacedName{Name: IngressName, Namespace: instance.Spec.Namespace}
@@ -0,0 +1,349 @@
+// Copyright 2023 The ClusterLink Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Level is the verbosity of the audit log.
+type Level int
+
+const (
+	// LevelNone disables the audit log.
+	LevelNone Level = iota
+	// LevelManagement records management operations which modify objects.
+	LevelManagement
+	// LevelAuthorization additionally records all authorization decisions.
+	LevelAuthorization
+	// LevelAll additionally records management operations which only read objects.
+	LevelAll
+)
+
+var levelNames = map[string]Level{
+	"none":          LevelNone,
+	"management":    LevelManagement,
+	"authorization": LevelAuthorization,
+	"all":           LevelAll,
+}
+
+// ParseLevel parses an audit level name.
+func ParseLevel(name string) (Level, error) {
+	level, ok := levelNames[name]
+	if !ok {
+		return LevelNone, fmt.Errorf("unknown audit level '%s'", name)
+	}
+
+	return level, nil
+}
+
+// Category is the category of an audited operation.
+type Category string
+
+const (
+	// CategoryManagement is the category of management operations.
+	CategoryManagement Category = "management"
+	// CategoryAuthorization is the category of authorization decisions.
+	CategoryAuthorization Category = "authorization"
+)
+
+// Outcome is the outcome of an audited operation.
+type Outcome string
+
+const (
+	// OutcomeSuccess indicates a management operation which succeeded.
+	OutcomeSuccess Outcome = "success"
+	// OutcomeFailure indicates a management operation which failed.
+	OutcomeFailure Outcome = "failure"
+	// OutcomeAllow indicates an allowed operation.
+	OutcomeAllow Outcome = "allow"
+	// OutcomeDeny indicates a denied operation.
+	OutcomeDeny Outcome = "deny"
+)
+
+// Record is a single audit log entry.
+type Record struct {
+	// Sequence is the sequence number of the record in the audit log.
+	Sequence uint64 `json:"sequence"`
+	// Time the record was logged.
+	Time time.Time `json:"time"`
+	// Category of the audited operation.
+	Category Category `json:"category"`
+	// Actor is the identity performing the operation.
+	Actor string `json:"actor,omitempty"`
+	// Action is the operation performed.
+	Action string `json:"action"`
+	// Resource is the type of object the operation was performed on.
+	Resource string `json:"resource,omitempty"`
+	// Name of the object the operation was performed on.
+	Name string `json:"name,omitempty"`
+	// Outcome of the operation.
+	Outcome Outcome `json:"outcome"`
+	// Reason for the outcome.
+	Reason string `json:"reason,omitempty"`
+	// Policy is the name of the policy deciding the outcome.
+	Policy string `json:"policy,omitempty"`
+	// Source attributes of an authorized connection.
+	Source map[string]string `json:"source,omitempty"`
+	// Destination attributes of an authorized connection.
+	Destination map[string]string `json:"destination,omitempty"`
+	// PrevHash is the hash of the previous record in the audit log.
+	PrevHash string `json:"prevHash"`
+	// Hash of this record, including PrevHash, chaining the audit log records.
+	Hash string `json:"hash"`
+	// Signature of Hash, using the signing key of the audit logger.
+	// Since records cannot be re-signed without the signing key, modifying, removing or
+	// inserting records breaks the chain of signed hashes.
+	Signature string `json:"signature"`
+}
+
+// computeHash returns the hash of a record, ignoring its current Hash and Signature fields.
+func (r *Record) computeHash() (string, error) {
+	unhashed := *r
+	unhashed.Hash = ""
+	unhashed.Signature = ""
+
+	encoded, err := json.Marshal(&unhashed)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Logger writes hash-chained and signed audit records, one JSON object per line.
+// A nil logger discards all records.
+type Logger struct {
+	level      Level
+	writer     io.Writer
+	closer     io.Closer
+	signingKey ed25519.PrivateKey
+
+	lock     sync.Mutex
+	sequence uint64
+	lastHash string
+
+	logger *logrus.Entry
+}
+
+// Enabled returns true if records of the given level are logged.
+func (l *Logger) Enabled(level Level) bool {
+	return l != nil && level <= l.level
+}
+
+// Log writes a record of the given level, if enabled.
+// The record sequence number, time, hashes and signature are set by the logger.
+func (l *Logger) Log(level Level, record *Record) {
+	if !l.Enabled(level) {
+		return
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	record.Sequence = l.sequence + 1
+	record.Time = time.Now().UTC()
+	record.PrevHash = l.lastHash
+
+	hash, err := record.computeHash()
+	if err != nil {
+		l.logger.Errorf("Cannot hash audit record: %v.", err)
+		return
+	}
+	record.Hash = hash
+	record.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(l.signingKey, []byte(hash)))
+
+	encoded, err := json.Marshal(record)
+	if err != nil {
+		l.logger.Errorf("Cannot encode audit record: %v.", err)
+		return
+	}
+
+	if _, err := l.writer.Write(append(encoded, '\n')); err != nil {
+		l.logger.Errorf("Cannot write audit record: %v.", err)
+		return
+	}
+
+	l.sequence = record.Sequence
+	l.lastHash = record.Hash
+}
+
+// Close the audit log.
+func (l *Logger) Close() error {
+	if l == nil || l.closer == nil {
+		return nil
+	}
+
+	return l.closer.Close()
+}
+
+// NewLogger returns a new audit logger writing to the given writer, signing records using the given key.
+func NewLogger(level Level, writer io.Writer, signingKey ed25519.PrivateKey) *Logger {
+	return &Logger{
+		level:      level,
+		writer:     writer,
+		signingKey: signingKey,
+		logger:     logrus.WithField("component", "audit"),
+	}
+}
+
+// ReadSigningKey reads a PEM-encoded (PKCS #8) Ed25519 private key for signing audit records.
+func ReadSigningKey(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read audit signing key: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("cannot decode audit signing key: no PEM data found")
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse audit signing key: %w", err)
+	}
+
+	signingKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("audit signing key is not an Ed25519 key")
+	}
+
+	return signingKey, nil
+}
+
+// Open returns a new audit logger writing to the given file path, or to stdout if path is empty.
+// An existing audit log file is appended to, continuing its hash chain.
+func Open(level Level, path string, signingKey ed25519.PrivateKey) (*Logger, error) {
+	if len(signingKey) != ed25519.PrivateKeySize {
+		return nil, errors.New("invalid audit signing key")
+	}
+
+	if path == "" {
+		return NewLogger(level, os.Stdout, signingKey), nil
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open audit log file: %w", err)
+	}
+
+	last, err := lastRecord(file)
+	if err != nil {
+		if closeErr := file.Close(); closeErr != nil {
+			logrus.Warnf("Cannot close audit log file: %v.", closeErr)
+		}
+		return nil, fmt.Errorf("cannot read audit log file: %w", err)
+	}
+
+	logger := NewLogger(level, file, signingKey)
+	logger.closer = file
+	if last != nil {
+		logger.sequence = last.Sequence
+		logger.lastHash = last.Hash
+	}
+
+	return logger, nil
+}
+
+// lastRecord returns the last record read from r, or nil if there are no records.
+func lastRecord(r io.Reader) (*Record, error) {
+	var last *Record
+	err := scan(r, func(record *Record) error {
+		last = record
+		return nil
+	})
+
+	return last, err
+}
+
+// scan decodes the records read from r, one per line.
+func scan(r io.Reader, handler func(record *Record) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return err
+		}
+
+		if err := handler(&record); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
+
+// Verify verifies the hash chain of the audit records read from r,
+// and that each record is signed by the private key of the given public key.
+// Returns the number of verified records.
+func Verify(r io.Reader, publicKey ed25519.PublicKey) (int, error) {
+	var count int
+	var prev *Record
+	err := scan(r, func(record *Record) error {
+		if prev != nil {
+			if record.Sequence != prev.Sequence+1 {
+				return fmt.Errorf("record %d: expected sequence %d", record.Sequence, prev.Sequence+1)
+			}
+
+			if record.PrevHash != prev.Hash {
+				return fmt.Errorf("record %d: previous hash mismatch", record.Sequence)
+			}
+		}
+
+		hash, err := record.computeHash()
+		if err != nil {
+			return fmt.Errorf("record %d: %w", record.Sequence, err)
+		}
+
+		if hash != record.Hash {
+			return fmt.Errorf("record %d: hash mismatch", record.Sequence)
+		}
+
+		signature, err := base64.StdEncoding.DecodeString(record.Signature)
+		if err != nil || !ed25519.Verify(publicKey, []byte(record.Hash), signature) {
+			return fmt.Errorf("record %d: invalid signature", record.Sequence)
+		}
+
+		prev = record
+		count++
+		return nil
+	})
+	if err != nil {
+		return count, fmt.Errorf("audit log verification failed: %w", err)
+	}
+
+	return count, nil
+}
@@ -0,0 +1,166 @@
+// Copyright 2023 The ClusterLink Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit_test
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/clusterlink-net/clusterlink/pkg/util/audit"
+)
+
+// generateKey returns a new audit signing key and its public key.
+func generateKey(t *testing.T) (ed25519.PrivateKey, ed25519.PublicKey) {
+	t.Helper()
+
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.Nil(t, err)
+	return privateKey, publicKey
+}
+
+func managementRecord(name string) *audit.Record {
+	return &audit.Record{
+		Category: audit.CategoryManagement,
+		Actor:    "admin",
+		Action:   "create",
+		Resource: "/peers",
+		Name:     name,
+		Outcome:  audit.OutcomeSuccess,
+	}
+}
+
+func TestLevel(t *testing.T) {
+	privateKey, publicKey := generateKey(t)
+	var buf bytes.Buffer
+	logger := audit.NewLogger(audit.LevelManagement, &buf, privateKey)
+
+	logger.Log(audit.LevelManagement, managementRecord("peer1"))
+	logger.Log(audit.LevelAuthorization, &audit.Record{Category: audit.CategoryAuthorization})
+	logger.Log(audit.LevelAll, managementRecord("peer2"))
+
+	count, err := audit.Verify(&buf, publicKey)
+	require.Nil(t, err)
+	require.Equal(t, 1, count)
+
+	_, err = audit.ParseLevel("unknown")
+	require.NotNil(t, err)
+
+	// nil logger discards records
+	var nilLogger *audit.Logger
+	require.False(t, nilLogger.Enabled(audit.LevelManagement))
+	nilLogger.Log(audit.LevelManagement, managementRecord("peer1"))
+}
+
+func TestHashChain(t *testing.T) {
+	privateKey, publicKey := generateKey(t)
+	var buf bytes.Buffer
+	logger := audit.NewLogger(audit.LevelAll, &buf, privateKey)
+	for _, name := range []string{"peer1", "peer2", "peer3"} {
+		logger.Log(audit.LevelManagement, managementRecord(name))
+	}
+
+	log := buf.String()
+	count, err := audit.Verify(strings.NewReader(log), publicKey)
+	require.Nil(t, err)
+	require.Equal(t, 3, count)
+
+	// modified record
+	_, err = audit.Verify(strings.NewReader(strings.Replace(log, "peer2", "peer4", 1)), publicKey)
+	require.NotNil(t, err)
+
+	// removed record
+	lines := strings.SplitAfter(log, "\n")
+	_, err = audit.Verify(strings.NewReader(lines[0]+lines[2]), publicKey)
+	require.NotNil(t, err)
+
+	// signed by another key
+	_, otherPublicKey := generateKey(t)
+	_, err = audit.Verify(strings.NewReader(log), otherPublicKey)
+	require.NotNil(t, err)
+
+	// modified record with a recomputed hash chain
+	var forged strings.Builder
+	prevHash := ""
+	for i, line := range lines[:3] {
+		var record audit.Record
+		require.Nil(t, json.Unmarshal([]byte(line), &record))
+		if i == 1 {
+			record.Name = "peer4"
+		}
+
+		record.PrevHash = prevHash
+		record.Hash = ""
+		signature := record.Signature
+		record.Signature = ""
+		encoded, err := json.Marshal(&record)
+		require.Nil(t, err)
+		sum := sha256.Sum256(encoded)
+		record.Hash = hex.EncodeToString(sum[:])
+		record.Signature = signature
+		prevHash = record.Hash
+
+		encoded, err = json.Marshal(&record)
+		require.Nil(t, err)
+		forged.Write(append(encoded, '\n'))
+	}
+
+	_, err = audit.Verify(strings.NewReader(forged.String()), publicKey)
+	require.NotNil(t, err)
+}
+
+func TestReopen(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "audit.log")
+
+	privateKey, publicKey := generateKey(t)
+	encodedKey, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	require.Nil(t, err)
+	keyPath := filepath.Join(dir, "audit.key")
+	require.Nil(t, os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: encodedKey}), 0o600))
+	signingKey, err := audit.ReadSigningKey(keyPath)
+	require.Nil(t, err)
+
+	_, err = audit.Open(audit.LevelManagement, path, nil)
+	require.NotNil(t, err)
+
+	logger, err := audit.Open(audit.LevelManagement, path, signingKey)
+	require.Nil(t, err)
+	logger.Log(audit.LevelManagement, managementRecord("peer1"))
+	require.Nil(t, logger.Close())
+
+	logger, err = audit.Open(audit.LevelManagement, path, signingKey)
+	require.Nil(t, err)
+	logger.Log(audit.LevelManagement, managementRecord("peer2"))
+	require.Nil(t, logger.Close())
+
+	file, err := os.Open(path)
+	require.Nil(t, err)
+	defer file.Close()
+
+	count, err := audit.Verify(file, publicKey)
+	require.Nil(t, err)
+	require.Equal(t, 2, count)
+}
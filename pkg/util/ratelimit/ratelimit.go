@@ -0,0 +1,95 @@
+// Copyright 2023 The ClusterLink Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// pruneInterval is the interval between removals of idle buckets.
+const pruneInterval = time.Minute
+
+// bucket is a token bucket, holding up to one second worth of tokens.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// refill adds the tokens accumulated since the last refill, up to the given rate.
+func (b *bucket) refill(now time.Time, rate float64) {
+	b.tokens = min(rate, b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+}
+
+// Limiter limits the rate of events, separately for each key.
+// Each key has a token bucket, which allows bursts of up to one second worth of events.
+type Limiter struct {
+	lock      sync.Mutex
+	buckets   map[string]*bucket
+	lastPrune time.Time
+
+	// now returns the current time.
+	now func() time.Time
+}
+
+// Allow returns true if an event of the given key does not exceed the given rate (events per second).
+// An allowed event consumes a token from the key bucket. A zero rate allows all events.
+func (l *Limiter) Allow(key string, rate uint32) bool {
+	if rate == 0 {
+		return true
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := l.now()
+	if now.Sub(l.lastPrune) >= pruneInterval {
+		l.prune(now)
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(rate), last: now}
+		l.buckets[key] = b
+	}
+
+	b.refill(now, float64(rate))
+	if b.tokens < 1 {
+		return false
+	}
+
+	b.tokens--
+	return true
+}
+
+// prune removes buckets which were refilled by now, as they are equivalent to new buckets.
+// Buckets are refilled within a second of their last event.
+func (l *Limiter) prune(now time.Time) {
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= time.Second {
+			delete(l.buckets, key)
+		}
+	}
+
+	l.lastPrune = now
+}
+
+// NewLimiter returns a new rate limiter.
+func NewLimiter() *Limiter {
+	return &Limiter{
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
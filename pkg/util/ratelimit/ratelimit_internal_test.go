@@ -0,0 +1,51 @@
+// Copyright 2023 The ClusterLink Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiter(t *testing.T) {
+	now := time.Now()
+	l := NewLimiter()
+	l.now = func() time.Time { return now }
+
+	// zero rate is not limited
+	for i := 0; i < 10; i++ {
+		require.True(t, l.Allow("unlimited", 0))
+	}
+
+	// a burst of up to one second worth of events is allowed
+	for i := 0; i < 4; i++ {
+		require.True(t, l.Allow("a", 4))
+	}
+	require.False(t, l.Allow("a", 4))
+
+	// keys are limited separately
+	require.True(t, l.Allow("b", 4))
+
+	// tokens are refilled by the rate
+	now = now.Add(time.Second / 4)
+	require.True(t, l.Allow("a", 4))
+	require.False(t, l.Allow("a", 4))
+
+	// refilled buckets are pruned
+	now = now.Add(pruneInterval)
+	require.True(t, l.Allow("a", 4))
+	require.Len(t, l.buckets, 1)
+}
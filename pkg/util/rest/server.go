@@ -14,6 +14,7 @@
 package rest
 
 import (
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"errors"
@@ -25,6 +26,7 @@ import (
 	"github.com/sirupsen/logrus"
 
 	"github.com/clusterlink-net/clusterlink/pkg/store"
+	"github.com/clusterlink-net/clusterlink/pkg/util/audit"
 	utilhttp "github.com/clusterlink-net/clusterlink/pkg/util/http"
 )
 
@@ -32,9 +34,42 @@ import (
 type Server struct {
 	utilhttp.Server
 
+	authorizer  Authorizer
+	auditLogger *audit.Logger
+
 	logger *logrus.Entry
 }
 
+// Operation is an operation on objects of a specific type.
+type Operation string
+
+const (
+	// OperationCreate creates an object.
+	OperationCreate Operation = "create"
+	// OperationUpdate updates an object.
+	OperationUpdate Operation = "update"
+	// OperationGet gets an object.
+	OperationGet Operation = "get"
+	// OperationDelete deletes an object.
+	OperationDelete Operation = "delete"
+	// OperationList lists all objects.
+	OperationList Operation = "list"
+	// OperationDump dumps the internal state of the server, which is not scoped to specific objects.
+	OperationDump Operation = "dump"
+)
+
+// IsReadOnly returns true if the operation does not modify objects.
+func (o Operation) IsReadOnly() bool {
+	return o == OperationGet || o == OperationList || o == OperationDump
+}
+
+// Authorizer for object operations.
+type Authorizer interface {
+	// Authorize returns an error if a request is not allowed to perform an operation
+	// on the object type served under basePath.
+	Authorize(r *http.Request, basePath string, op Operation) error
+}
+
 // Handler for object operations.
 type Handler interface {
 	// Decode and validate an object.
@@ -80,6 +115,8 @@ func (s *Server) create(spec *ServerObjectSpec, w http.ResponseWriter, r *http.R
 		return
 	}
 
+	setAuditName(r, object)
+
 	if err := spec.Handler.Create(object); err != nil {
 		var objectExistsErr *store.ObjectExistsError
 		if errors.As(err, &objectExistsErr) {
@@ -116,6 +153,8 @@ func (s *Server) update(spec *ServerObjectSpec, w http.ResponseWriter, r *http.R
 		return
 	}
 
+	setAuditName(r, object)
+
 	if err := spec.Handler.Update(object); err != nil {
 		var objectNotFoundError *store.ObjectNotFoundError
 		if errors.As(err, &objectNotFoundError) {
@@ -185,6 +224,8 @@ func (s *Server) deleteObject(spec *ServerObjectSpec, w http.ResponseWriter, r *
 		return
 	}
 
+	setAuditName(r, object)
+
 	result, err := spec.Handler.Delete(object)
 	if err != nil {
 		requestLogger.Errorf("Cannot delete object: %v.", err)
@@ -247,36 +288,137 @@ func (s *Server) list(spec *ServerObjectSpec, w http.ResponseWriter, r *http.Req
 	}
 }
 
+// SetAuthorizer sets the authorizer for all object operations.
+// If no authorizer is set, all operations are allowed.
+func (s *Server) SetAuthorizer(authorizer Authorizer) {
+	s.authorizer = authorizer
+}
+
+// SetAuditLogger sets the audit logger for all object operations.
+func (s *Server) SetAuditLogger(auditLogger *audit.Logger) {
+	s.auditLogger = auditLogger
+}
+
+// auditRecordKey is the request context key holding the audit record of the request.
+type auditRecordKey struct{}
+
+// setAuditName sets the audited object name of a request, according to the Name field of a decoded object.
+func setAuditName(r *http.Request, object any) {
+	record, ok := r.Context().Value(auditRecordKey{}).(*audit.Record)
+	if !ok {
+		return
+	}
+
+	value := reflect.Indirect(reflect.ValueOf(object))
+	if value.Kind() != reflect.Struct {
+		return
+	}
+
+	if name := value.FieldByName("Name"); name.Kind() == reflect.String {
+		record.Name = name.String()
+	}
+}
+
+// requestActor returns the identity of the client issuing a request.
+func requestActor(r *http.Request) string {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+		return ""
+	}
+
+	return r.TLS.VerifiedChains[0][0].Subject.CommonName
+}
+
+// statusRecorder is a response writer recording the response status code.
+type statusRecorder struct {
+	http.ResponseWriter
+
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// handle wraps an object operation handler with authorization and auditing.
+func (s *Server) handle(
+	spec *ServerObjectSpec,
+	op Operation,
+	handler func(*ServerObjectSpec, http.ResponseWriter, *http.Request),
+) http.HandlerFunc {
+	level := audit.LevelManagement
+	if op.IsReadOnly() {
+		level = audit.LevelAll
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		record := &audit.Record{
+			Category: audit.CategoryManagement,
+			Actor:    requestActor(r),
+			Action:   string(op),
+			Resource: spec.BasePath,
+			Name:     chi.URLParam(r, "name"),
+		}
+
+		if s.authorizer != nil {
+			if err := s.authorizer.Authorize(r, spec.BasePath, op); err != nil {
+				s.logger.WithFields(logrus.Fields{"operation": op, "path": r.URL.Path}).Warnf(
+					"Request not authorized: %v.", err)
+				http.Error(w, err.Error(), http.StatusForbidden)
+
+				// denied requests are audited regardless of the operation
+				record.Outcome = audit.OutcomeDeny
+				record.Reason = err.Error()
+				s.auditLogger.Log(audit.LevelManagement, record)
+				return
+			}
+		}
+
+		if !s.auditLogger.Enabled(level) {
+			handler(spec, w, r)
+			return
+		}
+
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		handler(spec, recorder, r.WithContext(context.WithValue(r.Context(), auditRecordKey{}, record)))
+
+		record.Outcome = audit.OutcomeSuccess
+		if recorder.status >= http.StatusBadRequest {
+			record.Outcome = audit.OutcomeFailure
+			record.Reason = http.StatusText(recorder.status)
+		}
+		s.auditLogger.Log(level, record)
+	}
+}
+
 // AddObjectHandlers adds the server a handlers for managing a specific object type.
 func (s *Server) AddObjectHandlers(spec *ServerObjectSpec) {
 	router := s.Router()
 
 	router.Route(spec.BasePath, func(cr chi.Router) {
-		cr.Post("/", func(w http.ResponseWriter, r *http.Request) {
-			s.create(spec, w, r)
-		})
-		cr.Put("/", func(w http.ResponseWriter, r *http.Request) {
-			s.update(spec, w, r)
-		})
-		cr.Get("/{name}", func(w http.ResponseWriter, r *http.Request) {
-			s.get(spec, w, r)
-		})
-		cr.Get("/", func(w http.ResponseWriter, r *http.Request) {
-			s.list(spec, w, r)
-		})
+		cr.Post("/", s.handle(spec, OperationCreate, s.create))
+		cr.Put("/", s.handle(spec, OperationUpdate, s.update))
+		cr.Get("/{name}", s.handle(spec, OperationGet, s.get))
+		cr.Get("/", s.handle(spec, OperationList, s.list))
 
 		if spec.DeleteByValue {
-			cr.Delete("/", func(w http.ResponseWriter, r *http.Request) {
-				s.deleteObject(spec, w, r)
-			})
+			cr.Delete("/", s.handle(spec, OperationDelete, s.deleteObject))
 		} else {
-			cr.Delete("/{name}", func(w http.ResponseWriter, r *http.Request) {
-				s.delete(spec, w, r)
-			})
+			cr.Delete("/{name}", s.handle(spec, OperationDelete, s.delete))
 		}
 	})
 }
 
+// AddOperationHandler adds the server a handler for an operation which is not on objects (e.g., a dump),
+// served for the given method and path. The operation is authorized and audited as object operations are.
+func (s *Server) AddOperationHandler(method, path string, op Operation, handler http.HandlerFunc) {
+	wrapped := func(_ *ServerObjectSpec, w http.ResponseWriter, r *http.Request) {
+		handler(w, r)
+	}
+
+	s.Router().Method(method, path, s.handle(&ServerObjectSpec{BasePath: path}, op, wrapped))
+}
+
 // NewServer returns a new empty REST-JSON server.
 func NewServer(name string, tlsConfig *tls.Config) *Server {
 	return &Server{
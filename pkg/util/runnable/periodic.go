@@ -0,0 +1,89 @@
+// Copyright 2023 The ClusterLink Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runnable
+
+import (
+	"sync"
+	"time"
+)
+
+// Periodic is a runnable calling a function at a fixed interval, until stopped.
+type Periodic struct {
+	name     string
+	interval time.Duration
+	tick     func()
+
+	// lock orders adding to wg in Start with waiting on it in Stop
+	lock     sync.Mutex
+	stopOnce sync.Once
+	stopCh   chan struct{}
+	wg       sync.WaitGroup
+}
+
+// Name of the runnable.
+func (p *Periodic) Name() string {
+	return p.name
+}
+
+// Start calling the function periodically. Returns immediately if already stopped.
+func (p *Periodic) Start() error {
+	p.lock.Lock()
+	select {
+	case <-p.stopCh:
+		p.lock.Unlock()
+		return nil
+	default:
+	}
+	p.wg.Add(1)
+	p.lock.Unlock()
+
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stopCh:
+			return nil
+		case <-ticker.C:
+			p.tick()
+		}
+	}
+}
+
+// Stop the runnable, waiting for a running function call to return. Safe to call more than once.
+func (p *Periodic) Stop() error {
+	p.stopOnce.Do(func() { close(p.stopCh) })
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.wg.Wait()
+	return nil
+}
+
+// GracefulStop does a graceful stop of the runnable.
+func (p *Periodic) GracefulStop() error {
+	return p.Stop()
+}
+
+// NewPeriodic returns a new runnable with the given name, calling tick at the given interval once started.
+func NewPeriodic(name string, interval time.Duration, tick func()) *Periodic {
+	return &Periodic{
+		name:     name,
+		interval: interval,
+		tick:     tick,
+		stopCh:   make(chan struct{}),
+	}
+}
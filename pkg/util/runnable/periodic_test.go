@@ -0,0 +1,52 @@
+// Copyright 2023 The ClusterLink Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runnable_test
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/clusterlink-net/clusterlink/pkg/util/runnable"
+)
+
+func TestPeriodic(t *testing.T) {
+	var ticks atomic.Int32
+	p := runnable.NewPeriodic("test", time.Millisecond, func() { ticks.Add(1) })
+	require.Equal(t, "test", p.Name())
+
+	done := make(chan error)
+	go func() { done <- p.Start() }()
+
+	require.Eventually(t, func() bool { return ticks.Load() >= 2 }, time.Second, time.Millisecond)
+
+	require.NoError(t, p.Stop())
+	require.NoError(t, <-done)
+
+	// stopping again must not panic
+	require.NoError(t, p.GracefulStop())
+
+	// no ticks after stop
+	stopped := ticks.Load()
+	time.Sleep(10 * time.Millisecond)
+	require.Equal(t, stopped, ticks.Load())
+}
+
+func TestPeriodicStopBeforeStart(t *testing.T) {
+	p := runnable.NewPeriodic("test", time.Millisecond, func() { t.Error("unexpected tick") })
+	require.NoError(t, p.Stop())
+	require.NoError(t, p.Start())
+}
@@ -0,0 +1,258 @@
+// Copyright 2023 The ClusterLink Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spiffe
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spiffe/go-spiffe/v2/bundle/x509bundle"
+	"github.com/spiffe/go-spiffe/v2/spiffeid"
+	"github.com/spiffe/go-spiffe/v2/spiffetls/tlsconfig"
+	"github.com/spiffe/go-spiffe/v2/svid/x509svid"
+	"github.com/spiffe/go-spiffe/v2/workloadapi"
+)
+
+const (
+	// SVIDFileName is the name of the file holding the X.509 SVID certificate chain.
+	SVIDFileName = "svid.pem"
+	// SVIDKeyFileName is the name of the file holding the X.509 SVID private key.
+	SVIDKeyFileName = "svid_key.pem"
+	// BundleFileName is the name of the file holding the X.509 trust bundle.
+	BundleFileName = "svid_bundle.pem"
+)
+
+// Source provides the local X.509 SVID, and the trust bundles for verifying workload SVIDs.
+type Source interface {
+	x509svid.Source
+	x509bundle.Source
+
+	// Close the source.
+	Close() error
+}
+
+// Config specifies where to obtain SVIDs from.
+type Config struct {
+	// WorkloadAPIAddress is the address of the SPIFFE Workload API (e.g. unix:///run/spire/agent.sock).
+	WorkloadAPIAddress string
+	// SVIDDirectory is a directory holding stand-in SVID files, used if WorkloadAPIAddress is not set.
+	SVIDDirectory string
+}
+
+// Enabled returns true if an SVID source is configured.
+func (c *Config) Enabled() bool {
+	return c.WorkloadAPIAddress != "" || c.SVIDDirectory != ""
+}
+
+// NewSource returns a new SVID source according to the configuration.
+func (c *Config) NewSource(ctx context.Context) (Source, error) {
+	if c.WorkloadAPIAddress != "" {
+		source, err := workloadapi.NewX509Source(
+			ctx, workloadapi.WithClientOptions(workloadapi.WithAddr(c.WorkloadAPIAddress)))
+		if err != nil {
+			return nil, fmt.Errorf("cannot fetch SVID from workload API: %w", err)
+		}
+
+		return source, nil
+	}
+
+	return NewFileSource(c.SVIDDirectory)
+}
+
+// FileSource is an SVID source backed by files, which are reloaded when modified.
+type FileSource struct {
+	directory string
+
+	lock    sync.Mutex
+	modTime time.Time
+	svid    *x509svid.SVID
+	bundle  *x509bundle.Bundle
+}
+
+// reload reloads the SVID files, if modified since last loaded.
+func (s *FileSource) reload() error {
+	certFile := filepath.Join(s.directory, SVIDFileName)
+	info, err := os.Stat(certFile)
+	if err != nil {
+		return err
+	}
+
+	if info.ModTime().Equal(s.modTime) {
+		return nil
+	}
+
+	svid, err := x509svid.Load(certFile, filepath.Join(s.directory, SVIDKeyFileName))
+	if err != nil {
+		return fmt.Errorf("cannot load SVID: %w", err)
+	}
+
+	bundle, err := x509bundle.Load(svid.ID.TrustDomain(), filepath.Join(s.directory, BundleFileName))
+	if err != nil {
+		return fmt.Errorf("cannot load trust bundle: %w", err)
+	}
+
+	s.svid = svid
+	s.bundle = bundle
+	s.modTime = info.ModTime()
+	return nil
+}
+
+// GetX509SVID returns the SVID.
+func (s *FileSource) GetX509SVID() (*x509svid.SVID, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if err := s.reload(); err != nil {
+		if s.svid == nil {
+			return nil, err
+		}
+
+		logrus.WithField("component", "spiffe").Warnf("Cannot reload SVID files: %v.", err)
+	}
+
+	return s.svid, nil
+}
+
+// GetX509BundleForTrustDomain returns the trust bundle for the given trust domain.
+func (s *FileSource) GetX509BundleForTrustDomain(trustDomain spiffeid.TrustDomain) (*x509bundle.Bundle, error) {
+	if _, err := s.GetX509SVID(); err != nil {
+		return nil, err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.bundle.GetX509BundleForTrustDomain(trustDomain)
+}
+
+// Close the source.
+func (s *FileSource) Close() error {
+	return nil
+}
+
+// NewFileSource returns a new SVID source reading SVID files from a directory.
+func NewFileSource(directory string) (*FileSource, error) {
+	source := &FileSource{directory: directory}
+	if _, err := source.GetX509SVID(); err != nil {
+		return nil, err
+	}
+
+	return source, nil
+}
+
+// ServerConfig returns a TLS configuration for a server requiring clients to present an SVID.
+// Any SVID verified by the trust bundle of its trust domain is accepted.
+func ServerConfig(source Source) *tls.Config {
+	return tlsconfig.MTLSServerConfig(source, source, tlsconfig.AuthorizeAny())
+}
+
+// PeerID returns the SPIFFE ID of the SVID presented by the remote side of a TLS connection.
+func PeerID(state *tls.ConnectionState) (spiffeid.ID, error) {
+	if len(state.PeerCertificates) == 0 {
+		return spiffeid.ID{}, fmt.Errorf("no peer certificate")
+	}
+
+	return x509svid.IDFromCert(state.PeerCertificates[0])
+}
+
+// writeFileAtomic writes a file by renaming a temporary file, so that file watchers never observe a partial file.
+func writeFileAtomic(path string, data []byte) error {
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, path)
+}
+
+// WriteFiles writes the current SVID and trust bundle of a source to files under a directory.
+func WriteFiles(source Source, directory string) error {
+	svid, err := source.GetX509SVID()
+	if err != nil {
+		return err
+	}
+
+	bundle, err := source.GetX509BundleForTrustDomain(svid.ID.TrustDomain())
+	if err != nil {
+		return err
+	}
+
+	certPEM, keyPEM, err := svid.Marshal()
+	if err != nil {
+		return err
+	}
+
+	bundlePEM, err := bundle.Marshal()
+	if err != nil {
+		return err
+	}
+
+	// bundle and key are written first, so that a watcher triggered on the SVID file observes a consistent set
+	if err := writeFileAtomic(filepath.Join(directory, BundleFileName), bundlePEM); err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(filepath.Join(directory, SVIDKeyFileName), keyPEM); err != nil {
+		return err
+	}
+
+	return writeFileAtomic(filepath.Join(directory, SVIDFileName), certPEM)
+}
+
+// SyncFiles writes the SVID and trust bundle fetched from the Workload API to files under a directory,
+// and keeps them updated until the context is done.
+// Returns once the files are first written.
+func SyncFiles(ctx context.Context, workloadAPIAddress, directory string) error {
+	source, err := workloadapi.NewX509Source(
+		ctx, workloadapi.WithClientOptions(workloadapi.WithAddr(workloadAPIAddress)))
+	if err != nil {
+		return fmt.Errorf("cannot fetch SVID from workload API: %w", err)
+	}
+
+	logger := logrus.WithField("component", "spiffe")
+	closeSource := func() {
+		if err := source.Close(); err != nil {
+			logger.Warnf("Cannot close SVID source: %v.", err)
+		}
+	}
+
+	if err := WriteFiles(source, directory); err != nil {
+		closeSource()
+		return err
+	}
+
+	go func() {
+		defer closeSource()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-source.Updated():
+				logger.Info("SVID updated.")
+				if err := WriteFiles(source, directory); err != nil {
+					logger.Errorf("Cannot write SVID files: %v.", err)
+				}
+			}
+		}
+	}()
+
+	return nil
+}
@@ -0,0 +1,205 @@
+// Copyright 2023 The ClusterLink Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spiffe_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"math/big"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spiffe/go-spiffe/v2/spiffeid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/clusterlink-net/clusterlink/pkg/util/spiffe"
+)
+
+// authority issues SVIDs for testing.
+type authority struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newAuthority(t *testing.T, trustDomain string) *authority {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		URIs:                  []*url.URL{{Scheme: "spiffe", Host: trustDomain}},
+	}
+
+	raw, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.Nil(t, err)
+	cert, err := x509.ParseCertificate(raw)
+	require.Nil(t, err)
+
+	return &authority{cert: cert, key: key}
+}
+
+// issue returns a certificate for the given URI SANs, and its PEM-encoded certificate and key.
+func (a *authority) issue(t *testing.T, uris ...string) (*x509.Certificate, []byte, []byte) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	for _, uri := range uris {
+		parsed, err := url.Parse(uri)
+		require.Nil(t, err)
+		template.URIs = append(template.URIs, parsed)
+	}
+
+	raw, err := x509.CreateCertificate(rand.Reader, template, a.cert, &key.PublicKey, a.key)
+	require.Nil(t, err)
+	cert, err := x509.ParseCertificate(raw)
+	require.Nil(t, err)
+
+	rawKey, err := x509.MarshalPKCS8PrivateKey(key)
+	require.Nil(t, err)
+
+	return cert,
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: raw}),
+		pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: rawKey})
+}
+
+// writeSVID writes SVID files issued by the authority to a directory, with the given modification time.
+func (a *authority) writeSVID(t *testing.T, directory, id string, modTime time.Time) {
+	t.Helper()
+
+	_, certPEM, keyPEM := a.issue(t, id)
+	bundlePEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: a.cert.Raw})
+
+	certFile := filepath.Join(directory, spiffe.SVIDFileName)
+	require.Nil(t, os.WriteFile(filepath.Join(directory, spiffe.BundleFileName), bundlePEM, 0o600))
+	require.Nil(t, os.WriteFile(filepath.Join(directory, spiffe.SVIDKeyFileName), keyPEM, 0o600))
+	require.Nil(t, os.WriteFile(certFile, certPEM, 0o600))
+	require.Nil(t, os.Chtimes(certFile, modTime, modTime))
+}
+
+func TestFileSource(t *testing.T) {
+	dir := t.TempDir()
+
+	// missing files
+	_, err := spiffe.NewFileSource(dir)
+	require.NotNil(t, err)
+
+	ca := newAuthority(t, "example.org")
+	now := time.Now()
+	ca.writeSVID(t, dir, "spiffe://example.org/ns/default/sa/client", now)
+
+	source, err := spiffe.NewFileSource(dir)
+	require.Nil(t, err)
+
+	svid, err := source.GetX509SVID()
+	require.Nil(t, err)
+	require.Equal(t, "spiffe://example.org/ns/default/sa/client", svid.ID.String())
+
+	bundle, err := source.GetX509BundleForTrustDomain(spiffeid.RequireTrustDomainFromString("example.org"))
+	require.Nil(t, err)
+	require.True(t, bundle.HasX509Authority(ca.cert))
+
+	_, err = source.GetX509BundleForTrustDomain(spiffeid.RequireTrustDomainFromString("other.org"))
+	require.NotNil(t, err)
+
+	// rotated SVID and authority
+	rotatedCA := newAuthority(t, "example.org")
+	rotatedCA.writeSVID(t, dir, "spiffe://example.org/ns/default/sa/rotated", now.Add(time.Minute))
+
+	svid, err = source.GetX509SVID()
+	require.Nil(t, err)
+	require.Equal(t, "spiffe://example.org/ns/default/sa/rotated", svid.ID.String())
+
+	bundle, err = source.GetX509BundleForTrustDomain(svid.ID.TrustDomain())
+	require.Nil(t, err)
+	require.True(t, bundle.HasX509Authority(rotatedCA.cert))
+	require.False(t, bundle.HasX509Authority(ca.cert))
+
+	// malformed SVID file keeps the last loaded SVID
+	certFile := filepath.Join(dir, spiffe.SVIDFileName)
+	require.Nil(t, os.WriteFile(certFile, []byte("malformed"), 0o600))
+	require.Nil(t, os.Chtimes(certFile, now.Add(2*time.Minute), now.Add(2*time.Minute)))
+
+	svid, err = source.GetX509SVID()
+	require.Nil(t, err)
+	require.Equal(t, "spiffe://example.org/ns/default/sa/rotated", svid.ID.String())
+
+	// a malformed SVID file cannot initialize a source
+	_, err = spiffe.NewFileSource(dir)
+	require.NotNil(t, err)
+}
+
+func TestPeerID(t *testing.T) {
+	ca := newAuthority(t, "example.org")
+
+	// no peer certificate
+	_, err := spiffe.PeerID(&tls.ConnectionState{})
+	require.NotNil(t, err)
+
+	tests := []struct {
+		name string
+		uris []string
+		id   string
+	}{{
+		name: "workload",
+		uris: []string{"spiffe://example.org/ns/default/sa/client"},
+		id:   "spiffe://example.org/ns/default/sa/client",
+	}, {
+		name: "no URI SAN",
+	}, {
+		name: "non-SPIFFE URI SAN",
+		uris: []string{"clusterlink://role/dataplane"},
+	}, {
+		name: "multiple URI SANs",
+		uris: []string{"spiffe://example.org/a", "spiffe://example.org/b"},
+	}, {
+		name: "invalid trust domain",
+		uris: []string{"spiffe://Example.org/workload"},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cert, _, _ := ca.issue(t, test.uris...)
+			id, err := spiffe.PeerID(&tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}})
+			if test.id == "" {
+				require.NotNil(t, err)
+				return
+			}
+
+			require.Nil(t, err)
+			require.Equal(t, test.id, id.String())
+		})
+	}
+}
@@ -14,6 +14,7 @@
 package tls
 
 import (
+	"bytes"
 	"crypto/tls"
 	"crypto/x509"
 	"fmt"
@@ -52,10 +53,19 @@ func ParseFiles(ca, cert, key string) (*ParsedCertData, error) {
 		return nil, fmt.Errorf("unable to parse x509 certificate: %w", err)
 	}
 
+	var issuer *x509.Certificate
+	if len(certificate.Certificate) > 1 {
+		issuer, err = x509.ParseCertificate(certificate.Certificate[1])
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse x509 issuer certificate: %w", err)
+		}
+	}
+
 	return &ParsedCertData{
 		certificate: certificate,
 		ca:          caCertPool,
 		x509cert:    x509cert,
+		issuer:      issuer,
 	}, nil
 }
 
@@ -64,6 +74,7 @@ type ParsedCertData struct {
 	certificate tls.Certificate
 	ca          *x509.CertPool
 	x509cert    *x509.Certificate
+	issuer      *x509.Certificate
 }
 
 // ServerConfig return a TLS configuration for a server.
@@ -90,3 +101,18 @@ func (c *ParsedCertData) ClientConfig(sni string) *tls.Config {
 func (c *ParsedCertData) DNSNames() []string {
 	return c.x509cert.DNSNames
 }
+
+// IssuedBySameCA returns true if the given verified certificate chain
+// was issued by the same certificate authority which issued this certificate.
+func (c *ParsedCertData) IssuedBySameCA(chain []*x509.Certificate) bool {
+	if len(chain) < 2 {
+		return false
+	}
+
+	if c.issuer != nil {
+		return chain[1].Equal(c.issuer)
+	}
+
+	// issuer certificate is not part of the certificate file, compare key identifiers instead
+	return len(c.x509cert.AuthorityKeyId) > 0 && bytes.Equal(chain[1].SubjectKeyId, c.x509cert.AuthorityKeyId)
+}